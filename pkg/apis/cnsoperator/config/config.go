@@ -41,3 +41,8 @@ const EmbedStoragePolicyQuotaCRFileName = "cns.vmware.com_storagepolicyquotas.ya
 var EmbedStoragePolicyUsageCRFile embed.FS
 
 const EmbedStoragePolicyUsageCRFileName = "cns.vmware.com_storagepolicyusages.yaml"
+
+//go:embed cns.vmware.com_snapshotpolicies.yaml
+var EmbedSnapshotPolicyCRFile embed.FS
+
+const EmbedSnapshotPolicyCRFileName = "cns.vmware.com_snapshotpolicies.yaml"
@@ -31,6 +31,7 @@ import (
 	cnsregistervolumev1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsregistervolume/v1alpha1"
 	cnsunregistervolumev1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsunregistervolume/v1alpha1"
 	cnsvolumemetadatav1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsvolumemetadata/v1alpha1"
+	snapshotpolicyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/snapshotpolicy/v1alpha1"
 	storagepolicyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagepolicy/v1alpha1"
 	storagepolicyv1alpha2 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagepolicy/v1alpha2"
 	storagequotaperiodicsyncv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagequotaperiodicsync/v1alpha1"
@@ -79,6 +80,10 @@ var (
 	CnsVirtualMachineSnapshotSingular = "vitualmachinesnapshot"
 	// CnsVirtualMachineSnapshotPlural is plural of VirtualMachineSnapshot
 	CnsVirtualMachineSnapshotPlural = "vitualmachinesnapshots"
+	// SnapshotPolicySingular is Singular of SnapshotPolicy
+	SnapshotPolicySingular = "snapshotpolicy"
+	// SnapshotPolicyPlural is plural of SnapshotPolicy
+	SnapshotPolicyPlural = "snapshotpolicies"
 )
 
 var (
@@ -155,6 +160,12 @@ func addKnownTypes(scheme *runtime.Scheme) error {
 		&storagepolicyv1alpha1.StoragePolicyUsageList{},
 	)
 
+	scheme.AddKnownTypes(
+		SchemeGroupVersion,
+		&snapshotpolicyv1alpha1.SnapshotPolicy{},
+		&snapshotpolicyv1alpha1.SnapshotPolicyList{},
+	)
+
 	scheme.AddKnownTypes(
 		SchemeGroupVersionV2,
 		&storagepolicyv1alpha2.StoragePolicyUsage{},
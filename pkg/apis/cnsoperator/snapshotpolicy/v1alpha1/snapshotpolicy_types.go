@@ -0,0 +1,89 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SnapshotPolicySpec defines the desired state of SnapshotPolicy
+type SnapshotPolicySpec struct {
+	// Label selector for the PersistentVolumeClaims in the namespace of the
+	// policy which are snapshotted by this policy
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// Schedule in standard cron format, e.g. "0 */6 * * *"
+	Schedule string `json:"schedule"`
+
+	// Name of the VolumeSnapshotClass used for the VolumeSnapshots created by this policy
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName"`
+
+	// Retention rules used to prune the VolumeSnapshots created by this policy
+	// +optional
+	Retention SnapshotRetention `json:"retention,omitempty"`
+}
+
+// SnapshotRetention defines when VolumeSnapshots created by a SnapshotPolicy are pruned.
+// The number of snapshots retained per volume never exceeds the maximum number of
+// snapshots allowed for the volume, whichever of the two is lower.
+type SnapshotRetention struct {
+	// Maximum number of VolumeSnapshots created by the policy retained per
+	// PersistentVolumeClaim. All VolumeSnapshots of a PersistentVolumeClaim
+	// count against the snapshot limit of its volume.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxCount int `json:"maxCount,omitempty"`
+
+	// Maximum age of the VolumeSnapshots retained, e.g. "168h"
+	// +optional
+	MaxAge *metav1.Duration `json:"maxAge,omitempty"`
+}
+
+// SnapshotPolicyStatus defines the observed state of SnapshotPolicy
+type SnapshotPolicyStatus struct {
+	// Time of the last scheduled run in which VolumeSnapshots were created for
+	// all the selected PersistentVolumeClaims
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// Error details of the last reconcile of this policy
+	// +optional
+	Error string `json:"error,omitempty"`
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SnapshotPolicy is the Schema for the snapshotpolicies API
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+type SnapshotPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SnapshotPolicySpec   `json:"spec,omitempty"`
+	Status SnapshotPolicyStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SnapshotPolicyList contains a list of SnapshotPolicy
+type SnapshotPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SnapshotPolicy `json:"items"`
+}
@@ -0,0 +1,98 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DatastoreDrainSpec defines the desired state of DatastoreDrain
+type DatastoreDrainSpec struct {
+	// Name of the StoragePool backed by the datastore to be drained
+	StoragePoolName string `json:"storagePoolName"`
+
+	// Maximum number of volumes relocated in parallel. Defaults to 1 when not set.
+	// +optional
+	// +kubebuilder:validation:Minimum=1
+	MaxConcurrentMigrations int `json:"maxConcurrentMigrations,omitempty"`
+}
+
+// DatastoreDrainStatus defines the observed state of DatastoreDrain
+type DatastoreDrainStatus struct {
+	// Phase of the drain, one of "Pending", "InProgress", "Completed" or "Failed"
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Message details of the last error encountered while draining the datastore
+	// +optional
+	Message string `json:"message,omitempty"`
+	// Volumes records the migration progress of every volume found on the datastore
+	// +optional
+	Volumes []VolumeMigrationStatus `json:"volumes,omitempty"`
+}
+
+// VolumeMigrationStatus describes the migration progress of a single volume
+type VolumeMigrationStatus struct {
+	// Name of the PersistentVolume being migrated
+	PVName string `json:"pvName"`
+	// CNS volume ID of the PersistentVolume
+	// +optional
+	VolumeID string `json:"volumeID,omitempty"`
+	// Name of the StoragePool the volume is relocated to
+	// +optional
+	TargetStoragePool string `json:"targetStoragePool,omitempty"`
+	// Phase of the migration, one of "Pending", "InProgress", "Completed" or "Failed"
+	// +optional
+	Phase string `json:"phase,omitempty"`
+	// Message details of the error encountered while migrating the volume
+	// +optional
+	Message string `json:"message,omitempty"`
+}
+
+// Phases used in DatastoreDrain.Status.Phase and VolumeMigrationStatus.Phase
+const (
+	DrainPhasePending    = "Pending"
+	DrainPhaseInProgress = "InProgress"
+	DrainPhaseCompleted  = "Completed"
+	DrainPhaseFailed     = "Failed"
+)
+
+// +genclient:nonNamespaced
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatastoreDrain is the Schema for the datastoredrains API
+// +k8s:openapi-gen=true
+// +kubebuilder:resource:scope=Cluster
+type DatastoreDrain struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   DatastoreDrainSpec   `json:"spec,omitempty"`
+	Status DatastoreDrainStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// DatastoreDrainList contains a list of DatastoreDrain
+type DatastoreDrainList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []DatastoreDrain `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&DatastoreDrain{}, &DatastoreDrainList{})
+}
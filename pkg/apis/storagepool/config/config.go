@@ -6,3 +6,8 @@ import "embed"
 var EmbedStoragePoolCRFile embed.FS
 
 const EmbedStoragePoolCRFileName = "cns.vmware.com_storagepools.yaml"
+
+//go:embed cns.vmware.com_datastoredrains.yaml
+var EmbedDatastoreDrainCRFile embed.FS
+
+const EmbedDatastoreDrainCRFileName = "cns.vmware.com_datastoredrains.yaml"
@@ -0,0 +1,435 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/vmware/govmomi/cns"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/soap"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// BulkOperationType is the type of CNS operation performed by a bulk operation.
+type BulkOperationType string
+
+const (
+	// BulkOperationUpdateVolumeMetadata updates the metadata of many volumes.
+	BulkOperationUpdateVolumeMetadata BulkOperationType = "UpdateVolumeMetadata"
+	// BulkOperationRelocateVolume relocates many volumes.
+	BulkOperationRelocateVolume BulkOperationType = "RelocateVolume"
+	// BulkOperationExtendVolume expands many volumes.
+	BulkOperationExtendVolume BulkOperationType = "ExtendVolume"
+
+	// defaultBulkOperationBatchSize is the default maximum number of specs
+	// combined into a single CNS call.
+	defaultBulkOperationBatchSize = 100
+	// defaultBulkOperationConcurrency is the default maximum number of CNS
+	// calls issued concurrently by bulk operations against a single vCenter.
+	defaultBulkOperationConcurrency = 4
+)
+
+// cnsOperation returns the kind of the CNS calls made by the bulk operation.
+func (t BulkOperationType) cnsOperation() cnsOperation {
+	if t == BulkOperationExtendVolume {
+		return cnsOperationExpandVolume
+	}
+	// UpdateVolumeMetadata and RelocateVolume update existing volumes.
+	return cnsOperationUpdateVolume
+}
+
+// BulkOperationRequest holds the specs of a bulk operation. Only the specs
+// matching the OperationType are used.
+type BulkOperationRequest struct {
+	// OperationType is the type of the CNS operation to perform.
+	OperationType BulkOperationType
+	// MetadataUpdateSpecs are the specs for BulkOperationUpdateVolumeMetadata.
+	MetadataUpdateSpecs []cnstypes.CnsVolumeMetadataUpdateSpec
+	// RelocateSpecs are the specs for BulkOperationRelocateVolume.
+	RelocateSpecs []cnstypes.BaseCnsVolumeRelocateSpec
+	// ExtendSpecs are the specs for BulkOperationExtendVolume.
+	ExtendSpecs []cnstypes.CnsVolumeExtendSpec
+	// BatchSize is the maximum number of specs combined into a single CNS
+	// call. defaultBulkOperationBatchSize is used if it is not set.
+	BatchSize int
+}
+
+// BulkOperationResult is the result of a bulk operation for a single volume.
+type BulkOperationResult struct {
+	// VolumeID is the ID of the volume.
+	VolumeID string
+	// FaultType is the type of fault that occurred for the volume, if any.
+	FaultType string
+	// Error that occurred for the volume, if any.
+	Error error
+}
+
+// bulkOperationBatch is a set of specs which are sent to CNS in a single call.
+type bulkOperationBatch struct {
+	volumeIDs []string
+	invoke    func(ctx context.Context) (*object.Task, error)
+	// singleSpecBatches, if set, are used to retry the batch one spec at a
+	// time when CNS rejects the call because it supports a single spec per
+	// call. It is only set for operations which are not applied at all when
+	// the call fails.
+	singleSpecBatches []bulkOperationBatch
+}
+
+var (
+	// bulkOperationConcurrency is the maximum number of CNS calls issued
+	// concurrently by bulk operations against a single vCenter.
+	bulkOperationConcurrency = defaultBulkOperationConcurrency
+	// bulkOperationSemaphores holds the concurrency budget of each vCenter.
+	bulkOperationSemaphores     = make(map[string]chan struct{})
+	bulkOperationSemaphoresLock sync.Mutex
+	// multiSpecExtendUnsupported holds the vCenters which rejected an
+	// ExtendVolume call with several specs, so that later bulk expansions
+	// against them send a single spec per call.
+	multiSpecExtendUnsupported     = make(map[string]bool)
+	multiSpecExtendUnsupportedLock sync.RWMutex
+)
+
+// SetBulkOperationConcurrency sets the maximum number of CNS calls issued
+// concurrently by bulk operations against a single vCenter.
+func SetBulkOperationConcurrency(concurrency int) {
+	if concurrency <= 0 {
+		concurrency = defaultBulkOperationConcurrency
+	}
+	bulkOperationSemaphoresLock.Lock()
+	defer bulkOperationSemaphoresLock.Unlock()
+	bulkOperationConcurrency = concurrency
+	bulkOperationSemaphores = make(map[string]chan struct{})
+}
+
+// getBulkOperationSemaphore returns the concurrency budget for the given vCenter.
+func getBulkOperationSemaphore(vCenterHost string) chan struct{} {
+	bulkOperationSemaphoresLock.Lock()
+	defer bulkOperationSemaphoresLock.Unlock()
+	semaphore, ok := bulkOperationSemaphores[vCenterHost]
+	if !ok {
+		semaphore = make(chan struct{}, bulkOperationConcurrency)
+		bulkOperationSemaphores[vCenterHost] = semaphore
+	}
+	return semaphore
+}
+
+// splitIntoBatches returns the [start, end) index ranges of batches of at
+// most batchSize items for a list of count items.
+func splitIntoBatches(count int, batchSize int) [][2]int {
+	if batchSize <= 0 {
+		batchSize = defaultBulkOperationBatchSize
+	}
+	var batches [][2]int
+	for start := 0; start < count; start += batchSize {
+		batches = append(batches, [2]int{start, min(start+batchSize, count)})
+	}
+	return batches
+}
+
+// ExecuteBulkOperation combines the specs of the given request into batched
+// CNS calls, waits on the CNS tasks through the ListView task monitor and
+// returns the result for each volume in the order of the request specs.
+// Each CNS call gets its own operation timeout. The specs of the request are
+// not modified. The returned error is set if any of the volumes failed.
+func (m *defaultManager) ExecuteBulkOperation(ctx context.Context,
+	request *BulkOperationRequest) ([]BulkOperationResult, error) {
+	log := logger.GetLogger(ctx)
+	err := validateManager(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	// Set up the VC connection.
+	err = m.virtualCenter.ConnectCns(ctx)
+	if err != nil {
+		log.Errorf("ConnectCns failed with err: %+v", err)
+		return nil, err
+	}
+	// Set up the ListView before the batches wait on their tasks concurrently.
+	// The ListView outlives the request, so it must not be cancelled with it.
+	if m.listViewIf == nil {
+		err = m.initListView(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+	}
+	batches, err := m.constructBulkOperationBatches(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	semaphore := getBulkOperationSemaphore(m.virtualCenter.Config.Host)
+	batchResults := make([][]BulkOperationResult, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func(i int, batch bulkOperationBatch) {
+			defer wg.Done()
+			select {
+			case semaphore <- struct{}{}:
+				defer func() { <-semaphore }()
+			case <-ctx.Done():
+				batchResults[i] = failBulkOperationBatch(batch.volumeIDs, csifault.CSIInternalFault, ctx.Err())
+				return
+			}
+			batchResults[i] = m.executeBulkOperationBatch(ctx, request.OperationType, batch)
+		}(i, batch)
+	}
+	wg.Wait()
+
+	var results []BulkOperationResult
+	var failedVolumes []string
+	for _, batchResult := range batchResults {
+		for _, result := range batchResult {
+			if result.Error != nil {
+				failedVolumes = append(failedVolumes, result.VolumeID)
+			}
+			results = append(results, result)
+		}
+	}
+	if len(failedVolumes) != 0 {
+		return results, logger.LogNewErrorf(log, "bulk %s failed for volumes: %s",
+			request.OperationType, strings.Join(failedVolumes, ","))
+	}
+	log.Infof("bulk %s succeeded for %d volumes", request.OperationType, len(results))
+	return results, nil
+}
+
+// constructBulkOperationBatches splits the specs of the request into batches
+// which are sent to CNS in a single call. The specs are copied, so the
+// request is left untouched.
+func (m *defaultManager) constructBulkOperationBatches(ctx context.Context,
+	request *BulkOperationRequest) ([]bulkOperationBatch, error) {
+	log := logger.GetLogger(ctx)
+	var batches []bulkOperationBatch
+	switch request.OperationType {
+	case BulkOperationUpdateVolumeMetadata:
+		// Update the VSphereUser in the specs to the session user, as done for
+		// single volume metadata updates.
+		s, err := m.virtualCenter.Client.SessionManager.UserSession(ctx)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to get usersession with err: %v", err)
+		}
+		if s == nil {
+			return nil, errors.New("nil session obtained from session manager")
+		}
+		specs := make([]cnstypes.CnsVolumeMetadataUpdateSpec, len(request.MetadataUpdateSpecs))
+		for i, spec := range request.MetadataUpdateSpecs {
+			containerCluster := spec.Metadata.ContainerCluster
+			containerCluster.VSphereUser = s.UserName
+			spec.Metadata.ContainerCluster = containerCluster
+			specs[i] = spec
+		}
+		for _, r := range splitIntoBatches(len(specs), request.BatchSize) {
+			batchSpecs := specs[r[0]:r[1]]
+			var volumeIDs []string
+			for _, spec := range batchSpecs {
+				volumeIDs = append(volumeIDs, spec.VolumeId.Id)
+			}
+			batches = append(batches, bulkOperationBatch{
+				volumeIDs: volumeIDs,
+				invoke: func(ctx context.Context) (*object.Task, error) {
+					return m.virtualCenter.CnsClient.UpdateVolumeMetadata(ctx, batchSpecs)
+				},
+			})
+		}
+	case BulkOperationRelocateVolume:
+		specs := append([]cnstypes.BaseCnsVolumeRelocateSpec(nil), request.RelocateSpecs...)
+		for _, r := range splitIntoBatches(len(specs), request.BatchSize) {
+			batchSpecs := specs[r[0]:r[1]]
+			var volumeIDs []string
+			for _, spec := range batchSpecs {
+				volumeIDs = append(volumeIDs, spec.GetCnsVolumeRelocateSpec().VolumeId.Id)
+			}
+			batches = append(batches, bulkOperationBatch{
+				volumeIDs: volumeIDs,
+				invoke: func(ctx context.Context) (*object.Task, error) {
+					return m.virtualCenter.CnsClient.RelocateVolume(ctx, batchSpecs...)
+				},
+			})
+		}
+	case BulkOperationExtendVolume:
+		specs := append([]cnstypes.CnsVolumeExtendSpec(nil), request.ExtendSpecs...)
+		batchSize := request.BatchSize
+		multiSpecExtendUnsupportedLock.RLock()
+		if multiSpecExtendUnsupported[m.virtualCenter.Config.Host] {
+			batchSize = 1
+		}
+		multiSpecExtendUnsupportedLock.RUnlock()
+		for _, r := range splitIntoBatches(len(specs), batchSize) {
+			batch := m.newExtendVolumeBatch(specs[r[0]:r[1]])
+			if len(batch.volumeIDs) > 1 {
+				// A failed ExtendVolume call expands none of the volumes, so
+				// the batch can be retried one volume at a time.
+				for _, spec := range specs[r[0]:r[1]] {
+					batch.singleSpecBatches = append(batch.singleSpecBatches,
+						m.newExtendVolumeBatch([]cnstypes.CnsVolumeExtendSpec{spec}))
+				}
+			}
+			batches = append(batches, batch)
+		}
+	default:
+		return nil, logger.LogNewErrorf(log, "unsupported bulk operation type %q", request.OperationType)
+	}
+	return batches, nil
+}
+
+// newExtendVolumeBatch returns a batch expanding the volumes of the given specs.
+func (m *defaultManager) newExtendVolumeBatch(specs []cnstypes.CnsVolumeExtendSpec) bulkOperationBatch {
+	var volumeIDs []string
+	for _, spec := range specs {
+		volumeIDs = append(volumeIDs, spec.VolumeId.Id)
+	}
+	return bulkOperationBatch{
+		volumeIDs: volumeIDs,
+		invoke: func(ctx context.Context) (*object.Task, error) {
+			return m.virtualCenter.CnsClient.ExtendVolume(ctx, specs)
+		},
+	}
+}
+
+// executeBulkOperationBatch sends a single batch to CNS and returns the result
+// for each volume in the batch. If CNS rejects the batch because it supports a
+// single spec per call and the batch can be retried one spec at a time, the
+// specs are sent one by one.
+func (m *defaultManager) executeBulkOperationBatch(ctx context.Context, operationType BulkOperationType,
+	batch bulkOperationBatch) []BulkOperationResult {
+	log := logger.GetLogger(ctx)
+	results, err := m.invokeBulkOperationBatch(ctx, operationType, batch)
+	if err == nil || len(batch.singleSpecBatches) == 0 {
+		return results
+	}
+	// Other failures, such as an interrupted wait on a task which may still be
+	// running, are returned so that the caller retries the whole operation.
+	if isTaskWaitInterrupted(err) || !isMultiSpecUnsupportedFault(err) {
+		return results
+	}
+	log.Infof("CNS %s does not support several specs per call, retrying volumes %v one at a time",
+		operationType, batch.volumeIDs)
+	if operationType == BulkOperationExtendVolume {
+		multiSpecExtendUnsupportedLock.Lock()
+		multiSpecExtendUnsupported[m.virtualCenter.Config.Host] = true
+		multiSpecExtendUnsupportedLock.Unlock()
+	}
+	results = nil
+	for _, singleSpecBatch := range batch.singleSpecBatches {
+		singleSpecResults, _ := m.invokeBulkOperationBatch(ctx, operationType, singleSpecBatch)
+		results = append(results, singleSpecResults...)
+	}
+	return results
+}
+
+// isMultiSpecUnsupportedFault returns true if CNS failed a call with several
+// specs because it supports a single spec per call. vCenter fails such
+// ExtendVolume calls with an InvalidArgument fault on the InputSpec property.
+func isMultiSpecUnsupportedFault(err error) bool {
+	var fault vim25types.AnyType
+	var taskErr *taskFaultError
+	if errors.As(err, &taskErr) {
+		fault = taskErr.fault.Fault
+	} else if soap.IsSoapFault(err) {
+		fault = soap.ToSoapFault(err).VimFault()
+	}
+	switch fault := fault.(type) {
+	case *vim25types.InvalidArgument:
+		return fault.InvalidProperty == "InputSpec"
+	case vim25types.InvalidArgument:
+		return fault.InvalidProperty == "InputSpec"
+	case *vim25types.NotSupported, vim25types.NotSupported:
+		return true
+	}
+	return false
+}
+
+// invokeBulkOperationBatch sends a single batch to CNS with its own operation
+// timeout and waits on the CNS task. It returns the result for each volume in
+// the batch and the error if the whole CNS call failed.
+func (m *defaultManager) invokeBulkOperationBatch(ctx context.Context, operationType BulkOperationType,
+	batch bulkOperationBatch) ([]BulkOperationResult, error) {
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, operationType.cnsOperation())
+	defer cancelFunc()
+	log := logger.GetLogger(ctx)
+	task, err := batch.invoke(ctx)
+	if err != nil {
+		log.Errorf("CNS %s failed from vCenter %q with err: %v", operationType, m.virtualCenter.Config.Host, err)
+		return failBulkOperationBatch(batch.volumeIDs, ExtractFaultTypeFromErr(ctx, err), err), err
+	}
+	taskInfo, err := m.waitOnTask(ctx, task.Reference())
+	if err != nil || taskInfo == nil {
+		log.Errorf("failed to get %s taskInfo from vCenter %q with err: %v",
+			operationType, m.virtualCenter.Config.Host, err)
+		if err != nil {
+			return failBulkOperationBatch(batch.volumeIDs, ExtractFaultTypeFromErr(ctx, err), err), err
+		}
+		err = fmt.Errorf("taskInfo is empty for %s task", operationType)
+		return failBulkOperationBatch(batch.volumeIDs, csifault.CSITaskInfoEmptyFault, err), err
+	}
+	log.Infof("Bulk %s: volumeIDs: %v, opId: %q", operationType, batch.volumeIDs, taskInfo.ActivationId)
+	taskResults, err := cns.GetTaskResultArray(ctx, taskInfo)
+	if err != nil {
+		log.Errorf("unable to find %s results from vCenter %q with taskID %s",
+			operationType, m.virtualCenter.Config.Host, taskInfo.Task.Value)
+		return failBulkOperationBatch(batch.volumeIDs, csifault.CSITaskResultEmptyFault, err), err
+	}
+	return compileBulkOperationResults(ctx, batch.volumeIDs, taskResults), nil
+}
+
+// compileBulkOperationResults returns the result for each of the given
+// volumes from the CNS task results.
+func compileBulkOperationResults(ctx context.Context, volumeIDs []string,
+	taskResults []cnstypes.BaseCnsVolumeOperationResult) []BulkOperationResult {
+	volumeResults := make(map[string]*cnstypes.CnsVolumeOperationResult)
+	for _, taskResult := range taskResults {
+		if taskResult == nil {
+			continue
+		}
+		volumeResult := taskResult.GetCnsVolumeOperationResult()
+		volumeResults[volumeResult.VolumeId.Id] = volumeResult
+	}
+	results := make([]BulkOperationResult, 0, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		result := BulkOperationResult{VolumeID: volumeID}
+		volumeResult, ok := volumeResults[volumeID]
+		if !ok {
+			result.FaultType = csifault.CSITaskResultEmptyFault
+			result.Error = fmt.Errorf("result not found for volume %q", volumeID)
+		} else if volumeResult.Fault != nil {
+			result.FaultType = ExtractFaultTypeFromVolumeResponseResult(ctx, volumeResult)
+			result.Error = fmt.Errorf("operation failed for volume %q. fault: %s",
+				volumeID, volumeResult.Fault.LocalizedMessage)
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// failBulkOperationBatch returns a failed result for each of the given volumes.
+func failBulkOperationBatch(volumeIDs []string, faultType string, err error) []BulkOperationResult {
+	results := make([]BulkOperationResult, 0, len(volumeIDs))
+	for _, volumeID := range volumeIDs {
+		results = append(results, BulkOperationResult{VolumeID: volumeID, FaultType: faultType, Error: err})
+	}
+	return results
+}
@@ -0,0 +1,250 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package volume
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	cnssim "github.com/vmware/govmomi/cns/simulator"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/simulator"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+)
+
+func TestSplitIntoBatches(t *testing.T) {
+	assert.Equal(t, [][2]int{{0, 2}, {2, 4}, {4, 5}}, splitIntoBatches(5, 2))
+	assert.Equal(t, [][2]int{{0, 5}}, splitIntoBatches(5, 0))
+	assert.Empty(t, splitIntoBatches(0, 2))
+}
+
+func TestCompileBulkOperationResults(t *testing.T) {
+	taskResults := []cnstypes.BaseCnsVolumeOperationResult{
+		&cnstypes.CnsVolumeOperationResult{
+			VolumeId: cnstypes.CnsVolumeId{Id: "vol-1"},
+		},
+		&cnstypes.CnsVolumeOperationResult{
+			VolumeId: cnstypes.CnsVolumeId{Id: "vol-2"},
+			Fault: &vim25types.LocalizedMethodFault{
+				Fault:            &vim25types.NotFound{},
+				LocalizedMessage: "volume not found",
+			},
+		},
+	}
+	results := compileBulkOperationResults(context.Background(), []string{"vol-1", "vol-2", "vol-3"},
+		taskResults)
+	assert.Len(t, results, 3)
+	assert.Equal(t, "vol-1", results[0].VolumeID)
+	assert.NoError(t, results[0].Error)
+	assert.Equal(t, "vol-2", results[1].VolumeID)
+	assert.Error(t, results[1].Error)
+	assert.Equal(t, "vol-3", results[2].VolumeID)
+	assert.Error(t, results[2].Error)
+	assert.Equal(t, csifault.CSITaskResultEmptyFault, results[2].FaultType)
+}
+
+func TestIsMultiSpecUnsupportedFault(t *testing.T) {
+	assert.True(t, isMultiSpecUnsupportedFault(&taskFaultError{fault: &vim25types.LocalizedMethodFault{
+		Fault: &vim25types.InvalidArgument{InvalidProperty: "InputSpec"},
+	}}))
+	assert.False(t, isMultiSpecUnsupportedFault(&taskFaultError{fault: &vim25types.LocalizedMethodFault{
+		Fault: &vim25types.InvalidArgument{InvalidProperty: "CapacityInMb"},
+	}}))
+	assert.False(t, isMultiSpecUnsupportedFault(fmt.Errorf("time out for task: %w", context.DeadlineExceeded)))
+	assert.False(t, isMultiSpecUnsupportedFault(errors.New("connection refused")))
+}
+
+func TestGetBulkOperationSemaphore(t *testing.T) {
+	SetBulkOperationConcurrency(2)
+	defer SetBulkOperationConcurrency(defaultBulkOperationConcurrency)
+	semaphore := getBulkOperationSemaphore("vc1")
+	assert.Equal(t, 2, cap(semaphore))
+	assert.Equal(t, semaphore, getBulkOperationSemaphore("vc1"))
+	assert.NotEqual(t, semaphore, getBulkOperationSemaphore("vc2"))
+}
+
+// newBulkOperationTestManager returns a volume manager connected to a vCenter
+// simulator with the CNS simulator registered, and the IDs of the given number
+// of block volumes created on it.
+func newBulkOperationTestManager(t *testing.T, ctx context.Context, volumeCount int) (*defaultManager, []string) {
+	model := simulator.VPX()
+	t.Cleanup(model.Remove)
+	if err := model.Create(); err != nil {
+		t.Fatal(err)
+	}
+	model.Service.TLS = new(tls.Config)
+	s := model.Service.NewServer()
+	// The server is not closed, as the ListView keeps a WaitForUpdates call
+	// pending on it.
+	model.Service.RegisterSDK(cnssim.New())
+
+	port, err := strconv.Atoi(s.URL.Port())
+	if err != nil {
+		t.Fatal(err)
+	}
+	username := s.URL.User.Username() + "@vsphere.local"
+	password, _ := s.URL.User.Password()
+	// The session user agent is read from the driver config on connect.
+	configPath := filepath.Join(t.TempDir(), "vsphere.conf")
+	conf := fmt.Sprintf("[Global]\ninsecure-flag = \"true\"\n"+
+		"[VirtualCenter \"%s\"]\nuser = \"%s\"\npassword = \"%s\"\ndatacenters = \"DC0\"\nport = \"%d\"",
+		s.URL.Hostname(), username, password, port)
+	if err = os.WriteFile(configPath, []byte(conf), 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VSPHERE_CSI_CONFIG", configPath)
+	vc := &cnsvsphere.VirtualCenter{
+		Config: &cnsvsphere.VirtualCenterConfig{
+			Host:     s.URL.Hostname(),
+			Port:     port,
+			Username: username,
+			Password: password,
+			Insecure: true,
+		},
+		ClientMutex: &sync.Mutex{},
+	}
+	if err = vc.ConnectCns(ctx); err != nil {
+		t.Fatal(err)
+	}
+	datastore, err := find.NewFinder(vc.Client.Client).DefaultDatastore(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var volumeIDs []string
+	for i := 0; i < volumeCount; i++ {
+		task, err := vc.CnsClient.CreateVolume(ctx, []cnstypes.CnsVolumeCreateSpec{{
+			Name:       fmt.Sprintf("bulk-volume-%d", i),
+			VolumeType: "BLOCK",
+			Datastores: []vim25types.ManagedObjectReference{datastore.Reference()},
+			BackingObjectDetails: &cnstypes.CnsBlockBackingDetails{
+				CnsBackingObjectDetails: cnstypes.CnsBackingObjectDetails{CapacityInMb: 1024},
+			},
+		}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		taskInfo, err := task.WaitForResult(ctx, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		taskResult := taskInfo.Result.(cnstypes.CnsVolumeOperationBatchResult).VolumeResults[0]
+		volumeIDs = append(volumeIDs, taskResult.GetCnsVolumeOperationResult().VolumeId.Id)
+	}
+	m := &defaultManager{virtualCenter: vc}
+	if err = m.initListView(ctx); err != nil {
+		t.Fatal(err)
+	}
+	listView := m.listViewIf.(*ListViewImpl)
+	err = wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 30*time.Second, true,
+		func(context.Context) (bool, error) {
+			return listView.IsListViewReady(), nil
+		})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, volumeIDs
+}
+
+func TestExecuteBulkOperationUpdateVolumeMetadata(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, volumeIDs := newBulkOperationTestManager(t, ctx, 3)
+
+	var specs []cnstypes.CnsVolumeMetadataUpdateSpec
+	for _, volumeID := range append(volumeIDs, "unknown-volume") {
+		specs = append(specs, cnstypes.CnsVolumeMetadataUpdateSpec{
+			VolumeId: cnstypes.CnsVolumeId{Id: volumeID},
+			Metadata: cnstypes.CnsVolumeMetadata{
+				ContainerCluster: cnstypes.CnsContainerCluster{ClusterId: "test-cluster"},
+			},
+		})
+	}
+	results, err := m.ExecuteBulkOperation(ctx, &BulkOperationRequest{
+		OperationType:       BulkOperationUpdateVolumeMetadata,
+		MetadataUpdateSpecs: specs,
+		BatchSize:           2,
+	})
+	assert.Error(t, err)
+	assert.Len(t, results, 4)
+	for i, volumeID := range volumeIDs {
+		assert.Equal(t, volumeID, results[i].VolumeID)
+		assert.NoError(t, results[i].Error)
+	}
+	assert.Equal(t, "unknown-volume", results[3].VolumeID)
+	assert.Error(t, results[3].Error)
+	// The specs of the caller are left untouched.
+	for _, spec := range specs {
+		assert.Empty(t, spec.Metadata.ContainerCluster.VSphereUser)
+	}
+}
+
+func TestExecuteBulkOperationExtendVolume(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m, volumeIDs := newBulkOperationTestManager(t, ctx, 3)
+	defer func() {
+		multiSpecExtendUnsupportedLock.Lock()
+		delete(multiSpecExtendUnsupported, m.virtualCenter.Config.Host)
+		multiSpecExtendUnsupportedLock.Unlock()
+	}()
+
+	var specs []cnstypes.CnsVolumeExtendSpec
+	for _, volumeID := range volumeIDs {
+		specs = append(specs, cnstypes.CnsVolumeExtendSpec{
+			VolumeId:     cnstypes.CnsVolumeId{Id: volumeID},
+			CapacityInMb: 2048,
+		})
+	}
+	// The simulator rejects ExtendVolume calls with several specs, as vCenter
+	// does, so the batches are retried one volume at a time.
+	results, err := m.ExecuteBulkOperation(ctx, &BulkOperationRequest{
+		OperationType: BulkOperationExtendVolume,
+		ExtendSpecs:   specs,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 3)
+	for i, volumeID := range volumeIDs {
+		assert.Equal(t, volumeID, results[i].VolumeID)
+		assert.NoError(t, results[i].Error)
+	}
+	multiSpecExtendUnsupportedLock.RLock()
+	assert.True(t, multiSpecExtendUnsupported[m.virtualCenter.Config.Host])
+	multiSpecExtendUnsupportedLock.RUnlock()
+
+	queryResult, err := m.virtualCenter.CnsClient.QueryVolume(ctx, &cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeIDs[0]}},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, queryResult.Volumes, 1)
+	assert.Equal(t, int64(2048),
+		queryResult.Volumes[0].BackingObjectDetails.GetCnsBackingObjectDetails().CapacityInMb)
+}
@@ -68,6 +68,16 @@ type TaskResult struct {
 	Err      error
 }
 
+// taskFaultError is the error of a task which failed with a fault. Its message
+// is the localized message of the fault.
+type taskFaultError struct {
+	fault *types.LocalizedMethodFault
+}
+
+func (e *taskFaultError) Error() string {
+	return e.fault.LocalizedMessage
+}
+
 var ErrListViewTaskAddition = errors.New("failure to add task to listview")
 var ErrSessionNotAuthenticated = errors.New("session is not authenticated")
 
@@ -443,7 +453,7 @@ func (l *ListViewImpl) processTaskUpdate(prop types.PropertyChange) {
 		return
 	} else if taskInfo.State == types.TaskInfoStateError {
 		result.TaskInfo = nil
-		result.Err = errors.New(taskInfo.Error.LocalizedMessage)
+		result.Err = &taskFaultError{fault: taskInfo.Error}
 	} else {
 		result.TaskInfo = &taskInfo
 		result.Err = nil
@@ -36,6 +36,7 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -155,6 +156,9 @@ type Manager interface {
 	// BatchAttachVolumes attaches multiple volumes to a virtual machine.
 	BatchAttachVolumes(ctx context.Context,
 		vm *cnsvsphere.VirtualMachine, batchAttachRequest []BatchAttachRequest) ([]BatchAttachResult, string, error)
+	// ExecuteBulkOperation combines the specs of the given request into batched
+	// CNS calls and returns the result for each volume.
+	ExecuteBulkOperation(ctx context.Context, request *BulkOperationRequest) ([]BulkOperationResult, error)
 	// UnregisterVolume unregisters a volume from CNS.
 	// If unregisterDisk is true, it will also unregister the disk from FCD.
 	UnregisterVolume(ctx context.Context, volumeID string, unregisterDisk bool) (string, error)
@@ -238,6 +242,29 @@ var (
 	snapshotTaskMapLock sync.Mutex
 	// Alias for CreateVolumeOperationRequestDetails function declaration.
 	createRequestDetails = cnsvolumeoperationrequest.CreateVolumeOperationRequestDetails
+	// operationTimeouts holds the timeouts configured for CNS operations.
+	operationTimeouts = make(map[cnsOperation]time.Duration)
+	// operationTimeoutsLock is used for mitigating race condition during
+	// read/write on operationTimeouts.
+	operationTimeoutsLock sync.RWMutex
+)
+
+// cnsOperation is the kind of CNS operation invoked by a Manager call. It selects
+// the timeout configured for the call.
+type cnsOperation int
+
+const (
+	cnsOperationCreateVolume cnsOperation = iota
+	cnsOperationDeleteVolume
+	cnsOperationAttachVolume
+	cnsOperationDetachVolume
+	cnsOperationExpandVolume
+	cnsOperationUpdateVolume
+	cnsOperationCreateSnapshot
+	cnsOperationDeleteSnapshot
+	cnsOperationQuery
+	// cnsOperationSyncVolume has no configurable timeout.
+	cnsOperationSyncVolume
 )
 
 // createSnapshotTaskDetails has the same structure as createVolumeTaskDetails
@@ -459,6 +486,11 @@ func (m *defaultManager) MonitorCreateVolumeTask(ctx context.Context,
 			return nil, ExtractFaultTypeFromErr(ctx, err), err
 		}
 
+		if isTaskWaitInterrupted(err) {
+			log.Errorf("stopped waiting on CreateVolume task %s for volume %s, keeping it pending. Error: %v",
+				task.Reference().Value, volNameFromInputSpec, err)
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
 		// WaitForResult can fail for many reasons, including:
 		// - CNS restarted and marked "InProgress" tasks as "Failed".
 		// - Any failures from CNS.
@@ -869,7 +901,7 @@ func waitForResultOrTimeout(csiOpContext context.Context, taskMoRef vim25types.M
 	var err error
 	select {
 	case <-csiOpContext.Done():
-		err = fmt.Errorf("time out for task %v before response from CNS", taskMoRef)
+		err = fmt.Errorf("time out for task %v before response from CNS: %w", taskMoRef, csiOpContext.Err())
 		taskInfo = nil
 	case result := <-ch:
 		err = result.Err
@@ -878,6 +910,14 @@ func waitForResultOrTimeout(csiOpContext context.Context, taskMoRef vim25types.M
 	return taskInfo, err
 }
 
+// isTaskWaitInterrupted returns true if the wait on a CNS task ended because the
+// context of the operation expired or was cancelled. The task may still be running
+// on CNS, so its details are kept InProgress for the retried operation to wait on it
+// instead of invoking another task.
+func isTaskWaitInterrupted(err error) bool {
+	return errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled)
+}
+
 func (m *defaultManager) initListView(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
 	var err error
@@ -987,7 +1027,7 @@ func (m *defaultManager) createVolume(ctx context.Context, spec *cnstypes.CnsVol
 // CreateVolume creates a new volume given its spec.
 func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec,
 	extraParams interface{}) (*CnsVolumeInfo, string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationCreateVolume)
 	defer cancelFunc()
 	internalCreateVolume := func() (*CnsVolumeInfo, string, error) {
 		log := logger.GetLogger(ctx)
@@ -1047,11 +1087,53 @@ func (m *defaultManager) CreateVolume(ctx context.Context, spec *cnstypes.CnsVol
 	return resp, faultType, err
 }
 
+// SetOperationTimeouts sets the timeouts of the CNS operations invoked by the
+// Manager from the given config. Operations without a configured timeout wait
+// until the deadline of their context.
+func SetOperationTimeouts(ctx context.Context, cfg config.VolumeOperationTimeoutConfig) {
+	log := logger.GetLogger(ctx)
+	timeouts := make(map[cnsOperation]time.Duration)
+	var effective []string
+	for _, t := range []struct {
+		op           cnsOperation
+		name         string
+		timeoutInSec int
+	}{
+		{cnsOperationCreateVolume, "CreateVolume", cfg.CreateVolumeInSec},
+		{cnsOperationDeleteVolume, "DeleteVolume", cfg.DeleteVolumeInSec},
+		{cnsOperationAttachVolume, "AttachVolume", cfg.AttachVolumeInSec},
+		{cnsOperationDetachVolume, "DetachVolume", cfg.DetachVolumeInSec},
+		{cnsOperationExpandVolume, "ExpandVolume", cfg.ExpandVolumeInSec},
+		{cnsOperationUpdateVolume, "UpdateVolume", cfg.UpdateVolumeInSec},
+		{cnsOperationCreateSnapshot, "CreateSnapshot", cfg.CreateSnapshotInSec},
+		{cnsOperationDeleteSnapshot, "DeleteSnapshot", cfg.DeleteSnapshotInSec},
+		{cnsOperationQuery, "Query", cfg.QueryInSec},
+	} {
+		if t.timeoutInSec > 0 {
+			timeouts[t.op] = time.Duration(t.timeoutInSec) * time.Second
+			effective = append(effective, fmt.Sprintf("%s=%v", t.name, timeouts[t.op]))
+		}
+	}
+	log.Debugf("Setting timeouts of CNS operations: %s", strings.Join(effective, ", "))
+
+	operationTimeoutsLock.Lock()
+	defer operationTimeoutsLock.Unlock()
+	operationTimeouts = timeouts
+}
+
 // ensureOperationContextHasATimeout checks if the passed context has a timeout associated with it.
-// If there is no timeout set, we set it to 300 seconds. This is the same as set by sidecars.
+// If a timeout is configured for the given operation, it is applied on top of the deadline
+// of the passed context, so the earlier of the two is honored. Otherwise, if there is no timeout
+// set, we set it to 300 seconds. This is the same as set by sidecars.
 // If a timeout is already set, we don't change it.
-func ensureOperationContextHasATimeout(ctx context.Context) (context.Context, context.CancelFunc) {
-	_, ok := ctx.Deadline()
+func ensureOperationContextHasATimeout(ctx context.Context, op cnsOperation) (context.Context, context.CancelFunc) {
+	operationTimeoutsLock.RLock()
+	timeout, ok := operationTimeouts[op]
+	operationTimeoutsLock.RUnlock()
+	if ok {
+		return context.WithTimeout(ctx, timeout)
+	}
+	_, ok = ctx.Deadline()
 	if !ok {
 		// no timeout is set, so we need to set it
 		return context.WithTimeout(ctx, VolumeOperationTimeoutInSeconds*time.Second)
@@ -1062,7 +1144,7 @@ func ensureOperationContextHasATimeout(ctx context.Context) (context.Context, co
 // AttachVolume attaches a volume to a virtual machine given the spec.
 func (m *defaultManager) AttachVolume(ctx context.Context,
 	vm *cnsvsphere.VirtualMachine, volumeID string, checkNVMeController bool) (string, string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationAttachVolume)
 	defer cancelFunc()
 	var internalAttachVolume func(bool) (string, string, error)
 	internalAttachVolume = func(hasRetriedAfterReregister bool) (string, string, error) {
@@ -1221,7 +1303,7 @@ func (m *defaultManager) AttachVolume(ctx context.Context,
 // DetachVolume detaches a volume from the virtual machine given the spec.
 func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string) (string,
 	error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationDetachVolume)
 	defer cancelFunc()
 	var internalDetachVolume func(bool) (string, error)
 	internalDetachVolume = func(hasRetriedAfterReregister bool) (string, error) {
@@ -1383,7 +1465,7 @@ func (m *defaultManager) DetachVolume(ctx context.Context, vm *cnsvsphere.Virtua
 
 // DeleteVolume deletes a volume given its spec.
 func (m *defaultManager) DeleteVolume(ctx context.Context, volumeID string, deleteDisk bool) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationDeleteVolume)
 	defer cancelFunc()
 	internalDeleteVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -1688,7 +1770,7 @@ func (m *defaultManager) deleteVolumeWithImprovedIdempotency(ctx context.Context
 
 // UpdateVolumeMetadata updates a volume given its spec.
 func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstypes.CnsVolumeMetadataUpdateSpec) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationUpdateVolume)
 	defer cancelFunc()
 	var internalUpdateVolumeMetadata func(bool) error
 	internalUpdateVolumeMetadata = func(hasRetriedAfterReregister bool) error {
@@ -1797,7 +1879,7 @@ func (m *defaultManager) UpdateVolumeMetadata(ctx context.Context, spec *cnstype
 
 // UpdateVolumeCrypto updates a volume given its spec.
 func (m *defaultManager) UpdateVolumeCrypto(ctx context.Context, spec *cnstypes.CnsVolumeCryptoUpdateSpec) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationUpdateVolume)
 	defer cancelFunc()
 	var internalUpdateVolumeCrypto func(bool) error
 	internalUpdateVolumeCrypto = func(hasRetriedAfterReregister bool) error {
@@ -1884,7 +1966,7 @@ func (m *defaultManager) UpdateVolumeCrypto(ctx context.Context, spec *cnstypes.
 // ExpandVolume expands a volume given its spec.
 func (m *defaultManager) ExpandVolume(ctx context.Context, volumeID string, size int64,
 	extraParams interface{}) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationExpandVolume)
 	defer cancelFunc()
 	internalExpandVolume := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -2155,6 +2237,11 @@ func (m *defaultManager) expandVolumeWithImprovedIdempotency(ctx context.Context
 				return "", nil
 			}
 		}
+		if isTaskWaitInterrupted(finalErr) {
+			log.Errorf("stopped waiting on ExtendVolume task %s for volume %s, keeping it pending. Error: %v",
+				task.Reference().Value, volumeID, finalErr)
+			return ExtractFaultTypeFromErr(ctx, finalErr), finalErr
+		}
 		// WaitForResult can fail for many reasons, including:
 		// - CNS restarted and marked "InProgress" tasks as "Failed".
 		// - Any other CNS failures.
@@ -2256,7 +2343,7 @@ func (m *defaultManager) expandVolumeWithImprovedIdempotency(ctx context.Context
 // QueryVolume returns volumes matching the given filter.
 func (m *defaultManager) QueryVolume(ctx context.Context,
 	queryFilter cnstypes.CnsQueryFilter) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationQuery)
 	defer cancelFunc()
 	internalQueryVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -2294,7 +2381,7 @@ func (m *defaultManager) QueryVolume(ctx context.Context,
 // QueryAllVolume returns all volumes matching the given filter and selection.
 func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
 	querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationQuery)
 	defer cancelFunc()
 	internalQueryAllVolume := func() (*cnstypes.CnsQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -2333,7 +2420,7 @@ func (m *defaultManager) QueryAllVolume(ctx context.Context, queryFilter cnstype
 // which CnsQueryVolumeInfoResult is extracted.
 func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 	volumeIDList []cnstypes.CnsVolumeId) (*cnstypes.CnsQueryVolumeInfoResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationQuery)
 	defer cancelFunc()
 	internalQueryVolumeInfo := func() (*cnstypes.CnsQueryVolumeInfoResult, error) {
 		log := logger.GetLogger(ctx)
@@ -2399,7 +2486,7 @@ func (m *defaultManager) QueryVolumeInfo(ctx context.Context,
 
 func (m *defaultManager) RelocateVolume(ctx context.Context,
 	relocateSpecList ...cnstypes.BaseCnsVolumeRelocateSpec) (*object.Task, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationUpdateVolume)
 	defer cancelFunc()
 	internalRelocateVolume := func() (*object.Task, error) {
 		log := logger.GetLogger(ctx)
@@ -2436,7 +2523,7 @@ func (m *defaultManager) RelocateVolume(ctx context.Context,
 
 // ConfigureVolumeACLs configures net permissions for a given CnsVolumeACLConfigureSpec.
 func (m *defaultManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationUpdateVolume)
 	defer cancelFunc()
 	internalConfigureVolumeACLs := func() error {
 		log := logger.GetLogger(ctx)
@@ -2571,7 +2658,7 @@ func (m *defaultManager) RetrieveVStorageObject(ctx context.Context,
 // parameters are not specified.
 func (m *defaultManager) QueryVolumeAsync(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
 	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationQuery)
 	defer cancelFunc()
 	log := logger.GetLogger(ctx)
 	err := validateManager(ctx, m)
@@ -2634,7 +2721,7 @@ func (m *defaultManager) QueryVolumeAsync(ctx context.Context, queryFilter cnsty
 
 func (m *defaultManager) QuerySnapshots(ctx context.Context, snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter) (
 	*cnstypes.CnsSnapshotQueryResult, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationQuery)
 	defer cancelFunc()
 	internalQuerySnapshots := func() (*cnstypes.CnsSnapshotQueryResult, error) {
 		log := logger.GetLogger(ctx)
@@ -3058,6 +3145,16 @@ func (m *defaultManager) createSnapshotWithTransaction(ctx context.Context, volu
 	if err != nil && !apierrors.IsNotFound(err) {
 		return nil, csifault.CSIInternalFault, err
 	}
+	// Validate if previous operation is pending.
+	if err == nil && volumeOperationDetails.OperationDetails != nil && IsTaskPending(volumeOperationDetails) {
+		log.Infof("Snapshot with name %s has CreateSnapshot task %s pending on CNS.",
+			instanceName, volumeOperationDetails.OperationDetails.TaskID)
+		taskMoRef := vim25types.ManagedObjectReference{
+			Type:  "Task",
+			Value: volumeOperationDetails.OperationDetails.TaskID,
+		}
+		createSnapshotsTask = object.NewTask(m.virtualCenter.Client.Client, taskMoRef)
+	}
 	defer func() {
 		// Persist the operation details before returning if the improved idempotency is enabled. Only success or error
 		// needs to be stored as InProgress details are stored when the task is created on CNS.
@@ -3078,34 +3175,41 @@ func (m *defaultManager) createSnapshotWithTransaction(ctx context.Context, volu
 			}
 		}
 	}()
-	volumeOperationDetails = createRequestDetails(instanceName, volumeID, "", 0, quotaInfo,
-		metav1.Now(),
-		"", "", "", taskInvocationStatusInProgress, "")
-	if err := m.operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
-		// Don't return if CreateSnapshot details can't be stored.
-		log.Warnf("failed to store CreateSnapshot details with error: %v", err)
-	}
-	createSnapshotsTask, err = invokeCNSCreateSnapshot(ctx, m.virtualCenter, volumeID, instanceName, snapshotID)
-	if err != nil {
+	if createSnapshotsTask == nil {
 		volumeOperationDetails = createRequestDetails(instanceName, volumeID, "", 0, quotaInfo,
-			volumeOperationDetails.OperationDetails.TaskInvocationTimestamp, "", "", "",
-			taskInvocationStatusError, err.Error())
-		faultType := ExtractFaultTypeFromErr(ctx, err)
-		return nil, faultType, logger.LogNewErrorf(log, "failed to create snapshot with error: %v", err)
-	}
-	// Persist the volume operation details.
-	volumeOperationDetails = createRequestDetails(instanceName, volumeID, "", 0, quotaInfo,
-		volumeOperationDetails.OperationDetails.TaskInvocationTimestamp,
-		createSnapshotsTask.Reference().Value, "", "", taskInvocationStatusInProgress, "")
-	if err := m.operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
-		// Don't return if CreateSnapshot details can't be stored.
-		log.Warnf("failed to store CreateSnapshot details with error: %v", err)
+			metav1.Now(),
+			"", "", "", taskInvocationStatusInProgress, "")
+		if err := m.operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
+			// Don't return if CreateSnapshot details can't be stored.
+			log.Warnf("failed to store CreateSnapshot details with error: %v", err)
+		}
+		createSnapshotsTask, err = invokeCNSCreateSnapshot(ctx, m.virtualCenter, volumeID, instanceName, snapshotID)
+		if err != nil {
+			volumeOperationDetails = createRequestDetails(instanceName, volumeID, "", 0, quotaInfo,
+				volumeOperationDetails.OperationDetails.TaskInvocationTimestamp, "", "", "",
+				taskInvocationStatusError, err.Error())
+			faultType := ExtractFaultTypeFromErr(ctx, err)
+			return nil, faultType, logger.LogNewErrorf(log, "failed to create snapshot with error: %v", err)
+		}
+		// Persist the volume operation details.
+		volumeOperationDetails = createRequestDetails(instanceName, volumeID, "", 0, quotaInfo,
+			volumeOperationDetails.OperationDetails.TaskInvocationTimestamp,
+			createSnapshotsTask.Reference().Value, "", "", taskInvocationStatusInProgress, "")
+		if err := m.operationStore.StoreRequestDetails(ctx, volumeOperationDetails); err != nil {
+			// Don't return if CreateSnapshot details can't be stored.
+			log.Warnf("failed to store CreateSnapshot details with error: %v", err)
+		}
 	}
 
 	var createSnapshotsTaskInfo *vim25types.TaskInfo
 	var faultType string
 	createSnapshotsTaskInfo, err = m.waitOnTask(ctx, createSnapshotsTask.Reference())
 	if err != nil {
+		if isTaskWaitInterrupted(err) {
+			log.Errorf("stopped waiting on CreateSnapshot task %s for snapshot %q on volume %q, keeping it pending. "+
+				"Error: %v", createSnapshotsTask.Reference().Value, instanceName, volumeID, err)
+			return nil, ExtractFaultTypeFromErr(ctx, err), err
+		}
 		if createSnapshotsTaskInfo != nil && IsNotSupportedFault(ctx, createSnapshotsTaskInfo.Error) {
 			faultType = "vim25:NotSupported"
 			err = fmt.Errorf("failed to create snapshot with fault: %q", faultType)
@@ -3182,7 +3286,7 @@ func (m *defaultManager) createSnapshotWithTransaction(ctx context.Context, volu
 // which is generated by the CSI snapshotter sidecar.
 func (m *defaultManager) CreateSnapshot(
 	ctx context.Context, volumeID string, snapshotName string, extraParams interface{}) (*CnsSnapshotInfo, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationCreateSnapshot)
 	defer cancelFunc()
 	internalCreateSnapshot := func() (*CnsSnapshotInfo, error) {
 		log := logger.GetLogger(ctx)
@@ -3437,6 +3541,11 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 				return cnsSnapshotInfo, nil
 			}
 		}
+		if isTaskWaitInterrupted(err) {
+			log.Errorf("stopped waiting on DeleteSnapshot task %s for snapshot %q on volume %q, keeping it pending. "+
+				"Error: %v", deleteSnapshotTask.Reference().Value, snapshotID, volumeID, err)
+			return nil, err
+		}
 
 		volumeOperationDetails = createRequestDetails(instanceName, "", "", 0, nil,
 			volumeOperationDetails.OperationDetails.TaskInvocationTimestamp, deleteSnapshotTask.Reference().Value, "",
@@ -3543,7 +3652,7 @@ func (m *defaultManager) deleteSnapshotWithImprovedIdempotencyCheck(
 
 func (m *defaultManager) DeleteSnapshot(ctx context.Context, volumeID string, snapshotID string,
 	extraParams interface{}) (*CnsSnapshotInfo, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationDeleteSnapshot)
 	defer cancelFunc()
 	internalDeleteSnapshot := func() (*CnsSnapshotInfo, error) {
 		log := logger.GetLogger(ctx)
@@ -3821,7 +3930,7 @@ func constructBatchAttachSpecList(ctx context.Context, vm *cnsvsphere.VirtualMac
 func (m *defaultManager) BatchAttachVolumes(ctx context.Context,
 	vm *cnsvsphere.VirtualMachine,
 	batchAttachRequest []BatchAttachRequest) ([]BatchAttachResult, string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationAttachVolume)
 	defer cancelFunc()
 	internalBatchAttachVolumes := func() ([]BatchAttachResult, string, error) {
 		log := logger.GetLogger(ctx)
@@ -3930,7 +4039,7 @@ func (m *defaultManager) BatchAttachVolumes(ctx context.Context,
 
 // SyncVolume creates a new volume given its spec.
 func (m *defaultManager) SyncVolume(ctx context.Context, syncVolumeSpecs []cnstypes.CnsSyncVolumeSpec) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationSyncVolume)
 	defer cancelFunc()
 	internalSyncVolumeInfo := func() (string, error) {
 		log := logger.GetLogger(ctx)
@@ -4007,7 +4116,7 @@ func (m *defaultManager) SetListViewNotReady(ctx context.Context) {
 // UnregisterVolume unregisters a volume from CNS.
 // If unregisterDisk is true, it will also unregister the disk from FCD.
 func (m *defaultManager) UnregisterVolume(ctx context.Context, volumeID string, unregisterDisk bool) (string, error) {
-	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx)
+	ctx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationDeleteVolume)
 	defer cancelFunc()
 	start := time.Now()
 	faultType, err := m.unregisterVolume(ctx, volumeID, unregisterDisk)
@@ -187,6 +187,12 @@ func (m MockManager) BatchAttachVolumes(ctx context.Context, vm *cnsvsphere.Virt
 	panic("implement me")
 }
 
+func (m MockManager) ExecuteBulkOperation(ctx context.Context,
+	request *BulkOperationRequest) ([]BulkOperationResult, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (m MockManager) UnregisterVolume(ctx context.Context, volumeID string, unregisterDisk bool) (string, error) {
 	if m.failRequest {
 		return "", m.err
@@ -2,14 +2,24 @@ package volume
 
 import (
 	"context"
+	"errors"
+	"runtime"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/agiledragon/gomonkey/v2"
 	"github.com/stretchr/testify/assert"
+	"github.com/vmware/govmomi"
 	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/object"
 	vim25types "github.com/vmware/govmomi/vim25/types"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
 )
 
 const createVolumeTaskTimeout = 3 * time.Second
@@ -26,6 +36,7 @@ func TestWaitForResultOrTimeoutFail(t *testing.T) {
 	}
 	taskInfo, err := waitForResultOrTimeout(ctx, taskMoRef, ch)
 	assert.Error(t, err)
+	assert.True(t, isTaskWaitInterrupted(err))
 	var expectedTaskInfo *vim25types.TaskInfo
 	assert.Equal(t, expectedTaskInfo, taskInfo)
 }
@@ -46,6 +57,35 @@ func TestWaitForResultOrTimeoutPass(t *testing.T) {
 	assert.Equal(t, expectedTaskInfo, taskInfo)
 }
 
+func TestEnsureOperationContextHasATimeout(t *testing.T) {
+	ctx := context.Background()
+	SetOperationTimeouts(ctx, config.VolumeOperationTimeoutConfig{ExpandVolumeInSec: 60})
+	defer SetOperationTimeouts(ctx, config.VolumeOperationTimeoutConfig{})
+
+	// The configured timeout is applied to an operation without deadline.
+	opCtx, cancelFunc := ensureOperationContextHasATimeout(ctx, cnsOperationExpandVolume)
+	defer cancelFunc()
+	deadline, ok := opCtx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(60*time.Second), deadline, 5*time.Second)
+
+	// The earlier deadline of the RPC is kept.
+	rpcCtx, rpcCancelFunc := context.WithTimeout(ctx, 10*time.Second)
+	defer rpcCancelFunc()
+	rpcDeadline, _ := rpcCtx.Deadline()
+	opCtx, cancelFunc = ensureOperationContextHasATimeout(rpcCtx, cnsOperationExpandVolume)
+	defer cancelFunc()
+	deadline, _ = opCtx.Deadline()
+	assert.Equal(t, rpcDeadline, deadline)
+
+	// Operations without a configured timeout fall back to the default timeout.
+	opCtx, cancelFunc = ensureOperationContextHasATimeout(ctx, cnsOperationCreateVolume)
+	defer cancelFunc()
+	deadline, ok = opCtx.Deadline()
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(VolumeOperationTimeoutInSeconds*time.Second), deadline, 5*time.Second)
+}
+
 func performSlowTask(ch chan TaskResult, delay time.Duration) {
 	time.Sleep(delay)
 	ch <- TaskResult{
@@ -530,3 +570,95 @@ func TestReRegistrationIdempotency(t *testing.T) {
 		})
 	}
 }
+
+// fakeOperationStore is an in-memory VolumeOperationRequest store.
+type fakeOperationStore struct {
+	details map[string]*cnsvolumeoperationrequest.VolumeOperationRequestDetails
+}
+
+func (f *fakeOperationStore) GetRequestDetails(ctx context.Context,
+	name string) (*cnsvolumeoperationrequest.VolumeOperationRequestDetails, error) {
+	details, ok := f.details[name]
+	if !ok {
+		return nil, apierrors.NewNotFound(schema.GroupResource{Resource: "cnsvolumeoperationrequests"}, name)
+	}
+	return details, nil
+}
+
+func (f *fakeOperationStore) StoreRequestDetails(ctx context.Context,
+	instance *cnsvolumeoperationrequest.VolumeOperationRequestDetails) error {
+	f.details[instance.Name] = instance
+	return nil
+}
+
+func (f *fakeOperationStore) DeleteRequestDetails(ctx context.Context, name string) error {
+	delete(f.details, name)
+	return nil
+}
+
+// fakeListView records the tasks waited on. It sends result for every task
+// if set, else the tasks never complete.
+type fakeListView struct {
+	ListViewIf
+	result *TaskResult
+	tasks  []string
+}
+
+func (f *fakeListView) AddTask(ctx context.Context, taskMoRef vim25types.ManagedObjectReference,
+	ch chan TaskResult) error {
+	f.tasks = append(f.tasks, taskMoRef.Value)
+	if f.result != nil {
+		ch <- *f.result
+	}
+	return nil
+}
+
+func (f *fakeListView) RemoveTask(ctx context.Context, taskMoRef vim25types.ManagedObjectReference) error {
+	return nil
+}
+
+func TestCreateSnapshotWithTransactionReattachesToPendingTask(t *testing.T) {
+	// Skip test on ARM64 due to gomonkey limitations
+	if runtime.GOARCH == "arm64" {
+		t.Skip("Skipping test on ARM64 due to gomonkey function patching limitations")
+	}
+	ctx := context.Background()
+	var invocations int
+	patches := gomonkey.ApplyFunc(invokeCNSCreateSnapshot, func(_ context.Context, _ *cnsvsphere.VirtualCenter,
+		_ string, _ string, _ string) (*object.Task, error) {
+		invocations++
+		return object.NewTask(nil, vim25types.ManagedObjectReference{Type: "Task", Value: "task-1"}), nil
+	})
+	defer patches.Reset()
+
+	store := &fakeOperationStore{details: make(map[string]*cnsvolumeoperationrequest.VolumeOperationRequestDetails)}
+	listView := &fakeListView{}
+	m := &defaultManager{
+		virtualCenter: &cnsvsphere.VirtualCenter{
+			Client: &govmomi.Client{},
+			Config: &cnsvsphere.VirtualCenterConfig{Host: "vc1"},
+		},
+		operationStore: store,
+		listViewIf:     listView,
+	}
+	instanceName := "snapshot-1234-vol-1"
+
+	// The wait on the task is interrupted by the deadline of the operation.
+	opCtx, cancelFunc := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelFunc()
+	_, _, err := m.createSnapshotWithTransaction(opCtx, "vol-1", "snapshot-1234", nil)
+	assert.True(t, isTaskWaitInterrupted(err))
+	details := store.details[instanceName]
+	if assert.NotNil(t, details) {
+		assert.Equal(t, taskInvocationStatusInProgress, details.OperationDetails.TaskStatus)
+		assert.Equal(t, "task-1", details.OperationDetails.TaskID)
+	}
+
+	// The retry waits on the pending task instead of invoking a new one.
+	listView.result = &TaskResult{Err: errors.New("task failed")}
+	_, _, err = m.createSnapshotWithTransaction(ctx, "vol-1", "snapshot-1234", nil)
+	assert.Error(t, err)
+	assert.Equal(t, 1, invocations)
+	assert.Equal(t, []string{"task-1", "task-1"}, listView.tasks)
+	assert.Equal(t, taskInvocationStatusError, store.details[instanceName].OperationDetails.TaskStatus)
+}
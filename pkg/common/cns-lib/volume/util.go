@@ -146,11 +146,21 @@ func IsDiskAttached(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID
 // getNvmeUUID returns the NVME formatted UUID.
 func getNvmeUUID(ctx context.Context, uuid string) (string, error) {
 	log := logger.GetLogger(ctx)
-	uuidBytes, err := uuidlib.Parse(uuid)
+	nvmeUUID, err := ConvertToNvmeUUID(uuid)
 	if err != nil {
 		log.Errorf("Error while parsing uuid with err=%v", err)
 		return "", err
 	}
+	return nvmeUUID, nil
+}
+
+// ConvertToNvmeUUID converts the backing UUID of a virtual disk to the UUID of
+// the namespace exposed for the disk by a virtual NVMe controller.
+func ConvertToNvmeUUID(uuid string) (string, error) {
+	uuidBytes, err := uuidlib.Parse(uuid)
+	if err != nil {
+		return "", err
+	}
 	var nvmeUUID uuidlib.UUID
 	nvmeUUID[0] = uuidBytes[8]
 	nvmeUUID[1] = uuidBytes[9]
@@ -58,6 +58,9 @@ type VirtualCenterManager interface {
 	// IsCnsSnapshotSupported checks if cns volume snapshot is supported
 	// or not on the vCenter Host.
 	IsCnsSnapshotSupported(ctx context.Context, host string) (bool, error)
+	// IsExpandVolumeWithSnapshotsSupported checks if volumes with cns snapshots
+	// can be expanded or not on the vCenter Host.
+	IsExpandVolumeWithSnapshotsSupported(ctx context.Context, host string) (bool, error)
 	// IsCnsTransactionSupported checks if cns transaction is supported
 	// or not on the vCenter Host.
 	IsCnsTransactionSupported(ctx context.Context, host string) (bool, error)
@@ -214,6 +217,31 @@ func (m *defaultVirtualCenterManager) IsCnsSnapshotSupported(ctx context.Context
 	return false, nil
 }
 
+// IsExpandVolumeWithSnapshotsSupported checks if volumes with cns snapshots
+// can be expanded or not.
+func (m *defaultVirtualCenterManager) IsExpandVolumeWithSnapshotsSupported(ctx context.Context,
+	host string) (bool, error) {
+	log := logger.GetLogger(ctx)
+
+	// Get VC instance.
+	vcenter, err := m.GetVirtualCenter(ctx, host)
+	if err != nil {
+		log.Errorf("Failed to get vCenter. Err: %v", err)
+		return false, err
+	}
+	vcVersion := vcenter.Client.ServiceContent.About.Version
+	isvSphere80U3orAbove, err := IsvSphereVersion80U3orAbove(ctx, vcenter.Client.ServiceContent.About)
+	if err != nil {
+		return false, logger.LogNewErrorf(log, "Error while checking the vSphere Version %q , Err= %+v",
+			vcVersion, err)
+	}
+	if isvSphere80U3orAbove {
+		return true, nil
+	}
+	log.Infof("Expansion of volumes with CNS snapshots is not supported on vCenter version %q", vcVersion)
+	return false, nil
+}
+
 // IsCnsTransactionSupported checks if cns transaction is supported or not.
 func (m *defaultVirtualCenterManager) IsCnsTransactionSupported(ctx context.Context, host string) (bool, error) {
 	log := logger.GetLogger(ctx)
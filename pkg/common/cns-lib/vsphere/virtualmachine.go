@@ -20,6 +20,8 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net"
+	"slices"
 	"strings"
 
 	"github.com/vmware/govmomi/vapi/tags"
@@ -437,3 +439,169 @@ func findMissingCategories(topologyCategories map[string]string) []string {
 	}
 	return missing
 }
+
+const (
+	// maxSCSIControllersPerVM is the maximum number of SCSI controllers supported on a VM.
+	maxSCSIControllersPerVM = 4
+	// maxDevicesPerPVSCSIController is the number of usable unit numbers on a
+	// ParaVirtual SCSI controller. Unit number 7 is reserved for the controller itself.
+	maxDevicesPerPVSCSIController = 15
+	// maxDevicesPerNVMeController is the number of namespaces supported on a NVMe controller.
+	maxDevicesPerNVMeController = 15
+)
+
+// VolumeAttachCapacity holds the volume attach capacity of a virtual machine,
+// computed from its device list.
+type VolumeAttachCapacity struct {
+	// AttachedVolumes is the set of FCD IDs attached to the virtual machine.
+	AttachedVolumes map[string]struct{}
+	// FreePVSCSISlots is the number of disks which can still be attached to the
+	// virtual machine using ParaVirtual SCSI controllers, including the
+	// controllers which can be hot-added.
+	FreePVSCSISlots int
+	// FreeNVMeSlots is the number of disks which can still be attached to the
+	// existing NVMe controllers of the virtual machine. NVMe controllers are
+	// not hot-added on attach, so only the existing controllers are counted.
+	FreeNVMeSlots int
+	// FreeNVMeSlotsPerController holds the number of free namespaces of each
+	// existing NVMe controller, keyed by controller key.
+	FreeNVMeSlotsPerController map[int32]int
+	// NVMeControllerKeyWithFreeSlot is the key of an existing NVMe controller
+	// which has a free namespace. It is nil if no such controller exists.
+	NVMeControllerKeyWithFreeSlot *int32
+}
+
+// GetVolumeAttachCapacity returns the volume attach capacity of the virtual machine.
+func (vm *VirtualMachine) GetVolumeAttachCapacity(ctx context.Context) (*VolumeAttachCapacity, error) {
+	log := logger.GetLogger(ctx)
+	devices, err := vm.VirtualMachine.Device(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get devices for VM %v. Error: %+v", vm, err)
+	}
+	capacity := computeVolumeAttachCapacity(devices)
+	log.Debugf("Volume attach capacity for VM %v: attached volumes: %d, free PVSCSI slots: %d, "+
+		"free NVMe slots: %d", vm, len(capacity.AttachedVolumes), capacity.FreePVSCSISlots, capacity.FreeNVMeSlots)
+	return capacity, nil
+}
+
+// computeVolumeAttachCapacity computes the volume attach capacity from the
+// given device list. Slots on existing controllers occupied by any disk are
+// treated as used, and PVSCSI controllers which are not yet present on the VM
+// are counted as fully free since they are hot-added on attach.
+func computeVolumeAttachCapacity(devices object.VirtualDeviceList) *VolumeAttachCapacity {
+	capacity := &VolumeAttachCapacity{
+		AttachedVolumes:            make(map[string]struct{}),
+		FreeNVMeSlotsPerController: make(map[int32]int),
+	}
+	usedUnits := make(map[int32]int)
+	pvscsiControllers := make(map[int32]struct{})
+	nvmeControllers := make(map[int32]struct{})
+	scsiControllerCount := 0
+	for _, device := range devices {
+		switch dev := device.(type) {
+		case *types.ParaVirtualSCSIController:
+			pvscsiControllers[dev.Key] = struct{}{}
+			scsiControllerCount++
+		case types.BaseVirtualSCSIController:
+			// Other SCSI controller types count towards the SCSI controller limit
+			// of the VM, but disks are not attached to them.
+			scsiControllerCount++
+		case *types.VirtualNVMEController:
+			nvmeControllers[dev.Key] = struct{}{}
+		case *types.VirtualDisk:
+			usedUnits[dev.ControllerKey]++
+			if dev.VDiskId != nil && dev.VDiskId.Id != "" {
+				capacity.AttachedVolumes[dev.VDiskId.Id] = struct{}{}
+			}
+		}
+	}
+	for key := range pvscsiControllers {
+		capacity.FreePVSCSISlots += max(maxDevicesPerPVSCSIController-usedUnits[key], 0)
+	}
+	capacity.FreePVSCSISlots += max(maxSCSIControllersPerVM-scsiControllerCount, 0) * maxDevicesPerPVSCSIController
+	for key := range nvmeControllers {
+		free := max(maxDevicesPerNVMeController-usedUnits[key], 0)
+		capacity.FreeNVMeSlots += free
+		capacity.FreeNVMeSlotsPerController[key] = free
+		// Pick the controller with the lowest key so that the choice is stable.
+		if free > 0 && (capacity.NVMeControllerKeyWithFreeSlot == nil ||
+			key < *capacity.NVMeControllerKeyWithFreeSlot) {
+			controllerKey := key
+			capacity.NVMeControllerKeyWithFreeSlot = &controllerKey
+		}
+	}
+	return capacity
+}
+
+// FreeSlots returns the number of disks which can still be attached to the
+// virtual machine on any controller type.
+func (capacity *VolumeAttachCapacity) FreeSlots() int {
+	return capacity.FreePVSCSISlots + capacity.FreeNVMeSlots
+}
+
+// GetUnavailabilityReason returns the reason why the virtual machine can not
+// be used by its guest, i.e. when it is powered off, orphaned or on a host which
+// is not connected to vCenter. An empty reason is returned if the virtual
+// machine is available.
+func (vm *VirtualMachine) GetUnavailabilityReason(ctx context.Context) (string, error) {
+	log := logger.GetLogger(ctx)
+	var vmMo mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(), []string{"runtime.powerState", "runtime.connectionState"}, &vmMo)
+	if err != nil {
+		return "", logger.LogNewErrorf(log, "failed to get runtime properties of VM %v. Error: %+v", vm, err)
+	}
+	return getUnavailabilityReason(vmMo.Runtime), nil
+}
+
+// getUnavailabilityReason returns the reason why a virtual machine with the
+// given runtime info is unavailable, or an empty string if it is available.
+func getUnavailabilityReason(runtime types.VirtualMachineRuntimeInfo) string {
+	if runtime.ConnectionState != "" && runtime.ConnectionState != types.VirtualMachineConnectionStateConnected {
+		// The connection state is disconnected when the host of the VM is
+		// not connected to vCenter.
+		return string(runtime.ConnectionState)
+	}
+	if runtime.PowerState == types.VirtualMachinePowerStatePoweredOff {
+		return string(runtime.PowerState)
+	}
+	return ""
+}
+
+// GetGuestIPAddresses returns the IP addresses reported by VMware Tools for
+// the guest NICs of the virtual machine. Loopback and link-local addresses
+// are excluded as they can not be used to reach the virtual machine.
+func (vm *VirtualMachine) GetGuestIPAddresses(ctx context.Context) ([]string, error) {
+	log := logger.GetLogger(ctx)
+	var vmMo mo.VirtualMachine
+	err := vm.Properties(ctx, vm.Reference(), []string{"guest.net"}, &vmMo)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get guest network properties of VM %v. Error: %+v", vm, err)
+	}
+	if vmMo.Guest == nil {
+		return nil, nil
+	}
+	return getGuestIPAddresses(vmMo.Guest.Net), nil
+}
+
+// getGuestIPAddresses returns the sorted, de-duplicated list of usable IP
+// addresses found on the given guest NICs.
+func getGuestIPAddresses(nics []types.GuestNicInfo) []string {
+	var ips []string
+	for _, nic := range nics {
+		addresses := nic.IpAddress
+		if nic.IpConfig != nil && len(addresses) == 0 {
+			for _, ipAddress := range nic.IpConfig.IpAddress {
+				addresses = append(addresses, ipAddress.IpAddress)
+			}
+		}
+		for _, address := range addresses {
+			ip := net.ParseIP(address)
+			if ip == nil || ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsUnspecified() {
+				continue
+			}
+			ips = append(ips, ip.String())
+		}
+	}
+	slices.Sort(ips)
+	return slices.Compact(ips)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"reflect"
 	"sync"
 	"testing"
 
@@ -29,6 +30,7 @@ import (
 	"github.com/vmware/govmomi/vapi/rest"
 	_ "github.com/vmware/govmomi/vapi/simulator"
 	"github.com/vmware/govmomi/vapi/tags"
+	"github.com/vmware/govmomi/vim25/types"
 )
 
 var (
@@ -180,3 +182,129 @@ func TestIsInZoneRegion(t *testing.T) {
 		t.Fatalf("VM should belong to specified zone and region")
 	}
 }
+
+// TestComputeVolumeAttachCapacity verifies free attach slots are computed from
+// the device list, counting the controllers which can be hot-added.
+func TestComputeVolumeAttachCapacity(t *testing.T) {
+	newDisk := func(controllerKey int32, fcdID string) *types.VirtualDisk {
+		disk := &types.VirtualDisk{}
+		disk.ControllerKey = controllerKey
+		if fcdID != "" {
+			disk.VDiskId = &types.ID{Id: fcdID}
+		}
+		return disk
+	}
+	pvscsi := &types.ParaVirtualSCSIController{}
+	pvscsi.Key = 1000
+	lsiLogic := &types.VirtualLsiLogicController{}
+	lsiLogic.Key = 1001
+	nvme := &types.VirtualNVMEController{}
+	nvme.Key = 31000
+
+	devices := object.VirtualDeviceList{
+		pvscsi, lsiLogic, nvme,
+		// Boot disk on the PVSCSI controller.
+		newDisk(1000, ""),
+		newDisk(1000, "fcd-1"),
+		newDisk(1000, "fcd-2"),
+		newDisk(31000, "fcd-3"),
+	}
+	capacity := computeVolumeAttachCapacity(devices)
+	if len(capacity.AttachedVolumes) != 3 {
+		t.Fatalf("expected 3 attached volumes, got %d", len(capacity.AttachedVolumes))
+	}
+	if _, ok := capacity.AttachedVolumes["fcd-2"]; !ok {
+		t.Fatalf("expected fcd-2 to be reported as attached")
+	}
+	// 12 free slots on the existing PVSCSI controller and 2 controllers which can be hot-added.
+	if capacity.FreePVSCSISlots != 12+2*maxDevicesPerPVSCSIController {
+		t.Fatalf("unexpected free PVSCSI slots: %d", capacity.FreePVSCSISlots)
+	}
+	// 14 free namespaces on the existing NVMe controller, NVMe controllers are not hot-added.
+	if capacity.FreeNVMeSlots != 14 {
+		t.Fatalf("unexpected free NVMe slots: %d", capacity.FreeNVMeSlots)
+	}
+	if capacity.FreeSlots() != capacity.FreePVSCSISlots+14 {
+		t.Fatalf("unexpected free slots: %d", capacity.FreeSlots())
+	}
+	if capacity.NVMeControllerKeyWithFreeSlot == nil || *capacity.NVMeControllerKeyWithFreeSlot != 31000 {
+		t.Fatalf("expected NVMe controller 31000 to have a free slot, got %v",
+			capacity.NVMeControllerKeyWithFreeSlot)
+	}
+	if capacity.FreeNVMeSlotsPerController[31000] != 14 {
+		t.Fatalf("unexpected free slots on NVMe controller 31000: %d", capacity.FreeNVMeSlotsPerController[31000])
+	}
+
+	capacity = computeVolumeAttachCapacity(object.VirtualDeviceList{})
+	if capacity.FreePVSCSISlots != maxSCSIControllersPerVM*maxDevicesPerPVSCSIController {
+		t.Fatalf("unexpected free PVSCSI slots for VM without controllers: %d", capacity.FreePVSCSISlots)
+	}
+	if capacity.FreeNVMeSlots != 0 || capacity.NVMeControllerKeyWithFreeSlot != nil {
+		t.Fatalf("expected no NVMe controller with free slot for VM without controllers")
+	}
+}
+
+func TestGetUnavailabilityReason(t *testing.T) {
+	tests := []struct {
+		runtime  types.VirtualMachineRuntimeInfo
+		expected string
+	}{
+		{
+			runtime: types.VirtualMachineRuntimeInfo{
+				ConnectionState: types.VirtualMachineConnectionStateConnected,
+				PowerState:      types.VirtualMachinePowerStatePoweredOn,
+			},
+			expected: "",
+		},
+		{
+			runtime: types.VirtualMachineRuntimeInfo{
+				ConnectionState: types.VirtualMachineConnectionStateConnected,
+				PowerState:      types.VirtualMachinePowerStatePoweredOff,
+			},
+			expected: "poweredOff",
+		},
+		{
+			runtime: types.VirtualMachineRuntimeInfo{
+				ConnectionState: types.VirtualMachineConnectionStateOrphaned,
+				PowerState:      types.VirtualMachinePowerStatePoweredOn,
+			},
+			expected: "orphaned",
+		},
+		{
+			runtime: types.VirtualMachineRuntimeInfo{
+				ConnectionState: types.VirtualMachineConnectionStateDisconnected,
+				PowerState:      types.VirtualMachinePowerStatePoweredOn,
+			},
+			expected: "disconnected",
+		},
+	}
+	for _, test := range tests {
+		if reason := getUnavailabilityReason(test.runtime); reason != test.expected {
+			t.Errorf("expected reason %q for runtime %+v, got %q", test.expected, test.runtime, reason)
+		}
+	}
+}
+
+func TestGetGuestIPAddresses(t *testing.T) {
+	nics := []types.GuestNicInfo{
+		{
+			IpAddress: []string{"10.0.0.12", "127.0.0.1", "fe80::250:56ff:fe8a:1", "10.0.0.12"},
+		},
+		{
+			IpAddress: []string{"192.168.1.5", "fd00::5"},
+		},
+		{
+			IpConfig: &types.NetIpConfigInfo{
+				IpAddress: []types.NetIpConfigInfoIpAddress{{IpAddress: "10.0.0.2"}, {IpAddress: "invalid"}},
+			},
+		},
+	}
+	expected := []string{"10.0.0.12", "10.0.0.2", "192.168.1.5", "fd00::5"}
+	ips := getGuestIPAddresses(nics)
+	if !reflect.DeepEqual(ips, expected) {
+		t.Fatalf("expected guest IPs %v, got %v", expected, ips)
+	}
+	if ips := getGuestIPAddresses(nil); len(ips) != 0 {
+		t.Fatalf("expected no guest IPs for VM without NICs, got %v", ips)
+	}
+}
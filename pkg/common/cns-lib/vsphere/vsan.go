@@ -19,10 +19,27 @@ package vsphere
 import (
 	"context"
 
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
 	"github.com/vmware/govmomi/vsan"
+	"github.com/vmware/govmomi/vsan/methods"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
+// vsanFileServiceSystemInstance is the managed object serving the vSAN File
+// Service APIs of the vCenter.
+var vsanFileServiceSystemInstance = types.ManagedObjectReference{
+	Type:  "VsanFileServiceSystem",
+	Value: "vsan-cluster-file-service-system",
+}
+
+// fileShareQueryLimit is the number of file shares fetched per
+// VsanClusterQueryFileShares call.
+const fileShareQueryLimit = 100
+
 // ConnectVsan creates a VSAN client for the virtual center.
 func (vc *VirtualCenter) ConnectVsan(ctx context.Context) error {
 	log := logger.GetLogger(ctx)
@@ -50,3 +67,200 @@ func (vc *VirtualCenter) DisconnectVsan(ctx context.Context) error {
 	}
 	return nil
 }
+
+// QueryFileShareUsedCapacity returns the used capacity in MB of the vSAN file
+// shares with the given names on the given vSAN datastore, keyed by share name.
+// Shares which are not found are not included in the result.
+func (vc *VirtualCenter) QueryFileShareUsedCapacity(ctx context.Context, datastore types.ManagedObjectReference,
+	shareNames []string) (map[string]int64, error) {
+	usedCapacityMB := make(map[string]int64)
+	includeBasic, includeUsedCapacity := true, true
+	properties := &vsantypes.VsanFileShareQueryProperties{
+		IncludeBasic:        &includeBasic,
+		IncludeUsedCapacity: &includeUsedCapacity,
+	}
+	err := vc.queryFileShares(ctx, datastore, shareNames, properties, func(share vsantypes.VsanFileShare) {
+		if share.Runtime != nil {
+			usedCapacityMB[share.Config.Name] = share.Runtime.UsedCapacity
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return usedCapacityMB, nil
+}
+
+// QueryFileShareNetPermissions returns the net permissions in the ACL of the
+// vSAN file shares with the given names on the given vSAN datastore, keyed by
+// share name. Shares which are not found are not included in the result.
+func (vc *VirtualCenter) QueryFileShareNetPermissions(ctx context.Context, datastore types.ManagedObjectReference,
+	shareNames []string) (map[string][]vsanfstypes.VsanFileShareNetPermission, error) {
+	netPerms := make(map[string][]vsanfstypes.VsanFileShareNetPermission)
+	includeBasic := true
+	properties := &vsantypes.VsanFileShareQueryProperties{IncludeBasic: &includeBasic}
+	err := vc.queryFileShares(ctx, datastore, shareNames, properties, func(share vsantypes.VsanFileShare) {
+		perms := make([]vsanfstypes.VsanFileShareNetPermission, 0, len(share.Config.Permission))
+		for _, perm := range share.Config.Permission {
+			perms = append(perms, vsanfstypes.VsanFileShareNetPermission{
+				Ips:         perm.Ips,
+				Permissions: vsanfstypes.VsanFileShareAccessType(perm.Permissions),
+				AllowRoot:   perm.AllowRoot != nil && *perm.AllowRoot,
+			})
+		}
+		netPerms[share.Config.Name] = perms
+	})
+	if err != nil {
+		return nil, err
+	}
+	return netPerms, nil
+}
+
+// IsFileServiceKerberosEnabled returns true if a vSAN file service domain of
+// the cluster of the given vSAN datastore is joined to a directory service,
+// which Kerberos security of NFS file shares requires.
+func (vc *VirtualCenter) IsFileServiceKerberosEnabled(ctx context.Context,
+	datastore types.ManagedObjectReference) (bool, error) {
+	log := logger.GetLogger(ctx)
+	if err := vc.ConnectVsan(ctx); err != nil {
+		return false, err
+	}
+	cluster, err := vc.getVsanDatastoreCluster(ctx, datastore)
+	if err != nil {
+		return false, err
+	}
+	res, err := methods.VsanClusterQueryFsDomains(ctx, vc.VsanClient, &vsantypes.VsanClusterQueryFsDomains{
+		This:    vsanFileServiceSystemInstance,
+		Cluster: &cluster,
+	})
+	if err != nil {
+		return false, logger.LogNewErrorf(log, "failed to query file service domains on cluster %v. Error: %+v",
+			cluster, err)
+	}
+	for _, domain := range res.Returnval {
+		if domain.Config != nil && domain.Config.DirectoryServerConfig != nil {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// SetFileShareNfsSecurity sets the NFS security type of the vSAN file share
+// with the given name on the given vSAN datastore, if it is not set already.
+func (vc *VirtualCenter) SetFileShareNfsSecurity(ctx context.Context, datastore types.ManagedObjectReference,
+	shareName string, secType vsantypes.VsanFileShareNfsSecType) error {
+	log := logger.GetLogger(ctx)
+	var fileShare *vsantypes.VsanFileShare
+	includeBasic := true
+	properties := &vsantypes.VsanFileShareQueryProperties{IncludeBasic: &includeBasic}
+	err := vc.queryFileShares(ctx, datastore, []string{shareName}, properties, func(share vsantypes.VsanFileShare) {
+		if share.Config.Name == shareName {
+			fileShare = &share
+		}
+	})
+	if err != nil {
+		return err
+	}
+	if fileShare == nil {
+		return logger.LogNewErrorf(log, "file share %q not found on datastore %v", shareName, datastore)
+	}
+	if fileShare.Config.NfsSecType == string(secType) {
+		return nil
+	}
+	cluster, err := vc.getVsanDatastoreCluster(ctx, datastore)
+	if err != nil {
+		return err
+	}
+	config := *fileShare.Config
+	config.NfsSecType = string(secType)
+	res, err := methods.VsanReconfigureFileShare(ctx, vc.VsanClient, &vsantypes.VsanReconfigureFileShare{
+		This:      vsanFileServiceSystemInstance,
+		ShareUuid: fileShare.Uuid,
+		Config:    config,
+		Cluster:   &cluster,
+	})
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to reconfigure file share %q. Error: %+v", shareName, err)
+	}
+	if err := object.NewTask(vc.Client.Client, res.Returnval).Wait(ctx); err != nil {
+		return logger.LogNewErrorf(log, "failed to set NFS security type %q on file share %q. Error: %+v",
+			secType, shareName, err)
+	}
+	log.Infof("Set NFS security type %q on file share %q", secType, shareName)
+	return nil
+}
+
+// queryFileShares calls fn with each vSAN file share with the given names on
+// the given vSAN datastore, fetching the given properties of the shares.
+func (vc *VirtualCenter) queryFileShares(ctx context.Context, datastore types.ManagedObjectReference,
+	shareNames []string, properties *vsantypes.VsanFileShareQueryProperties,
+	fn func(share vsantypes.VsanFileShare)) error {
+	log := logger.GetLogger(ctx)
+	if len(shareNames) == 0 {
+		return nil
+	}
+	if err := vc.ConnectVsan(ctx); err != nil {
+		return err
+	}
+	cluster, err := vc.getVsanDatastoreCluster(ctx, datastore)
+	if err != nil {
+		return err
+	}
+	req := vsantypes.VsanClusterQueryFileShares{
+		This:    vsanFileServiceSystemInstance,
+		Cluster: &cluster,
+		QuerySpec: vsantypes.VsanFileShareQuerySpec{
+			Names:      shareNames,
+			Limit:      fileShareQueryLimit,
+			Properties: properties,
+		},
+	}
+	for {
+		res, err := methods.VsanClusterQueryFileShares(ctx, vc.VsanClient, &req)
+		if err != nil {
+			return logger.LogNewErrorf(log, "failed to query file shares on cluster %v. Error: %+v",
+				cluster, err)
+		}
+		if res.Returnval == nil {
+			break
+		}
+		for _, share := range res.Returnval.FileShares {
+			if share.Config == nil {
+				continue
+			}
+			fn(share)
+		}
+		if res.Returnval.NextOffset == "" || len(res.Returnval.FileShares) == 0 {
+			break
+		}
+		req.QuerySpec.Offset = res.Returnval.NextOffset
+	}
+	return nil
+}
+
+// getVsanDatastoreCluster returns the cluster of the hosts mounting the given
+// vSAN datastore.
+func (vc *VirtualCenter) getVsanDatastoreCluster(ctx context.Context,
+	datastore types.ManagedObjectReference) (types.ManagedObjectReference, error) {
+	log := logger.GetLogger(ctx)
+	var dsMo mo.Datastore
+	err := vc.Client.RetrieveOne(ctx, datastore, []string{"host"}, &dsMo)
+	if err != nil {
+		return types.ManagedObjectReference{}, logger.LogNewErrorf(log,
+			"failed to get hosts of datastore %v. Error: %+v", datastore, err)
+	}
+	if len(dsMo.Host) == 0 {
+		return types.ManagedObjectReference{}, logger.LogNewErrorf(log,
+			"datastore %v is not mounted on any host", datastore)
+	}
+	var hostMo mo.HostSystem
+	err = vc.Client.RetrieveOne(ctx, dsMo.Host[0].Key, []string{"parent"}, &hostMo)
+	if err != nil {
+		return types.ManagedObjectReference{}, logger.LogNewErrorf(log,
+			"failed to get parent of host %v. Error: %+v", dsMo.Host[0].Key, err)
+	}
+	if hostMo.Parent == nil || hostMo.Parent.Type != "ClusterComputeResource" {
+		return types.ManagedObjectReference{}, logger.LogNewErrorf(log,
+			"host %v of datastore %v is not in a cluster", dsMo.Host[0].Key, datastore)
+	}
+	return *hostMo.Parent, nil
+}
@@ -99,6 +99,9 @@ const (
 	// DefaultListVolumeThreshold specifies the default maximum number of differences in volumes between CNS
 	// and kubernetes
 	DefaultListVolumeThreshold = 50
+	// DefaultNetPermissionKey is the key of the NetPermissions entry added
+	// when no net permissions are given in the config on vanilla clusters.
+	DefaultNetPermissionKey = "#"
 	// supervisorIDPrefix is added before the SupervisorID
 	// Using this CNS UI can form an appropriate URL to navigate from CNS UI to WCP UI
 	supervisorIDPrefix = "vSphereSupervisorID-"
@@ -153,6 +156,10 @@ var (
 	// NetPermissions is not among the ones listed.
 	ErrInvalidNetPermission = errors.New("invalid value for Permissions under NetPermission Config")
 
+	// ErrInvalidNFSExport is returned when an NFSExport config does not
+	// define the server or an absolute export path.
+	ErrInvalidNFSExport = errors.New("server and absolute path are required under NFSExport Config")
+
 	// ErrMissingTopologyCategoriesForMultiVCenterSetup is returned when the TopologyCategories are not specified for
 	// Multi vCenter deployment
 	ErrMissingTopologyCategoriesForMultiVCenterSetup = errors.New("vsphere CSI config requires " +
@@ -434,7 +441,7 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 		// If no net permissions are given, assume default.
 		log.Debug("No Net Permissions given in Config. Using default permissions.")
 		if clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
-			cfg.NetPermissions = map[string]*NetPermissionConfig{"#": GetDefaultNetPermission()}
+			cfg.NetPermissions = map[string]*NetPermissionConfig{DefaultNetPermissionKey: GetDefaultNetPermission()}
 		}
 	} else {
 		for key, netPerm := range cfg.NetPermissions {
@@ -451,6 +458,12 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 			}
 		}
 	}
+	for key, nfsExport := range cfg.NFSExport {
+		if nfsExport.Server == "" || !strings.HasPrefix(nfsExport.Path, "/") {
+			log.Errorf("Invalid NFSExport Config %s: %+v", key, nfsExport)
+			return ErrInvalidNFSExport
+		}
+	}
 
 	if cfg.Global.CnsRegisterVolumesCleanupIntervalInMin == 0 {
 		cfg.Global.CnsRegisterVolumesCleanupIntervalInMin = DefaultCnsRegisterVolumesCleanupIntervalInMin
@@ -512,6 +525,33 @@ func validateConfig(ctx context.Context, cfg *Config) error {
 		cfg.Global.ListVolumeThreshold = DefaultListVolumeThreshold
 		log.Debugf("Setting default list volume threshold to %v", cfg.Global.ListVolumeThreshold)
 	}
+
+	if cfg.Global.AttachBatchWindowInMs < 0 {
+		return logger.LogNewErrorf(log, "invalid value %d for attach-batch-window-inms",
+			cfg.Global.AttachBatchWindowInMs)
+	}
+
+	if cfg.Global.BulkOperationConcurrency < 0 {
+		return logger.LogNewErrorf(log, "invalid value %d for bulk-operation-concurrency",
+			cfg.Global.BulkOperationConcurrency)
+	}
+
+	timeouts := cfg.VolumeOperationTimeout
+	for name, timeout := range map[string]int{
+		"create-volume-insec":   timeouts.CreateVolumeInSec,
+		"delete-volume-insec":   timeouts.DeleteVolumeInSec,
+		"attach-volume-insec":   timeouts.AttachVolumeInSec,
+		"detach-volume-insec":   timeouts.DetachVolumeInSec,
+		"expand-volume-insec":   timeouts.ExpandVolumeInSec,
+		"update-volume-insec":   timeouts.UpdateVolumeInSec,
+		"create-snapshot-insec": timeouts.CreateSnapshotInSec,
+		"delete-snapshot-insec": timeouts.DeleteSnapshotInSec,
+		"query-insec":           timeouts.QueryInSec,
+	} {
+		if timeout < 0 {
+			return logger.LogNewErrorf(log, "invalid value %d for %s", timeout, name)
+		}
+	}
 	return nil
 }
 
@@ -149,6 +149,62 @@ func TestValidateConfigWithInvalidPermissions(t *testing.T) {
 	}
 }
 
+func TestValidateConfigWithInvalidNFSExport(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+		NFSExport: map[string]*NFSExportConfig{
+			"filer": {
+				Server: "filer.example.com",
+				Path:   "exports/k8s",
+			},
+		},
+	}
+
+	err := validateConfig(ctx, cfg)
+	if err != ErrInvalidNFSExport {
+		t.Errorf("Expected error due to relative path in NFSExport. Config given - %+v", *cfg)
+	}
+	cfg.NFSExport["filer"].Path = "/exports/k8s"
+	err = validateConfig(ctx, cfg)
+	if err != nil {
+		t.Errorf("Unexpected error for valid NFSExport. Config given - %+v, error: %v", *cfg, err)
+	}
+}
+
+func TestValidateConfigWithInvalidVolumeOperationTimeout(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	cfg.VolumeOperationTimeout.ExpandVolumeInSec = -1
+
+	err := validateConfig(ctx, cfg)
+	if err == nil {
+		t.Errorf("Expected error due to negative expand volume timeout. Config given - %+v", *cfg)
+	}
+	cfg.VolumeOperationTimeout.ExpandVolumeInSec = 60
+	err = validateConfig(ctx, cfg)
+	if err != nil {
+		t.Errorf("Unexpected error for valid volume operation timeouts. Config given - %+v, error: %v", *cfg, err)
+	}
+}
+
+func TestValidateConfigWithInvalidBulkOperationConcurrency(t *testing.T) {
+	cfg := &Config{
+		VirtualCenter: idealVCConfig,
+	}
+	cfg.Global.BulkOperationConcurrency = -1
+
+	err := validateConfig(ctx, cfg)
+	if err == nil {
+		t.Errorf("Expected error due to negative bulk operation concurrency. Config given - %+v", *cfg)
+	}
+	cfg.Global.BulkOperationConcurrency = 8
+	err = validateConfig(ctx, cfg)
+	if err != nil {
+		t.Errorf("Unexpected error for valid bulk operation concurrency. Config given - %+v, error: %v", *cfg, err)
+	}
+}
+
 func TestValidateConfigWithInvalidClusterId(t *testing.T) {
 	cfg := &Config{
 		VirtualCenter: idealVCConfig,
@@ -74,18 +74,33 @@ type Config struct {
 		// ListVolumeThreshold specifies the maximum number of differences in volume that can exist between CNS
 		// and kubernetes
 		ListVolumeThreshold int `gcfg:"list-volume-threshold"`
+		// AttachBatchWindowInMs specifies the time window over which concurrent
+		// ControllerPublishVolume calls for the same node VM are collected and
+		// attached with a single batch attach call. Batching is disabled when 0.
+		AttachBatchWindowInMs int `gcfg:"attach-batch-window-inms"`
+		// BulkOperationConcurrency specifies the maximum number of CNS calls
+		// issued concurrently by bulk operations, such as the volume metadata
+		// updates of full sync, against a single vCenter. Defaults to 4 when 0.
+		BulkOperationConcurrency int `gcfg:"bulk-operation-concurrency"`
 	}
 
 	// Multiple sets of Net Permissions applied to all file shares
 	// The string can uniquely represent each Net Permissions config
 	NetPermissions map[string]*NetPermissionConfig
 
+	// NFS exports the nfs-subdir backend provisions file volumes on, keyed
+	// by the name used in the "nfsexport" StorageClass parameter.
+	NFSExport map[string]*NFSExportConfig
+
 	// Virtual Center configurations
 	VirtualCenter map[string]*VirtualCenterConfig
 
 	// Snapshot configurations.
 	Snapshot SnapshotConfig
 
+	// Timeouts of the operations the driver invokes on CNS.
+	VolumeOperationTimeout VolumeOperationTimeoutConfig
+
 	// Guest Cluster configurations, only used by GC
 	GC GCConfig
 
@@ -133,6 +148,17 @@ type NetPermissionConfig struct {
 	RootSquash bool `gcfg:"rootsquash"`
 }
 
+// NFSExportConfig consists of information used to provision file volumes as
+// directories on an NFS export
+type NFSExportConfig struct {
+	// NFS server hostname or IP address.
+	Server string `gcfg:"server"`
+	// Exported path on the NFS server. Example: "/exports/k8s"
+	Path string `gcfg:"path"`
+	// Comma separated options used by the controller to mount the export. Optional.
+	MountOptions string `gcfg:"mount-options"`
+}
+
 // VirtualCenterConfig contains information used to access a remote vCenter
 // endpoint.
 type VirtualCenterConfig struct {
@@ -193,5 +219,32 @@ type SnapshotConfig struct {
 	GranularMaxSnapshotsPerBlockVolumeInVVOL int `gcfg:"granular-max-snapshots-per-block-volume-vvol"`
 }
 
+// VolumeOperationTimeoutConfig contains the timeouts, in seconds, after which the
+// driver stops waiting on a CNS operation. A timeout never extends the deadline of
+// the CSI RPC invoking the operation. Operations without a configured timeout wait
+// until the deadline of the RPC, or until the default volume operation timeout when
+// the RPC has no deadline.
+type VolumeOperationTimeoutConfig struct {
+	// CreateVolumeInSec specifies the timeout of CreateVolume operations.
+	CreateVolumeInSec int `gcfg:"create-volume-insec"`
+	// DeleteVolumeInSec specifies the timeout of DeleteVolume and UnregisterVolume operations.
+	DeleteVolumeInSec int `gcfg:"delete-volume-insec"`
+	// AttachVolumeInSec specifies the timeout of AttachVolume and BatchAttachVolumes operations.
+	AttachVolumeInSec int `gcfg:"attach-volume-insec"`
+	// DetachVolumeInSec specifies the timeout of DetachVolume operations.
+	DetachVolumeInSec int `gcfg:"detach-volume-insec"`
+	// ExpandVolumeInSec specifies the timeout of ExpandVolume operations.
+	ExpandVolumeInSec int `gcfg:"expand-volume-insec"`
+	// UpdateVolumeInSec specifies the timeout of operations updating the metadata, the
+	// encryption, the placement or the ACLs of a volume.
+	UpdateVolumeInSec int `gcfg:"update-volume-insec"`
+	// CreateSnapshotInSec specifies the timeout of CreateSnapshot operations.
+	CreateSnapshotInSec int `gcfg:"create-snapshot-insec"`
+	// DeleteSnapshotInSec specifies the timeout of DeleteSnapshot operations.
+	DeleteSnapshotInSec int `gcfg:"delete-snapshot-insec"`
+	// QueryInSec specifies the timeout of volume and snapshot queries.
+	QueryInSec int `gcfg:"query-insec"`
+}
+
 // EnvClusterFlavor is the k8s cluster type on which CSI Driver is being deployed
 const EnvClusterFlavor = "CLUSTER_FLAVOR"
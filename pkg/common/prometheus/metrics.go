@@ -148,6 +148,38 @@ var (
 		// Possible volume_health_type - "accessible-volumes", "inaccessible-volumes"
 		[]string{"volume_health_type"})
 
+	// VolumeInaccessibleSecondsCounterVec is a counter metric to observe the time volumes were inaccessible.
+	VolumeInaccessibleSecondsCounterVec = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "vsphere_volume_inaccessible_seconds_total",
+		Help: "Total time in seconds volumes were observed to be inaccessible",
+	},
+		[]string{"namespace", "datastore"})
+
+	// VolumeHealthLastTransitionGaugeVec is a gauge metric to observe the unix time of the last
+	// volume health transition.
+	VolumeHealthLastTransitionGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_volume_health_last_transition_timestamp_seconds",
+		Help: "Unix time of the last transition of a volume to the given health status",
+	},
+		// Possible volume_health - "accessible", "inaccessible"
+		[]string{"namespace", "datastore", "volume_health"})
+
+	// FileVolumeUsedBytesGaugeVec is a gauge metric to observe the used capacity of the
+	// vSAN file shares backing file volumes.
+	FileVolumeUsedBytesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_file_volume_used_bytes",
+		Help: "Used capacity in bytes of the vSAN file share backing a file volume",
+	},
+		[]string{"namespace", "persistentvolumeclaim"})
+
+	// FileVolumeQuotaBytesGaugeVec is a gauge metric to observe the hard quota of the
+	// vSAN file shares backing file volumes.
+	FileVolumeQuotaBytesGaugeVec = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "vsphere_file_volume_quota_bytes",
+		Help: "Hard quota in bytes of the vSAN file share backing a file volume",
+	},
+		[]string{"namespace", "persistentvolumeclaim"})
+
 	// FullSyncOpsHistVec is a histogram vector metric to observe CSI Full Sync.
 	FullSyncOpsHistVec = promauto.NewHistogramVec(prometheus.HistogramOpts{
 		Name: "vsphere_full_sync_ops_histogram",
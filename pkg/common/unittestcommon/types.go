@@ -50,6 +50,10 @@ type FakeK8SOrchestrator struct {
 	csiNodeTopologyInstances []interface{}
 	// PVCs for testing
 	pvcs []*v1.PersistentVolumeClaim
+	// PV volume attributes keyed by volumeID for testing
+	volumeAttributes map[string]map[string]string
+	// PV annotations keyed by PV name for testing
+	pvAnnotations map[string]map[string]string
 }
 
 // volumeMigration holds mocked migrated volume information
@@ -223,6 +227,11 @@ func (m *MockVolumeManager) BatchAttachVolumes(ctx context.Context,
 	return []cnsvolume.BatchAttachResult{}, "", nil
 }
 
+func (m *MockVolumeManager) ExecuteBulkOperation(ctx context.Context,
+	request *cnsvolume.BulkOperationRequest) ([]cnsvolume.BulkOperationResult, error) {
+	return []cnsvolume.BulkOperationResult{}, nil
+}
+
 func (m *MockVolumeManager) SyncVolume(ctx context.Context,
 	syncVolumeSpecs []cnstypes.CnsSyncVolumeSpec) (string, error) {
 	return "", nil
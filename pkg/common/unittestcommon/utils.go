@@ -530,6 +530,18 @@ func (c *FakeK8SOrchestrator) GetPVNameFromCSIVolumeID(volumeID string) (string,
 	return "mock-pv", true
 }
 
+// GetVolumeAttributes returns the volume attributes set for the volumeID through SetVolumeAttributes.
+func (c *FakeK8SOrchestrator) GetVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error) {
+	return c.volumeAttributes[volumeID], nil
+}
+
+// GetPersistentVolumeAnnotations returns the PV annotations set for the pvName through
+// SetPersistentVolumeAnnotations.
+func (c *FakeK8SOrchestrator) GetPersistentVolumeAnnotations(ctx context.Context,
+	pvName string) (map[string]string, error) {
+	return c.pvAnnotations[pvName], nil
+}
+
 // GetPVCNameFromCSIVolumeID returns `pvc name` and `pvc namespace` for the given volumeID using volumeIDToPvcMap.
 func (c *FakeK8SOrchestrator) GetPVCNameFromCSIVolumeID(volumeID string) (string, string, bool) {
 	if strings.Contains(volumeID, "invalid") {
@@ -578,6 +590,29 @@ func (c *FakeK8SOrchestrator) InitializeCSINodes(ctx context.Context) error {
 	return nil
 }
 
+// UpdateCSINodeAllocatableCount updates the allocatable volume count of the
+// vSphere CSI driver on the CSINode instance for the given node name.
+func (c *FakeK8SOrchestrator) UpdateCSINodeAllocatableCount(ctx context.Context, nodeName string,
+	count int32) error {
+	return nil
+}
+
+// GetNodeFileVolumeCount returns the number of file volumes published to the given node.
+func (c *FakeK8SOrchestrator) GetNodeFileVolumeCount(ctx context.Context, nodeName string) (int, error) {
+	return 0, nil
+}
+
+// IsNodeOutOfService returns true if the given node is tainted with the
+// node.kubernetes.io/out-of-service taint.
+func (c *FakeK8SOrchestrator) IsNodeOutOfService(ctx context.Context, nodeName string) (bool, error) {
+	return false, nil
+}
+
+// RecordNodeEvent records an event with the given type, reason and message on the given node.
+func (c *FakeK8SOrchestrator) RecordNodeEvent(ctx context.Context, nodeName string, eventType string,
+	reason string, message string) {
+}
+
 // StartZonesInformer starts a dynamic informer which listens on Zones CR in
 // topology.tanzu.vmware.com/v1alpha1 API group.
 func (c *FakeK8SOrchestrator) StartZonesInformer(ctx context.Context, restClientConfig *restclient.Config,
@@ -648,6 +683,22 @@ func (c *FakeK8SOrchestrator) SetPVCs(pvcs []*v1.PersistentVolumeClaim) {
 	c.pvcs = pvcs
 }
 
+// SetVolumeAttributes sets the PV volume attributes of the volumeID for testing
+func (c *FakeK8SOrchestrator) SetVolumeAttributes(volumeID string, attributes map[string]string) {
+	if c.volumeAttributes == nil {
+		c.volumeAttributes = make(map[string]map[string]string)
+	}
+	c.volumeAttributes[volumeID] = attributes
+}
+
+// SetPersistentVolumeAnnotations sets the annotations of the PV pvName for testing
+func (c *FakeK8SOrchestrator) SetPersistentVolumeAnnotations(pvName string, annotations map[string]string) {
+	if c.pvAnnotations == nil {
+		c.pvAnnotations = make(map[string]map[string]string)
+	}
+	c.pvAnnotations[pvName] = annotations
+}
+
 // configFromVCSim starts a vcsim instance and returns config for use against the
 // vcsim instance. The vcsim instance is configured with an empty tls.Config.
 func configFromVCSim(vcsimParams VcsimParams, isTopologyEnv bool) (*config.Config, func()) {
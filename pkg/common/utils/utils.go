@@ -24,8 +24,10 @@ import (
 	vmoperatortypes "github.com/vmware-tanzu/vm-operator/api/v1alpha2"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"google.golang.org/grpc/codes"
+	"k8s.io/apimachinery/pkg/api/resource"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	storagepolicyv1alpha2 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagepolicy/v1alpha2"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
@@ -336,3 +338,69 @@ func PatchVirtualMachine(ctx context.Context, vmOperatorClient client.Client,
 	log.Infof("PatchVirtualMachine: successfully patched the virtualmachine, name: %s", vm.Name)
 	return nil
 }
+
+// GetStoragePolicyQuotaUsage returns the ID of the storage policy of the given
+// StorageClass, the StoragePolicyQuota limit set on the storage policy in the
+// namespace and the storage consumed against it. The storage policy of the
+// StorageClass is taken from the StoragePolicyUsage CRs, which the syncer
+// creates for each StorageClass of a storage policy with a quota. The used and
+// reserved storage is summed over the StoragePolicyUsage CRs of all
+// StorageClasses and resource kinds of the storage policy. The returned limit
+// is nil if no quota applies to the StorageClass.
+func GetStoragePolicyQuotaUsage(ctx context.Context, cnsOperatorClient client.Client, namespace string,
+	storageClassName string) (string, *resource.Quantity, *resource.Quantity, error) {
+	log := logger.GetLogger(ctx)
+	usageList := &storagepolicyv1alpha2.StoragePolicyUsageList{}
+	err := cnsOperatorClient.List(ctx, usageList, client.InNamespace(namespace))
+	if err != nil {
+		return "", nil, nil, logger.LogNewErrorf(log,
+			"failed to list StoragePolicyUsage CRs in namespace %q. Err: %v", namespace, err)
+	}
+	var storagePolicyID string
+	for _, usage := range usageList.Items {
+		if usage.Spec.StorageClassName == storageClassName {
+			storagePolicyID = usage.Spec.StoragePolicyId
+			break
+		}
+	}
+	if storagePolicyID == "" {
+		log.Debugf("No StoragePolicyUsage found for StorageClass %q in namespace %q", storageClassName, namespace)
+		return "", nil, nil, nil
+	}
+
+	quotaList := &storagepolicyv1alpha2.StoragePolicyQuotaList{}
+	err = cnsOperatorClient.List(ctx, quotaList, client.InNamespace(namespace))
+	if err != nil {
+		return "", nil, nil, logger.LogNewErrorf(log,
+			"failed to list StoragePolicyQuota CRs in namespace %q. Err: %v", namespace, err)
+	}
+	var limit *resource.Quantity
+	for _, quota := range quotaList.Items {
+		if quota.Spec.StoragePolicyId == storagePolicyID && quota.Spec.Limit != nil &&
+			quota.DeletionTimestamp == nil {
+			limit = quota.Spec.Limit
+			break
+		}
+	}
+	if limit == nil {
+		log.Debugf("No StoragePolicyQuota limit found for storage policy %q in namespace %q",
+			storagePolicyID, namespace)
+		return storagePolicyID, nil, nil, nil
+	}
+
+	consumed := resource.NewQuantity(0, resource.BinarySI)
+	for _, usage := range usageList.Items {
+		if usage.Spec.StoragePolicyId != storagePolicyID || usage.Status.ResourceTypeLevelQuotaUsage == nil {
+			continue
+		}
+		if usage.Status.ResourceTypeLevelQuotaUsage.Used != nil {
+			consumed.Add(*usage.Status.ResourceTypeLevelQuotaUsage.Used)
+		}
+		if usage.Status.ResourceTypeLevelQuotaUsage.Reserved != nil {
+			consumed.Add(*usage.Status.ResourceTypeLevelQuotaUsage.Reserved)
+		}
+	}
+	log.Debugf("Storage policy %q in namespace %q has quota limit %s, consumed %s",
+		storagePolicyID, namespace, limit.String(), consumed.String())
+	return storagePolicyID, limit, consumed, nil
+}
@@ -19,6 +19,7 @@ package common
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -272,6 +273,9 @@ func GenerateDatastoreMapForBlockVolumes(ctx context.Context,
 // check on them. It will return a map of cluster id to the list of datastore
 // objects. The key is cluster moid with vSAN FS enabled and Host.Config.Storage
 // privilege. The value is a list of vSAN datastoreInfo objects for the cluster.
+// If TargetvSANFileShareClusters is configured for the given VC, only those
+// clusters are retained, so that each VC in a multi-VC deployment produces its
+// own set of candidate clusters for file volume placement.
 func GenerateFSEnabledClustersToDsMap(ctx context.Context,
 	vc *cnsvsphere.VirtualCenter) (map[string][]*cnsvsphere.DatastoreInfo, error) {
 	log := logger.GetLogger(ctx)
@@ -307,6 +311,9 @@ func GenerateFSEnabledClustersToDsMap(ctx context.Context,
 		return nil, err
 	}
 
+	// Retain only the clusters configured as targets for file shares on this VC.
+	fsEnabledClusterToDsURLsMap = filterTargetvSANFileShareClusters(ctx, vc, fsEnabledClusterToDsURLsMap)
+
 	// Create a map of cluster to dsInfo objects. These objects are used while
 	// calling CNS API to create file volumes.
 	for clusterMoID, dsURLs := range fsEnabledClusterToDsURLsMap {
@@ -323,6 +330,31 @@ func GenerateFSEnabledClustersToDsMap(ctx context.Context,
 	return clusterToDsInfoListMap, nil
 }
 
+// filterTargetvSANFileShareClusters returns the subset of fsEnabledClusterToDsURLsMap
+// whose cluster moids are listed in TargetvSANFileShareClusters of the given VC.
+// The map is returned as is when no target clusters are configured for the VC.
+func filterTargetvSANFileShareClusters(ctx context.Context, vc *cnsvsphere.VirtualCenter,
+	fsEnabledClusterToDsURLsMap map[string][]string) map[string][]string {
+	log := logger.GetLogger(ctx)
+	if vc.Config == nil || len(vc.Config.TargetvSANFileShareClusters) == 0 {
+		return fsEnabledClusterToDsURLsMap
+	}
+	targetClusters := make(map[string]struct{})
+	for _, clusterMoID := range vc.Config.TargetvSANFileShareClusters {
+		targetClusters[strings.TrimSpace(clusterMoID)] = struct{}{}
+	}
+	filteredMap := make(map[string][]string)
+	for clusterMoID, dsURLs := range fsEnabledClusterToDsURLsMap {
+		if _, ok := targetClusters[clusterMoID]; ok {
+			filteredMap[clusterMoID] = dsURLs
+		} else {
+			log.Debugf("Skipping vSAN FS enabled cluster %q as it is not listed in "+
+				"TargetvSANFileShareClusters for vCenter %q", clusterMoID, vc.Config.Host)
+		}
+	}
+	return filteredMap
+}
+
 // getDatastoresWithBlockVolumePrivs gets datastores with required priv for CSI
 // user.
 func getDatastoresWithBlockVolumePrivs(ctx context.Context, vc *cnsvsphere.VirtualCenter,
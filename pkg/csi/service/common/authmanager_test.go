@@ -0,0 +1,47 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+)
+
+func TestFilterTargetvSANFileShareClusters(t *testing.T) {
+	fsEnabledClusterToDsURLsMap := map[string][]string{
+		"domain-c1": {"ds:///vmfs/volumes/vsan:1/"},
+		"domain-c2": {"ds:///vmfs/volumes/vsan:2/"},
+	}
+
+	t.Run("NoTargetClustersConfigured", func(t *testing.T) {
+		vc := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{Host: "vc1"}}
+		result := filterTargetvSANFileShareClusters(ctx, vc, fsEnabledClusterToDsURLsMap)
+		assert.Equal(t, fsEnabledClusterToDsURLsMap, result)
+	})
+
+	t.Run("TargetClustersConfigured", func(t *testing.T) {
+		vc := &cnsvsphere.VirtualCenter{Config: &cnsvsphere.VirtualCenterConfig{
+			Host:                        "vc2",
+			TargetvSANFileShareClusters: []string{" domain-c2", "domain-c3"},
+		}}
+		result := filterTargetvSANFileShareClusters(ctx, vc, fsEnabledClusterToDsURLsMap)
+		assert.Equal(t, map[string][]string{"domain-c2": {"ds:///vmfs/volumes/vsan:2/"}}, result)
+	})
+}
@@ -18,6 +18,7 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -27,6 +28,7 @@ import (
 	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v8/clientset/versioned"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/util/wait"
 	clientset "k8s.io/client-go/kubernetes"
 
@@ -36,6 +38,7 @@ import (
 
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
@@ -225,9 +228,11 @@ func UseVslmAPIs(ctx context.Context, aboutInfo vim25types.AboutInfo) (bool, err
 }
 
 // ValidateControllerExpandVolumeRequest is the helper function to validate
-// ControllerExpandVolumeRequest for all block controllers.
+// ControllerExpandVolumeRequest for all block controllers. File volumes are
+// only accepted when isFileVolumeExpansionSupported is true.
 // Function returns error if validation fails otherwise returns nil.
-func ValidateControllerExpandVolumeRequest(ctx context.Context, req *csi.ControllerExpandVolumeRequest) error {
+func ValidateControllerExpandVolumeRequest(ctx context.Context, req *csi.ControllerExpandVolumeRequest,
+	isFileVolumeExpansionSupported bool) error {
 	log := logger.GetLogger(ctx)
 	// Check for required parameters.
 	if len(req.GetVolumeId()) == 0 {
@@ -243,9 +248,7 @@ func ValidateControllerExpandVolumeRequest(ctx context.Context, req *csi.Control
 		return logger.LogNewErrorCode(log, codes.InvalidArgument, "volume capabilities is a required parameter")
 	}
 
-	// TODO: Remove this restriction when volume expansion is supported for
-	// File Volumes.
-	if IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCaps}) {
+	if !isFileVolumeExpansionSupported && IsFileVolumeRequest(ctx, []*csi.VolumeCapability{volCaps}) {
 		return logger.LogNewErrorCode(log, codes.Unimplemented,
 			"volume expansion is only supported for block volume type")
 	}
@@ -448,3 +451,101 @@ func WaitForVolumeSnapshotDeleted(ctx context.Context, client snapshotterClientS
 
 	return nil
 }
+
+// GetSnapshotLimitForNamespace reads the snapshot limit from ConfigMap in the namespace.
+// Returns the effective limit after applying defaults and absolute max clamping.
+func GetSnapshotLimitForNamespace(ctx context.Context, k8sClient clientset.Interface, namespace string) (int, error) {
+	log := logger.GetLogger(ctx)
+
+	// Get ConfigMap from the namespace
+	cm, err := k8sClient.CoreV1().ConfigMaps(namespace).Get(ctx, ConfigMapCSILimits, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// ConfigMap not found, use default
+			log.Infof("GetSnapshotLimitForNamespace: ConfigMap %s not found in namespace %s, using default: %d",
+				ConfigMapCSILimits, namespace, DefaultMaxSnapshotsPerVolume)
+			return DefaultMaxSnapshotsPerVolume, nil
+		}
+		// Other error occurred
+		log.Errorf("GetSnapshotLimitForNamespace: failed to get ConfigMap %s in namespace %s, err: %v",
+			ConfigMapCSILimits, namespace, err)
+		return 0, err
+	}
+
+	// Check if the key exists in ConfigMap data
+	limitStr, exists := cm.Data[ConfigMapKeyMaxSnapshotsPerVolume]
+	if !exists {
+		// Key not found in ConfigMap, fail the request
+		errMsg := fmt.Sprintf("ConfigMap %s exists in namespace %s but missing required key '%s'",
+			ConfigMapCSILimits, namespace, ConfigMapKeyMaxSnapshotsPerVolume)
+		log.Errorf("GetSnapshotLimitForNamespace: %s", errMsg)
+		return 0, errors.New(errMsg)
+	}
+
+	// Parse the limit value
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 0 {
+		// Invalid value, fail the request
+		errMsg := fmt.Sprintf(
+			"ConfigMap %s in namespace %s has invalid value '%s' for key '%s': must be a non-negative integer",
+			ConfigMapCSILimits, namespace, limitStr, ConfigMapKeyMaxSnapshotsPerVolume)
+		log.Errorf("GetSnapshotLimitForNamespace: %s", errMsg)
+		return 0, errors.New(errMsg)
+	}
+
+	// Clamp to absolute max if exceeded
+	if limit > AbsoluteMaxSnapshotsPerVolume {
+		log.Warnf(
+			"GetSnapshotLimitForNamespace: namespace %s ConfigMap limit %d exceeds absolute max %d, clamping to absolute max",
+			namespace, limit, AbsoluteMaxSnapshotsPerVolume)
+		return AbsoluteMaxSnapshotsPerVolume, nil
+	}
+
+	log.Infof("GetSnapshotLimitForNamespace: namespace %s snapshot limit from ConfigMap: %d", namespace, limit)
+	return limit, nil
+}
+
+// GetMaxSnapshotsPerBlockVolume returns the maximum number of snapshots allowed for a block
+// volume on the given datastore. The global maximum applies by default and is overridden by
+// the granular maximum configured for vSAN or VVOL datastores.
+func GetMaxSnapshotsPerBlockVolume(ctx context.Context, snapshotConfig config.SnapshotConfig,
+	datastoreUrl string) int {
+	log := logger.GetLogger(ctx)
+	maxSnapshotsPerBlockVolume := snapshotConfig.GlobalMaxSnapshotsPerBlockVolume
+	log.Infof("The limit of the maximum number of snapshots per block volume is "+
+		"set to the global maximum (%v) by default.", maxSnapshotsPerBlockVolume)
+
+	var isGranularMaxEnabled bool
+	if strings.Contains(datastoreUrl, strings.ToLower(string(vim25types.HostFileSystemVolumeFileSystemTypeVsan))) {
+		if snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVSAN > 0 {
+			maxSnapshotsPerBlockVolume = snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVSAN
+			isGranularMaxEnabled = true
+		}
+	} else if strings.Contains(datastoreUrl, strings.ToLower(string(vim25types.HostFileSystemVolumeFileSystemTypeVVOL))) {
+		if snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVVOL > 0 {
+			maxSnapshotsPerBlockVolume = snapshotConfig.GranularMaxSnapshotsPerBlockVolumeInVVOL
+			isGranularMaxEnabled = true
+		}
+	}
+	if isGranularMaxEnabled {
+		log.Infof("The limit of the maximum number of snapshots per block volume on datastore %q is "+
+			"overridden by the granular maximum (%v).", datastoreUrl, maxSnapshotsPerBlockVolume)
+	}
+	return maxSnapshotsPerBlockVolume
+}
+
+// GetLinkedClonesCountForSnapshot returns the number of LinkedClone PVs created out of the
+// VolumeSnapshot with the given UID. Such PVs carry the VolumeSnapshot UID as a label.
+func GetLinkedClonesCountForSnapshot(ctx context.Context, k8sClient clientset.Interface,
+	snapshotUID string) (int, error) {
+	labelSelector := labels.SelectorFromSet(map[string]string{
+		VolumeContextAttributeLinkedCloneVolumeSnapshotSourceUID: snapshotUID,
+	})
+	pvList, err := k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{
+		LabelSelector: labelSelector.String(),
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(pvList.Items), nil
+}
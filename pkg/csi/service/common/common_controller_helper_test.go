@@ -20,7 +20,11 @@ import (
 	"context"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	vim25types "github.com/vmware/govmomi/vim25/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 // TestUseVslmAPIsFuncForVC67Update3l tests UseVslmAPIs method for VC version 6.7 Update 3l
@@ -198,3 +202,164 @@ func TestCheckAPIForVC70u3(t *testing.T) {
 		t.Fatalf("CheckAPI method failing for VC %q", vcVersion)
 	}
 }
+
+func TestGetSnapshotLimitForNamespace(t *testing.T) {
+	t.Run("WhenConfigMapExists_ValidValue", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "5",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		limit, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.Nil(t, err)
+		assert.Equal(t, 5, limit)
+	})
+
+	t.Run("WhenConfigMapExists_ValueEqualsMax", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "32",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		limit, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.Nil(t, err)
+		assert.Equal(t, 32, limit)
+	})
+
+	t.Run("WhenConfigMapExists_ValueExceedsMax", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "50",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		limit, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.Nil(t, err)
+		assert.Equal(t, 32, limit) // Should be capped to absolute max
+	})
+
+	t.Run("WhenConfigMapExists_ValueIsZero", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "0",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		limit, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.Nil(t, err)
+		assert.Equal(t, 0, limit) // 0 means block all snapshots
+	})
+
+	t.Run("WhenConfigMapExists_ValueIsNegative", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "-5",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		_, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "invalid value")
+		assert.Contains(t, err.Error(), "must be a non-negative integer")
+	})
+
+	t.Run("WhenConfigMapExists_InvalidFormat", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{
+				ConfigMapKeyMaxSnapshotsPerVolume: "abc",
+			},
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		_, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "invalid value")
+		assert.Contains(t, err.Error(), "must be a non-negative integer")
+	})
+
+	t.Run("WhenConfigMapExists_MissingKey", func(t *testing.T) {
+		// Setup
+		cm := &v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      ConfigMapCSILimits,
+				Namespace: "test-namespace",
+			},
+			Data: map[string]string{}, // ConfigMap exists but key is missing
+		}
+		fakeClient := fake.NewClientset(cm)
+
+		// Execute
+		_, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "missing required key")
+	})
+
+	t.Run("WhenConfigMapNotFound", func(t *testing.T) {
+		// Setup
+		fakeClient := fake.NewClientset() // Empty clientset
+
+		// Execute
+		limit, err := GetSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
+
+		// Verify
+		assert.Nil(t, err)
+		assert.Equal(t, DefaultMaxSnapshotsPerVolume, limit) // Should return default (4)
+	})
+}
@@ -97,12 +97,26 @@ type COCommonInterface interface {
 	// GetPVNameFromCSIVolumeID retrieves the pv name from the volumeID.
 	// This method will not return pv name in case of in-tree migrated volumes
 	GetPVNameFromCSIVolumeID(volumeID string) (string, bool)
+	// GetVolumeAttributes returns the CSI volume attributes recorded on the PV of the given volumeID.
+	GetVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error)
+	// GetPersistentVolumeAnnotations returns the annotations of the PV with the given name.
+	GetPersistentVolumeAnnotations(ctx context.Context, pvName string) (map[string]string, error)
 	// GetPVCNameFromCSIVolumeID returns `pvc name` and `pvc namespace` for the given volumeID using volumeIDToPvcMap.
 	GetPVCNameFromCSIVolumeID(volumeID string) (string, string, bool)
 	// GetVolumeIDFromPVCName returns volumeID for the given pvc name and namespace.
 	GetVolumeIDFromPVCName(namespace string, pvcName string) (string, bool)
 	// InitializeCSINodes creates CSINode instances for each K8s node with the appropriate topology keys.
 	InitializeCSINodes(ctx context.Context) error
+	// UpdateCSINodeAllocatableCount updates the allocatable volume count of the
+	// vSphere CSI driver on the CSINode instance for the given node name.
+	UpdateCSINodeAllocatableCount(ctx context.Context, nodeName string, count int32) error
+	// GetNodeFileVolumeCount returns the number of file volumes published to the given node.
+	GetNodeFileVolumeCount(ctx context.Context, nodeName string) (int, error)
+	// IsNodeOutOfService returns true if the given node is tainted with the
+	// node.kubernetes.io/out-of-service taint.
+	IsNodeOutOfService(ctx context.Context, nodeName string) (bool, error)
+	// RecordNodeEvent records an event with the given type, reason and message on the given node.
+	RecordNodeEvent(ctx context.Context, nodeName string, eventType string, reason string, message string)
 	// StartZonesInformer starts a dynamic informer which listens on Zones CR in
 	// topology.tanzu.vmware.com/v1alpha1 API group.
 	StartZonesInformer(ctx context.Context, restClientConfig *restclient.Config, namespace string) error
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -47,8 +48,11 @@ import (
 	k8stypes "k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/informers"
 	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
 	restclient "k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	clientconfig "sigs.k8s.io/controller-runtime/pkg/client/config"
 	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
@@ -258,6 +262,9 @@ type K8sOrchestrator struct {
 	// this map contains UID of PVC with status Pending.
 	// When PVC's status turn to Bound, it is deleted from the map
 	pvcUIDCache sync.Map // key: PVC UID (string), value: namespaced name (string)
+	// eventRecorder records events on K8s objects. It is created on first use.
+	eventRecorder     record.EventRecorder
+	eventRecorderOnce sync.Once
 }
 
 // K8sGuestInitParams lists the set of parameters required to run the init for
@@ -391,6 +398,12 @@ func Newk8sOrchestrator(ctx context.Context, controllerClusterFlavor cnstypes.Cn
 				}
 			}
 
+			if serviceMode != "node" && operationMode != operationModeWebHookServer {
+				// Register the PV informer before starting the informers, so that
+				// GetVolumeAttributes can read PVs from its cache.
+				k8sOrchestratorInstance.informerManager.GetPVLister()
+			}
+
 			k8sOrchestratorInstance.informerManager.Listen()
 			atomic.StoreUint32(&k8sOrchestratorInstanceInitialized, 1)
 			log.Info("k8sOrchestratorInstance initialized")
@@ -2101,6 +2114,51 @@ func (c *K8sOrchestrator) GetPVNameFromCSIVolumeID(volumeID string) (string, boo
 	return c.volumeIDToNameMap.get(volumeID)
 }
 
+// GetVolumeAttributes returns the CSI volume attributes recorded on the PV of
+// the given volumeID, read from the PV informer cache. volumeIDToNameMap is
+// used to find the PV when it is populated.
+func (c *K8sOrchestrator) GetVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	pvLister := c.informerManager.GetPVLister()
+	if c.volumeIDToNameMap != nil {
+		pvName, ok := c.volumeIDToNameMap.get(volumeID)
+		if !ok {
+			return nil, logger.LogNewErrorf(log, "failed to find PV for volume %q", volumeID)
+		}
+		pv, err := pvLister.Get(pvName)
+		if err != nil {
+			return nil, logger.LogNewErrorf(log, "failed to get PV %q for volume %q. Error: %+v",
+				pvName, volumeID, err)
+		}
+		if pv.Spec.CSI == nil {
+			return nil, nil
+		}
+		return pv.Spec.CSI.VolumeAttributes, nil
+	}
+	pvs, err := pvLister.List(labels.Everything())
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to list PVs. Error: %+v", err)
+	}
+	for _, pv := range pvs {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name && pv.Spec.CSI.VolumeHandle == volumeID {
+			return pv.Spec.CSI.VolumeAttributes, nil
+		}
+	}
+	return nil, logger.LogNewErrorf(log, "failed to find PV for volume %q", volumeID)
+}
+
+// GetPersistentVolumeAnnotations returns the annotations of the PV with the
+// given name, read from the API server.
+func (c *K8sOrchestrator) GetPersistentVolumeAnnotations(ctx context.Context,
+	pvName string) (map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	pv, err := c.k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvName, metav1.GetOptions{})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get PV %q. Error: %+v", pvName, err)
+	}
+	return pv.Annotations, nil
+}
+
 // GetPVCNameFromCSIVolumeID returns `pvc name` and `pvc namespace` for the given volumeID using volumeIDToPvcMap.
 func (c *K8sOrchestrator) GetPVCNameFromCSIVolumeID(volumeID string) (
 	pvcName string, pvcNamespace string, exists bool) {
@@ -2289,6 +2347,119 @@ func (c *K8sOrchestrator) UpdatePersistentVolumeLabel(ctx context.Context,
 	})
 }
 
+// UpdateCSINodeAllocatableCount updates the allocatable volume count of the
+// vSphere CSI driver on the CSINode instance for the given node name.
+// Updating the allocatable count requires the MutableCSINodeAllocatableCount
+// feature gate to be enabled on the API server.
+func (c *K8sOrchestrator) UpdateCSINodeAllocatableCount(ctx context.Context,
+	nodeName string, count int32) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		log := logger.GetLogger(ctx)
+		csiNode, err := c.k8sClient.StorageV1().CSINodes().Get(ctx, nodeName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("error getting CSINode %s from API server: %w", nodeName, err)
+		}
+		driverFound := false
+		for i := range csiNode.Spec.Drivers {
+			driver := &csiNode.Spec.Drivers[i]
+			if driver.Name != common.VSphereCSIDriverName {
+				continue
+			}
+			driverFound = true
+			if driver.Allocatable != nil && driver.Allocatable.Count != nil && *driver.Allocatable.Count == count {
+				log.Debugf("CSINode %s already has allocatable count %d", nodeName, count)
+				return nil
+			}
+			driver.Allocatable = &storagev1.VolumeNodeResources{Count: &count}
+		}
+		if !driverFound {
+			return fmt.Errorf("driver %s is not registered on CSINode %s", common.VSphereCSIDriverName, nodeName)
+		}
+		_, err = c.k8sClient.StorageV1().CSINodes().Update(ctx, csiNode, metav1.UpdateOptions{})
+		if apierrors.IsInvalid(err) {
+			return fmt.Errorf("allocatable count on CSINode %s is immutable, enable the "+
+				"MutableCSINodeAllocatableCount feature gate on the API server. Error: %w", nodeName, err)
+		}
+		if err != nil {
+			log.Errorf("error updating allocatable count on CSINode %s to %d. Error: %v", nodeName, count, err)
+			return err
+		}
+		log.Infof("Successfully updated allocatable count on CSINode %s to %d", nodeName, count)
+		return nil
+	})
+}
+
+// GetNodeFileVolumeCount returns the number of file volumes published to the
+// given node, from the attached volume attachments of the vSphere CSI driver.
+func (c *K8sOrchestrator) GetNodeFileVolumeCount(ctx context.Context, nodeName string) (int, error) {
+	log := logger.GetLogger(ctx)
+	if c.volumeNameToNodesMap == nil {
+		return 0, logger.LogNewErrorf(log, "volume attachments are not tracked by this container orchestrator")
+	}
+	var pvNames []string
+	c.volumeNameToNodesMap.RLock()
+	for pvName, nodes := range c.volumeNameToNodesMap.items {
+		if slices.Contains(nodes, nodeName) {
+			pvNames = append(pvNames, pvName)
+		}
+	}
+	c.volumeNameToNodesMap.RUnlock()
+
+	count := 0
+	pvLister := c.informerManager.GetPVLister()
+	for _, pvName := range pvNames {
+		pv, err := pvLister.Get(pvName)
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return 0, logger.LogNewErrorf(log, "failed to get PV %q. Error: %+v", pvName, err)
+		}
+		if isFileVolume(ctx, pv) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// IsNodeOutOfService returns true if the given node is tainted with the
+// node.kubernetes.io/out-of-service taint.
+func (c *K8sOrchestrator) IsNodeOutOfService(ctx context.Context, nodeName string) (bool, error) {
+	node, err := c.k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("error getting node %s from API server: %w", nodeName, err)
+	}
+	for _, taint := range node.Spec.Taints {
+		if taint.Key == v1.TaintNodeOutOfService {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RecordNodeEvent records an event with the given type, reason and message on
+// the given node. Failures are only logged.
+func (c *K8sOrchestrator) RecordNodeEvent(ctx context.Context, nodeName string, eventType string,
+	reason string, message string) {
+	log := logger.GetLogger(ctx)
+	c.eventRecorderOnce.Do(func() {
+		eventBroadcaster := record.NewBroadcaster()
+		eventBroadcaster.StartRecordingToSink(
+			&typedcorev1.EventSinkImpl{
+				Interface: c.k8sClient.CoreV1().Events(""),
+			},
+		)
+		c.eventRecorder = eventBroadcaster.NewRecorder(scheme.Scheme,
+			v1.EventSource{Component: csitypes.Name})
+	})
+	node, err := c.k8sClient.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("failed to get node %q to record event %q. Error: %v", nodeName, reason, err)
+		return
+	}
+	c.eventRecorder.Event(node, eventType, reason, message)
+}
+
 // GetPVCNamespacedNameByUID returns the PVC's namespaced name (namespace/name) for the given UID.
 // If the PVC is not found in the cache, it returns an empty string and false.
 func (c *K8sOrchestrator) GetPVCNamespacedNameByUID(uid string) (k8stypes.NamespacedName, bool) {
@@ -22,11 +22,14 @@ import (
 	"strconv"
 	"sync"
 	"testing"
+	"time"
 
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
 
 	"github.com/stretchr/testify/assert"
 	"k8s.io/apimachinery/pkg/runtime"
@@ -34,6 +37,7 @@ import (
 	wcpcapv1alph1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/wcpcapabilities/v1alpha1"
 	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
 
 var (
@@ -368,3 +372,87 @@ func TestSetWcpCapabilitiesMap_Success(t *testing.T) {
 	val, _ = WcpCapabilitiesMap.Load("CapabilityB")
 	assert.Equal(t, false, val)
 }
+
+func TestIsNodeOutOfService(t *testing.T) {
+	k8sClient := k8sfake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Spec: v1.NodeSpec{
+				Taints: []v1.Taint{{Key: v1.TaintNodeOutOfService, Effect: v1.TaintEffectNoExecute}},
+			},
+		},
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+		},
+	)
+	orchestrator := &K8sOrchestrator{k8sClient: k8sClient}
+
+	outOfService, err := orchestrator.IsNodeOutOfService(ctx, "node-1")
+	assert.NoError(t, err)
+	assert.True(t, outOfService)
+
+	outOfService, err = orchestrator.IsNodeOutOfService(ctx, "node-2")
+	assert.NoError(t, err)
+	assert.False(t, outOfService)
+
+	_, err = orchestrator.IsNodeOutOfService(ctx, "node-3")
+	assert.Error(t, err)
+}
+
+func TestGetVolumeAttributes(t *testing.T) {
+	ctx := context.Background()
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-1"},
+		Spec: v1.PersistentVolumeSpec{
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{
+					Driver:       "csi.vsphere.vmware.com",
+					VolumeHandle: "vol-1",
+					VolumeAttributes: map[string]string{
+						common.AttributeExpansionMode: common.ExpansionModeOffline,
+					},
+				},
+			},
+		},
+	}
+	informerManager := k8s.NewInformer(ctx, k8sfake.NewSimpleClientset(pv), true)
+	if err := informerManager.AddPVListener(ctx, nil, nil, nil); err != nil {
+		t.Fatalf("failed to add PV listener. Error: %v", err)
+	}
+	informerManager.Listen()
+	err := wait.PollUntilContextTimeout(ctx, 100*time.Millisecond, 10*time.Second, true,
+		func(ctx context.Context) (bool, error) {
+			_, err := informerManager.GetPVLister().Get(pv.Name)
+			return err == nil, nil
+		})
+	if err != nil {
+		t.Fatalf("PV %q was not synced to the informer cache. Error: %v", pv.Name, err)
+	}
+	k8sOrchestrator := K8sOrchestrator{
+		informerManager: informerManager,
+		volumeIDToNameMap: &volumeIDToNameMap{
+			RWMutex: &sync.RWMutex{},
+			items:   map[string]string{"vol-1": pv.Name, "vol-2": "pv-2"},
+		},
+	}
+
+	attributes, err := k8sOrchestrator.GetVolumeAttributes(ctx, "vol-1")
+	assert.NoError(t, err)
+	assert.Equal(t, common.ExpansionModeOffline, attributes[common.AttributeExpansionMode])
+
+	// PV missing from the informer cache.
+	_, err = k8sOrchestrator.GetVolumeAttributes(ctx, "vol-2")
+	assert.Error(t, err)
+
+	// Volume without a PV.
+	_, err = k8sOrchestrator.GetVolumeAttributes(ctx, "vol-3")
+	assert.Error(t, err)
+
+	// volumeIDToNameMap is not populated, e.g. in Guest clusters.
+	k8sOrchestrator.volumeIDToNameMap = nil
+	attributes, err = k8sOrchestrator.GetVolumeAttributes(ctx, "vol-1")
+	assert.NoError(t, err)
+	assert.Equal(t, common.ExpansionModeOffline, attributes[common.AttributeExpansionMode])
+	_, err = k8sOrchestrator.GetVolumeAttributes(ctx, "vol-3")
+	assert.Error(t, err)
+}
@@ -53,6 +53,90 @@ const (
 	// For Example: StoragePolicy: "vSAN Default Storage Policy".
 	AttributeStoragePolicyName = "storagepolicyname"
 
+	// AttributeDiskControllerType represents the type of the virtual controller
+	// to which block volumes of the Storage Class are attached.
+	// For Example: DiskController: "nvme".
+	AttributeDiskControllerType = "diskcontroller"
+
+	// DiskControllerTypePVSCSI represents the ParaVirtual SCSI controller type.
+	DiskControllerTypePVSCSI = "pvscsi"
+
+	// DiskControllerTypeNVMe represents the NVMe controller type.
+	DiskControllerTypeNVMe = "nvme"
+
+	// AttributeExpansionMode represents how block volumes of the Storage Class
+	// may be expanded. For Example: ExpansionMode: "offline".
+	AttributeExpansionMode = "expansionmode"
+
+	// ExpansionModeOnline allows block volumes to be expanded while attached,
+	// provided online expansion is supported by vCenter.
+	ExpansionModeOnline = "online"
+
+	// ExpansionModeOffline only allows block volumes to be expanded while
+	// detached from all nodes.
+	ExpansionModeOffline = "offline"
+
+	// AttributeNfsVersion represents the NFS protocol version file volumes of
+	// the Storage Class are mounted with. For Example: NfsVersion: "3".
+	AttributeNfsVersion = "nfsversion"
+
+	// NfsVersion3 represents the NFSv3 protocol.
+	NfsVersion3 = "3"
+
+	// NfsVersion41 represents the NFSv4.1 protocol, used by default.
+	NfsVersion41 = "4.1"
+
+	// AttributeNfsSecurity represents the NFS security flavor file volumes of
+	// the Storage Class are mounted with. For Example: NfsSecurity: "krb5p".
+	AttributeNfsSecurity = "nfssecurity"
+
+	// NfsSecuritySys represents AUTH_SYS security, used by default.
+	NfsSecuritySys = "sys"
+
+	// NfsSecurityKrb5 represents Kerberos authentication.
+	NfsSecurityKrb5 = "krb5"
+
+	// NfsSecurityKrb5i represents Kerberos authentication with integrity checking.
+	NfsSecurityKrb5i = "krb5i"
+
+	// NfsSecurityKrb5p represents Kerberos authentication with privacy (encryption).
+	NfsSecurityKrb5p = "krb5p"
+
+	// AttributeNetPermissionIPs represents the comma separated IPs, IP ranges
+	// or subnets granted access to file volumes of the Storage Class.
+	// For Example: NetPermissionIPs: "10.20.0.0/16,10.30.1.5".
+	AttributeNetPermissionIPs = "netpermissionips"
+
+	// AttributeNetPermission represents the access level granted to the IPs in
+	// NetPermissionIPs. For Example: NetPermission: "READ_ONLY".
+	AttributeNetPermission = "netpermission"
+
+	// AttributeRootSquash represents whether root access from the IPs in
+	// NetPermissionIPs is squashed. For Example: RootSquash: "true".
+	AttributeRootSquash = "rootsquash"
+
+	// AttributeSoftQuotaPercent represents the soft quota of file volumes of
+	// the Storage Class, in percent of the requested size.
+	// For Example: SoftQuotaPercent: "80".
+	AttributeSoftQuotaPercent = "softquotapercent"
+
+	// AttributeFileBackend represents the backend file volumes of the Storage
+	// Class are provisioned on. For Example: FileBackend: "nfs-subdir".
+	AttributeFileBackend = "filebackend"
+
+	// FileBackendVsanFileService provisions file volumes as vSAN file shares,
+	// used by default.
+	FileBackendVsanFileService = "vsan-file-service"
+
+	// FileBackendNFSSubdir provisions file volumes as directories on an NFS
+	// export given in the NFSExport section of the config.
+	FileBackendNFSSubdir = "nfs-subdir"
+
+	// AttributeNFSExport represents the name of the NFSExport section of the
+	// config nfs-subdir file volumes of the Storage Class are provisioned on.
+	// It can be omitted when a single NFS export is configured.
+	AttributeNFSExport = "nfsexport"
+
 	// AttributeStoragePolicyID represents Storage Policy Id in the Storage Classs.
 	// For Example: StoragePolicyId: "251bce41-cb24-41df-b46b-7c75aed3c4ee".
 	AttributeStoragePolicyID = "storagepolicyid"
@@ -118,6 +202,9 @@ const (
 	// NTFSFsType represents ntfs
 	NTFSFsType = "ntfs"
 
+	// ReFSFsType represents refs
+	ReFSFsType = "refs"
+
 	// NfsFsType represents nfs mount type.
 	NfsFsType = "nfs"
 
@@ -159,6 +246,26 @@ const (
 	// Nfsv4AccessPoint is the access point of file volume.
 	Nfsv4AccessPoint = "Nfsv4AccessPoint"
 
+	// Nfsv3AccessPoint is the NFSv3 access point of file volume.
+	Nfsv3AccessPoint = "Nfsv3AccessPoint"
+
+	// NFSSubdirVolumeIDPrefix is the prefix of the IDs of file volumes
+	// provisioned by the nfs-subdir backend. The ID has the form
+	// "nfs-subdir:<export name>/<directory name>".
+	NFSSubdirVolumeIDPrefix = "nfs-subdir:"
+
+	// AnnFileShareUsedBytes is the PV annotation holding the used capacity of
+	// the vSAN file share backing a file volume, as last queried by the syncer.
+	AnnFileShareUsedBytes = "csi.vsphere.volume/file-share-used-bytes"
+
+	// AnnFileShareQuotaBytes is the PV annotation holding the hard quota of the
+	// vSAN file share backing a file volume, as last queried by the syncer.
+	AnnFileShareQuotaBytes = "csi.vsphere.volume/file-share-quota-bytes"
+
+	// NfsClientIPs is the comma separated list of node IPs added to the ACL
+	// of a file volume when it was published to the node.
+	NfsClientIPs = "NfsClientIPs"
+
 	// MinSupportedVCenterMajor is the minimum, major version of vCenter
 	// on which CNS is supported.
 	MinSupportedVCenterMajor int = 6
@@ -443,6 +550,43 @@ const (
 	CSIInternalGeneratedClusterID = "csi-internal-generated-cluster-id"
 	// TopologyAwareFileVolume enables provisioning of file volumes in a topology enabled environment
 	TopologyAwareFileVolume = "topology-aware-file-volume"
+	// VolumeAttachLimits enables computing the volume attach limit of a node from
+	// the free controller slots of the node VM and publishing it on the CSINode.
+	VolumeAttachLimits = "volume-attach-limits"
+	// ForceDetachOnNodeFailure enables force detaching block volumes from node
+	// VMs which are unavailable and tainted with node.kubernetes.io/out-of-service.
+	ForceDetachOnNodeFailure = "force-detach-on-node-failure"
+	// VanillaStoragePolicyQuota enables StoragePolicyQuota and StoragePolicyUsage
+	// on vanilla clusters to limit the capacity used per namespace and storage policy.
+	VanillaStoragePolicyQuota = "vanilla-storage-policy-quota"
+	// DatastoreDrain enables the DatastoreDrain CR to relocate all volumes from
+	// the datastore backing a StoragePool of any type to other StoragePools.
+	DatastoreDrain = "datastore-drain"
+	// CrossZoneSnapshotRestore enables creating volumes from snapshots on vanilla
+	// clusters on a datastore other than the snapshot datastore, e.g. in a
+	// different zone, which is compatible with the requested topology and policy.
+	CrossZoneSnapshotRestore = "cross-zone-snapshot-restore"
+	// SnapshotPolicy enables the SnapshotPolicy CR to create and prune
+	// VolumeSnapshots of PVCs on a schedule.
+	SnapshotPolicy = "snapshot-policy"
+	// FileVolumeExpansion enables expansion of vSAN file share backed file
+	// volumes on vanilla clusters.
+	FileVolumeExpansion = "file-volume-expansion"
+	// VolumeHealthHistory enables recording volume health transitions on
+	// CNSVolumeInfo CRs and exporting volume inaccessibility metrics.
+	VolumeHealthHistory = "volume-health-history"
+	// FileVolumeNodeACL enables granting access to vanilla file volumes only
+	// to the IPs of the nodes they are published to.
+	FileVolumeNodeACL = "file-volume-node-acl"
+	// FileSharePermissions enables StorageClass parameters overriding the net
+	// permissions and soft quota of vanilla file volumes.
+	FileSharePermissions = "file-share-permissions"
+	// FileVolumeUsage enables reporting the usage of the vSAN file shares
+	// backing vanilla file volumes in NodeGetVolumeStats and metrics.
+	FileVolumeUsage = "file-volume-usage"
+	// PluggableFileBackends enables provisioning vanilla file volumes on
+	// backends other than vSAN File Service, selected by StorageClass.
+	PluggableFileBackends = "pluggable-file-backends"
 	// PodVMOnStretchedSupervisor is the WCP FSS which determines if PodVM
 	// support is available on stretched supervisor cluster.
 	PodVMOnStretchedSupervisor = "PodVM_On_Stretched_Supervisor_Supported"
@@ -85,6 +85,9 @@ type CreateVolumeSpec struct {
 	ContentSourceSnapshotID string // SnapshotID from VolumeContentSource in CreateVolumeRequest
 	CryptoKeyID             *CryptoKeyID
 	IsLinkedCloneRequest    bool
+	// SkipDefaultNetPermission skips the default allow-all net permission for
+	// file volumes whose ACL is managed per node on publish.
+	SkipDefaultNetPermission bool
 }
 
 // StorageClassParams represents the storage class parameterss
@@ -93,6 +96,27 @@ type StorageClassParams struct {
 	StoragePolicyName string
 	CSIMigration      string
 	Datastore         string
+	// DiskControllerType is the type of the controller to attach block volumes to.
+	DiskControllerType string
+	// ExpansionMode is "offline" when block volumes may only be expanded while detached.
+	ExpansionMode string
+	// NfsVersion is the NFS protocol version file volumes are mounted with.
+	NfsVersion string
+	// NfsSecurity is the NFS security flavor file volumes are mounted with.
+	NfsSecurity string
+	// NetPermissionIPs, NetPermission and RootSquash override the net
+	// permissions of the config for file volumes.
+	NetPermissionIPs string
+	NetPermission    string
+	RootSquash       string
+	// SoftQuotaPercent is the soft quota of file volumes in percent of the
+	// requested size.
+	SoftQuotaPercent string
+	// FileBackend is the backend file volumes are provisioned on.
+	FileBackend string
+	// NFSExport is the name of the configured NFS export nfs-subdir file
+	// volumes are provisioned on.
+	NFSExport string
 }
 
 type CryptoKeyID struct {
@@ -29,6 +29,7 @@ import (
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	"github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 	apiMeta "k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -170,6 +171,12 @@ func IsFileVolumeRequest(ctx context.Context, capabilities []*csi.VolumeCapabili
 	return false
 }
 
+// IsNFSSubdirVolumeID checks whether the volume ID belongs to a file volume
+// provisioned by the nfs-subdir backend, which is not a CNS volume.
+func IsNFSSubdirVolumeID(volumeID string) bool {
+	return strings.HasPrefix(volumeID, NFSSubdirVolumeIDPrefix)
+}
+
 // IsVolumeReadOnly checks the access mode in Volume Capability and decides
 // if volume is readonly or not.
 func IsVolumeReadOnly(capability *csi.VolumeCapability) bool {
@@ -202,10 +209,11 @@ func validateVolumeCapabilities(volCaps []*csi.VolumeCapability,
 
 		if volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
 			// For ReadWriteOnce access mode we only support following filesystems:
-			// ext3, ext4, xfs for Linux and ntfs for Windows.
+			// ext3, ext4, xfs for Linux and ntfs, refs for Windows.
 			if volCap.GetMount() != nil && !(volCap.GetMount().FsType == Ext4FsType ||
 				volCap.GetMount().FsType == Ext3FsType || volCap.GetMount().FsType == XFSType ||
-				strings.ToLower(volCap.GetMount().FsType) == NTFSFsType || volCap.GetMount().FsType == "") {
+				strings.ToLower(volCap.GetMount().FsType) == NTFSFsType ||
+				strings.ToLower(volCap.GetMount().FsType) == ReFSFsType || volCap.GetMount().FsType == "") {
 				return fmt.Errorf("fstype %s not supported for ReadWriteOnce volume creation",
 					volCap.GetMount().FsType)
 			}
@@ -239,6 +247,25 @@ func IsValidVolumeCapabilities(ctx context.Context, volCaps []*csi.VolumeCapabil
 	return validateVolumeCapabilities(volCaps, BlockVolumeCaps, BlockVolumeType)
 }
 
+// ValidateBlockVolumeStorageClassParams returns an error if the given
+// StorageClass parameters only apply to file volumes.
+func ValidateBlockVolumeStorageClassParams(scParams *StorageClassParams) error {
+	if HasFileShareParams(scParams) {
+		return fmt.Errorf("params %q, %q, %q and %q are not supported for block volumes",
+			AttributeNetPermissionIPs, AttributeNetPermission, AttributeRootSquash, AttributeSoftQuotaPercent)
+	}
+	return nil
+}
+
+// ValidateFileVolumeStorageClassParams returns an error if the given
+// StorageClass parameters only apply to block volumes.
+func ValidateFileVolumeStorageClassParams(scParams *StorageClassParams) error {
+	if scParams.DiskControllerType != "" {
+		return fmt.Errorf("param %q is not supported for file volumes", AttributeDiskControllerType)
+	}
+	return nil
+}
+
 // ParseStorageClassParams parses the params in the CSI CreateVolumeRequest API
 // call back to StorageClassParams structure.
 func ParseStorageClassParams(ctx context.Context, params map[string]string,
@@ -257,6 +284,26 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if param == AttributeDiskControllerType {
+				scParams.DiskControllerType = strings.ToLower(value)
+			} else if param == AttributeExpansionMode {
+				scParams.ExpansionMode = strings.ToLower(value)
+			} else if param == AttributeNfsVersion {
+				scParams.NfsVersion = value
+			} else if param == AttributeNfsSecurity {
+				scParams.NfsSecurity = strings.ToLower(value)
+			} else if param == AttributeNetPermissionIPs {
+				scParams.NetPermissionIPs = value
+			} else if param == AttributeNetPermission {
+				scParams.NetPermission = strings.ToUpper(value)
+			} else if param == AttributeRootSquash {
+				scParams.RootSquash = strings.ToLower(value)
+			} else if param == AttributeSoftQuotaPercent {
+				scParams.SoftQuotaPercent = value
+			} else if param == AttributeFileBackend {
+				scParams.FileBackend = strings.ToLower(value)
+			} else if param == AttributeNFSExport {
+				scParams.NFSExport = value
 			} else {
 				return nil, fmt.Errorf("invalid param: %q and value: %q", param, value)
 			}
@@ -271,6 +318,26 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 				scParams.StoragePolicyName = value
 			} else if param == AttributeFsType {
 				log.Warnf("param 'fstype' is deprecated, please use 'csi.storage.k8s.io/fstype' instead")
+			} else if param == AttributeDiskControllerType {
+				scParams.DiskControllerType = strings.ToLower(value)
+			} else if param == AttributeExpansionMode {
+				scParams.ExpansionMode = strings.ToLower(value)
+			} else if param == AttributeNfsVersion {
+				scParams.NfsVersion = value
+			} else if param == AttributeNfsSecurity {
+				scParams.NfsSecurity = strings.ToLower(value)
+			} else if param == AttributeNetPermissionIPs {
+				scParams.NetPermissionIPs = value
+			} else if param == AttributeNetPermission {
+				scParams.NetPermission = strings.ToUpper(value)
+			} else if param == AttributeRootSquash {
+				scParams.RootSquash = strings.ToLower(value)
+			} else if param == AttributeSoftQuotaPercent {
+				scParams.SoftQuotaPercent = value
+			} else if param == AttributeFileBackend {
+				scParams.FileBackend = strings.ToLower(value)
+			} else if param == AttributeNFSExport {
+				scParams.NFSExport = value
 			} else if param == CSIMigrationParams {
 				scParams.CSIMigration = value
 			} else {
@@ -301,9 +368,155 @@ func ParseStorageClassParams(ctx context.Context, params map[string]string,
 			}
 		}
 	}
+	if scParams.DiskControllerType != "" && scParams.DiskControllerType != DiskControllerTypePVSCSI &&
+		scParams.DiskControllerType != DiskControllerTypeNVMe {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			scParams.DiskControllerType, AttributeDiskControllerType, DiskControllerTypePVSCSI,
+			DiskControllerTypeNVMe)
+	}
+	if scParams.ExpansionMode != "" && scParams.ExpansionMode != ExpansionModeOnline &&
+		scParams.ExpansionMode != ExpansionModeOffline {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			scParams.ExpansionMode, AttributeExpansionMode, ExpansionModeOnline, ExpansionModeOffline)
+	}
+	if err := validateNfsParams(scParams.NfsVersion, scParams.NfsSecurity); err != nil {
+		return nil, err
+	}
+	if scParams.FileBackend != "" && scParams.FileBackend != FileBackendVsanFileService &&
+		scParams.FileBackend != FileBackendNFSSubdir {
+		return nil, fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			scParams.FileBackend, AttributeFileBackend, FileBackendVsanFileService, FileBackendNFSSubdir)
+	}
+	if scParams.NFSExport != "" && scParams.FileBackend != FileBackendNFSSubdir {
+		return nil, fmt.Errorf("param %q is only supported with param %q set to %q",
+			AttributeNFSExport, AttributeFileBackend, FileBackendNFSSubdir)
+	}
+	if err := ValidateFileShareParams(map[string]string{
+		AttributeNetPermissionIPs: scParams.NetPermissionIPs,
+		AttributeNetPermission:    scParams.NetPermission,
+		AttributeRootSquash:       scParams.RootSquash,
+		AttributeSoftQuotaPercent: scParams.SoftQuotaPercent,
+	}); err != nil {
+		return nil, err
+	}
 	return scParams, nil
 }
 
+// ValidateFileShareParams validates the file share net permission and soft
+// quota parameters in the given Storage Class parameters. Other parameters
+// are ignored.
+func ValidateFileShareParams(params map[string]string) error {
+	for param, value := range params {
+		if value == "" {
+			continue
+		}
+		switch strings.ToLower(param) {
+		case AttributeNetPermissionIPs:
+			for _, ips := range strings.Split(value, ",") {
+				if strings.TrimSpace(ips) == "" {
+					return fmt.Errorf("invalid value %q for param %q. IPs must not be empty",
+						value, AttributeNetPermissionIPs)
+				}
+			}
+		case AttributeNetPermission:
+			switch vsanfstypes.VsanFileShareAccessType(strings.ToUpper(value)) {
+			case vsanfstypes.VsanFileShareAccessTypeREAD_WRITE, vsanfstypes.VsanFileShareAccessTypeREAD_ONLY,
+				vsanfstypes.VsanFileShareAccessTypeNO_ACCESS:
+			default:
+				return fmt.Errorf("invalid value %q for param %q. Supported values are %q, %q and %q",
+					value, AttributeNetPermission, vsanfstypes.VsanFileShareAccessTypeREAD_WRITE,
+					vsanfstypes.VsanFileShareAccessTypeREAD_ONLY, vsanfstypes.VsanFileShareAccessTypeNO_ACCESS)
+			}
+		case AttributeRootSquash:
+			if _, err := strconv.ParseBool(value); err != nil {
+				return fmt.Errorf("invalid value %q for param %q. Value must be a boolean",
+					value, AttributeRootSquash)
+			}
+		case AttributeSoftQuotaPercent:
+			percent, err := strconv.Atoi(value)
+			if err != nil || percent < 1 || percent > 100 {
+				return fmt.Errorf("invalid value %q for param %q. Value must be an integer between 1 and 100",
+					value, AttributeSoftQuotaPercent)
+			}
+		}
+	}
+	return nil
+}
+
+// HasFileShareParams returns true if the Storage Class overrides the net
+// permissions or soft quota of file volumes.
+func HasFileShareParams(scParams *StorageClassParams) bool {
+	return scParams.NetPermissionIPs != "" || scParams.NetPermission != "" || scParams.RootSquash != "" ||
+		scParams.SoftQuotaPercent != ""
+}
+
+// GetFileShareNetPermissions returns the net permissions of file volumes
+// given in the Storage Class, with one entry per IP, IP range or subnet.
+// Unset IPs default to all IPs, unless skipDefault is set as access is then
+// granted to the nodes the volume is published to, and an unset permission
+// defaults to READ_WRITE. Nil is returned if the Storage Class does not
+// override the net permissions.
+func GetFileShareNetPermissions(scParams *StorageClassParams,
+	skipDefault bool) []vsanfstypes.VsanFileShareNetPermission {
+	if scParams.NetPermissionIPs == "" && scParams.NetPermission == "" && scParams.RootSquash == "" {
+		return nil
+	}
+	permission := vsanfstypes.VsanFileShareAccessTypeREAD_WRITE
+	if scParams.NetPermission != "" {
+		permission = vsanfstypes.VsanFileShareAccessType(scParams.NetPermission)
+	}
+	rootSquash, _ := strconv.ParseBool(scParams.RootSquash)
+	netPerms := make([]vsanfstypes.VsanFileShareNetPermission, 0)
+	ipsList := scParams.NetPermissionIPs
+	if ipsList == "" {
+		if skipDefault {
+			return netPerms
+		}
+		ipsList = "*"
+	}
+	for _, ips := range strings.Split(ipsList, ",") {
+		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
+			Ips:         strings.TrimSpace(ips),
+			Permissions: permission,
+			AllowRoot:   !rootSquash,
+		})
+	}
+	return netPerms
+}
+
+// GetFileShareSoftQuotaMB returns the soft quota of a file volume of the given
+// size given in the Storage Class. The size is returned if no soft quota is set.
+func GetFileShareSoftQuotaMB(scParams *StorageClassParams, capacityMB int64) int64 {
+	percent, err := strconv.Atoi(scParams.SoftQuotaPercent)
+	if err != nil || percent < 1 || percent > 100 {
+		return capacityMB
+	}
+	return max(capacityMB*int64(percent)/100, 1)
+}
+
+// validateNfsParams validates the NFS protocol version and security flavor
+// given in the Storage Class. vSAN file shares only support Kerberos over NFSv4.1.
+func validateNfsParams(nfsVersion string, nfsSecurity string) error {
+	if nfsVersion != "" && nfsVersion != NfsVersion3 && nfsVersion != NfsVersion41 {
+		return fmt.Errorf("invalid value %q for param %q. Supported values are %q and %q",
+			nfsVersion, AttributeNfsVersion, NfsVersion3, NfsVersion41)
+	}
+	if nfsSecurity != "" && nfsSecurity != NfsSecuritySys && !IsKerberosNfsSecurity(nfsSecurity) {
+		return fmt.Errorf("invalid value %q for param %q. Supported values are %q, %q, %q and %q",
+			nfsSecurity, AttributeNfsSecurity, NfsSecuritySys, NfsSecurityKrb5, NfsSecurityKrb5i, NfsSecurityKrb5p)
+	}
+	if nfsVersion == NfsVersion3 && IsKerberosNfsSecurity(nfsSecurity) {
+		return fmt.Errorf("param %q value %q is not supported with NFS version %q",
+			AttributeNfsSecurity, nfsSecurity, NfsVersion3)
+	}
+	return nil
+}
+
+// IsKerberosNfsSecurity returns true if the given NFS security flavor uses Kerberos.
+func IsKerberosNfsSecurity(nfsSecurity string) bool {
+	return nfsSecurity == NfsSecurityKrb5 || nfsSecurity == NfsSecurityKrb5i || nfsSecurity == NfsSecurityKrb5p
+}
+
 // GetK8sCloudOperatorServicePort return the port to connect the
 // K8sCloudOperator gRPC service.
 // If environment variable POD_LISTENER_SERVICE_PORT is set and valid,
@@ -20,6 +20,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/agiledragon/gomonkey/v2"
@@ -36,6 +37,7 @@ import (
 	"github.com/stretchr/testify/assert"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
 )
 
 var (
@@ -164,6 +166,22 @@ func TestValidVolumeCapabilitiesForBlock(t *testing.T) {
 	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
 		t.Errorf("Block VolCap = %+v failed validation!", volCap)
 	}
+	// fstype=refs and mode=SINGLE_NODE_WRITER
+	volCap = []*csi.VolumeCapability{
+		{
+			AccessType: &csi.VolumeCapability_Mount{
+				Mount: &csi.VolumeCapability_MountVolume{
+					FsType: "refs",
+				},
+			},
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+	if err := IsValidVolumeCapabilities(ctx, volCap); err != nil {
+		t.Errorf("Block VolCap = %+v failed validation!", volCap)
+	}
 	// volumeMode=block and accessMode=SINGLE_NODE_WRITER
 	volCap = []*csi.VolumeCapability{
 		{
@@ -374,6 +392,15 @@ func isStorageClassParamsEqual(expected *StorageClassParams, actual *StorageClas
 	if expected.StoragePolicyName != actual.StoragePolicyName {
 		return false
 	}
+	if expected.DiskControllerType != actual.DiskControllerType {
+		return false
+	}
+	if expected.ExpansionMode != actual.ExpansionMode {
+		return false
+	}
+	if expected.NfsVersion != actual.NfsVersion || expected.NfsSecurity != actual.NfsSecurity {
+		return false
+	}
 	return true
 }
 
@@ -411,6 +438,160 @@ func TestParseStorageClassParamsWithValidParams(t *testing.T) {
 	}
 }
 
+func TestParseStorageClassParamsWithDiskControllerType(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName:  "policy1",
+		AttributeDiskControllerType: "NVMe",
+	}
+	expectedScParams := &StorageClassParams{
+		StoragePolicyName:  "policy1",
+		DiskControllerType: DiskControllerTypeNVMe,
+	}
+	actualScParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Errorf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if !isStorageClassParamsEqual(expectedScParams, actualScParams) {
+		t.Errorf("Expected: %+v\n Actual: %+v", expectedScParams, actualScParams)
+	}
+
+	params[AttributeDiskControllerType] = "ide"
+	scParam, err := ParseStorageClassParams(ctx, params, true)
+	if err == nil {
+		t.Errorf("error expected but not received. scParam received from ParseStorageClassParams: %v", scParam)
+	}
+}
+
+func TestValidateBlockVolumeStorageClassParams(t *testing.T) {
+	scParams := &StorageClassParams{StoragePolicyName: "policy1", DiskControllerType: DiskControllerTypeNVMe}
+	if err := ValidateBlockVolumeStorageClassParams(scParams); err != nil {
+		t.Errorf("unexpected error for block volume params %+v: %v", scParams, err)
+	}
+	scParams.SoftQuotaPercent = "80"
+	if err := ValidateBlockVolumeStorageClassParams(scParams); err == nil {
+		t.Errorf("expected error for param %q on block volume", AttributeSoftQuotaPercent)
+	}
+}
+
+func TestValidateFileVolumeStorageClassParams(t *testing.T) {
+	scParams := &StorageClassParams{StoragePolicyName: "policy1"}
+	if err := ValidateFileVolumeStorageClassParams(scParams); err != nil {
+		t.Errorf("unexpected error for file volume params %+v: %v", scParams, err)
+	}
+	scParams.DiskControllerType = DiskControllerTypeNVMe
+	if err := ValidateFileVolumeStorageClassParams(scParams); err == nil {
+		t.Errorf("expected error for param %q on file volume", AttributeDiskControllerType)
+	}
+}
+
+func TestParseStorageClassParamsWithExpansionMode(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName: "policy1",
+		AttributeExpansionMode:     "Offline",
+	}
+	expectedScParams := &StorageClassParams{
+		StoragePolicyName: "policy1",
+		ExpansionMode:     ExpansionModeOffline,
+	}
+	actualScParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Errorf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if !isStorageClassParamsEqual(expectedScParams, actualScParams) {
+		t.Errorf("Expected: %+v\n Actual: %+v", expectedScParams, actualScParams)
+	}
+
+	params[AttributeExpansionMode] = "manual"
+	scParam, err := ParseStorageClassParams(ctx, params, true)
+	if err == nil {
+		t.Errorf("error expected but not received. scParam received from ParseStorageClassParams: %v", scParam)
+	}
+}
+
+func TestParseStorageClassParamsWithNfsParams(t *testing.T) {
+	params := map[string]string{
+		AttributeStoragePolicyName: "policy1",
+		AttributeNfsVersion:        NfsVersion41,
+		AttributeNfsSecurity:       "KRB5P",
+	}
+	expectedScParams := &StorageClassParams{
+		StoragePolicyName: "policy1",
+		NfsVersion:        NfsVersion41,
+		NfsSecurity:       NfsSecurityKrb5p,
+	}
+	actualScParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Errorf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if !isStorageClassParamsEqual(expectedScParams, actualScParams) {
+		t.Errorf("Expected: %+v\n Actual: %+v", expectedScParams, actualScParams)
+	}
+
+	invalidParams := []map[string]string{
+		{AttributeNfsVersion: "4"},
+		{AttributeNfsSecurity: "ntlm"},
+		// Kerberos is only supported over NFSv4.1.
+		{AttributeNfsVersion: NfsVersion3, AttributeNfsSecurity: NfsSecurityKrb5},
+	}
+	for _, params := range invalidParams {
+		scParam, err := ParseStorageClassParams(ctx, params, true)
+		if err == nil {
+			t.Errorf("error expected for params %v but not received. scParam received: %v", params, scParam)
+		}
+	}
+}
+
+func TestGetFileShareNetPermissionsAndSoftQuota(t *testing.T) {
+	params := map[string]string{
+		AttributeNetPermissionIPs: "10.20.0.0/16, 10.30.1.5",
+		AttributeNetPermission:    "read_only",
+		AttributeRootSquash:       "true",
+		AttributeSoftQuotaPercent: "80",
+	}
+	scParams, err := ParseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Fatalf("failed to parse params: %+v, err: %+v", params, err)
+	}
+	if !HasFileShareParams(scParams) {
+		t.Errorf("expected file share params to be set in %+v", scParams)
+	}
+	netPerms := GetFileShareNetPermissions(scParams, false)
+	expectedNetPerms := []vsanfstypes.VsanFileShareNetPermission{
+		{Ips: "10.20.0.0/16", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY, AllowRoot: false},
+		{Ips: "10.30.1.5", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY, AllowRoot: false},
+	}
+	if !reflect.DeepEqual(netPerms, expectedNetPerms) {
+		t.Errorf("Expected net permissions: %+v\n Actual: %+v", expectedNetPerms, netPerms)
+	}
+	if softQuota := GetFileShareSoftQuotaMB(scParams, 1024); softQuota != 819 {
+		t.Errorf("expected soft quota 819 MB, got %d", softQuota)
+	}
+
+	// Unset IPs and permission default to READ_WRITE access for all IPs.
+	scParams = &StorageClassParams{RootSquash: "false"}
+	netPerms = GetFileShareNetPermissions(scParams, false)
+	expectedNetPerms = []vsanfstypes.VsanFileShareNetPermission{
+		{Ips: "*", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_WRITE, AllowRoot: true},
+	}
+	if !reflect.DeepEqual(netPerms, expectedNetPerms) {
+		t.Errorf("Expected net permissions: %+v\n Actual: %+v", expectedNetPerms, netPerms)
+	}
+	// Access is not granted to all IPs by default with per-node ACLs.
+	if netPerms := GetFileShareNetPermissions(scParams, true); netPerms == nil || len(netPerms) != 0 {
+		t.Errorf("expected empty net permissions with per-node ACLs, got %+v", netPerms)
+	}
+	netPerms = GetFileShareNetPermissions(&StorageClassParams{NetPermissionIPs: "10.20.0.0/16"}, true)
+	if len(netPerms) != 1 || netPerms[0].Ips != "10.20.0.0/16" {
+		t.Errorf("expected net permission for 10.20.0.0/16 with per-node ACLs, got %+v", netPerms)
+	}
+	if softQuota := GetFileShareSoftQuotaMB(scParams, 1024); softQuota != 1024 {
+		t.Errorf("expected soft quota to default to the requested size, got %d", softQuota)
+	}
+	if netPerms := GetFileShareNetPermissions(&StorageClassParams{}, false); netPerms != nil {
+		t.Errorf("expected no net permissions without file share params, got %+v", netPerms)
+	}
+}
+
 func TestParseStorageClassParamsWithMigrationEnabledNagative(t *testing.T) {
 	csiMigrationFeatureState := true
 	params := map[string]string{
@@ -18,7 +18,10 @@ package common
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -518,28 +521,37 @@ func CreateBlockVolumeUtilForMultiVC(ctx context.Context, reqParams interface{},
 				break
 			}
 		}
-		if !isSharedDatastoreURL {
+		if !isSharedDatastoreURL && !opts.VolFromSnapshotOnTargetDs {
 			return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
 				"failed to get the compatible shared datastore for create volume from snapshot %q in vCenter %q",
 				params.Spec.ContentSourceSnapshotID, params.Vcenter.Config.Host)
 		}
-		// Check if DatastoreURL specified in the StorageClass is present in any one of the datacenters.
-		datastoreInfoObjList, err = getDatastoreInfoObjList(ctx, params.Vcenter, params.SnapshotDatastoreURL)
-		if err != nil {
-			// TODO: Need to figure out which fault need to return when datastore cannot be found in given vCenter.
-			// Currently, just return csi.fault.Internal.
-			return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log, "failed to get datastore "+
-				"object in vCenter %q for datastore URL %q associated with snapshot %q",
-				params.Vcenter.Config.Host, params.SnapshotDatastoreURL, params.Spec.ContentSourceSnapshotID)
-		}
+		if isSharedDatastoreURL {
+			// Check if DatastoreURL specified in the StorageClass is present in any one of the datacenters.
+			datastoreInfoObjList, err = getDatastoreInfoObjList(ctx, params.Vcenter, params.SnapshotDatastoreURL)
+			if err != nil {
+				// TODO: Need to figure out which fault need to return when datastore cannot be found in given vCenter.
+				// Currently, just return csi.fault.Internal.
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log, "failed to get datastore "+
+					"object in vCenter %q for datastore URL %q associated with snapshot %q",
+					params.Vcenter.Config.Host, params.SnapshotDatastoreURL, params.Spec.ContentSourceSnapshotID)
+			}
 
-		log.Infof("Overwrite the datastores field in create spec %+v", createSpec.Datastores)
-		createSpec.Datastores = nil
-		for _, datastoreInfoObj := range datastoreInfoObjList {
-			createSpec.Datastores = append(createSpec.Datastores, datastoreInfoObj.Reference())
-			// overwrite the datastores field in create spec with the compatible datastores
-			log.Infof("add snapshot datastore %v when create volume from snapshot %s", datastoreInfoObj.Reference(),
-				params.Spec.ContentSourceSnapshotID)
+			log.Infof("Overwrite the datastores field in create spec %+v", createSpec.Datastores)
+			createSpec.Datastores = nil
+			for _, datastoreInfoObj := range datastoreInfoObjList {
+				createSpec.Datastores = append(createSpec.Datastores, datastoreInfoObj.Reference())
+				// overwrite the datastores field in create spec with the compatible datastores
+				log.Infof("add snapshot datastore %v when create volume from snapshot %s",
+					datastoreInfoObj.Reference(), params.Spec.ContentSourceSnapshotID)
+			}
+		} else {
+			// The snapshot datastore is not compatible with the requested topology
+			// or storage policy. Restore the snapshot on one of the compatible
+			// shared datastores instead.
+			log.Infof("Snapshot datastore %q is not among the compatible shared datastores in vCenter %q. "+
+				"Creating volume from snapshot %q on target datastores %+v", params.SnapshotDatastoreURL,
+				params.Vcenter.Config.Host, params.Spec.ContentSourceSnapshotID, createSpec.Datastores)
 		}
 	}
 
@@ -633,16 +645,8 @@ func CreateFileVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 		}
 	}
 
-	// Retrieve net permissions from CnsConfig of manager and convert to required
-	// format.
-	netPerms := make([]vsanfstypes.VsanFileShareNetPermission, 0)
-	for _, netPerm := range cnsConfig.NetPermissions {
-		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
-			Ips:         netPerm.Ips,
-			Permissions: netPerm.Permissions,
-			AllowRoot:   !netPerm.RootSquash,
-		})
-	}
+	netPerms := GetFileVolumeBaseNetPermissions(spec.ScParams, cnsConfig.NetPermissions,
+		spec.SkipDefaultNetPermission)
 
 	clusterID := cnsConfig.Global.ClusterID
 	if useSupervisorId {
@@ -668,7 +672,7 @@ func CreateFileVolumeUtil(ctx context.Context, clusterFlavor cnstypes.CnsCluster
 			ContainerClusterArray: containerClusterArray,
 		},
 		CreateSpec: &cnstypes.CnsVSANFileCreateSpec{
-			SoftQuotaInMb: spec.CapacityMB,
+			SoftQuotaInMb: GetFileShareSoftQuotaMB(spec.ScParams, spec.CapacityMB),
 			Permission:    netPerms,
 		},
 	}
@@ -736,6 +740,89 @@ func getHostVsanUUID(ctx context.Context, hostMoID string, vc *vsphere.VirtualCe
 	return nodeUUID, nil
 }
 
+// ConfigureFileVolumeNodeACLUtil adds the given node IPs to, or removes them
+// from, the ACL of the file volume with the given volume ID.
+func ConfigureFileVolumeNodeACLUtil(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	ips []string, readOnly bool, delete bool) error {
+	log := logger.GetLogger(ctx)
+	if len(ips) == 0 {
+		return nil
+	}
+	accessType := vsanfstypes.VsanFileShareAccessTypeREAD_WRITE
+	if readOnly {
+		accessType = vsanfstypes.VsanFileShareAccessTypeREAD_ONLY
+	}
+	netPerms := make([]vsanfstypes.VsanFileShareNetPermission, 0, len(ips))
+	for _, ip := range ips {
+		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
+			Ips:         ip,
+			Permissions: accessType,
+			AllowRoot:   true,
+		})
+	}
+	err := ConfigureFileVolumeNetPermissionsUtil(ctx, volumeManager, volumeID, netPerms, delete)
+	if err != nil {
+		return logger.LogNewErrorf(log, "failed to configure ACLs for file volume %q with IPs %v. Error: %+v",
+			volumeID, ips, err)
+	}
+	return nil
+}
+
+// GetFileVolumeBaseNetPermissions returns the net permissions a file volume
+// is created with: the ones given in the storage class, if any, or else the
+// ones in the CNS config. Access for all IPs is not granted by default with
+// skipDefault, as access is then granted to the nodes the volume is published to.
+func GetFileVolumeBaseNetPermissions(scParams *StorageClassParams,
+	netPermissions map[string]*config.NetPermissionConfig, skipDefault bool) []vsanfstypes.VsanFileShareNetPermission {
+	netPerms := GetFileShareNetPermissions(scParams, skipDefault)
+	if netPerms != nil {
+		return netPerms
+	}
+	netPerms = make([]vsanfstypes.VsanFileShareNetPermission, 0)
+	for key, netPerm := range netPermissions {
+		if skipDefault && key == config.DefaultNetPermissionKey {
+			continue
+		}
+		netPerms = append(netPerms, vsanfstypes.VsanFileShareNetPermission{
+			Ips:         netPerm.Ips,
+			Permissions: netPerm.Permissions,
+			AllowRoot:   !netPerm.RootSquash,
+		})
+	}
+	return netPerms
+}
+
+// ConfigureFileVolumeNetPermissionsUtil adds the given net permissions to, or
+// removes them from, the ACL of the file volume with the given volume ID.
+func ConfigureFileVolumeNetPermissionsUtil(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	netPerms []vsanfstypes.VsanFileShareNetPermission, delete bool) error {
+	log := logger.GetLogger(ctx)
+	spec := getFileVolumeACLSpec(volumeID, netPerms, delete)
+	log.Debugf("Configuring ACLs for file volume %q with spec: %+v", volumeID, spec)
+	err := volumeManager.ConfigureVolumeACLs(ctx, spec)
+	if err != nil {
+		return err
+	}
+	log.Infof("Successfully configured ACLs for file volume %q with net permissions %+v, delete: %t",
+		volumeID, netPerms, delete)
+	return nil
+}
+
+// getFileVolumeACLSpec returns the CnsVolumeACLConfigureSpec adding or
+// removing the given net permissions on the file volume.
+func getFileVolumeACLSpec(volumeID string, netPerms []vsanfstypes.VsanFileShareNetPermission,
+	delete bool) cnstypes.CnsVolumeACLConfigureSpec {
+	return cnstypes.CnsVolumeACLConfigureSpec{
+		VolumeId: cnstypes.CnsVolumeId{Id: volumeID},
+		AccessControlSpecList: []cnstypes.CnsNFSAccessControlSpec{
+			{
+				Permission: netPerms,
+				Delete:     delete,
+			},
+		},
+	}
+}
+
 // AttachVolumeUtil is the helper function to attach CNS volume to specified vm.
 func AttachVolumeUtil(ctx context.Context, volumeManager cnsvolume.Manager,
 	vm *vsphere.VirtualMachine,
@@ -784,6 +871,41 @@ func DeleteVolumeUtil(ctx context.Context, volManager cnsvolume.Manager, volumeI
 	return "", nil
 }
 
+// ValidateVolumeSnapshotsForExpansion returns a FailedPrecondition error
+// listing the CNS snapshots of the given volume, if it has any and volumes with
+// snapshots can not be expanded on the vCenter.
+func ValidateVolumeSnapshotsForExpansion(ctx context.Context, vCenterManager vsphere.VirtualCenterManager,
+	vCenterHost string, volumeManager cnsvolume.Manager, volumeID string) (string, error) {
+	log := logger.GetLogger(ctx)
+	isExpandWithSnapshotsSupported, err := vCenterManager.IsExpandVolumeWithSnapshotsSupported(ctx, vCenterHost)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to check if expansion of volumes with snapshots is supported on VC due to error: %v", err)
+	}
+	if isExpandWithSnapshotsSupported {
+		log.Debugf("volume %s can be expanded regardless of its CNS snapshots on vCenter %q",
+			volumeID, vCenterHost)
+		return "", nil
+	}
+	snapshots, _, err := QueryVolumeSnapshotsByVolumeID(ctx, volumeManager, volumeID, QuerySnapshotLimit)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to retrieve snapshots for volume: %s. Error: %+v", volumeID, err)
+	}
+	if len(snapshots) == 0 {
+		log.Infof("The volume %s can be safely expanded as no CNS snapshots were found.", volumeID)
+		return "", nil
+	}
+	snapshotIDs := make([]string, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		snapshotIDs = append(snapshotIDs, snapshot.SnapshotId)
+	}
+	return csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+		"volume: %s with existing snapshots %v cannot be expanded as vCenter %q does not support "+
+			"expansion of volumes with snapshots. Please delete snapshots before expanding the volume",
+		volumeID, snapshotIDs, vCenterHost)
+}
+
 // ExpandVolumeUtil is the helper function to extend CNS volume for given
 // volumeId.
 func ExpandVolumeUtil(ctx context.Context, vCenterManager vsphere.VirtualCenterManager,
@@ -853,27 +975,8 @@ func ListSnapshotsUtil(ctx context.Context, volManager cnsvolume.Manager, volume
 		return snapshots, "", nil
 	} else if volumeID != "" {
 		// Retrieve all snapshots for a volume-id
-		// Check if the volume-id specified is of Block type.
-		queryFilter := cnstypes.CnsQueryFilter{
-			VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
-		}
-		querySelection := cnstypes.CnsQuerySelection{
-			Names: []string{string(cnstypes.QuerySelectionNameTypeVolumeType)},
-		}
-		// Validate that the volume-id is of block volume type.
-		queryResult, err := utils.QueryVolumeUtil(ctx, volManager, queryFilter, &querySelection)
-		if err != nil {
-			return nil, "", logger.LogNewErrorCodef(log, codes.Internal,
-				"queryVolumeUtil failed with err=%+v", err)
-		}
-
-		if len(queryResult.Volumes) == 0 {
-			return nil, "", logger.LogNewErrorCodef(log, codes.Internal,
-				"volumeID %q not found in QueryVolumeUtil", volumeID)
-		}
-		if queryResult.Volumes[0].VolumeType == FileVolumeType {
-			return nil, "", logger.LogNewErrorCodef(log, codes.Unimplemented,
-				"ListSnapshot for file volume: %q not supported", volumeID)
+		if err := validateBlockVolumeForListSnapshots(ctx, volManager, volumeID); err != nil {
+			return nil, "", err
 		}
 		return QueryVolumeSnapshotsByVolumeID(ctx, volManager, volumeID, maxEntries)
 	} else {
@@ -1082,6 +1185,204 @@ func QueryVolumeSnapshotsByVolumeID(ctx context.Context, volManager cnsvolume.Ma
 	return csiSnapshots, nextToken, nil
 }
 
+// validateBlockVolumeForListSnapshots checks that the volume exists and is of Block type.
+func validateBlockVolumeForListSnapshots(ctx context.Context, volManager cnsvolume.Manager, volumeID string) error {
+	log := logger.GetLogger(ctx)
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypeVolumeType)},
+	}
+	// Validate that the volume-id is of block volume type.
+	queryResult, err := utils.QueryVolumeUtil(ctx, volManager, queryFilter, &querySelection)
+	if err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"queryVolumeUtil failed with err=%+v", err)
+	}
+
+	if len(queryResult.Volumes) == 0 {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"volumeID %q not found in QueryVolumeUtil", volumeID)
+	}
+	if queryResult.Volumes[0].VolumeType == FileVolumeType {
+		return logger.LogNewErrorCodef(log, codes.Unimplemented,
+			"ListSnapshot for file volume: %q not supported", volumeID)
+	}
+	return nil
+}
+
+// listSnapshotsToken is the decoded form of the opaque ListSnapshots token. It
+// holds the vCenter to continue listing from and the CNS QuerySnapshots cursor
+// offset within that vCenter.
+type listSnapshotsToken struct {
+	VCHost string `json:"vc,omitempty"`
+	Offset int64  `json:"offset"`
+}
+
+// EncodeListSnapshotsToken returns the opaque ListSnapshots token for the given
+// vCenter and CNS QuerySnapshots cursor offset.
+func EncodeListSnapshotsToken(vcHost string, offset int64) string {
+	// Marshalling a struct of a string and an integer cannot fail.
+	tokenBytes, _ := json.Marshal(listSnapshotsToken{VCHost: vcHost, Offset: offset})
+	return base64.RawURLEncoding.EncodeToString(tokenBytes)
+}
+
+// DecodeListSnapshotsToken returns the vCenter and CNS QuerySnapshots cursor
+// offset encoded in the ListSnapshots token.
+func DecodeListSnapshotsToken(token string) (string, int64, error) {
+	tokenBytes, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to decode ListSnapshots token %q: %v", token, err)
+	}
+	var decoded listSnapshotsToken
+	if err := json.Unmarshal(tokenBytes, &decoded); err != nil {
+		return "", 0, fmt.Errorf("failed to parse ListSnapshots token %q: %v", token, err)
+	}
+	if decoded.Offset < 0 {
+		return "", 0, fmt.Errorf("ListSnapshots token %q has a negative offset", token)
+	}
+	return decoded.VCHost, decoded.Offset, nil
+}
+
+// QueryVolumeSnapshotsPage returns at most maxEntries snapshots starting at the
+// CNS QuerySnapshots cursor offset. If volumeID is set, only snapshots of that
+// volume are queried. Filtering and paging are both done by CNS, so only the
+// requested page is held in memory. The returned offset is the cursor offset
+// to continue from, or 0 if all snapshots have been returned.
+func QueryVolumeSnapshotsPage(ctx context.Context, volManager cnsvolume.Manager, volumeID string,
+	offset int64, maxEntries int64) ([]*csi.Snapshot, int64, error) {
+	log := logger.GetLogger(ctx)
+	queryFilter := cnstypes.CnsSnapshotQueryFilter{}
+	if volumeID != "" {
+		if err := validateBlockVolumeForListSnapshots(ctx, volManager, volumeID); err != nil {
+			return nil, 0, err
+		}
+		queryFilter.SnapshotQuerySpecs = []cnstypes.CnsSnapshotQuerySpec{
+			{VolumeId: cnstypes.CnsVolumeId{Id: volumeID}},
+		}
+	}
+	var queryResultEntries []cnstypes.CnsSnapshotQueryResultEntry
+	nextOffset := int64(0)
+	for int64(len(queryResultEntries)) < maxEntries {
+		queryFilter.Cursor = &cnstypes.CnsCursor{
+			Offset: offset,
+			Limit:  min(maxEntries-int64(len(queryResultEntries)), QuerySnapshotLimit),
+		}
+		queryResult, err := volManager.QuerySnapshots(ctx, queryFilter)
+		if err != nil {
+			return nil, 0, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to query snapshots at offset %d, err: %+v", offset, err)
+		}
+		remaining := maxEntries - int64(len(queryResultEntries))
+		if queryResult.Cursor.TotalRecords == 0 && len(queryResult.Entries) != 0 {
+			// The cursor was not honored and all entries were returned at once.
+			// Page through them in a stable order instead.
+			entries := queryResult.Entries
+			slices.SortStableFunc(entries, compareSnapshotQueryResultEntries)
+			start := min(offset, int64(len(entries)))
+			end := min(start+remaining, int64(len(entries)))
+			queryResultEntries = append(queryResultEntries, entries[start:end]...)
+			nextOffset = 0
+			if end < int64(len(entries)) {
+				nextOffset = end
+			}
+			break
+		}
+		// Never serve more than requested, even if more entries are returned. The
+		// offset to continue from is derived from the entries actually served.
+		entries := queryResult.Entries
+		if int64(len(entries)) > remaining {
+			entries = entries[:remaining]
+		}
+		queryResultEntries = append(queryResultEntries, entries...)
+		offset += int64(len(entries))
+		// Stop once all records have been returned or no more entries are returned.
+		if len(entries) == 0 || offset >= queryResult.Cursor.TotalRecords {
+			nextOffset = 0
+			break
+		}
+		nextOffset = offset
+	}
+	snapshots, err := snapshotQueryEntriesToCSISnapshots(ctx, volManager, queryResultEntries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return snapshots, nextOffset, nil
+}
+
+// compareSnapshotQueryResultEntries orders snapshot query result entries by
+// creation time, volume ID and snapshot ID. Faults are ordered last.
+func compareSnapshotQueryResultEntries(a, b cnstypes.CnsSnapshotQueryResultEntry) int {
+	if (a.Error == nil) != (b.Error == nil) {
+		if a.Error == nil {
+			return -1
+		}
+		return 1
+	}
+	if c := a.Snapshot.CreateTime.Compare(b.Snapshot.CreateTime); c != 0 {
+		return c
+	}
+	if c := strings.Compare(a.Snapshot.VolumeId.Id, b.Snapshot.VolumeId.Id); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Snapshot.SnapshotId.Id, b.Snapshot.SnapshotId.Id)
+}
+
+// snapshotQueryEntriesToCSISnapshots converts the CNS QuerySnapshots result
+// entries to CSI snapshots. Entries for volumes which were not found are skipped.
+func snapshotQueryEntriesToCSISnapshots(ctx context.Context, volManager cnsvolume.Manager,
+	queryResultEntries []cnstypes.CnsSnapshotQueryResultEntry) ([]*csi.Snapshot, error) {
+	log := logger.GetLogger(ctx)
+	var (
+		csiSnapshots []*csi.Snapshot
+		volumeIds    []cnstypes.CnsVolumeId
+		snapEntries  []cnstypes.CnsSnapshotQueryResultEntry
+	)
+	volumeIdSet := make(map[string]struct{})
+	for _, queryResult := range queryResultEntries {
+		if queryResult.Error != nil {
+			if faultInfo, ok := queryResult.Error.Fault.(*cnstypes.CnsVolumeNotFoundFault); ok {
+				log.Warnf("volume %s was not found during QuerySnapshots, ignore volume..", faultInfo.VolumeId.Id)
+				continue
+			}
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"unexpected fault %+v received in QuerySnapshots result: %+v", queryResult.Error.Fault, queryResult)
+		}
+		snapEntries = append(snapEntries, queryResult)
+		if _, ok := volumeIdSet[queryResult.Snapshot.VolumeId.Id]; !ok {
+			volumeIdSet[queryResult.Snapshot.VolumeId.Id] = struct{}{}
+			volumeIds = append(volumeIds, queryResult.Snapshot.VolumeId)
+		}
+	}
+	if len(snapEntries) == 0 {
+		return csiSnapshots, nil
+	}
+	// Retrieve the volume size as an approximation for snapshot size.
+	// TODO: Retrieve Snapshot size directly from CnsQuerySnapshot once supported.
+	cnsVolumeDetailsMap, err := utils.QueryVolumeDetailsUtil(ctx, volManager, volumeIds)
+	if err != nil {
+		log.Errorf("failed to retrieve volume details for volume-ids: %v, err: %+v", volumeIds, err)
+		return nil, err
+	}
+	for _, queryResult := range snapEntries {
+		volumeDetails, ok := cnsVolumeDetailsMap[queryResult.Snapshot.VolumeId.Id]
+		if !ok {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"cns query volume did not return the volume: %s", queryResult.Snapshot.VolumeId.Id)
+		}
+		csiSnapshots = append(csiSnapshots, &csi.Snapshot{
+			SnapshotId: queryResult.Snapshot.VolumeId.Id + VSphereCSISnapshotIdDelimiter +
+				queryResult.Snapshot.SnapshotId.Id,
+			SourceVolumeId: queryResult.Snapshot.VolumeId.Id,
+			CreationTime:   timestamppb.New(queryResult.Snapshot.CreateTime),
+			SizeBytes:      volumeDetails.SizeInMB * MbInBytes,
+			ReadyToUse:     true,
+		})
+	}
+	return csiSnapshots, nil
+}
+
 // queryVolumeByIDInternal is the internal implementation that can be overridden for testing
 var queryVolumeByIDInternal = func(ctx context.Context, volManager cnsvolume.Manager, volumeID string,
 	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsVolume, error) {
@@ -2,6 +2,7 @@ package common
 
 import (
 	"context"
+	"fmt"
 	"runtime"
 	"testing"
 
@@ -10,9 +11,13 @@ import (
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeoperationrequest"
 )
@@ -21,6 +26,9 @@ import (
 type mockVolumeManager struct {
 	createVolumeFunc func(ctx context.Context, spec *cnstypes.CnsVolumeCreateSpec,
 		extraParams interface{}) (*cnsvolume.CnsVolumeInfo, string, error)
+	querySnapshotsFunc func(ctx context.Context,
+		snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter) (*cnstypes.CnsSnapshotQueryResult, error)
+	configureVolumeACLsFunc func(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error
 }
 
 func (m *mockVolumeManager) UnregisterVolume(ctx context.Context, volumeID string,
@@ -84,6 +92,9 @@ func (m *mockVolumeManager) ResetManager(ctx context.Context, vcenter *vsphere.V
 	return nil
 }
 func (m *mockVolumeManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+	if m.configureVolumeACLsFunc != nil {
+		return m.configureVolumeACLsFunc(ctx, spec)
+	}
 	return nil
 }
 func (m *mockVolumeManager) RegisterDisk(ctx context.Context, path string, name string) (string, error) {
@@ -106,6 +117,9 @@ func (m *mockVolumeManager) DeleteSnapshot(ctx context.Context, volumeID string,
 }
 func (m *mockVolumeManager) QuerySnapshots(ctx context.Context,
 	snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter) (*cnstypes.CnsSnapshotQueryResult, error) {
+	if m.querySnapshotsFunc != nil {
+		return m.querySnapshotsFunc(ctx, snapshotQueryFilter)
+	}
 	return nil, nil
 }
 func (m *mockVolumeManager) IsListViewReady() bool {
@@ -127,6 +141,11 @@ func (m *mockVolumeManager) BatchAttachVolumes(ctx context.Context,
 	return []cnsvolume.BatchAttachResult{}, "", nil
 }
 
+func (m *mockVolumeManager) ExecuteBulkOperation(ctx context.Context,
+	request *cnsvolume.BulkOperationRequest) ([]cnsvolume.BulkOperationResult, error) {
+	return []cnsvolume.BulkOperationResult{}, nil
+}
+
 func (m *mockVolumeManager) SyncVolume(ctx context.Context,
 	syncVolumeSpecs []cnstypes.CnsSyncVolumeSpec) (string, error) {
 	return "", nil
@@ -423,3 +442,244 @@ func TestCreateBlockVolumeFromSnapshotTargetDatastore(t *testing.T) {
 		})
 	}
 }
+
+// TestCreateBlockVolumeUtilForMultiVCFromSnapshotOnTargetDatastore verifies that a snapshot
+// whose datastore is not compatible with the requested topology is restored on the compatible
+// shared datastores only when VolFromSnapshotOnTargetDs is set.
+func TestCreateBlockVolumeUtilForMultiVCFromSnapshotOnTargetDatastore(t *testing.T) {
+	targetDatastoreMoRef := types.ManagedObjectReference{Type: "Datastore", Value: "datastore-target"}
+	targetDatastoreInfo := &vsphere.DatastoreInfo{
+		Datastore: &vsphere.Datastore{Datastore: object.NewDatastore(nil, targetDatastoreMoRef)},
+		Info:      &types.DatastoreInfo{Url: "ds:///vmfs/volumes/target-datastore/"},
+	}
+	cnsConfig := &config.Config{}
+	cnsConfig.Global.ClusterID = "test-cluster"
+	cnsConfig.VirtualCenter = map[string]*config.VirtualCenterConfig{
+		"test-vc": {User: "test-user"},
+	}
+
+	testCases := []struct {
+		name                      string
+		volFromSnapshotOnTargetDs bool
+		expectError               bool
+	}{
+		{
+			name:                      "Feature disabled - should fail",
+			volFromSnapshotOnTargetDs: false,
+			expectError:               true,
+		},
+		{
+			name:                      "Feature enabled - should restore on target datastore",
+			volFromSnapshotOnTargetDs: true,
+			expectError:               false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var capturedCreateSpec *cnstypes.CnsVolumeCreateSpec
+			mockVolumeManager := &mockVolumeManager{
+				createVolumeFunc: func(_ context.Context, spec *cnstypes.CnsVolumeCreateSpec,
+					_ interface{}) (*cnsvolume.CnsVolumeInfo, string, error) {
+					capturedCreateSpec = spec
+					return &cnsvolume.CnsVolumeInfo{VolumeID: cnstypes.CnsVolumeId{Id: "test-volume-id"}}, "", nil
+				},
+			}
+			params := VanillaCreateBlockVolParamsForMultiVC{
+				Vcenter: &vsphere.VirtualCenter{
+					Config: &vsphere.VirtualCenterConfig{Host: "test-vc"},
+				},
+				VolumeManager: mockVolumeManager,
+				CNSConfig:     cnsConfig,
+				Spec: &CreateVolumeSpec{
+					Name:                    "test-volume",
+					CapacityMB:              1024,
+					VolumeType:              BlockVolumeType,
+					ContentSourceSnapshotID: "source-volume-id+snapshot-id",
+					ScParams:                &StorageClassParams{},
+				},
+				SharedDatastores:     []*vsphere.DatastoreInfo{targetDatastoreInfo},
+				SnapshotDatastoreURL: "ds:///vmfs/volumes/snapshot-datastore/",
+				ClusterFlavor:        cnstypes.CnsClusterFlavorVanilla,
+			}
+
+			volumeInfo, _, err := CreateBlockVolumeUtilForMultiVC(context.Background(), params,
+				CreateBlockVolumeOptions{VolFromSnapshotOnTargetDs: tc.volFromSnapshotOnTargetDs})
+			if tc.expectError {
+				assert.Error(t, err)
+				assert.Nil(t, capturedCreateSpec, "CreateVolume should not have been called")
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "test-volume-id", volumeInfo.VolumeID.Id)
+			assert.Equal(t, []types.ManagedObjectReference{targetDatastoreMoRef}, capturedCreateSpec.Datastores)
+			assert.NotNil(t, capturedCreateSpec.VolumeSource)
+		})
+	}
+}
+
+func TestListSnapshotsToken(t *testing.T) {
+	token := EncodeListSnapshotsToken("vc1.example.com", 128)
+	vcHost, offset, err := DecodeListSnapshotsToken(token)
+	assert.NoError(t, err)
+	assert.Equal(t, "vc1.example.com", vcHost)
+	assert.Equal(t, int64(128), offset)
+
+	_, _, err = DecodeListSnapshotsToken("10")
+	assert.Error(t, err)
+	_, _, err = DecodeListSnapshotsToken(EncodeListSnapshotsToken("vc1.example.com", -1))
+	assert.Error(t, err)
+}
+
+// TestQueryVolumeSnapshotsPage tests that QueryVolumeSnapshotsPage serves snapshots
+// page by page using the CNS cursor.
+func TestQueryVolumeSnapshotsPage(t *testing.T) {
+	// Skip test on ARM64 due to gomonkey limitations
+	if runtime.GOARCH == "arm64" {
+		t.Skip("Skipping test on ARM64 due to gomonkey function patching limitations")
+	}
+	volumeID := "volume-1"
+	var allEntries []cnstypes.CnsSnapshotQueryResultEntry
+	for i := 0; i < 5; i++ {
+		allEntries = append(allEntries, cnstypes.CnsSnapshotQueryResultEntry{
+			Snapshot: cnstypes.CnsSnapshot{
+				VolumeId:   cnstypes.CnsVolumeId{Id: volumeID},
+				SnapshotId: cnstypes.CnsSnapshotId{Id: fmt.Sprintf("snapshot-%d", i)},
+			},
+		})
+	}
+	volManager := &mockVolumeManager{
+		querySnapshotsFunc: func(_ context.Context,
+			filter cnstypes.CnsSnapshotQueryFilter) (*cnstypes.CnsSnapshotQueryResult, error) {
+			start := min(filter.Cursor.Offset, int64(len(allEntries)))
+			end := min(start+filter.Cursor.Limit, int64(len(allEntries)))
+			return &cnstypes.CnsSnapshotQueryResult{
+				Entries: allEntries[start:end],
+				Cursor: cnstypes.CnsCursor{
+					Offset:       end,
+					Limit:        filter.Cursor.Limit,
+					TotalRecords: int64(len(allEntries)),
+				},
+			}, nil
+		},
+	}
+	patches := gomonkey.ApplyFunc(utils.QueryVolumeDetailsUtil, func(_ context.Context, _ cnsvolume.Manager,
+		_ []cnstypes.CnsVolumeId) (map[string]*utils.CnsVolumeDetails, error) {
+		return map[string]*utils.CnsVolumeDetails{
+			volumeID: {VolumeID: volumeID, SizeInMB: 1024},
+		}, nil
+	})
+	defer patches.Reset()
+
+	var snapshotIDs []string
+	offset := int64(0)
+	for numOfPages := 1; ; numOfPages++ {
+		snapshots, nextOffset, err := QueryVolumeSnapshotsPage(context.TODO(), volManager, "", offset, 2)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, len(snapshots), 2)
+		for _, snapshot := range snapshots {
+			snapshotIDs = append(snapshotIDs, snapshot.SnapshotId)
+		}
+		if nextOffset == 0 {
+			assert.Equal(t, 3, numOfPages)
+			break
+		}
+		offset = nextOffset
+	}
+	assert.Equal(t, []string{
+		volumeID + "+snapshot-0", volumeID + "+snapshot-1", volumeID + "+snapshot-2",
+		volumeID + "+snapshot-3", volumeID + "+snapshot-4",
+	}, snapshotIDs)
+}
+
+func TestConfigureFileVolumeNodeACLUtil(t *testing.T) {
+	ctx := context.Background()
+	var specs []cnstypes.CnsVolumeACLConfigureSpec
+	mockVolManager := &mockVolumeManager{
+		configureVolumeACLsFunc: func(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+			specs = append(specs, spec)
+			return nil
+		},
+	}
+
+	// No ACL update is made when the node has no IPs.
+	err := ConfigureFileVolumeNodeACLUtil(ctx, mockVolManager, "file:vol-1", nil, false, false)
+	assert.NoError(t, err)
+	assert.Empty(t, specs)
+
+	err = ConfigureFileVolumeNodeACLUtil(ctx, mockVolManager, "file:vol-1",
+		[]string{"10.0.0.1", "10.0.0.2"}, true, false)
+	assert.NoError(t, err)
+	assert.Len(t, specs, 1)
+	assert.Equal(t, "file:vol-1", specs[0].VolumeId.Id)
+	assert.Len(t, specs[0].AccessControlSpecList, 1)
+	accessControlSpec := specs[0].AccessControlSpecList[0]
+	assert.False(t, accessControlSpec.Delete)
+	assert.Len(t, accessControlSpec.Permission, 2)
+	for i, ip := range []string{"10.0.0.1", "10.0.0.2"} {
+		assert.Equal(t, ip, accessControlSpec.Permission[i].Ips)
+		assert.Equal(t, vsanfstypes.VsanFileShareAccessTypeREAD_ONLY, accessControlSpec.Permission[i].Permissions)
+		assert.True(t, accessControlSpec.Permission[i].AllowRoot)
+	}
+
+	err = ConfigureFileVolumeNodeACLUtil(ctx, mockVolManager, "file:vol-1", []string{"10.0.0.1"}, false, true)
+	assert.NoError(t, err)
+	assert.Len(t, specs, 2)
+	assert.True(t, specs[1].AccessControlSpecList[0].Delete)
+	assert.Equal(t, vsanfstypes.VsanFileShareAccessTypeREAD_WRITE,
+		specs[1].AccessControlSpecList[0].Permission[0].Permissions)
+
+	mockVolManager.configureVolumeACLsFunc = func(ctx context.Context,
+		spec cnstypes.CnsVolumeACLConfigureSpec) error {
+		return fmt.Errorf("CNS fault")
+	}
+	err = ConfigureFileVolumeNodeACLUtil(ctx, mockVolManager, "file:vol-1", []string{"10.0.0.1"}, false, false)
+	assert.Error(t, err)
+}
+
+// fakeVCenterManager reports whether volumes with snapshots can be expanded
+// based on the version of a fake vCenter.
+type fakeVCenterManager struct {
+	vsphere.VirtualCenterManager
+	about types.AboutInfo
+}
+
+func (m *fakeVCenterManager) IsExpandVolumeWithSnapshotsSupported(ctx context.Context,
+	host string) (bool, error) {
+	return vsphere.IsvSphereVersion80U3orAbove(ctx, m.about)
+}
+
+func TestValidateVolumeSnapshotsForExpansion(t *testing.T) {
+	// Skip test on ARM64 due to gomonkey limitations
+	if runtime.GOARCH == "arm64" {
+		t.Skip("Skipping test on ARM64 due to gomonkey function patching limitations")
+	}
+	ctx := context.Background()
+	volumeID := "vol-1"
+	patches := gomonkey.ApplyFunc(utils.QuerySnapshotsUtil, func(_ context.Context, _ cnsvolume.Manager,
+		_ cnstypes.CnsSnapshotQueryFilter, _ int64) ([]cnstypes.CnsSnapshotQueryResultEntry, string, error) {
+		return []cnstypes.CnsSnapshotQueryResultEntry{{
+			Snapshot: cnstypes.CnsSnapshot{
+				SnapshotId: cnstypes.CnsSnapshotId{Id: "snap-1"},
+				VolumeId:   cnstypes.CnsVolumeId{Id: volumeID},
+			},
+		}}, "", nil
+	})
+	defer patches.Reset()
+	patches.ApplyFunc(utils.QueryVolumeDetailsUtil, func(_ context.Context, _ cnsvolume.Manager,
+		_ []cnstypes.CnsVolumeId) (map[string]*utils.CnsVolumeDetails, error) {
+		return map[string]*utils.CnsVolumeDetails{volumeID: {VolumeID: volumeID, SizeInMB: 1024}}, nil
+	})
+
+	// Volumes with snapshots can be expanded on vSphere 8.0U3 and above.
+	vCenterManager := &fakeVCenterManager{about: types.AboutInfo{Version: "8.0.3"}}
+	faultType, err := ValidateVolumeSnapshotsForExpansion(ctx, vCenterManager, "vc1", &mockVolumeManager{}, volumeID)
+	assert.NoError(t, err)
+	assert.Empty(t, faultType)
+
+	// Volumes with snapshots can not be expanded on older vCenters.
+	vCenterManager = &fakeVCenterManager{about: types.AboutInfo{Version: "8.0.2"}}
+	faultType, err = ValidateVolumeSnapshotsForExpansion(ctx, vCenterManager, "vc1", &mockVolumeManager{}, volumeID)
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Contains(t, err.Error(), volumeID+VSphereCSISnapshotIdDelimiter+"snap-1")
+	assert.Equal(t, csifault.CSIInvalidArgumentFault, faultType)
+}
@@ -21,11 +21,15 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/davecgh/go-spew/spew"
 
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 
 	disk "github.com/kubernetes-csi/csi-proxy/v2/pkg/disk"
@@ -45,12 +49,38 @@ import (
 // black assignment is used to check if it can be cast
 var _ CSIProxyMounter = &csiProxyMounter{}
 
+// rawDiskDevicePathPrefix is the prefix of the device path of a disk, which
+// is followed by the disk number.
+const rawDiskDevicePathPrefix = `\\.\PHYSICALDRIVE`
+
+// setDiskState sets the offline/online state of a disk. It is a variable so
+// that unit tests can replace it.
+var setDiskState = SetDiskState
+
+// isDiskReadOnly checks if a disk is read-only. It is a variable so that unit
+// tests can replace it.
+var isDiskReadOnly = IsDiskReadOnly
+
+// diskReadOnlyCacheTTL is the time the read-only state of a disk is cached
+// for, as CSI Proxy does not report it and it is fetched with PowerShell.
+const diskReadOnlyCacheTTL = 5 * time.Minute
+
+// diskReadOnlyState is the cached read-only state of a disk.
+type diskReadOnlyState struct {
+	isReadOnly bool
+	fetchedAt  time.Time
+}
+
 type csiProxyMounter struct {
 	Ctx          context.Context
 	FsClient     fs.Interface
 	DiskClient   disk.Interface
 	VolumeClient volume.Interface
 	SystemClient systemApi.Interface
+	// diskReadOnlyCache caches the read-only state of disks by disk number.
+	diskReadOnlyCache map[uint32]diskReadOnlyState
+	// diskReadOnlyLock protects diskReadOnlyCache.
+	diskReadOnlyLock sync.Mutex
 }
 
 // CSIProxyMounter extends the mount.Interface interface with CSI Proxy methods.
@@ -81,6 +111,20 @@ type CSIProxyMounter interface {
 	StatFS(ctx context.Context, path string) (available, capacity, used, inodesFree, inodes, inodesUsed int64, err error)
 	// GetBIOSSerialNumber - Get bios serial number
 	GetBIOSSerialNumber(ctx context.Context) (string, error)
+	// IsMountedFolder checks if the given directory is a volume mount point.
+	IsMountedFolder(path string) (bool, error)
+	// PublishRawDisk brings the disk online and links the target path to it as a raw block device.
+	PublishRawDisk(ctx context.Context, diskNumber string, target string) error
+	// UnpublishRawDisk removes the link of a raw block device and takes the disk offline once it is unused.
+	UnpublishRawDisk(ctx context.Context, target string) (bool, error)
+	// IsRawDiskLink checks if the given path links to a raw block device.
+	IsRawDiskLink(path string) bool
+	// GetDiskNumberFromPath returns the number of the disk backing the volume or raw block device at the path.
+	GetDiskNumberFromPath(ctx context.Context, path string) (uint32, error)
+	// GetDiskSizeInBytes returns the size of the disk with the given number.
+	GetDiskSizeInBytes(ctx context.Context, diskNumber uint32) (int64, error)
+	// GetDiskCondition returns the online and read-only state of the disk with the given number.
+	GetDiskCondition(ctx context.Context, diskNumber uint32) (isOnline bool, isReadOnly bool, err error)
 }
 
 // NewSafeMounter returns mounter with exec
@@ -302,6 +346,7 @@ func (mounter *csiProxyMounter) FormatAndMount(ctx context.Context, source strin
 		IsOnline:   true,
 	}
 	err = SetDiskState(ctx, attachRequest)
+	mounter.forgetDiskReadOnly(attachRequest.DiskNumber)
 	if err != nil {
 		log.Errorf("failed to set disk state as online for disk: %d, err: %v", attachRequest.DiskNumber, err)
 		return err
@@ -333,11 +378,15 @@ func (mounter *csiProxyMounter) FormatAndMount(ctx context.Context, source strin
 	// If the volume is not formatted, then format it, else proceed to mount.
 	if !isVolumeFormattedResponse.Formatted {
 		log.Infof("volumeID is not formatted : %s", volumeID)
-		formatVolumeRequest := &volume.FormatVolumeRequest{
-			VolumeID: volumeID,
-			// TODO: Accept the filesystem and other options
+		if strings.ToLower(fstype) == common.ReFSFsType {
+			// The volume API of CSI Proxy only formats volumes with NTFS.
+			err = FormatVolume(ctx, volumeID, common.ReFSFsType)
+		} else {
+			formatVolumeRequest := &volume.FormatVolumeRequest{
+				VolumeID: volumeID,
+			}
+			_, err = mounter.VolumeClient.FormatVolume(ctx, formatVolumeRequest)
 		}
-		_, err = mounter.VolumeClient.FormatVolume(ctx, formatVolumeRequest)
 		if err != nil {
 			return err
 		}
@@ -455,7 +504,9 @@ func (mounter *csiProxyMounter) ResizeVolume(ctx context.Context, devicePath str
 		DiskNumber: diskNumber,
 		IsOnline:   true,
 	}
-	if err = SetDiskState(ctx, setDiskStateRequest); err != nil {
+	err = SetDiskState(ctx, setDiskStateRequest)
+	mounter.forgetDiskReadOnly(diskNumber)
+	if err != nil {
 		log.Errorf("failed to set disk state as Online for disk: %d, err: %v", setDiskStateRequest.DiskNumber, err)
 		return err
 	}
@@ -502,14 +553,21 @@ func (mounter *csiProxyMounter) GetDiskTotalBytes(ctx context.Context, volumeId
 	}
 	diskNumber := getDiskNumberResponse.DiskNumber
 
-	DiskStatsResponse, err := mounter.DiskClient.GetDiskStats(ctx,
+	return mounter.GetDiskSizeInBytes(ctx, diskNumber)
+}
+
+// GetDiskSizeInBytes returns the size of the disk with the given number.
+func (mounter *csiProxyMounter) GetDiskSizeInBytes(ctx context.Context, diskNumber uint32) (int64, error) {
+	log := logger.GetLogger(ctx)
+	diskStatsResponse, err := mounter.DiskClient.GetDiskStats(ctx,
 		&disk.GetDiskStatsRequest{
 			DiskNumber: diskNumber,
 		})
 	if err != nil {
 		log.Errorf("failed to get disk stats for disk number: %d, err: %v", diskNumber, err)
+		return -1, err
 	}
-	return DiskStatsResponse.TotalBytes, err
+	return diskStatsResponse.TotalBytes, nil
 }
 
 // StatFS returns info about volume
@@ -580,6 +638,209 @@ func (mounter *csiProxyMounter) GetBIOSSerialNumber(ctx context.Context) (string
 	return serialNoResponse.SerialNumber, err
 }
 
+// PublishRawDisk brings the disk with the given number online and links the
+// target path to the device path of the disk, exposing it as a raw block
+// device. The disk is neither partitioned nor formatted.
+func (mounter *csiProxyMounter) PublishRawDisk(ctx context.Context, diskNumber string, target string) error {
+	log := logger.GetLogger(ctx)
+	diskNum, err := strconv.ParseUint(diskNumber, 10, 32)
+	if err != nil {
+		return fmt.Errorf("parse %s failed with error: %v", diskNumber, err)
+	}
+	// ensure disk is online
+	log.Infof("setting disk %d to online", diskNum)
+	onlineRequest := &disk.SetDiskStateRequest{
+		DiskNumber: uint32(diskNum),
+		IsOnline:   true,
+	}
+	err = setDiskState(ctx, onlineRequest)
+	mounter.forgetDiskReadOnly(onlineRequest.DiskNumber)
+	if err != nil {
+		log.Errorf("failed to set disk state as online for disk: %d, err: %v", onlineRequest.DiskNumber, err)
+		return err
+	}
+
+	devicePath := rawDiskDevicePathPrefix + strconv.FormatUint(diskNum, 10)
+	target = normalizeWindowsPath(target)
+	if link, err := os.Readlink(target); err == nil && link == devicePath {
+		log.Infof("target %q is already linked to %q", target, devicePath)
+		return nil
+	}
+	// The link is created directly, as the filesystem API of CSI Proxy only
+	// accepts paths to files and directories. Kubelet may have created the
+	// target path as a directory, so it is replaced with the link.
+	if err = os.RemoveAll(target); err != nil {
+		return fmt.Errorf("failed to remove target %q: %v", target, err)
+	}
+	if err = os.MkdirAll(filepath.Dir(target), 0750); err != nil {
+		return fmt.Errorf("failed to create parent directory of target %q: %v", target, err)
+	}
+	if err = os.Symlink(devicePath, target); err != nil {
+		return fmt.Errorf("failed to link target %q to %q: %v", target, devicePath, err)
+	}
+	log.Infof("target %q linked to raw disk %q", target, devicePath)
+	return nil
+}
+
+// UnpublishRawDisk removes the link at the target path to the device path of
+// a disk. Once no other pod has the disk published, the disk is taken offline
+// to have a clean state. It returns false if the target path is not such a
+// link.
+func (mounter *csiProxyMounter) UnpublishRawDisk(ctx context.Context, target string) (bool, error) {
+	log := logger.GetLogger(ctx)
+	diskNumber, ok := getRawDiskNumber(target)
+	if !ok {
+		return false, nil
+	}
+	target = normalizeWindowsPath(target)
+	if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+		return true, fmt.Errorf("failed to remove target %q: %v", target, err)
+	}
+	// Kubelet publishes a raw block volume to every pod using it at a target
+	// path in the same directory, named after the pod UID.
+	published, err := hasRawDiskLink(filepath.Dir(target), diskNumber)
+	if err != nil {
+		return true, err
+	}
+	if published {
+		log.Infof("disk %d is still published to other pods. Skipping setting it offline.", diskNumber)
+		return true, nil
+	}
+	log.Infof("setting disk %d to offline", diskNumber)
+	offlineRequest := &disk.SetDiskStateRequest{
+		DiskNumber: diskNumber,
+		IsOnline:   false,
+	}
+	if err := setDiskState(ctx, offlineRequest); err != nil {
+		log.Errorf("failed to set disk state as offline for disk: %d, err: %v", diskNumber, err)
+		return true, err
+	}
+	return true, nil
+}
+
+// IsRawDiskLink checks if the given path links to the device path of a disk.
+func (mounter *csiProxyMounter) IsRawDiskLink(path string) bool {
+	_, ok := getRawDiskNumber(path)
+	return ok
+}
+
+// hasRawDiskLink checks if any entry of the given directory links to the
+// device path of the disk with the given number.
+func hasRawDiskLink(dir string, diskNumber uint32) (bool, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to read directory %q: %v", dir, err)
+	}
+	for _, entry := range entries {
+		if linkedDiskNumber, ok := getRawDiskNumber(filepath.Join(dir, entry.Name())); ok &&
+			linkedDiskNumber == diskNumber {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// getRawDiskNumber returns the number of the disk whose device path the
+// given path links to.
+func getRawDiskNumber(path string) (uint32, bool) {
+	link, err := os.Readlink(normalizeWindowsPath(path))
+	if err != nil {
+		return 0, false
+	}
+	diskNumber, ok := strings.CutPrefix(strings.ToUpper(link), rawDiskDevicePathPrefix)
+	if !ok {
+		return 0, false
+	}
+	diskNum, err := strconv.ParseUint(diskNumber, 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(diskNum), true
+}
+
+// GetDiskNumberFromPath returns the number of the disk whose device path the
+// given path links to, or else of the disk backing the volume mounted at it.
+func (mounter *csiProxyMounter) GetDiskNumberFromPath(ctx context.Context, path string) (uint32, error) {
+	log := logger.GetLogger(ctx)
+	if diskNumber, ok := getRawDiskNumber(path); ok {
+		return diskNumber, nil
+	}
+	idResponse, err := mounter.VolumeClient.GetVolumeIDFromTargetPath(ctx,
+		&volume.GetVolumeIDFromTargetPathRequest{TargetPath: path})
+	if err != nil {
+		log.Errorf("failed to get volume id from target path: %q, err: %v", path, err)
+		return 0, err
+	}
+	diskNumberResponse, err := mounter.VolumeClient.GetDiskNumberFromVolumeID(ctx,
+		&volume.GetDiskNumberFromVolumeIDRequest{VolumeID: idResponse.VolumeID})
+	if err != nil {
+		log.Errorf("failed to get disk number from volumeID: %q, err: %v", idResponse.VolumeID, err)
+		return 0, err
+	}
+	return diskNumberResponse.DiskNumber, nil
+}
+
+// GetDiskCondition returns the online and read-only state of the disk with the
+// given number. The read-only state of offline disks is not checked, and the
+// read-only state of online disks is cached for diskReadOnlyCacheTTL.
+func (mounter *csiProxyMounter) GetDiskCondition(ctx context.Context, diskNumber uint32) (bool, bool, error) {
+	stateResponse, err := mounter.DiskClient.GetDiskState(ctx, &disk.GetDiskStateRequest{DiskNumber: diskNumber})
+	if err != nil {
+		return false, false, fmt.Errorf("failed to get state of disk %d: %v", diskNumber, err)
+	}
+	if !stateResponse.IsOnline {
+		return false, false, nil
+	}
+	mounter.diskReadOnlyLock.Lock()
+	defer mounter.diskReadOnlyLock.Unlock()
+	if state, ok := mounter.diskReadOnlyCache[diskNumber]; ok && time.Since(state.fetchedAt) < diskReadOnlyCacheTTL {
+		return true, state.isReadOnly, nil
+	}
+	isReadOnly, err := isDiskReadOnly(ctx, diskNumber)
+	if err != nil {
+		return false, false, err
+	}
+	if mounter.diskReadOnlyCache == nil {
+		mounter.diskReadOnlyCache = make(map[uint32]diskReadOnlyState)
+	}
+	mounter.diskReadOnlyCache[diskNumber] = diskReadOnlyState{isReadOnly: isReadOnly, fetchedAt: time.Now()}
+	return true, isReadOnly, nil
+}
+
+// forgetDiskReadOnly removes the cached read-only state of the disk with the
+// given number, as setting the state of a disk clears its read-only flag.
+func (mounter *csiProxyMounter) forgetDiskReadOnly(diskNumber uint32) {
+	mounter.diskReadOnlyLock.Lock()
+	defer mounter.diskReadOnlyLock.Unlock()
+	delete(mounter.diskReadOnlyCache, diskNumber)
+}
+
+// FormatVolume formats the volume with the given ID with the given filesystem.
+func FormatVolume(ctx context.Context, volumeID string, fsType string) error {
+	log := logger.GetLogger(ctx)
+	log.Infof("formatting volume %s with %s", volumeID, fsType)
+	cmd := `Get-Volume -UniqueId "$Env:volumeID" | Format-Volume -FileSystem $Env:fsType -Confirm:$false`
+	out, err := utils.RunPowershellCmd(cmd, "volumeID="+volumeID, "fsType="+fsType)
+	if err != nil {
+		return fmt.Errorf("error formatting volume. cmd: %s, output: %s, error: %v", cmd, string(out), err)
+	}
+	return nil
+}
+
+// IsDiskReadOnly checks if the disk with the given number is read-only.
+func IsDiskReadOnly(ctx context.Context, diskNumber uint32) (bool, error) {
+	cmd := fmt.Sprintf("(Get-Disk -Number %d).IsReadOnly", diskNumber)
+	out, err := utils.RunPowershellCmd(cmd)
+	if err != nil {
+		return false, fmt.Errorf("error getting disk read-only state. cmd: %s, output: %s, error: %v",
+			cmd, string(out), err)
+	}
+	return strings.EqualFold(strings.TrimSpace(string(out)), "true"), nil
+}
+
 // SetDiskState sets the offline/online state of a disk.
 func SetDiskState(ctx context.Context, attachReq *disk.SetDiskStateRequest) error {
 	cmd := fmt.Sprintf("Set-Disk -Number %d -IsReadOnly $false;Set-Disk -Number %d -IsOffline $%t",
@@ -0,0 +1,194 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2025 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package mounter
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	disk "github.com/kubernetes-csi/csi-proxy/v2/pkg/disk"
+)
+
+// fakeDiskClient is a fake of the disk API of CSI Proxy.
+type fakeDiskClient struct {
+	disk.Interface
+	diskIDs     map[uint32]*disk.DiskIDs
+	diskOffline map[uint32]bool
+}
+
+func (f *fakeDiskClient) ListDiskIDs(ctx context.Context, req *disk.ListDiskIDsRequest) (
+	*disk.ListDiskIDsResponse, error) {
+	return &disk.ListDiskIDsResponse{DiskIDs: f.diskIDs}, nil
+}
+
+func (f *fakeDiskClient) GetDiskState(ctx context.Context, req *disk.GetDiskStateRequest) (
+	*disk.GetDiskStateResponse, error) {
+	return &disk.GetDiskStateResponse{IsOnline: !f.diskOffline[req.DiskNumber]}, nil
+}
+
+// fakeSetDiskState replaces setDiskState for the duration of the test and
+// records the requested disk states.
+func fakeSetDiskState(t *testing.T) map[uint32]bool {
+	diskStates := make(map[uint32]bool)
+	origSetDiskState := setDiskState
+	setDiskState = func(ctx context.Context, req *disk.SetDiskStateRequest) error {
+		diskStates[req.DiskNumber] = req.IsOnline
+		return nil
+	}
+	t.Cleanup(func() { setDiskState = origSetDiskState })
+	return diskStates
+}
+
+func newFakeCSIProxyMounter() *csiProxyMounter {
+	return &csiProxyMounter{
+		Ctx: context.Background(),
+		DiskClient: &fakeDiskClient{
+			diskIDs: map[uint32]*disk.DiskIDs{
+				0: {Page83: "", SerialNumber: "os-disk"},
+				2: {Page83: "6000c29a98d05e384a43f0ef189aaf5a"},
+			},
+			diskOffline: map[uint32]bool{3: true},
+		},
+	}
+}
+
+func TestGetDiskNumber(t *testing.T) {
+	ctx := context.Background()
+	mounter := newFakeCSIProxyMounter()
+	diskNumber, err := mounter.GetDiskNumber(ctx, "6000c29a98d05e384a43f0ef189aaf5a")
+	if err != nil {
+		t.Fatalf("failed to get disk number. err: %v", err)
+	}
+	if diskNumber != "2" {
+		t.Errorf("expected disk number 2, got %s", diskNumber)
+	}
+	if _, err := mounter.GetDiskNumber(ctx, "6000c2900000000000000000000000"); err == nil {
+		t.Errorf("expected error for disk which is not attached")
+	}
+}
+
+func TestPublishAndUnpublishRawDisk(t *testing.T) {
+	ctx := context.Background()
+	mounter := newFakeCSIProxyMounter()
+	diskStates := fakeSetDiskState(t)
+	tmpDir := t.TempDir()
+	// Creating symlinks requires the SeCreateSymbolicLinkPrivilege.
+	if err := os.Symlink(tmpDir, filepath.Join(tmpDir, "link")); err != nil {
+		t.Skipf("symlinks can not be created. err: %v", err)
+	}
+	publishDir := filepath.Join(tmpDir, "volumeDevices", "publish", "pvc-1234")
+	target := filepath.Join(publishDir, "pod1")
+	// Kubelet creates the target path as a directory.
+	if err := os.MkdirAll(target, 0750); err != nil {
+		t.Fatalf("failed to create target. err: %v", err)
+	}
+
+	if err := mounter.PublishRawDisk(ctx, "2", target); err != nil {
+		t.Fatalf("failed to publish raw disk. err: %v", err)
+	}
+	if online, ok := diskStates[2]; !ok || !online {
+		t.Errorf("expected disk 2 to be set online")
+	}
+	if link, err := os.Readlink(target); err != nil || link != `\\.\PHYSICALDRIVE2` {
+		t.Errorf("expected target to link to disk 2, got %q, err: %v", link, err)
+	}
+	if !mounter.IsRawDiskLink(target) {
+		t.Errorf("expected target to be detected as a raw block device")
+	}
+	// Publishing again is idempotent.
+	if err := mounter.PublishRawDisk(ctx, "2", target); err != nil {
+		t.Fatalf("failed to publish raw disk again. err: %v", err)
+	}
+
+	// The disk stays online while it is published to another pod.
+	target2 := filepath.Join(publishDir, "pod2")
+	if err := mounter.PublishRawDisk(ctx, "2", target2); err != nil {
+		t.Fatalf("failed to publish raw disk to second target. err: %v", err)
+	}
+	unpublished, err := mounter.UnpublishRawDisk(ctx, target)
+	if err != nil || !unpublished {
+		t.Fatalf("failed to unpublish raw disk. unpublished: %v, err: %v", unpublished, err)
+	}
+	if !diskStates[2] {
+		t.Errorf("expected disk 2 to stay online while published to another pod")
+	}
+	if _, err := os.Lstat(target); !os.IsNotExist(err) {
+		t.Errorf("expected target to be removed, err: %v", err)
+	}
+	unpublished, err = mounter.UnpublishRawDisk(ctx, target2)
+	if err != nil || !unpublished {
+		t.Fatalf("failed to unpublish raw disk from second target. unpublished: %v, err: %v", unpublished, err)
+	}
+	if diskStates[2] {
+		t.Errorf("expected disk 2 to be set offline")
+	}
+	// Paths which do not link to a disk are left to the caller.
+	unpublished, err = mounter.UnpublishRawDisk(ctx, t.TempDir())
+	if err != nil || unpublished {
+		t.Errorf("expected directory not to be unpublished as raw disk. unpublished: %v, err: %v",
+			unpublished, err)
+	}
+}
+
+func TestGetDiskCondition(t *testing.T) {
+	ctx := context.Background()
+	mounter := newFakeCSIProxyMounter()
+	origIsDiskReadOnly := isDiskReadOnly
+	readOnlyChecks := make(map[uint32]int)
+	isDiskReadOnly = func(ctx context.Context, diskNumber uint32) (bool, error) {
+		readOnlyChecks[diskNumber]++
+		return diskNumber == 4, nil
+	}
+	t.Cleanup(func() { isDiskReadOnly = origIsDiskReadOnly })
+
+	tests := []struct {
+		diskNumber uint32
+		isOnline   bool
+		isReadOnly bool
+	}{
+		{diskNumber: 2, isOnline: true, isReadOnly: false},
+		{diskNumber: 3, isOnline: false, isReadOnly: false},
+		{diskNumber: 4, isOnline: true, isReadOnly: true},
+	}
+	for _, test := range tests {
+		isOnline, isReadOnly, err := mounter.GetDiskCondition(ctx, test.diskNumber)
+		if err != nil {
+			t.Fatalf("failed to get condition of disk %d. err: %v", test.diskNumber, err)
+		}
+		if isOnline != test.isOnline || isReadOnly != test.isReadOnly {
+			t.Errorf("disk %d: expected online %v and read-only %v, got online %v and read-only %v",
+				test.diskNumber, test.isOnline, test.isReadOnly, isOnline, isReadOnly)
+		}
+	}
+	// The read-only state of offline disks is not checked and the state of
+	// online disks is cached until the disk state is set again.
+	if _, _, err := mounter.GetDiskCondition(ctx, 4); err != nil {
+		t.Fatalf("failed to get condition of disk 4. err: %v", err)
+	}
+	if readOnlyChecks[3] != 0 || readOnlyChecks[4] != 1 {
+		t.Errorf("expected read-only state to be checked once for disk 4 only, got %v", readOnlyChecks)
+	}
+	mounter.forgetDiskReadOnly(4)
+	if _, _, err := mounter.GetDiskCondition(ctx, 4); err != nil {
+		t.Fatalf("failed to get condition of disk 4. err: %v", err)
+	}
+	if readOnlyChecks[4] != 2 {
+		t.Errorf("expected read-only state of disk 4 to be checked again, got %d checks", readOnlyChecks[4])
+	}
+}
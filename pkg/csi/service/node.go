@@ -19,7 +19,9 @@ package service
 import (
 	"context"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -41,6 +43,10 @@ const (
 	// If Customer is using vSphere 8.0, they are allowed to set MAX_VOLUMES_PER_NODE to 255
 	// when CSI is released with feature-gate - max-pvscsi-targets-per-vm enabled
 	maxAllowedBlockVolumesPerNodeInvSphere8 = 255
+
+	// kubeletCSIVolumesDir is the directory under a pod's volumes directory in
+	// which kubelet creates the target paths of CSI volumes.
+	kubeletCSIVolumesDir = "kubernetes.io~csi"
 )
 
 var topologyService commoncotypes.NodeTopologyService
@@ -297,8 +303,21 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 			"received empty targetpath %q", targetPath)
 	}
 
+	volumeCondition, err := driver.osUtils.GetVolumeCondition(ctx, targetPath)
+	if err != nil {
+		log.Warnf("failed to get condition of volume %q at path %q. Err: %v", volumeID, targetPath, err)
+	}
 	volMetrics, err := driver.osUtils.GetMetrics(ctx, targetPath)
 	if err != nil {
+		if volumeCondition != nil && volumeCondition.Abnormal {
+			// The usage of a volume whose disk is unavailable can not be
+			// fetched, report the condition of the volume instead.
+			log.Warnf("failed to get metrics of abnormal volume %q. Err: %v", volumeID, err)
+			return &csi.NodeGetVolumeStatsResponse{
+				Usage:           []*csi.VolumeUsage{{Unit: csi.VolumeUsage_BYTES}},
+				VolumeCondition: volumeCondition,
+			}, nil
+		}
 		return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 	}
 
@@ -326,6 +345,15 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 	if !ok {
 		log.Warn("failed to fetch used inodes")
 	}
+	if strings.HasPrefix(volumeID, "file:") &&
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeUsage) {
+		// The NFS mount reports the capacity of the whole vSAN File Service,
+		// report the usage and quota of the file share recorded by the syncer instead.
+		if shareUsed, shareTotal, found := getFileShareUsage(ctx, targetPath); found {
+			used, capacity = shareUsed, shareTotal
+			available = max(shareTotal-shareUsed, 0)
+		}
+	}
 	return &csi.NodeGetVolumeStatsResponse{
 		Usage: []*csi.VolumeUsage{
 			{
@@ -341,39 +369,86 @@ func (driver *vsphereCSIDriver) NodeGetVolumeStats(
 				Unit:      csi.VolumeUsage_INODES,
 			},
 		},
+		VolumeCondition: volumeCondition,
 	}, nil
 }
 
+// getFileShareUsage returns the used bytes and the quota in bytes of the file
+// share backing the file volume published at targetPath, as recorded on the
+// annotations of its PV. found is false if the usage is not recorded.
+func getFileShareUsage(ctx context.Context, targetPath string) (used int64, total int64, found bool) {
+	log := logger.GetLogger(ctx)
+	pvName := getPVNameFromTargetPath(targetPath)
+	if pvName == "" {
+		log.Debugf("failed to get PV name from target path %q", targetPath)
+		return 0, 0, false
+	}
+	annotations, err := commonco.ContainerOrchestratorUtility.GetPersistentVolumeAnnotations(ctx, pvName)
+	if err != nil {
+		log.Warnf("failed to get annotations of PV %q. Err: %v", pvName, err)
+		return 0, 0, false
+	}
+	used, err = strconv.ParseInt(annotations[common.AnnFileShareUsedBytes], 10, 64)
+	if err != nil {
+		return 0, 0, false
+	}
+	total, err = strconv.ParseInt(annotations[common.AnnFileShareQuotaBytes], 10, 64)
+	if err != nil || total <= 0 {
+		return 0, 0, false
+	}
+	return used, total, true
+}
+
+// getPVNameFromTargetPath returns the PV name in the target path created by
+// kubelet for a CSI volume, which is of the form
+// /var/lib/kubelet/pods/<pod-uid>/volumes/kubernetes.io~csi/<pv-name>/mount.
+// An empty string is returned for any other path.
+func getPVNameFromTargetPath(targetPath string) string {
+	volumeDir := filepath.Dir(filepath.Clean(targetPath))
+	if filepath.Base(filepath.Dir(volumeDir)) != kubeletCSIVolumesDir {
+		return ""
+	}
+	return filepath.Base(volumeDir)
+}
+
 func (driver *vsphereCSIDriver) NodeGetCapabilities(
 	ctx context.Context,
 	req *csi.NodeGetCapabilitiesRequest) (
 	*csi.NodeGetCapabilitiesResponse, error) {
 
-	return &csi.NodeGetCapabilitiesResponse{
-		Capabilities: []*csi.NodeServiceCapability{
-			{
-				Type: &csi.NodeServiceCapability_Rpc{
-					Rpc: &csi.NodeServiceCapability_RPC{
-						Type: csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
-					},
+	capabilities := []*csi.NodeServiceCapability{
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_STAGE_UNSTAGE_VOLUME,
 				},
 			},
-			{
-				Type: &csi.NodeServiceCapability_Rpc{
-					Rpc: &csi.NodeServiceCapability_RPC{
-						Type: csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
-					},
+		},
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_EXPAND_VOLUME,
 				},
 			},
-			{
-				Type: &csi.NodeServiceCapability_Rpc{
-					Rpc: &csi.NodeServiceCapability_RPC{
-						Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
-					},
+		},
+		{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_GET_VOLUME_STATS,
 				},
 			},
 		},
-	}, nil
+	}
+	if osutils.VolumeConditionSupported {
+		capabilities = append(capabilities, &csi.NodeServiceCapability{
+			Type: &csi.NodeServiceCapability_Rpc{
+				Rpc: &csi.NodeServiceCapability_RPC{
+					Type: csi.NodeServiceCapability_RPC_VOLUME_CONDITION,
+				},
+			},
+		})
+	}
+	return &csi.NodeGetCapabilitiesResponse{Capabilities: capabilities}, nil
 }
 
 // NodeGetInfo RPC returns the NodeGetInfoResponse with mandatory fields
@@ -383,7 +458,11 @@ func (driver *vsphereCSIDriver) NodeGetCapabilities(
 // attached is deterministic by inspecting SCSI controllers of the VM, but for
 // file volume, this is not deterministic. We can not set this limit on
 // MaxVolumesPerNode, since single driver is used for both block and file
-// volumes.
+// volumes. When the volume-attach-limits feature is enabled on Vanilla
+// clusters, the controller computes the block volume attach limit from the
+// free controller slots of the node VM, adds the file volumes published to the
+// node and publishes it as the allocatable count on the CSINode, overriding
+// MaxVolumesPerNode.
 func (driver *vsphereCSIDriver) NodeGetInfo(
 	ctx context.Context,
 	req *csi.NodeGetInfoRequest) (
@@ -452,3 +452,15 @@ func TestOsUtilsInitialization(t *testing.T) {
 	// OsUtils is not initialized when CO initialization fails because it happens after CO init
 	assert.Nil(t, driver.osUtils, "OsUtils should not be initialized when CO initialization fails")
 }
+
+func TestGetPVNameFromTargetPath(t *testing.T) {
+	tests := map[string]string{
+		"/var/lib/kubelet/pods/1234/volumes/kubernetes.io~csi/pvc-abc/mount":  "pvc-abc",
+		"/var/lib/kubelet/pods/1234/volumes/kubernetes.io~csi/pvc-abc/mount/": "pvc-abc",
+		"/var/lib/kubelet/pods/1234/volumes/kubernetes.io~nfs/pvc-abc/mount":  "",
+		"/tmp/target": "",
+	}
+	for targetPath, expected := range tests {
+		assert.Equal(t, expected, getPVNameFromTargetPath(targetPath), "target path %q", targetPath)
+	}
+}
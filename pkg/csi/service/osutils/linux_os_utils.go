@@ -23,6 +23,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"syscall"
@@ -38,6 +39,7 @@ import (
 	"k8s.io/mount-utils"
 	utilexec "k8s.io/utils/exec"
 
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/mounter"
@@ -48,11 +50,35 @@ const (
 	blockPrefix = "wwn-0x"
 	dmiDir      = "/sys/class/dmi"
 	UUIDPrefix  = "VMware-"
+	// nvmeUUIDPrefix and nvmeEUIPrefix are the prefixes of the links created
+	// under /dev/disk/by-id for NVMe namespaces, based on the namespace UUID
+	// or NGUID reported by the controller.
+	nvmeUUIDPrefix = "nvme-uuid."
+	nvmeEUIPrefix  = "nvme-eui."
+	sysClassNVMe   = "/sys/class/nvme"
+	// VolumeConditionSupported is false as the condition of volumes is not
+	// reported on Linux.
+	VolumeConditionSupported = false
 )
 
 // defaultFileMountOptions are the mount flag options used by default while publishing a file volume.
 var defaultFileMountOptions = []string{"hard", "sec=sys", "vers=4", "minorversion=1"}
 
+var (
+	// krb5KeytabPath is the path of the Kerberos keytab used by rpc.gssd to
+	// establish the security context of Kerberos NFS mounts. The /etc
+	// directory of the host is mounted at /host/etc in the node plugin.
+	krb5KeytabPath = "/host/etc/krb5.keytab"
+	// procDir is the directory listing the processes running on the host. The
+	// node plugin does not share the PID namespace of the host, which is
+	// mounted at /host/proc instead.
+	procDir = "/host/proc"
+)
+
+// rpcGssdProcessName is the name of the daemon handling the Kerberos security
+// context of NFS mounts.
+const rpcGssdProcessName = "rpc.gssd"
+
 // NewOsUtils creates OsUtils with a linux specific mounter
 func NewOsUtils(ctx context.Context) (*OsUtils, error) {
 	log := logger.GetLogger(ctx)
@@ -521,6 +547,12 @@ func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Me
 	return metrics, nil
 }
 
+// GetVolumeCondition is a noop for linux, the condition of volumes is not
+// reported.
+func (osUtils *OsUtils) GetVolumeCondition(ctx context.Context, volumePath string) (*csi.VolumeCondition, error) {
+	return nil, nil
+}
+
 // GetBlockSizeBytes returns the Block size in bytes
 func (osUtils *OsUtils) GetBlockSizeBytes(ctx context.Context, devicePath string) (int64, error) {
 	cmdArgs := []string{"--getsize64", devicePath}
@@ -738,13 +770,33 @@ func (osUtils *OsUtils) PublishFileVol(
 	if params.Ro {
 		mntFlags = append(mntFlags, "ro")
 	}
-	// Add defaultFileMountOptions to the mntFlags.
-	mntFlags = append(mntFlags, defaultFileMountOptions...)
+	nfsVersion := req.GetVolumeContext()[common.AttributeNfsVersion]
+	nfsSecurity := req.GetVolumeContext()[common.AttributeNfsSecurity]
+	if common.IsKerberosNfsSecurity(nfsSecurity) {
+		if err := checkKerberosMountPrerequisites(); err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+				"unable to mount file volume with sec=%s: %v", nfsSecurity, err)
+		}
+	}
+	// Add the mount options of the NFS version and security flavor to the mntFlags.
+	mntFlags = append(mntFlags, getFileMountOptions(nfsVersion, nfsSecurity)...)
 	// Retrieve the file share access point from publish context.
-	mntSrc, ok := req.GetPublishContext()[common.Nfsv4AccessPoint]
-	if !ok {
-		return nil, logger.LogNewErrorCode(log, codes.Internal,
-			"nfs v4 accesspoint not set in publish context")
+	var mntSrc string
+	var ok bool
+	if nfsVersion == common.NfsVersion3 {
+		// NFSv3 shares are mounted with the nfs fstype.
+		fsType = common.NfsFsType
+		mntSrc, ok = req.GetPublishContext()[common.Nfsv3AccessPoint]
+		if !ok {
+			return nil, logger.LogNewErrorCode(log, codes.Internal,
+				"nfs v3 accesspoint not set in publish context")
+		}
+	} else {
+		mntSrc, ok = req.GetPublishContext()[common.Nfsv4AccessPoint]
+		if !ok {
+			return nil, logger.LogNewErrorCode(log, codes.Internal,
+				"nfs v4 accesspoint not set in publish context")
+		}
 	}
 	// Directly mount the file share volume to the pod. No bind mount required.
 	log.Debugf("PublishFileVolume: Attempting to mount %q to %q with fstype %q and mountflags %v",
@@ -757,6 +809,65 @@ func (osUtils *OsUtils) PublishFileVol(
 	return &csi.NodePublishVolumeResponse{}, nil
 }
 
+// getFileMountOptions returns the mount options of a file volume mounted with
+// the given NFS version and security flavor. The defaultFileMountOptions are
+// returned if neither is set.
+func getFileMountOptions(nfsVersion string, nfsSecurity string) []string {
+	if nfsVersion == "" && nfsSecurity == "" {
+		return defaultFileMountOptions
+	}
+	if nfsSecurity == "" {
+		nfsSecurity = common.NfsSecuritySys
+	}
+	if nfsVersion == common.NfsVersion3 {
+		return []string{"hard", "sec=" + nfsSecurity, "vers=3"}
+	}
+	return []string{"hard", "sec=" + nfsSecurity, "vers=4", "minorversion=1"}
+}
+
+// checkKerberosMountPrerequisites returns an error if the Kerberos keytab is
+// missing on the node or rpc.gssd is not running, as Kerberos NFS mounts would
+// otherwise fail or hang with a permission error.
+func checkKerberosMountPrerequisites() error {
+	if _, err := os.Stat(krb5KeytabPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("kerberos keytab %q not found on the node", krb5KeytabPath)
+		}
+		return fmt.Errorf("failed to access kerberos keytab %q: %v", krb5KeytabPath, err)
+	}
+	running, err := isProcessRunning(procDir, rpcGssdProcessName)
+	if err != nil {
+		return fmt.Errorf("failed to check whether %s is running: %v", rpcGssdProcessName, err)
+	}
+	if !running {
+		return fmt.Errorf("%s is not running on the node", rpcGssdProcessName)
+	}
+	return nil
+}
+
+// isProcessRunning returns true if a process with the given name is listed
+// in the given proc directory.
+func isProcessRunning(procDir string, name string) (bool, error) {
+	entries, err := os.ReadDir(procDir)
+	if err != nil {
+		return false, err
+	}
+	for _, entry := range entries {
+		if _, err := strconv.Atoi(entry.Name()); err != nil || !entry.IsDir() {
+			continue
+		}
+		comm, err := os.ReadFile(filepath.Join(procDir, entry.Name(), "comm"))
+		if err != nil {
+			// The process may have exited.
+			continue
+		}
+		if strings.TrimSpace(string(comm)) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // GetDevice returns a Device struct with info about the given device, or
 // an error if it doesn't exist or is not a block device.
 func (osUtils *OsUtils) GetDevice(ctx context.Context, path string) (*Device, error) {
@@ -831,12 +942,18 @@ func (osUtils *OsUtils) GetDeviceRescanPath(dev *Device) (string, error) {
 	// Refer to https://kb.vmware.com/s/article/1006371
 	parts := strings.Split(dev.RealDev, "/")
 	if len(parts) == 3 && strings.HasPrefix(parts[1], "dev") {
+		// For NVMe namespaces, `/sys/block/$DEVICE/device` is the NVMe
+		// controller, which exposes `rescan_controller` instead.
+		if strings.HasPrefix(parts[2], "nvme") {
+			return filepath.EvalSymlinks(filepath.Join("/sys/block", parts[2], "device", "rescan_controller"))
+		}
 		return filepath.EvalSymlinks(filepath.Join("/sys/block", parts[2], "device", "rescan"))
 	}
 	return "", fmt.Errorf("illegal path for device %q", dev.RealDev)
 }
 
-// GetDiskPath return the full DiskPath for diskID
+// GetDiskPath return the full DiskPath for diskID. The disk is looked up both
+// as a SCSI disk and as a NVMe namespace.
 func (osUtils *OsUtils) GetDiskPath(id string) (string, error) {
 	var (
 		devs []os.DirEntry
@@ -846,10 +963,10 @@ func (osUtils *OsUtils) GetDiskPath(id string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	targetDisk := blockPrefix + id
+	targetDisks := getDiskIDLinkNames(id)
 
 	for _, f := range devs {
-		if f.Name() == targetDisk {
+		if slices.Contains(targetDisks, f.Name()) {
 			return filepath.Join(devDiskID, f.Name()), nil
 		}
 	}
@@ -857,6 +974,34 @@ func (osUtils *OsUtils) GetDiskPath(id string) (string, error) {
 	return "", nil
 }
 
+// getDiskIDLinkNames returns the names of the links under /dev/disk/by-id
+// which may point to the disk with the given diskID.
+func getDiskIDLinkNames(id string) []string {
+	names := []string{blockPrefix + id}
+	nvmeUUID, err := cnsvolume.ConvertToNvmeUUID(id)
+	if err != nil {
+		return names
+	}
+	return append(names, nvmeUUIDPrefix+nvmeUUID, nvmeEUIPrefix+strings.ReplaceAll(nvmeUUID, "-", ""))
+}
+
+// rescanNVMeControllers rescans the namespaces of all the NVMe controllers on
+// the node, so that newly attached namespaces are discovered.
+func (osUtils *OsUtils) rescanNVMeControllers(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	controllers, err := os.ReadDir(sysClassNVMe)
+	if err != nil {
+		// No NVMe controller present on the node.
+		return
+	}
+	for _, controller := range controllers {
+		rescanPath := filepath.Join(sysClassNVMe, controller.Name(), "rescan_controller")
+		if err := os.WriteFile(rescanPath, []byte{'1'}, 0200); err != nil {
+			log.Warnf("error rescanning NVMe controller %q. Error: %v", controller.Name(), err)
+		}
+	}
+}
+
 // VerifyVolumeAttached verifies if the volume path exist for diskID
 func (osUtils *OsUtils) VerifyVolumeAttached(ctx context.Context, diskID string) (string, error) {
 	log := logger.GetLogger(ctx)
@@ -866,6 +1011,16 @@ func (osUtils *OsUtils) VerifyVolumeAttached(ctx context.Context, diskID string)
 		return "", logger.LogNewErrorCodef(log, codes.Internal,
 			"error trying to read attached disks: %v", err)
 	}
+	if volPath == "" {
+		// Namespaces attached to NVMe controllers may not be discovered until
+		// the controller is rescanned.
+		osUtils.rescanNVMeControllers(ctx)
+		volPath, err = osUtils.GetDiskPath(diskID)
+		if err != nil {
+			return "", logger.LogNewErrorCodef(log, codes.Internal,
+				"error trying to read attached disks: %v", err)
+		}
+	}
 	if volPath == "" {
 		return "", logger.LogNewErrorCodef(log, codes.NotFound,
 			"disk: %s not attached to node", diskID)
@@ -5,7 +5,11 @@ package osutils
 
 import (
 	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -52,3 +56,81 @@ func TestUnescape(t *testing.T) {
 		})
 	}
 }
+
+func TestGetDiskIDLinkNames(t *testing.T) {
+	names := getDiskIDLinkNames("6000c2951a2b3c4d5e6f708192a3b4c5")
+	expected := []string{
+		"wwn-0x6000c2951a2b3c4d5e6f708192a3b4c5",
+		"nvme-uuid.5e6f7081-92a3-b4c5-000c-29651a2b3c4d",
+		"nvme-eui.5e6f708192a3b4c5000c29651a2b3c4d",
+	}
+	if len(names) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], names[i])
+		}
+	}
+
+	// Only the SCSI link name is expected when the disk ID is not a valid UUID.
+	names = getDiskIDLinkNames("invalid")
+	if len(names) != 1 || names[0] != "wwn-0xinvalid" {
+		t.Errorf("unexpected link names for invalid disk ID: %v", names)
+	}
+}
+
+func TestGetFileMountOptions(t *testing.T) {
+	tests := []struct {
+		nfsVersion, nfsSecurity string
+		expected                []string
+	}{
+		{"", "", defaultFileMountOptions},
+		{"4.1", "", []string{"hard", "sec=sys", "vers=4", "minorversion=1"}},
+		{"", "krb5p", []string{"hard", "sec=krb5p", "vers=4", "minorversion=1"}},
+		{"3", "", []string{"hard", "sec=sys", "vers=3"}},
+		{"3", "sys", []string{"hard", "sec=sys", "vers=3"}},
+	}
+	for _, test := range tests {
+		options := getFileMountOptions(test.nfsVersion, test.nfsSecurity)
+		if !reflect.DeepEqual(options, test.expected) {
+			t.Errorf("expected mount options %v for version %q and security %q, got %v",
+				test.expected, test.nfsVersion, test.nfsSecurity, options)
+		}
+	}
+}
+
+func TestCheckKerberosMountPrerequisites(t *testing.T) {
+	tmpDir := t.TempDir()
+	origKeytabPath, origProcDir := krb5KeytabPath, procDir
+	defer func() {
+		krb5KeytabPath, procDir = origKeytabPath, origProcDir
+	}()
+	krb5KeytabPath = filepath.Join(tmpDir, "krb5.keytab")
+	procDir = filepath.Join(tmpDir, "proc")
+	writeComm := func(pid string, comm string) {
+		if err := os.MkdirAll(filepath.Join(procDir, pid), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(procDir, pid, "comm"), []byte(comm+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeComm("1", "systemd")
+
+	err := checkKerberosMountPrerequisites()
+	if err == nil || !strings.Contains(err.Error(), "keytab") {
+		t.Fatalf("expected missing keytab error, got %v", err)
+	}
+	if err := os.WriteFile(krb5KeytabPath, []byte("keytab"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err = checkKerberosMountPrerequisites()
+	if err == nil || !strings.Contains(err.Error(), rpcGssdProcessName) {
+		t.Fatalf("expected %s not running error, got %v", rpcGssdProcessName, err)
+	}
+	writeComm("42", rpcGssdProcessName)
+	if err := checkKerberosMountPrerequisites(); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+}
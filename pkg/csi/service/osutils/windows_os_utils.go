@@ -22,6 +22,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
 	"google.golang.org/grpc/codes"
@@ -37,6 +38,18 @@ import (
 
 const (
 	UUIDPrefix = "VMware-"
+	// VolumeConditionSupported is true as the condition of volumes is
+	// reported based on the state of the disks backing them.
+	VolumeConditionSupported = true
+	// diskRescanRetries is the number of times the disks are rescanned
+	// while waiting for an expanded disk to report its new size.
+	diskRescanRetries = 5
+	// diskRescanInterval is the time to wait after rescanning the disks.
+	diskRescanInterval = time.Second
+	// partitionOverheadBytes is the maximum difference between the size of
+	// a disk and the size of the volume spanning it, which is taken by the
+	// GPT partition table, the Microsoft reserved partition and alignment.
+	partitionOverheadBytes = 256 * common.MbInBytes
 )
 
 // NewOsUtils creates OsUtils with a linux specific mounter
@@ -78,14 +91,6 @@ func (osUtils *OsUtils) NodeStageBlockVolume(
 	// type:vSphere CNS Block Volume] XXX_NoUnkeyedLiteral:{} XXX_unrecognized:[] XXX_sizecache:0}
 	// Note: fs_type comes as ext4 if not specified in storage class else it takes value from storage class
 
-	// Check if this is a MountVolume or BlockVolume.
-	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
-		// Volume is a raw block volume.
-		return nil, logger.LogNewErrorCodef(log, codes.Internal,
-			"Stage for raw block Volume access type is currently not supported for windows node")
-	}
-
-	// Block Volume with Mount access type.
 	pubCtx := req.GetPublishContext()
 	stagingTargetPath := req.GetStagingTargetPath()
 	diskID, err := osUtils.GetDiskID(pubCtx, log)
@@ -106,6 +111,15 @@ func (osUtils *OsUtils) NodeStageBlockVolume(
 			"failed to get Disk Number, err: %v", err)
 	}
 	log.Infof("nodeStageBlockVolume diskNumber %s, diskId %s,stagingTargetPath %s", diskNumber, diskID, stagingTargetPath)
+
+	// Check if this is a MountVolume or BlockVolume.
+	if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
+		// Volume is a raw block volume, the disk is brought online when it is published.
+		log.Infof("nodeStageBlockVolume: Skipping staging for block volume ID %q", params.VolID)
+		return &csi.NodeStageVolumeResponse{}, nil
+	}
+
+	// Block Volume with Mount access type.
 	mounted, err := osUtils.haveMountPoint(ctx, stagingTargetPath)
 	if err != nil {
 		return nil, err
@@ -164,6 +178,16 @@ func (osUtils *OsUtils) CleanupStagePath(ctx context.Context, stagingTarget stri
 		return err
 	}
 
+	// Raw block volumes are not staged, so nothing is mounted at their staging target.
+	mounted, err := mounter.IsMountedFolder(stagingTarget)
+	if err != nil {
+		return fmt.Errorf("failed to check if stagingTarget %q is mounted: %v", stagingTarget, err)
+	}
+	if !mounted {
+		log.Infof("Staging target %q for volume %q is not mounted. Skipping unmount.", stagingTarget, volID)
+		return nil
+	}
+
 	// unmount Block volume.
 	log.Infof("Attempting to unmount target %q for volume %q", stagingTarget, volID)
 	err = mounter.Unmount(stagingTarget)
@@ -176,13 +200,24 @@ func (osUtils *OsUtils) CleanupStagePath(ctx context.Context, stagingTarget stri
 
 // CleanupPublishPath will unmount and remove publish path
 func (osUtils *OsUtils) CleanupPublishPath(ctx context.Context, target string, volID string) error {
-	//log := logger.GetLogger(ctx)
+	log := logger.GetLogger(ctx)
 	// for windows, unpublish means removing symlink only
 	// get the mounter
 	mounter, err := GetMounter(ctx, osUtils)
 	if err != nil {
 		return err
 	}
+	// Raw block volumes are published as a link to the disk, which is taken
+	// offline once it is not published to any other pod.
+	unpublished, err := mounter.UnpublishRawDisk(ctx, target)
+	if err != nil {
+		return fmt.Errorf(
+			"error unpublishing raw block volume %q at publishTarget: %v", volID, err)
+	}
+	if unpublished {
+		log.Infof("Unpublished raw block volume %q from target %q", volID, target)
+		return nil
+	}
 	// no need to check if target exist first as rmdir do not throw error if path does not exists.
 	err = mounter.Rmdir(ctx, target)
 	if err != nil {
@@ -230,9 +265,34 @@ func (osUtils *OsUtils) PublishBlockVol(
 	*csi.NodePublishVolumeResponse, error) {
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
+	log.Infof("PublishBlockVolume called with args: %+v", params)
 
-	return nil, logger.LogNewErrorCodef(log, codes.Internal,
-		"PublishBlockVol Raw Block devices are currently not supported by windows : %v", req)
+	// Read-only is not supported for BlockVolume, as the link to the disk does
+	// not prevent it from being modified.
+	if params.Ro {
+		return nil, logger.LogNewErrorCode(log, codes.InvalidArgument,
+			"read only not supported for Block Volume")
+	}
+	diskID, err := osUtils.GetDiskID(req.GetPublishContext(), log)
+	if err != nil {
+		return nil, err
+	}
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return nil, err
+	}
+	// Get the windows specific disk number
+	diskNumber, err := mounter.GetDiskNumber(ctx, diskID)
+	if err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get Disk Number, err: %v", err)
+	}
+	if err := mounter.PublishRawDisk(ctx, diskNumber, params.Target); err != nil {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"error publishing raw block volume. Parameters: %v err: %v", params, err)
+	}
+	log.Infof("NodePublishVolume for raw block volume %q successful to path %q", req.GetVolumeId(), params.Target)
+	return &csi.NodePublishVolumeResponse{}, nil
 }
 
 // PublishBlockVol mounts file volume to publish target
@@ -255,11 +315,29 @@ func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Me
 	if err != nil {
 		return nil, err
 	}
+	metrics := &k8svol.Metrics{Time: metav1.Now()}
+	if mounter.IsRawDiskLink(path) {
+		// Raw block volumes have no filesystem, only the size of the disk is reported.
+		diskNumber, err := mounter.GetDiskNumberFromPath(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		capacity, err := mounter.GetDiskSizeInBytes(ctx, diskNumber)
+		if err != nil {
+			return nil, err
+		}
+		metrics.Available = resource.NewQuantity(0, resource.BinarySI)
+		metrics.Capacity = resource.NewQuantity(capacity, resource.BinarySI)
+		metrics.Used = resource.NewQuantity(0, resource.BinarySI)
+		metrics.Inodes = resource.NewQuantity(0, resource.BinarySI)
+		metrics.InodesFree = resource.NewQuantity(0, resource.BinarySI)
+		metrics.InodesUsed = resource.NewQuantity(0, resource.BinarySI)
+		return metrics, nil
+	}
 	available, capacity, usage, inodes, inodesFree, inodesUsed, err := mounter.StatFS(ctx, path)
 	if err != nil {
 		return nil, err
 	}
-	metrics := &k8svol.Metrics{Time: metav1.Now()}
 	metrics.Available = resource.NewQuantity(available, resource.BinarySI)
 	metrics.Capacity = resource.NewQuantity(capacity, resource.BinarySI)
 	metrics.Used = resource.NewQuantity(usage, resource.BinarySI)
@@ -269,6 +347,39 @@ func (osUtils *OsUtils) GetMetrics(ctx context.Context, path string) (*k8svol.Me
 	return metrics, nil
 }
 
+// GetVolumeCondition reports the volume at the given path as abnormal if the
+// disk backing it can not be found, is offline or is read-only.
+func (osUtils *OsUtils) GetVolumeCondition(ctx context.Context, volumePath string) (*csi.VolumeCondition, error) {
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return nil, err
+	}
+	diskNumber, err := mounter.GetDiskNumberFromPath(ctx, volumePath)
+	if err != nil {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("failed to find the disk backing the volume: %v", err),
+		}, nil
+	}
+	isOnline, isReadOnly, err := mounter.GetDiskCondition(ctx, diskNumber)
+	if err != nil {
+		return nil, err
+	}
+	if !isOnline {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("disk %d backing the volume is offline", diskNumber),
+		}, nil
+	}
+	if isReadOnly {
+		return &csi.VolumeCondition{
+			Abnormal: true,
+			Message:  fmt.Sprintf("disk %d backing the volume is read-only", diskNumber),
+		}, nil
+	}
+	return &csi.VolumeCondition{Abnormal: false, Message: "volume is healthy"}, nil
+}
+
 // GetBlockSizeBytes returns the Block size in bytes
 func (osUtils *OsUtils) GetBlockSizeBytes(ctx context.Context, devicePath string) (int64, error) {
 	mounter, err := GetMounter(ctx, osUtils)
@@ -374,9 +485,9 @@ func (osUtils *OsUtils) GetVolumeCapabilityFsType(ctx context.Context,
 			"vSAN file service volume can not be mounted on windows node")
 	}
 
-	// On Windows we only support ntfs filesystem. External-provisioner sets default fstype as ext4
+	// On Windows we only support ntfs and refs filesystems. External-provisioner sets default fstype as ext4
 	// when none is specified in StorageClass, hence overwrite it to ntfs while mounting the volume.
-	if fsType == common.NTFSFsType {
+	if fsType == common.NTFSFsType || fsType == common.ReFSFsType {
 		return fsType, nil
 	} else if fsType == "" || fsType == "ext4" {
 		log.Infof("replacing fsType: %q received from volume "+
@@ -388,7 +499,9 @@ func (osUtils *OsUtils) GetVolumeCapabilityFsType(ctx context.Context,
 	}
 }
 
-// ResizeVolume resizes the volume
+// ResizeVolume rescans the disks until the disk backing the volume reports
+// the requested size, then extends the partition and the volume to the end of
+// the disk through CSI Proxy and verifies the new size of the volume.
 func (osUtils *OsUtils) ResizeVolume(ctx context.Context, devicePath, volumePath string, reqVolSizeBytes int64) error {
 	log := logger.GetLogger(ctx)
 	// get the mounter
@@ -398,23 +511,54 @@ func (osUtils *OsUtils) ResizeVolume(ctx context.Context, devicePath, volumePath
 	}
 	log.Infof("resizing using csi proxy, devicePath %s", devicePath)
 
-	// Check the block size.
-	currentBlockSizeBytes, err := mounter.GetDiskTotalBytes(ctx, devicePath)
+	// A disk expanded while it is attached does not report its new size
+	// until the storage cache of the guest OS is updated.
+	diskSizeBytes, err := mounter.GetDiskTotalBytes(ctx, devicePath)
 	if err != nil {
 		return logger.LogNewErrorCodef(log, codes.Internal,
-			"error when getting size of block volume at path %s: %v", devicePath, err)
+			"error when getting size of disk backing volume %s: %v", devicePath, err)
 	}
-	// For windows volume size is less than the disk size by some mb.
-	if reqVolSizeBytes-currentBlockSizeBytes < 1024*1024 {
-		log.Infof("minimum extent size is 1 MB. Skip resize for volume %s from currentBytes=%d to wantedBytes=%d ",
-			devicePath, currentBlockSizeBytes, reqVolSizeBytes)
-		return nil
+	for i := 0; diskSizeBytes < reqVolSizeBytes && i < diskRescanRetries; i++ {
+		log.Infof("disk backing volume %s has size %d, less than the requested size %d. Rescanning disks",
+			devicePath, diskSizeBytes, reqVolSizeBytes)
+		if err = mounter.Rescan(ctx); err != nil {
+			return logger.LogNewErrorCodef(log, codes.Internal, "error when rescanning disks: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return logger.LogNewErrorCodef(log, status.FromContextError(ctx.Err()).Code(),
+				"stopped waiting for disk backing volume %s to report the requested size %d: %v",
+				devicePath, reqVolSizeBytes, ctx.Err())
+		case <-time.After(diskRescanInterval):
+		}
+		diskSizeBytes, err = mounter.GetDiskTotalBytes(ctx, devicePath)
+		if err != nil {
+			return logger.LogNewErrorCodef(log, codes.Internal,
+				"error when getting size of disk backing volume %s: %v", devicePath, err)
+		}
 	}
+	if diskSizeBytes < reqVolSizeBytes {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"requested volume size was %d, but got disk with size %d", reqVolSizeBytes, diskSizeBytes)
+	}
+
+	// Resizing brings the disk online and is a noop if the partition
+	// already spans the disk.
 	err = mounter.ResizeVolume(ctx, devicePath, reqVolSizeBytes)
 	if err != nil {
 		return fmt.Errorf(
 			"error when resizing filesystem on devicePath %s and volumePath %s, err: %v ", devicePath, volumePath, err)
 	}
+	volumeSizeBytes, err := mounter.GetVolumeSizeInBytes(ctx, devicePath)
+	if err != nil {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"error when getting size of volume %s: %v", devicePath, err)
+	}
+	// The volume is smaller than the disk by the partition overhead.
+	if volumeSizeBytes < reqVolSizeBytes-partitionOverheadBytes {
+		return logger.LogNewErrorCodef(log, codes.Internal,
+			"requested volume size was %d, but got volume with size %d", reqVolSizeBytes, volumeSizeBytes)
+	}
 	return nil
 }
 
@@ -512,5 +656,9 @@ func (osUtils *OsUtils) ShouldContinue(ctx context.Context) {
 
 // Check if device at given path is block device or not
 func (osUtils *OsUtils) IsBlockDevice(ctx context.Context, volumePath string) (bool, error) {
-	return false, nil
+	mounter, err := GetMounter(ctx, osUtils)
+	if err != nil {
+		return false, err
+	}
+	return mounter.IsRawDiskLink(volumePath), nil
 }
@@ -0,0 +1,149 @@
+//go:build windows
+// +build windows
+
+/*
+Copyright 2025 The Kubernetes Authors.
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+    http://www.apache.org/licenses/LICENSE-2.0
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package osutils
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/mounter"
+)
+
+// fakeCSIProxyMounter is a fake of the CSI Proxy mounter, which reports a
+// single disk.
+type fakeCSIProxyMounter struct {
+	mounter.CSIProxyMounter
+	diskNumberErr error
+	isOnline      bool
+	isReadOnly    bool
+	// diskSizes are the sizes the disk reports, one per query. The last size
+	// is reported once all others have been.
+	diskSizes       []int64
+	volumeSizeBytes int64
+	rescans         int
+	resized         bool
+}
+
+func (f *fakeCSIProxyMounter) GetDiskNumberFromPath(ctx context.Context, path string) (uint32, error) {
+	return 2, f.diskNumberErr
+}
+
+func (f *fakeCSIProxyMounter) GetDiskCondition(ctx context.Context, diskNumber uint32) (bool, bool, error) {
+	return f.isOnline, f.isReadOnly, nil
+}
+
+func (f *fakeCSIProxyMounter) GetDiskTotalBytes(ctx context.Context, devicePath string) (int64, error) {
+	size := f.diskSizes[0]
+	if len(f.diskSizes) > 1 {
+		f.diskSizes = f.diskSizes[1:]
+	}
+	return size, nil
+}
+
+func (f *fakeCSIProxyMounter) Rescan(ctx context.Context) error {
+	f.rescans++
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) ResizeVolume(ctx context.Context, devicePath string, sizeInBytes int64) error {
+	f.resized = true
+	return nil
+}
+
+func (f *fakeCSIProxyMounter) GetVolumeSizeInBytes(ctx context.Context, devicePath string) (int64, error) {
+	return f.volumeSizeBytes, nil
+}
+
+func newTestOsUtils(fakeMounter *fakeCSIProxyMounter) *OsUtils {
+	return &OsUtils{Mounter: &mount.SafeFormatAndMount{Interface: fakeMounter}}
+}
+
+func TestGetVolumeCondition(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name          string
+		diskNumberErr error
+		isOnline      bool
+		isReadOnly    bool
+		abnormal      bool
+	}{
+		{name: "healthy disk", isOnline: true},
+		{name: "disk not found", diskNumberErr: errors.New("volume not found"), abnormal: true},
+		{name: "offline disk", abnormal: true},
+		{name: "read-only disk", isOnline: true, isReadOnly: true, abnormal: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			osUtils := newTestOsUtils(&fakeCSIProxyMounter{
+				diskNumberErr: test.diskNumberErr,
+				isOnline:      test.isOnline,
+				isReadOnly:    test.isReadOnly,
+			})
+			condition, err := osUtils.GetVolumeCondition(ctx, `c:\var\lib\kubelet\pods\pod1\volumes\pvc-1234`)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if condition.Abnormal != test.abnormal {
+				t.Errorf("expected abnormal %v, got condition %+v", test.abnormal, condition)
+			}
+		})
+	}
+}
+
+func TestResizeVolumeWaitsForDiskSize(t *testing.T) {
+	ctx := context.Background()
+	reqVolSizeBytes := int64(2 * common.GbInBytes)
+
+	// The disk reports its new size after a rescan.
+	fakeMounter := &fakeCSIProxyMounter{
+		diskSizes:       []int64{common.GbInBytes, reqVolSizeBytes},
+		volumeSizeBytes: reqVolSizeBytes - partitionOverheadBytes,
+	}
+	if err := newTestOsUtils(fakeMounter).ResizeVolume(ctx, "volume1", "", reqVolSizeBytes); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if fakeMounter.rescans != 1 || !fakeMounter.resized {
+		t.Errorf("expected 1 rescan and the volume to be resized, got %d rescans and resized %v",
+			fakeMounter.rescans, fakeMounter.resized)
+	}
+
+	// The volume does not span the expanded disk.
+	fakeMounter = &fakeCSIProxyMounter{
+		diskSizes:       []int64{reqVolSizeBytes},
+		volumeSizeBytes: common.GbInBytes,
+	}
+	err := newTestOsUtils(fakeMounter).ResizeVolume(ctx, "volume1", "", reqVolSizeBytes)
+	if status.Code(err) != codes.Internal {
+		t.Errorf("expected error code %v, got %v", codes.Internal, err)
+	}
+
+	// Waiting for the disk size stops when the context is done.
+	cancelledCtx, cancel := context.WithCancel(ctx)
+	cancel()
+	fakeMounter = &fakeCSIProxyMounter{diskSizes: []int64{common.GbInBytes}}
+	err = newTestOsUtils(fakeMounter).ResizeVolume(cancelledCtx, "volume1", "", reqVolSizeBytes)
+	if status.Code(err) != codes.Canceled || fakeMounter.resized {
+		t.Errorf("expected error code %v without resizing the volume, got %v and resized %v",
+			codes.Canceled, err, fakeMounter.resized)
+	}
+}
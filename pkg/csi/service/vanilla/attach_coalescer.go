@@ -0,0 +1,314 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/utils/clock"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// pendingAttach holds the state of a single volume attach waiting in, or
+// being processed by, a batch. Publish calls for the same volume and node VM
+// share one pendingAttach, so retries from the external-attacher wait for the
+// attach already in progress instead of attaching the volume again.
+type pendingAttach struct {
+	volumeID string
+	// nvme is set if the volume is attached to a NVMe controller. The
+	// controller is picked when the batch is processed.
+	nvme bool
+	// waiters is the number of publish calls which waited on the attach.
+	waiters int
+	// deadline is the latest deadline among the publish calls which waited
+	// on the attach.
+	deadline time.Time
+	// done is closed once diskUUID, faultType and err are set.
+	done      chan struct{}
+	diskUUID  string
+	faultType string
+	err       error
+}
+
+// attachBatch is the set of attaches collected for a node VM during one
+// batching window.
+type attachBatch struct {
+	volumeManager cnsvolume.Manager
+	nodeVM        *cnsvsphere.VirtualMachine
+	attaches      []*pendingAttach
+	// windowElapsed is set when the batching window of the batch elapsed
+	// while an earlier batch for the node VM was still being processed.
+	windowElapsed bool
+}
+
+// attachCoalescer collects concurrent ControllerPublishVolume calls for the
+// same node VM over a short window and attaches the volumes with a single
+// BatchAttachVolumes call, instead of one VM reconfigure per volume.
+type attachCoalescer struct {
+	window time.Duration
+	clock  clock.WithDelayedExecution
+	mutex  sync.Mutex
+	// batches holds the batch currently collecting attaches, keyed by node VM UUID.
+	batches map[string]*attachBatch
+	// processing holds the node VMs for which a batch is being processed.
+	// Batches for the same node VM are processed one after the other, as the
+	// reconfigures of a VM can not run concurrently.
+	processing map[string]bool
+	// attaches holds all attaches which are waiting or in progress, keyed by
+	// node VM UUID and volume ID.
+	attaches map[string]*pendingAttach
+	// isDiskAttached returns the disk UUID of the volume if it is already
+	// attached to the node VM.
+	isDiskAttached func(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		checkNVMeController bool) (string, error)
+	// getVolumeAttachCapacity returns the volume attach capacity of the node VM.
+	getVolumeAttachCapacity func(vm *cnsvsphere.VirtualMachine,
+		ctx context.Context) (*cnsvsphere.VolumeAttachCapacity, error)
+}
+
+// newAttachCoalescer returns an attachCoalescer which batches the attaches
+// for a node VM received within the given window.
+func newAttachCoalescer(window time.Duration) *attachCoalescer {
+	return &attachCoalescer{
+		window:                  window,
+		clock:                   clock.RealClock{},
+		batches:                 make(map[string]*attachBatch),
+		processing:              make(map[string]bool),
+		attaches:                make(map[string]*pendingAttach),
+		isDiskAttached:          cnsvolume.IsDiskAttached,
+		getVolumeAttachCapacity: (*cnsvsphere.VirtualMachine).GetVolumeAttachCapacity,
+	}
+}
+
+// attachKey returns the key used to track the attach of a volume to a node VM.
+func attachKey(nodeVM *cnsvsphere.VirtualMachine, volumeID string) string {
+	return nodeVM.UUID + "/" + volumeID
+}
+
+// AttachVolume attaches the volume to the node VM as part of the batch for
+// the node VM and returns the disk UUID, or the fault type and error of the
+// attach. If nvme is set, the volume is attached to a NVMe controller of the
+// node VM with a free namespace. If ctx is done before the batch completes,
+// the attach keeps running in the background and a retry of the call will
+// pick up its result.
+func (ac *attachCoalescer) AttachVolume(ctx context.Context, volumeManager cnsvolume.Manager,
+	nodeVM *cnsvsphere.VirtualMachine, volumeID string, nvme bool) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	key := attachKey(nodeVM, volumeID)
+	ac.mutex.Lock()
+	attach, found := ac.attaches[key]
+	if found {
+		log.Infof("attach of volume %q to node VM %q is already in progress, waiting for its result "+
+			"along with %d other publish call(s)", volumeID, nodeVM.UUID, attach.waiters)
+	} else {
+		attach = &pendingAttach{
+			volumeID: volumeID,
+			nvme:     nvme,
+			done:     make(chan struct{}),
+		}
+		ac.attaches[key] = attach
+		batch, batchFound := ac.batches[nodeVM.UUID]
+		if !batchFound {
+			batch = &attachBatch{
+				volumeManager: volumeManager,
+				nodeVM:        nodeVM,
+			}
+			ac.batches[nodeVM.UUID] = batch
+			// The batch is flushed in its own goroutine, as clocks may run
+			// the function synchronously.
+			ac.clock.AfterFunc(ac.window, func() {
+				go ac.flush(nodeVM.UUID)
+			})
+		}
+		batch.attaches = append(batch.attaches, attach)
+		log.Debugf("volume %q added to attach batch of node VM %q with %d volume(s)",
+			volumeID, nodeVM.UUID, len(batch.attaches))
+	}
+	attach.waiters++
+	// Publish calls without a deadline are bounded by the default timeout of
+	// CNS operations, as the sidecars would do.
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(cnsvolume.VolumeOperationTimeoutInSeconds * time.Second)
+	}
+	if deadline.After(attach.deadline) {
+		attach.deadline = deadline
+	}
+	ac.mutex.Unlock()
+
+	select {
+	case <-attach.done:
+		return attach.diskUUID, attach.faultType, attach.err
+	case <-ctx.Done():
+		return "", csifault.CSIInternalFault, logger.LogNewErrorf(log,
+			"timed out waiting for attach of volume %q to node VM %q. Error: %v",
+			volumeID, nodeVM.UUID, ctx.Err())
+	}
+}
+
+// flush removes the batch collected for the node VM and attaches its volumes.
+// If a batch for the node VM is already being processed, the batch is left
+// to be processed once that one completes.
+func (ac *attachCoalescer) flush(nodeVMUUID string) {
+	ac.mutex.Lock()
+	batch := ac.batches[nodeVMUUID]
+	if batch == nil {
+		ac.mutex.Unlock()
+		return
+	}
+	if ac.processing[nodeVMUUID] {
+		batch.windowElapsed = true
+		ac.mutex.Unlock()
+		return
+	}
+	delete(ac.batches, nodeVMUUID)
+	ac.processing[nodeVMUUID] = true
+	ac.mutex.Unlock()
+
+	for batch != nil {
+		// The batch is shared by several publish calls, so it is not bound to
+		// the context of any one of them, but runs until the latest of their
+		// deadlines.
+		ac.mutex.Lock()
+		var deadline time.Time
+		for _, attach := range batch.attaches {
+			if attach.deadline.After(deadline) {
+				deadline = attach.deadline
+			}
+		}
+		ac.mutex.Unlock()
+		ctx, cancel := context.WithDeadline(logger.NewContextWithLogger(context.Background()), deadline)
+		ac.processBatch(ctx, batch)
+		cancel()
+
+		ac.mutex.Lock()
+		for _, attach := range batch.attaches {
+			delete(ac.attaches, attachKey(batch.nodeVM, attach.volumeID))
+			close(attach.done)
+		}
+		// Pick up the next batch for the node VM if its window elapsed while
+		// this batch was being processed. Otherwise it is flushed once its
+		// window elapses.
+		batch = ac.batches[nodeVMUUID]
+		if batch != nil && batch.windowElapsed {
+			delete(ac.batches, nodeVMUUID)
+		} else {
+			batch = nil
+			delete(ac.processing, nodeVMUUID)
+		}
+		ac.mutex.Unlock()
+	}
+}
+
+// processBatch attaches the volumes of the batch which are not yet attached
+// to the node VM and sets the result of each attach.
+func (ac *attachCoalescer) processBatch(ctx context.Context, batch *attachBatch) {
+	log := logger.GetLogger(ctx)
+	var attachRequests []cnsvolume.BatchAttachRequest
+	toAttach := make(map[string]*pendingAttach)
+	// freeNVMeSlots holds the free namespaces of the NVMe controllers of the
+	// node VM. It is read once per batch, so that the volumes of the batch are
+	// spread over the free namespaces instead of all being sent to the same
+	// controller.
+	var freeNVMeSlots map[int32]int
+	for _, attach := range batch.attaches {
+		diskUUID, err := ac.isDiskAttached(ctx, batch.nodeVM, attach.volumeID, false)
+		if err != nil {
+			attach.faultType = csifault.CSIInternalFault
+			attach.err = err
+			continue
+		}
+		if diskUUID != "" {
+			log.Infof("volume %q is already attached to node VM %v", attach.volumeID, batch.nodeVM)
+			attach.diskUUID = diskUUID
+			continue
+		}
+		var controllerKey *int32
+		if attach.nvme {
+			if freeNVMeSlots == nil {
+				capacity, err := ac.getVolumeAttachCapacity(batch.nodeVM, ctx)
+				if err != nil {
+					attach.faultType = csifault.CSIInternalFault
+					attach.err = err
+					continue
+				}
+				freeNVMeSlots = capacity.FreeNVMeSlotsPerController
+			}
+			controllerKey = takeNVMeSlot(freeNVMeSlots)
+			if controllerKey == nil {
+				attach.faultType = csifault.CSIInternalFault
+				attach.err = logger.LogNewErrorf(log, "no NVMe controller with a free slot available on "+
+					"node VM %v to attach volume %q", batch.nodeVM, attach.volumeID)
+				continue
+			}
+		}
+		attachRequests = append(attachRequests, cnsvolume.BatchAttachRequest{
+			VolumeID:      attach.volumeID,
+			ControllerKey: controllerKey,
+		})
+		toAttach[attach.volumeID] = attach
+	}
+	if len(attachRequests) == 0 {
+		return
+	}
+	log.Infof("Attaching %d volume(s) to node VM %v in a single batch", len(attachRequests), batch.nodeVM)
+	attachResults, faultType, err := batch.volumeManager.BatchAttachVolumes(ctx, batch.nodeVM, attachRequests)
+	for _, result := range attachResults {
+		attach, ok := toAttach[result.VolumeID]
+		if !ok {
+			continue
+		}
+		attach.diskUUID = result.DiskUUID
+		attach.faultType = result.FaultType
+		attach.err = result.Error
+		delete(toAttach, result.VolumeID)
+	}
+	// Volumes without a result of their own get the error of the batch.
+	for volumeID, attach := range toAttach {
+		if err != nil {
+			attach.faultType = faultType
+			attach.err = err
+		} else {
+			attach.faultType = csifault.CSIInternalFault
+			attach.err = logger.LogNewErrorf(log, "attach result not found for volume %q on node VM %v",
+				volumeID, batch.nodeVM)
+		}
+	}
+}
+
+// takeNVMeSlot takes a free namespace from the NVMe controller with the lowest
+// key which has one and returns the key of the controller. It returns nil if
+// no NVMe controller has a free namespace left.
+func takeNVMeSlot(freeNVMeSlots map[int32]int) *int32 {
+	var controllerKey *int32
+	for key, free := range freeNVMeSlots {
+		if free > 0 && (controllerKey == nil || key < *controllerKey) {
+			freeKey := key
+			controllerKey = &freeKey
+		}
+	}
+	if controllerKey != nil {
+		freeNVMeSlots[*controllerKey]--
+	}
+	return controllerKey
+}
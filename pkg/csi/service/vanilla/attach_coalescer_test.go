@@ -0,0 +1,278 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	testingclock "k8s.io/utils/clock/testing"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+)
+
+// fakeBatchAttachManager records the BatchAttachVolumes calls made to it.
+// If release is set, each call waits for a value on it before returning.
+type fakeBatchAttachManager struct {
+	cnsvolume.Manager
+	mutex        sync.Mutex
+	calls        [][]cnsvolume.BatchAttachRequest
+	deadlines    []time.Time
+	failVolumeID string
+	release      chan struct{}
+	running      int
+	maxRunning   int
+}
+
+func (m *fakeBatchAttachManager) BatchAttachVolumes(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	batchAttachRequest []cnsvolume.BatchAttachRequest) ([]cnsvolume.BatchAttachResult, string, error) {
+	m.mutex.Lock()
+	m.calls = append(m.calls, batchAttachRequest)
+	deadline, _ := ctx.Deadline()
+	m.deadlines = append(m.deadlines, deadline)
+	m.running++
+	m.maxRunning = max(m.maxRunning, m.running)
+	m.mutex.Unlock()
+	if m.release != nil {
+		<-m.release
+	}
+	m.mutex.Lock()
+	m.running--
+	m.mutex.Unlock()
+	var results []cnsvolume.BatchAttachResult
+	var err error
+	var faultType string
+	for _, request := range batchAttachRequest {
+		if request.VolumeID == m.failVolumeID {
+			err = errors.New("failed to attach volumes: " + request.VolumeID)
+			faultType = csifault.CSIBatchAttachFault
+			results = append(results, cnsvolume.BatchAttachResult{VolumeID: request.VolumeID,
+				Error: errors.New("attach failed"), FaultType: csifault.CSIInternalFault})
+			continue
+		}
+		results = append(results, cnsvolume.BatchAttachResult{VolumeID: request.VolumeID,
+			DiskUUID: "uuid-" + request.VolumeID})
+	}
+	return results, faultType, err
+}
+
+func TestAttachCoalescerBatchesAttachesPerNodeVM(t *testing.T) {
+	volumeManager := &fakeBatchAttachManager{failVolumeID: "vol-4"}
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	ac := newAttachCoalescer(time.Second)
+	ac.clock = fakeClock
+	ac.isDiskAttached = func(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		checkNVMeController bool) (string, error) {
+		if volumeID == "vol-3" {
+			return "uuid-attached", nil
+		}
+		return "", nil
+	}
+	nodeVM := &cnsvsphere.VirtualMachine{UUID: "vm-1"}
+
+	type attachResult struct {
+		diskUUID string
+		err      error
+	}
+	// vol-1 is published twice to simulate a retry from the external-attacher.
+	volumeIDs := []string{"vol-1", "vol-1", "vol-2", "vol-3", "vol-4"}
+	results := make([]attachResult, len(volumeIDs))
+	var wg sync.WaitGroup
+	for i, volumeID := range volumeIDs {
+		wg.Add(1)
+		go func(i int, volumeID string) {
+			defer wg.Done()
+			diskUUID, _, err := ac.AttachVolume(context.Background(), volumeManager, nodeVM, volumeID, false)
+			results[i] = attachResult{diskUUID: diskUUID, err: err}
+		}(i, volumeID)
+	}
+	waitForQueuedAttaches(t, ac, len(volumeIDs))
+	fakeClock.Step(time.Second)
+	wg.Wait()
+
+	assert.Len(t, volumeManager.calls, 1)
+	assert.Len(t, volumeManager.calls[0], 3)
+	assert.Equal(t, attachResult{diskUUID: "uuid-vol-1"}, results[0])
+	assert.Equal(t, attachResult{diskUUID: "uuid-vol-1"}, results[1])
+	assert.Equal(t, attachResult{diskUUID: "uuid-vol-2"}, results[2])
+	assert.Equal(t, attachResult{diskUUID: "uuid-attached"}, results[3])
+	assert.Error(t, results[4].err)
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	assert.Empty(t, ac.attaches)
+	assert.Empty(t, ac.batches)
+}
+
+func TestAttachCoalescerSerializesBatchesPerNodeVM(t *testing.T) {
+	volumeManager := &fakeBatchAttachManager{release: make(chan struct{})}
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	ac := newAttachCoalescer(time.Second)
+	ac.clock = fakeClock
+	ac.isDiskAttached = func(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		checkNVMeController bool) (string, error) {
+		return "", nil
+	}
+	nodeVM := &cnsvsphere.VirtualMachine{UUID: "vm-1"}
+
+	var wg sync.WaitGroup
+	attachVolume := func(volumeID string) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, err := ac.AttachVolume(context.Background(), volumeManager, nodeVM, volumeID, false)
+			assert.NoError(t, err)
+		}()
+	}
+	attachVolume("vol-1")
+	waitForQueuedAttaches(t, ac, 1)
+	fakeClock.Step(time.Second)
+	assert.Eventually(t, func() bool {
+		volumeManager.mutex.Lock()
+		defer volumeManager.mutex.Unlock()
+		return len(volumeManager.calls) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The window of the second batch elapses while the first batch is still
+	// being processed, so the second batch waits for it.
+	attachVolume("vol-2")
+	waitForQueuedAttaches(t, ac, 2)
+	fakeClock.Step(time.Second)
+	assert.Eventually(t, func() bool {
+		ac.mutex.Lock()
+		defer ac.mutex.Unlock()
+		return ac.batches[nodeVM.UUID] != nil && ac.batches[nodeVM.UUID].windowElapsed
+	}, 5*time.Second, 10*time.Millisecond)
+	volumeManager.mutex.Lock()
+	assert.Len(t, volumeManager.calls, 1)
+	volumeManager.mutex.Unlock()
+
+	volumeManager.release <- struct{}{}
+	volumeManager.release <- struct{}{}
+	wg.Wait()
+	volumeManager.mutex.Lock()
+	defer volumeManager.mutex.Unlock()
+	assert.Len(t, volumeManager.calls, 2)
+	assert.Equal(t, 1, volumeManager.maxRunning)
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	assert.Empty(t, ac.attaches)
+	assert.Empty(t, ac.batches)
+	assert.Empty(t, ac.processing)
+}
+
+// waitForQueuedAttaches waits until the given number of publish calls wait
+// on the attaches queued in the attach coalescer.
+func waitForQueuedAttaches(t *testing.T, ac *attachCoalescer, count int) {
+	assert.Eventually(t, func() bool {
+		ac.mutex.Lock()
+		defer ac.mutex.Unlock()
+		waiters := 0
+		for _, attach := range ac.attaches {
+			waiters += attach.waiters
+		}
+		return waiters == count
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestAttachCoalescerReturnsOnContextDone(t *testing.T) {
+	volumeManager := &fakeBatchAttachManager{}
+	ac := newAttachCoalescer(time.Hour)
+	nodeVM := &cnsvsphere.VirtualMachine{UUID: "vm-1"}
+	waitCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, faultType, err := ac.AttachVolume(waitCtx, volumeManager, nodeVM, "vol-1", false)
+	assert.Error(t, err)
+	assert.Equal(t, csifault.CSIInternalFault, faultType)
+	// The attach stays queued so that a retry waits for it.
+	assert.Contains(t, ac.attaches, attachKey(nodeVM, "vol-1"))
+}
+
+func TestAttachCoalescerAssignsNVMeSlotsFromOneCapacity(t *testing.T) {
+	volumeManager := &fakeBatchAttachManager{}
+	fakeClock := testingclock.NewFakeClock(time.Now())
+	ac := newAttachCoalescer(time.Second)
+	ac.clock = fakeClock
+	ac.isDiskAttached = func(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+		checkNVMeController bool) (string, error) {
+		return "", nil
+	}
+	capacityCalls := 0
+	ac.getVolumeAttachCapacity = func(vm *cnsvsphere.VirtualMachine,
+		ctx context.Context) (*cnsvsphere.VolumeAttachCapacity, error) {
+		capacityCalls++
+		return &cnsvsphere.VolumeAttachCapacity{
+			FreeNVMeSlotsPerController: map[int32]int{31000: 1, 31001: 1},
+		}, nil
+	}
+	nodeVM := &cnsvsphere.VirtualMachine{UUID: "vm-1"}
+
+	// The first publish has the later deadline, so the batch runs until then.
+	deadline := time.Now().Add(time.Hour)
+	deadlineCtx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), time.Minute)
+	defer shortCancel()
+	volumeIDs := []string{"vol-1", "vol-2", "vol-3", "vol-4"}
+	errs := make([]error, len(volumeIDs))
+	var wg sync.WaitGroup
+	for i, volumeID := range volumeIDs {
+		ctx := shortCtx
+		if i == 0 {
+			ctx = deadlineCtx
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// vol-4 is attached to a PVSCSI controller.
+			_, _, errs[i] = ac.AttachVolume(ctx, volumeManager, nodeVM, volumeID, volumeID != "vol-4")
+		}()
+	}
+	waitForQueuedAttaches(t, ac, len(volumeIDs))
+	fakeClock.Step(time.Second)
+	wg.Wait()
+
+	assert.Equal(t, 1, capacityCalls)
+	assert.Len(t, volumeManager.calls, 1)
+	assert.True(t, volumeManager.deadlines[0].Equal(deadline))
+	controllerKeys := make(map[int32]string)
+	for _, request := range volumeManager.calls[0] {
+		if request.VolumeID == "vol-4" {
+			assert.Nil(t, request.ControllerKey)
+			continue
+		}
+		if assert.NotNil(t, request.ControllerKey) {
+			assert.NotContains(t, controllerKeys, *request.ControllerKey)
+			controllerKeys[*request.ControllerKey] = request.VolumeID
+		}
+	}
+	assert.Len(t, controllerKeys, 2)
+	// Only two NVMe namespaces are free, so one of the three NVMe attaches fails.
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	assert.Equal(t, 1, failed)
+	assert.NoError(t, errs[3])
+}
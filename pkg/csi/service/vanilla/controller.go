@@ -23,8 +23,10 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
@@ -35,6 +37,7 @@ import (
 	"github.com/vmware/govmomi/units"
 	"github.com/vmware/govmomi/vim25/types"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/types/known/timestamppb"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 
@@ -98,26 +101,12 @@ func (d *defaultTopologyCalculator) CalculateAccessibleTopology(ctx context.Cont
 	// CreateVolume API.
 	datastoreURL := params.VolumeInfo.DatastoreURL
 	if datastoreURL == "" {
-		volumeIds := []cnstypes.CnsVolumeId{{Id: params.VolumeInfo.VolumeID.Id}}
-		queryFilter := cnstypes.CnsQueryFilter{
-			VolumeIds: volumeIds,
-		}
-
-		querySelection := cnstypes.CnsQuerySelection{
-			Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
-		}
-		queryResult, err := utils.QueryVolumeUtil(ctx, params.VolumeManager, queryFilter, &querySelection)
+		var err error
+		datastoreURL, err = queryVolumeDatastoreURL(ctx, params.VolumeManager, params.VolumeInfo.VolumeID.Id,
+			params.VCHost)
 		if err != nil {
-			return nil, logger.LogNewErrorCodef(log, codes.Internal,
-				"queryVolumeUtil failed for volumeID: %s in vCenter %q. Error: %+v",
-				params.VolumeInfo.VolumeID.Id, params.VCHost, err)
-		}
-		if len(queryResult.Volumes) == 0 || queryResult.Volumes[0].DatastoreUrl == "" {
-			return nil, logger.LogNewErrorCodef(log, codes.Internal,
-				"queryVolumeUtil could not retrieve volume information for volume ID: %q in vCenter %q",
-				params.VolumeInfo.VolumeID.Id, params.VCHost)
+			return nil, err
 		}
-		datastoreURL = queryResult.Volumes[0].DatastoreUrl
 	}
 
 	// Retrieve datastore topology information from CSINodeTopology CRs.
@@ -145,6 +134,13 @@ type controller struct {
 	topologyMgr commoncotypes.ControllerTopologyService
 	csi.UnimplementedControllerServer
 	topologyCalc TopologyCalculatorInterface
+	// attachCoalescer batches concurrent attaches to the same node VM.
+	// It is nil when attach batching is disabled.
+	attachCoalescer *attachCoalescer
+	// fileBackends holds the file volume backends keyed by the value of the
+	// "filebackend" StorageClass param. It is initialized on first use.
+	fileBackends     map[string]fileVolumeBackend
+	fileBackendsOnce sync.Once
 }
 
 var (
@@ -168,10 +164,7 @@ var (
 	// filters out all the potential shared datastores in a volume provisioning call.
 	errAllDSFilteredOut = errors.New("auth service could not find datastore for block volume provisioning")
 
-	// variable for list snapshots
-	CNSSnapshotsForListSnapshots = make([]cnstypes.CnsSnapshotQueryResultEntry, 0)
-	CNSVolumeDetailsMap          = make([]map[string]*utils.CnsVolumeDetails, 0)
-	volumeIDToNodeUUIDMap        = make(map[string]string)
+	volumeIDToNodeUUIDMap = make(map[string]string)
 )
 
 // New creates a CNS controller.
@@ -204,6 +197,13 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		common.CnsMgrSuspendCreateVolume)
 	isTopologyAwareFileVolumeEnabled = commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
 		common.TopologyAwareFileVolume)
+	if config.Global.AttachBatchWindowInMs > 0 {
+		log.Infof("Batching volume attaches per node VM with a window of %d ms",
+			config.Global.AttachBatchWindowInMs)
+		c.attachCoalescer = newAttachCoalescer(
+			time.Duration(config.Global.AttachBatchWindowInMs) * time.Millisecond)
+	}
+	cnsvolume.SetOperationTimeouts(ctx, config.VolumeOperationTimeout)
 
 	vcManager := cnsvsphere.GetVirtualCenterManager(ctx)
 	// Multi vCenter feature enabled
@@ -317,6 +317,9 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 	}
 
 	go cnsvolume.ClearInvalidTasksFromListView(true)
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeAttachLimits) {
+		go c.publishNodeVolumeAttachLimits(ctx)
+	}
 	cfgPath := cnsconfig.GetConfigPath(ctx)
 
 	watcher, err := fsnotify.NewWatcher()
@@ -436,6 +439,7 @@ func (c *controller) ReloadConfiguration() error {
 		c.authMgrs[newVCConfig.Host].ResetvCenterInstance(ctx, vcenter)
 	}
 	if newCfg != nil {
+		cnsvolume.SetOperationTimeouts(ctx, newCfg.VolumeOperationTimeout)
 		c.managers.CnsConfig = newCfg
 		log.Debugf("Updated managers.CnsConfig")
 	}
@@ -496,6 +500,10 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	if err := common.ValidateBlockVolumeStorageClassParams(scParams); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid storage class parameters for block volume. Error: %+v", err)
+	}
 
 	if scParams.CSIMigration == "true" {
 		if len(c.managers.VcenterConfigs) > 1 {
@@ -551,7 +559,10 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 	}
 	// Check if requested volume size and source snapshot size matches.
 	volumeSource := req.GetVolumeContentSource()
-	var contentSourceSnapshotID, snapshotDatastoreURL string
+	var contentSourceSnapshotID, snapshotDatastoreURL, snapshotVCHost string
+	// volFromSnapshotOnTargetDs allows restoring a snapshot on a datastore other
+	// than the snapshot datastore, e.g. in a different zone.
+	volFromSnapshotOnTargetDs := false
 	if volumeSource != nil {
 		sourceSnapshot := volumeSource.GetSnapshot()
 		if sourceSnapshot == nil {
@@ -600,11 +611,15 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				"snapshot size mismatch, requested volume size: %d but source snapshot size: %d",
 				volSizeBytes, snapshotSizeInBytes)
 		}
-		// Store the datastoreURL of snapshot for future use.
+		// Store the datastoreURL and vCenter of snapshot for future use.
 		snapshotDatastoreURL = cnsVolumeDetailsMap[cnsVolumeID].DatastoreUrl
+		snapshotVCHost = vCenterHost
+		volFromSnapshotOnTargetDs = commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.CrossZoneSnapshotRestore)
 		// If DatastoreURL parameter is given in StorageClass, check if
-		// snapshot datastore URL is same as DatastoreURL.
-		if scParams.DatastoreURL != "" {
+		// snapshot datastore URL is same as DatastoreURL, unless the snapshot
+		// can be restored on a different datastore.
+		if scParams.DatastoreURL != "" && !volFromSnapshotOnTargetDs {
 			if strings.TrimSpace(snapshotDatastoreURL) != strings.TrimSpace(scParams.DatastoreURL) {
 				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 					"datastore URL %q given in storage class does not match the snapshot datastore URL %q.",
@@ -788,6 +803,14 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 		if topologyRequirement != nil {
 			var topologySegmentsList []map[string]string
 			for vcHost, topologySegmentsList = range vcTopologySegmentsMap {
+				if volFromSnapshotOnTargetDs && vcHost != snapshotVCHost {
+					// A snapshot can only be restored in the vCenter it belongs to.
+					errMsg := fmt.Sprintf("Snapshot %q does not belong to vCenter %q",
+						contentSourceSnapshotID, vcHost)
+					log.Warn(errMsg)
+					combinedErrMssgs = append(combinedErrMssgs, errMsg)
+					continue
+				}
 				// Get VC instance.
 				vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
 				if err != nil {
@@ -875,6 +898,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 					},
 					common.CreateBlockVolumeOptions{
 						IsCSITransactionSupportEnabled: isCSITransactionSupportEnabled,
+						VolFromSnapshotOnTargetDs:      volFromSnapshotOnTargetDs,
 					})
 				if err != nil {
 					if cnsvolume.IsNotSupportedFaultType(ctx, faultType) {
@@ -892,6 +916,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 							},
 							common.CreateBlockVolumeOptions{
 								IsCSITransactionSupportEnabled: false,
+								VolFromSnapshotOnTargetDs:      volFromSnapshotOnTargetDs,
 							})
 					}
 					if err != nil {
@@ -960,6 +985,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 				},
 				common.CreateBlockVolumeOptions{
 					IsCSITransactionSupportEnabled: isCSITransactionSupportEnabled,
+					VolFromSnapshotOnTargetDs:      volFromSnapshotOnTargetDs,
 				})
 			if err != nil {
 				if cnsvolume.IsNotSupportedFaultType(ctx, faultType) {
@@ -977,6 +1003,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 						},
 						common.CreateBlockVolumeOptions{
 							IsCSITransactionSupportEnabled: false,
+							VolFromSnapshotOnTargetDs:      volFromSnapshotOnTargetDs,
 						})
 					if err != nil {
 						return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
@@ -998,9 +1025,7 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 			"failed to create volume. Errors encountered: %+v", combinedErrMssgs)
 	}
 
-	attributes := make(map[string]string)
-	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
-
+	attributes := getBlockVolumeAttributes(scParams)
 	if scParams.CSIMigration == "true" {
 		volumePath, err := volumeMigrationService.GetVolumePath(ctx, volumeInfo.VolumeID.Id)
 		if err != nil {
@@ -1075,6 +1100,20 @@ func (c *controller) createBlockVolumeWithPlacementEngineForMultiVC(ctx context.
 	return resp, "", nil
 }
 
+// getBlockVolumeAttributes returns the volume context recorded on the PV of a
+// new block volume, for single and multi vCenter deployments alike.
+func getBlockVolumeAttributes(scParams *common.StorageClassParams) map[string]string {
+	attributes := make(map[string]string)
+	attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+	if scParams.DiskControllerType != "" {
+		attributes[common.AttributeDiskControllerType] = scParams.DiskControllerType
+	}
+	if scParams.ExpansionMode == common.ExpansionModeOffline {
+		attributes[common.AttributeExpansionMode] = scParams.ExpansionMode
+	}
+	return attributes
+}
+
 // calculateAccessibleTopologiesForDatastore figures out the list of topologies from
 // which the given datastore is accessible when multi-VC FSS is enabled.
 func calculateAccessibleTopologiesForDatastore(ctx context.Context, vcenter *cnsvsphere.VirtualCenter,
@@ -1146,6 +1185,17 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"parsing storage class parameters failed with error: %+v", err)
 	}
+	if err := common.ValidateFileVolumeStorageClassParams(scParams); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid storage class parameters for file volume. Error: %+v", err)
+	}
+	if common.HasFileShareParams(scParams) &&
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileSharePermissions) {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameters %q, %q, %q and %q are not supported as %q feature is disabled",
+			common.AttributeNetPermissionIPs, common.AttributeNetPermission, common.AttributeRootSquash,
+			common.AttributeSoftQuotaPercent, common.FileSharePermissions)
+	}
 
 	var (
 		volTaskAlreadyRegistered bool
@@ -1154,6 +1204,10 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		volumeID                 string
 		vcenter                  *cnsvsphere.VirtualCenter
 		vcHost                   string
+		volumeMgr                cnsvolume.Manager
+		// fileShareCandidates holds the candidate datastores per requested
+		// topology segment of the VC the volume is created on.
+		fileShareCandidates []fileShareTopologyCandidate
 	)
 	// Get operation store
 	var operationStore cnsvolumeoperationrequest.VolumeOperationRequest
@@ -1189,6 +1243,11 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 				req.Name, volumeOperationDetails.VolumeID, volumeOperationDetails.OperationDetails.OpID)
 
 			volumeID = volumeOperationDetails.VolumeID
+			if volumeOperationDetails.OperationDetails.VCenterServer != "" {
+				vcHost = volumeOperationDetails.OperationDetails.VCenterServer
+			} else {
+				vcHost = c.managers.CnsConfig.Global.VCenterIP
+			}
 			volTaskAlreadyRegistered = true
 		} else if cnsvolume.IsTaskPending(volumeOperationDetails) {
 			volTaskAlreadyRegistered = true
@@ -1270,6 +1329,8 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 			Name:       req.Name,
 			ScParams:   scParams,
 			VolumeType: common.FileVolumeType,
+			SkipDefaultNetPermission: commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+				common.FileVolumeNodeACL),
 		}
 		var combinedErrMssgs []string
 		if topologyRequirement != nil {
@@ -1312,50 +1373,30 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 						scParams.StoragePolicyName, vcenter.Config.Host)
 				}
 
-				// Get accessible datastores in the VC's topology requirement.
-				candidateDatastores, err := placementengine.GetAllAccessibleDSInTopology(ctx,
-					topologySegmentsList, vcenter)
+				// Map the requested topology segments of the VC to the vSAN file service
+				// enabled datastores accessible from them.
+				candidates, err := c.getFileShareCandidatesForVC(ctx, vcenter, topologySegmentsList)
 				if err != nil {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-						"error finding candidate datastores in topology %+v for VC: %q",
-						topologySegmentsList, vcHost)
-				}
-				if len(candidateDatastores) == 0 {
-					errMsg := fmt.Sprintf("No candidate datastores available for file volume provisioning "+
-						"in topology: %+v in VC: %q", topologySegmentsList, vcenter.Config.Host)
-					log.Warn(errMsg)
-					combinedErrMssgs = append(combinedErrMssgs, errMsg)
+					combinedErrMssgs = append(combinedErrMssgs, err.Error())
 					continue
 				}
-
-				// Filter File service enabled DS from candidate datastores.
-				var fsEnabledCandidateDatastores []*cnsvsphere.DatastoreInfo
-				fsEnabledClusterToDsInfoMap := c.authMgrs[vcHost].GetFsEnabledClusterToDsMap(ctx)
-				for _, datastores := range fsEnabledClusterToDsInfoMap {
-					for _, fsEnabledDatastore := range datastores {
-						for _, ds := range candidateDatastores {
-							if ds.Info.Url == fsEnabledDatastore.Info.Url {
-								fsEnabledCandidateDatastores = append(fsEnabledCandidateDatastores, ds)
-							}
-						}
-					}
-				}
-				if len(fsEnabledCandidateDatastores) == 0 {
+				if len(candidates) == 0 {
 					// Possibly vsan file service is not enabled on any vsan cluster.
-					errMsg := fmt.Sprintf("No datastores found to create file volume on VC %q. vSAN file "+
-						"service may be disabled", vcHost)
+					errMsg := fmt.Sprintf("No datastores found to create file volume in topology %+v on VC %q. "+
+						"vSAN file service may be disabled", topologySegmentsList, vcHost)
 					log.Warn(errMsg)
 					combinedErrMssgs = append(combinedErrMssgs, errMsg)
 					continue
 				}
-				log.Infof("fsEnabledCandidateDatastores %v", fsEnabledCandidateDatastores)
 
 				// Filter Storage policy compatible datastores from candidate datastores list.
 				if scParams.StoragePolicyName != "" {
 					// Check storage policy compatibility.
 					var candidateDSMoRef []types.ManagedObjectReference
-					for _, ds := range fsEnabledCandidateDatastores {
-						candidateDSMoRef = append(candidateDSMoRef, ds.Reference())
+					for _, candidate := range candidates {
+						for _, ds := range candidate.datastores {
+							candidateDSMoRef = append(candidateDSMoRef, ds.Reference())
+						}
 					}
 					compat, err := vcenter.PbmCheckCompatibility(ctx, candidateDSMoRef, storagePolicyID)
 					if err != nil {
@@ -1370,45 +1411,77 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 					log.Infof("Datastores compatible with storage policy %q are %+v",
 						scParams.StoragePolicyName, compatibleDsMoids)
 
-					// Filter compatible datastores from candidate datastores list.
-					var compatibleDatastores []*cnsvsphere.DatastoreInfo
-					for _, ds := range fsEnabledCandidateDatastores {
-						if _, exists := compatibleDsMoids[ds.Reference().Value]; exists {
-							compatibleDatastores = append(compatibleDatastores, ds)
+					// Filter compatible datastores from the datastores of each segment.
+					var compatibleCandidates []fileShareTopologyCandidate
+					for _, candidate := range candidates {
+						var compatibleDatastores []*cnsvsphere.DatastoreInfo
+						for _, ds := range candidate.datastores {
+							if _, exists := compatibleDsMoids[ds.Reference().Value]; exists {
+								compatibleDatastores = append(compatibleDatastores, ds)
+							}
+						}
+						if len(compatibleDatastores) != 0 {
+							candidate.datastores = compatibleDatastores
+							compatibleCandidates = append(compatibleCandidates, candidate)
 						}
 					}
-					if len(compatibleDatastores) == 0 {
+					if len(compatibleCandidates) == 0 {
 						errMsg := fmt.Sprintf("No compatible datastores found for storage policy %q on VC %q",
 							scParams.StoragePolicyName, vcHost)
 						log.Warn(errMsg)
 						combinedErrMssgs = append(combinedErrMssgs, errMsg)
 						continue
 					}
-					fsEnabledCandidateDatastores = compatibleDatastores
+					candidates = compatibleCandidates
+				}
+				if common.IsKerberosNfsSecurity(scParams.NfsSecurity) {
+					var kerberosCandidates []fileShareTopologyCandidate
+					for _, candidate := range candidates {
+						candidate.datastores, err = filterKerberosEnabledDatastores(ctx, vcenter, candidate.datastores)
+						if err != nil {
+							return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+								"failed to check Kerberos support of vSAN file service. Error: %+v", err)
+						}
+						if len(candidate.datastores) != 0 {
+							kerberosCandidates = append(kerberosCandidates, candidate)
+						}
+					}
+					if len(kerberosCandidates) == 0 {
+						errMsg := fmt.Sprintf("No datastores found with Kerberos enabled on their vSAN file "+
+							"service domain in topology %+v on VC %q", topologySegmentsList, vcHost)
+						log.Warn(errMsg)
+						combinedErrMssgs = append(combinedErrMssgs, errMsg)
+						continue
+					}
+					candidates = kerberosCandidates
 				}
-				// TODO: Few errors encountered in CreateFileVolumeUtil can be retried instead of
-				// moving unto next VC. Need to throw a custom error for such scenarios.
-				volumeInfo, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
-					vcenter, c.managers.VolumeManagers[vcHost], c.managers.CnsConfig, &createVolumeSpec,
-					fsEnabledCandidateDatastores, []string{}, filterSuspendedDatastores, false, nil)
+				volumeMgr, err = GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
 				if err != nil {
-					log.Error(err)
-					combinedErrMssgs = append(combinedErrMssgs, err.Error())
-					continue
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
 				}
-				volumeID = volumeInfo.VolumeID.Id
-				log.Infof("volume %q created in vCenter %q.", volumeID, vcHost)
-
-				// In case task was successful from previous run but the CR was not created.
-				if len(c.managers.VcenterConfigs) > 1 {
-					// Create CNSVolumeInfo CR for the volume ID in case of multi VC deployment.
-					err = volumeInfoService.CreateVolumeInfo(ctx, volumeID, vcHost)
+				// Create the file share on the datastores of one segment at a time, in the
+				// order of preference, so that it is served from within that segment.
+				for _, candidate := range candidates {
+					log.Infof("Creating file volume in topology segment %+v on datastores %v",
+						candidate.segment, candidate.datastores)
+					// TODO: Few errors encountered in CreateFileVolumeUtil can be retried instead of
+					// moving unto next segment. Need to throw a custom error for such scenarios.
+					volumeInfo, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
+						vcenter, volumeMgr, c.managers.CnsConfig, &createVolumeSpec,
+						candidate.datastores, []string{}, filterSuspendedDatastores, false, nil)
 					if err != nil {
-						return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-							"failed to store volumeID %q for vCenter %q in CNSVolumeInfo CR. Error: %+v",
-							volumeInfo.VolumeID.Id, vcHost, err)
+						log.Error(err)
+						combinedErrMssgs = append(combinedErrMssgs, err.Error())
+						continue
 					}
+					volumeID = volumeInfo.VolumeID.Id
+					break
+				}
+				if volumeID == "" {
+					continue
 				}
+				fileShareCandidates = candidates
+				log.Infof("volume %q created in vCenter %q.", volumeID, vcHost)
 				break
 			}
 			// After iterating over all VCs, if volumeID is still empty, we error out.
@@ -1441,6 +1514,18 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to get vCenter instance for host %q. Error: %+v", vcHost, err)
 			}
+			if common.IsKerberosNfsSecurity(scParams.NfsSecurity) {
+				filteredDatastores, err = filterKerberosEnabledDatastores(ctx, vcenter, filteredDatastores)
+				if err != nil {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+						"failed to check Kerberos support of vSAN file service. Error: %+v", err)
+				}
+				if len(filteredDatastores) == 0 {
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+						"no datastores found with Kerberos enabled on their vSAN file service domain for "+
+							"%q value %q", common.AttributeNfsSecurity, scParams.NfsSecurity)
+				}
+			}
 			volumeInfo, faultType, err = common.CreateFileVolumeUtil(ctx, cnstypes.CnsClusterFlavorVanilla,
 				vcenter, c.managers.VolumeManagers[vcHost], c.managers.CnsConfig, &createVolumeSpec,
 				filteredDatastores, []string{}, filterSuspendedDatastores, false, nil)
@@ -1453,8 +1538,46 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 		}
 	}
 
+	if len(c.managers.VcenterConfigs) > 1 {
+		// Persist the VC chosen for this file volume, so that subsequent delete, expand,
+		// publish and full sync operations are routed to the right VC. This also covers
+		// the case where the task was successful in a previous run but the CR was not created.
+		err = createVolumeInfoIfNotExists(ctx, volumeID, vcHost)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to store volumeID %q for vCenter %q in CNSVolumeInfo CR. Error: %+v",
+				volumeID, vcHost, err)
+		}
+	}
+
+	if scParams.NfsSecurity != "" {
+		// This also covers the case where the task was successful in a previous
+		// run but the security type was not set.
+		if vcenter == nil {
+			vcenter, err = common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get vCenter instance for host %q. Error: %+v", vcHost, err)
+			}
+		}
+		err = setFileShareNfsSecurity(ctx, vcenter, c.managers.VolumeManagers[vcHost], volumeID,
+			scParams.NfsSecurity)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to set NFS security %q on file volume %q. Error: %+v", scParams.NfsSecurity, volumeID, err)
+		}
+	}
+
 	attributes := make(map[string]string)
 	attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
+	// The protocol version and security flavor are passed on to the node,
+	// which mounts the share with them.
+	if scParams.NfsVersion != "" {
+		attributes[common.AttributeNfsVersion] = scParams.NfsVersion
+	}
+	if scParams.NfsSecurity != "" {
+		attributes[common.AttributeNfsSecurity] = scParams.NfsSecurity
+	}
 
 	resp := &csi.CreateVolumeResponse{
 		Volume: &csi.Volume{
@@ -1463,6 +1586,19 @@ func (c *controller) createFileVolume(ctx context.Context, req *csi.CreateVolume
 			VolumeContext: attributes,
 		},
 	}
+	// For topology aware provisioning, return the topology segments from which
+	// the file share is served, so that the volume is only used in them.
+	if req.GetAccessibilityRequirements() != nil {
+		datastoreURL := ""
+		if volumeInfo != nil {
+			datastoreURL = volumeInfo.DatastoreURL
+		}
+		resp.Volume.AccessibleTopology, err = c.calculateFileVolumeAccessibleTopology(ctx,
+			req.GetAccessibilityRequirements(), volumeID, datastoreURL, vcHost, fileShareCandidates)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, err
+		}
+	}
 	return resp, "", nil
 }
 
@@ -1499,41 +1635,14 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 				}
 			}
 			volumeType = prometheus.PrometheusFileVolumeType
-			if len(c.managers.VcenterConfigs) > 1 {
-				isvSANFileServicesDisabledInAllVCs := true
-				for _, vcconfig := range c.managers.VcenterConfigs {
-					isvSANFileServicesSupported, err := c.managers.VcenterManager.IsvSANFileServicesSupported(ctx,
-						vcconfig.Host)
-					if err != nil {
-						return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
-							"failed to verify if vSAN file services is supported or not for vCenter: %s. "+
-								"Error:%+v", vcconfig.Host, err)
-					}
-					if isvSANFileServicesSupported {
-						isvSANFileServicesDisabledInAllVCs = false
-					}
-				}
-
-				if isvSANFileServicesDisabledInAllVCs {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
-						"fileshare volume creation is not supported on vSAN 67u3 release")
-				}
-				return c.createFileVolume(ctx, req)
-			} else {
-				isvSANFileServicesSupported, err := c.managers.VcenterManager.IsvSANFileServicesSupported(ctx,
-					c.managers.CnsConfig.Global.VCenterIP)
-				if err != nil {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-						"failed to verify if vSAN file services is supported or not. Error:%+v", err)
-				}
-				if !isvSANFileServicesSupported {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
-						"fileshare volume creation is not supported on vSAN 67u3 release")
-				}
-				return c.createFileVolume(ctx, req)
+			backend, err := c.getFileVolumeBackendForCreate(ctx, req.Parameters)
+			if err != nil {
+				return nil, csifault.CSIInvalidArgumentFault, err
 			}
+			return backend.CreateVolume(ctx, req)
 		}
 		volumeType = prometheus.PrometheusBlockVolumeType
+		// Block volumes of single and multi vCenter deployments share this path.
 		return c.createBlockVolumeWithPlacementEngineForMultiVC(ctx, req)
 	}
 	resp, faultType, err := createVolumeInternal()
@@ -1586,6 +1695,14 @@ func (c *controller) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequ
 		if err != nil {
 			return nil, csifault.CSIInvalidArgumentFault, err
 		}
+		if backend := c.getFileVolumeBackendForVolumeID(req.VolumeId); backend != nil {
+			volumeType = prometheus.PrometheusFileVolumeType
+			faultType, err = backend.DeleteVolume(ctx, req)
+			if err != nil {
+				return nil, faultType, err
+			}
+			return &csi.DeleteVolumeResponse{}, "", nil
+		}
 		if strings.Contains(req.VolumeId, ".vmdk") {
 			volumeType = prometheus.PrometheusBlockVolumeType
 			cnsVolumeType = common.BlockVolumeType
@@ -1716,53 +1833,27 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 				"validation for PublishVolume Request: %+v has failed. Error: %v", req, err)
 		}
 		publishInfo := make(map[string]string)
-		_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId, volumeInfoService)
-		if err != nil {
-			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-				"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
-		}
 		// Check whether its a block or file volume.
 		if common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()}) {
 			volumeType = prometheus.PrometheusFileVolumeType
 			// File Volume.
-			queryFilter := cnstypes.CnsQueryFilter{
-				VolumeIds: []cnstypes.CnsVolumeId{{Id: req.VolumeId}},
-			}
-			querySelection := cnstypes.CnsQuerySelection{
-				Names: []string{
-					string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
-				},
+			backend := c.getFileVolumeBackendForVolumeID(req.VolumeId)
+			if backend == nil {
+				backend = c.getFileVolumeBackend(common.FileBackendVsanFileService)
 			}
-			// Select only the backing object details.
-			queryResult, err := volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+			var faultType string
+			publishInfo, faultType, err = backend.ControllerPublishVolume(ctx, req)
 			if err != nil {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"queryVolume failed for volumeID: %q with err=%+v", req.VolumeId, err)
-			}
-			if len(queryResult.Volumes) == 0 {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"volumeID %s not found in QueryVolume", req.VolumeId)
-			}
-
-			vSANFileBackingDetails :=
-				queryResult.Volumes[0].BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails)
-			publishInfo[common.AttributeDiskType] = common.DiskTypeFileVolume
-			nfsv4AccessPointFound := false
-			for _, kv := range vSANFileBackingDetails.AccessPoints {
-				if kv.Key == common.Nfsv4AccessPointKey {
-					publishInfo[common.Nfsv4AccessPoint] = kv.Value
-					nfsv4AccessPointFound = true
-					break
-				}
-			}
-			if !nfsv4AccessPointFound {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to get NFSv4 access point for volume: %q. Returned vSAN file backing details: %+v",
-					req.VolumeId, vSANFileBackingDetails)
+				return nil, faultType, err
 			}
 		} else {
 			// Block Volume.
 			volumeType = prometheus.PrometheusBlockVolumeType
+			_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId, volumeInfoService)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
+			}
 			if strings.Contains(req.VolumeId, ".vmdk") {
 				// In-tree volume support.
 				if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration) {
@@ -1804,13 +1895,70 @@ func (c *controller) ControllerPublishVolume(ctx context.Context, req *csi.Contr
 					"failed to find VirtualMachine for node:%q. Error: %v", req.NodeId, err)
 			}
 			log.Debugf("Found VirtualMachine for node:%q.", req.NodeId)
+			isVolumeAttachLimitsEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+				common.VolumeAttachLimits)
+			diskControllerType := req.VolumeContext[common.AttributeDiskControllerType]
+			var capacity *cnsvsphere.VolumeAttachCapacity
+			if isVolumeAttachLimitsEnabled || diskControllerType == common.DiskControllerTypeNVMe {
+				capacity, err = nodevm.GetVolumeAttachCapacity(ctx)
+				if err != nil {
+					if diskControllerType == common.DiskControllerTypeNVMe {
+						return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+							"failed to get volume attach capacity for node %q. Error: %v", req.NodeId, err)
+					}
+					log.Warnf("failed to get volume attach capacity for node %q. Error: %v", req.NodeId, err)
+				}
+			}
+			var nvmeControllerKey *int32
+			if diskControllerType == common.DiskControllerTypeNVMe {
+				_, attached := capacity.AttachedVolumes[req.VolumeId]
+				if capacity.NVMeControllerKeyWithFreeSlot == nil && !attached {
+					// NVMe controllers are not hot-added, so the volume can only be
+					// attached to an existing NVMe controller with a free namespace.
+					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.ResourceExhausted,
+						"no NVMe controller with a free slot available on node %q to attach volume %q",
+						req.NodeId, req.VolumeId)
+				}
+				nvmeControllerKey = capacity.NVMeControllerKeyWithFreeSlot
+			}
+			if isVolumeAttachLimitsEnabled && capacity != nil && nvmeControllerKey == nil {
+				if _, attached := capacity.AttachedVolumes[req.VolumeId]; !attached &&
+					capacity.FreePVSCSISlots == 0 {
+					if capacity.NVMeControllerKeyWithFreeSlot == nil {
+						// Fail early instead of letting the attach task fail on a node
+						// VM which has no free controller slot left.
+						return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.ResourceExhausted,
+							"no free controller slot available on node %q to attach volume %q",
+							req.NodeId, req.VolumeId)
+					}
+					// The NVMe slots are part of the published attach limit, so use
+					// them once every PVSCSI slot of the node VM is taken.
+					log.Infof("no free PVSCSI slot on node %q. Attaching volume %q to NVMe controller %d",
+						req.NodeId, req.VolumeId, *capacity.NVMeControllerKeyWithFreeSlot)
+					nvmeControllerKey = capacity.NVMeControllerKeyWithFreeSlot
+				}
+			}
 			// faultType is returned from manager.AttachVolume.
-			diskUUID, faultType, err := common.AttachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId,
-				false)
+			var diskUUID, faultType string
+			if c.attachCoalescer != nil {
+				// The coalescer picks the NVMe controller of each volume in the
+				// batch, so that concurrent publishes do not share a namespace.
+				diskUUID, faultType, err = c.attachCoalescer.AttachVolume(ctx, volumeManager, nodevm,
+					req.VolumeId, nvmeControllerKey != nil)
+			} else if nvmeControllerKey != nil {
+				diskUUID, faultType, err = attachVolumeToController(ctx, volumeManager, nodevm, req.VolumeId,
+					*nvmeControllerKey)
+			} else {
+				diskUUID, faultType, err = common.AttachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId,
+					false)
+			}
 			if err != nil {
 				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to attach disk: %+q with node: %q err %+v", req.VolumeId, req.NodeId, err)
 			}
+			if isVolumeAttachLimitsEnabled {
+				c.refreshNodeVolumeAttachLimit(ctx, req.NodeId, nodevm)
+			}
 			publishInfo[common.AttributeDiskType] = common.DiskTypeBlockVolume
 			publishInfo[common.AttributeFirstClassDiskUUID] = common.FormatDiskUUID(diskUUID)
 		}
@@ -1862,6 +2010,14 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.Internal,
 				"validation for UnpublishVolume Request: %+v has failed. Error: %v", req, err)
 		}
+		if backend := c.getFileVolumeBackendForVolumeID(req.VolumeId); backend != nil {
+			volumeType = prometheus.PrometheusFileVolumeType
+			faultType, err = backend.ControllerUnpublishVolume(ctx, req)
+			if err != nil {
+				return nil, faultType, err
+			}
+			return &csi.ControllerUnpublishVolumeResponse{}, "", nil
+		}
 
 		_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, c, req.VolumeId, volumeInfoService)
 		if err != nil {
@@ -1894,7 +2050,11 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 			}
 			if queryResult.Volumes[0].VolumeType == common.FileVolumeType {
 				volumeType = prometheus.PrometheusFileVolumeType
-				log.Infof("Skipping ControllerUnpublish for file volume %q", req.VolumeId)
+				faultType, err = c.getFileVolumeBackend(common.FileBackendVsanFileService).ControllerUnpublishVolume(
+					ctx, req)
+				if err != nil {
+					return nil, faultType, err
+				}
 				return &csi.ControllerUnpublishVolumeResponse{}, "", nil
 			}
 		} else {
@@ -1948,11 +2108,26 @@ func (c *controller) ControllerUnpublishVolume(ctx context.Context, req *csi.Con
 					"failed to find VirtualMachine for node:%q. Error: %v", req.NodeId, err)
 			}
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ForceDetachOnNodeFailure) {
+			forceDetached, faultType, err := c.forceDetachVolumeIfNodeOutOfService(ctx, volumeManager,
+				req.NodeId, nodevm, req.VolumeId)
+			if err != nil {
+				return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to force detach disk: %+q from node: %q err %+v", req.VolumeId, req.NodeId, err)
+			}
+			if forceDetached {
+				log.Infof("ControllerUnpublishVolume successful for volume ID: %s", req.VolumeId)
+				return &csi.ControllerUnpublishVolumeResponse{}, "", nil
+			}
+		}
 		faultType, err = common.DetachVolumeUtil(ctx, volumeManager, nodevm, req.VolumeId)
 		if err != nil {
 			return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to detach disk: %+q from node: %q err %+v", req.VolumeId, req.NodeId, err)
 		}
+		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.VolumeAttachLimits) {
+			c.refreshNodeVolumeAttachLimit(ctx, req.NodeId, nodevm)
+		}
 		log.Infof("ControllerUnpublishVolume successful for volume ID: %s", req.VolumeId)
 		return &csi.ControllerUnpublishVolumeResponse{}, "", nil
 	}
@@ -2002,6 +2177,10 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		// Later we may need to define different csi faults.
 
 		// csifault.CSIInternalFault csifault.CSIUnimplementedFault csifault.CSIInvalidArgumentFault
+		if backend := c.getFileVolumeBackendForVolumeID(req.VolumeId); backend != nil {
+			volumeType = prometheus.PrometheusFileVolumeType
+			return backend.ControllerExpandVolume(ctx, req)
+		}
 		if strings.Contains(req.VolumeId, ".vmdk") {
 			if err := initVolumeMigrationService(ctx, c); err != nil {
 				// Error is already wrapped in CSI error code.
@@ -2028,7 +2207,34 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 				"failed to check if online expansion is supported due to error: %v", err)
 		}
 		isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
-		err = validateVanillaControllerExpandVolumeRequest(ctx, req, isOnlineExpansionEnabled, isOnlineExpansionSupported)
+		isFileVolumeExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.FileVolumeExpansion)
+		isFileVolume := req.GetVolumeCapability() != nil &&
+			common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()})
+		isOfflineExpansionRequired := false
+		if isOnlineExpansionEnabled && isOnlineExpansionSupported && !isFileVolume {
+			// Honour the StorageClass "expansionmode" param of the volume, which
+			// restricts expansion to detached volumes.
+			volumeAttributes, err := commonco.ContainerOrchestratorUtility.GetVolumeAttributes(ctx, req.VolumeId)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get volume attributes for volume: %q. Error: %v", req.VolumeId, err)
+			}
+			if volumeAttributes[common.AttributeExpansionMode] == common.ExpansionModeOffline {
+				log.Infof("StorageClass of volume %q only allows offline expansion", req.VolumeId)
+				isOnlineExpansionEnabled = false
+				isOfflineExpansionRequired = true
+			}
+		}
+		err = validateVanillaControllerExpandVolumeRequest(ctx, req, isOnlineExpansionEnabled, isOnlineExpansionSupported,
+			isFileVolumeExpansionEnabled)
+		if err != nil && isOfflineExpansionRequired && status.Code(err) == codes.FailedPrecondition {
+			// The external-resizer records this message in the ControllerResizeError
+			// condition of the PVC.
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
+				"volume %q can only be expanded while detached from all nodes as StorageClass param %q is set to %q",
+				req.VolumeId, common.AttributeExpansionMode, common.ExpansionModeOffline)
+		}
 		if err != nil {
 			msg := fmt.Sprintf("validation for ExpandVolume Request: %+v has failed. Error: %v",
 				req, err)
@@ -2036,31 +2242,25 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 			return nil, csifault.CSIInternalFault, err
 		}
 		volumeType = prometheus.PrometheusBlockVolumeType
+		if isFileVolume {
+			volumeType = prometheus.PrometheusFileVolumeType
+		}
 
 		volumeID := req.GetVolumeId()
 		volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
 		volSizeMB := int64(common.RoundUpSize(volSizeBytes, common.MbInBytes))
 		// Check if the volume contains CNS snapshots.
-		if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot) {
+		if !isFileVolume && commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot) {
 			isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vCenterHost)
 			if err != nil {
 				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
 					"failed to check if cns snapshot is supported on VC due to error: %v", err)
 			}
 			if isCnsSnapshotSupported {
-				snapshots, _, err := common.QueryVolumeSnapshotsByVolumeID(ctx, volumeManager, volumeID,
-					common.QuerySnapshotLimit)
+				faultType, err := common.ValidateVolumeSnapshotsForExpansion(ctx, vCenterManager, vCenterHost,
+					volumeManager, volumeID)
 				if err != nil {
-					return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-						"failed to retrieve snapshots for volume: %s. Error: %+v", volumeID, err)
-				}
-				if len(snapshots) == 0 {
-					log.Infof("The volume %s can be safely expanded as no CNS snapshots were found.",
-						req.VolumeId)
-				} else {
-					return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
-						"volume: %s with existing snapshots %v cannot be expanded. "+
-							"Please delete snapshots before expanding the volume", req.VolumeId, snapshots)
+					return nil, faultType, err
 				}
 			}
 		}
@@ -2083,6 +2283,11 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		if _, ok := req.GetVolumeCapability().GetAccessType().(*csi.VolumeCapability_Block); ok {
 			nodeExpansionRequired = false
 		}
+		// vSAN file shares are resized on the file server, so NFS clients see
+		// the new capacity without any node side operation.
+		if isFileVolume {
+			nodeExpansionRequired = false
+		}
 		log.Debugf("ControllerExpandVolumeInternal: returns %v as capacity and %v as NodeExpansionRequired",
 			int64(units.FileSize(volSizeMB*common.MbInBytes)), nodeExpansionRequired)
 		resp := &csi.ControllerExpandVolumeResponse{
@@ -2440,13 +2645,10 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
 	var (
-		vCenterHost                              string
-		vCenterManager                           cnsvsphere.VirtualCenterManager
-		volumeManager                            cnsvolume.Manager
-		err                                      error
-		maxSnapshotsPerBlockVolume               int
-		granularMaxSnapshotsPerBlockVolumeInVSAN int
-		granularMaxSnapshotsPerBlockVolumeInVVOL int
+		vCenterHost    string
+		vCenterManager cnsvsphere.VirtualCenterManager
+		volumeManager  cnsvolume.Manager
+		err            error
 	)
 	log.Infof("CreateSnapshot: called with args %+v", req)
 
@@ -2505,33 +2707,8 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 					"Queried VolumeType: %v", volumeType, cnsVolumeDetailsMap[volumeID].VolumeType)
 		}
 		// Check if snapshots number of this volume reaches the granular limit on VSAN/VVOL
-		maxSnapshotsPerBlockVolume = c.managers.CnsConfig.Snapshot.GlobalMaxSnapshotsPerBlockVolume
-		granularMaxSnapshotsPerBlockVolumeInVSAN =
-			c.managers.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVSAN
-		granularMaxSnapshotsPerBlockVolumeInVVOL =
-			c.managers.CnsConfig.Snapshot.GranularMaxSnapshotsPerBlockVolumeInVVOL
-		log.Infof("The limit of the maximum number of snapshots per block volume is "+
-			"set to the global maximum (%v) by default.", maxSnapshotsPerBlockVolume)
-
-		if granularMaxSnapshotsPerBlockVolumeInVSAN > 0 || granularMaxSnapshotsPerBlockVolumeInVVOL > 0 {
-			var isGranularMaxEnabled bool
-			if strings.Contains(datastoreUrl, strings.ToLower(string(types.HostFileSystemVolumeFileSystemTypeVsan))) {
-				if granularMaxSnapshotsPerBlockVolumeInVSAN > 0 {
-					maxSnapshotsPerBlockVolume = granularMaxSnapshotsPerBlockVolumeInVSAN
-					isGranularMaxEnabled = true
-				}
-			} else if strings.Contains(datastoreUrl, strings.ToLower(string(types.HostFileSystemVolumeFileSystemTypeVVOL))) {
-				if granularMaxSnapshotsPerBlockVolumeInVVOL > 0 {
-					maxSnapshotsPerBlockVolume = granularMaxSnapshotsPerBlockVolumeInVVOL
-					isGranularMaxEnabled = true
-				}
-			}
-
-			if isGranularMaxEnabled {
-				log.Infof("The limit of the maximum number of snapshots per block volume on datastore %q is "+
-					"overridden by the granular maximum (%v).", datastoreUrl, maxSnapshotsPerBlockVolume)
-			}
-		}
+		maxSnapshotsPerBlockVolume := common.GetMaxSnapshotsPerBlockVolume(ctx,
+			c.managers.CnsConfig.Snapshot, datastoreUrl)
 
 		// Check if snapshots number of this volume reaches the limit
 		snapshotList, _, err := common.QueryVolumeSnapshotsByVolumeID(ctx, volumeManager, volumeID,
@@ -2740,11 +2917,27 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 				return nil, logger.LogNewErrorCodef(log, codes.Unimplemented,
 					"VC %s version does not support snapshot operations", vCenterHost)
 			}
-			snapshots, nextToken, err = common.ListSnapshotsUtil(ctx, volManager, req.SourceVolumeId,
-				req.SnapshotId, req.StartingToken, maxEntries)
+			var offset int64
+			if req.StartingToken != "" {
+				var tokenVCHost string
+				tokenVCHost, offset, err = common.DecodeListSnapshotsToken(req.StartingToken)
+				if err != nil {
+					return nil, logger.LogNewErrorCodef(log, codes.Aborted, "invalid starting token: %v", err)
+				}
+				if tokenVCHost != vCenterHost {
+					return nil, logger.LogNewErrorCodef(log, codes.Aborted,
+						"starting token for VC %q does not match VC %q of volume %q",
+						tokenVCHost, vCenterHost, req.SourceVolumeId)
+				}
+			}
+			snapshots, offset, err = common.QueryVolumeSnapshotsPage(ctx, volManager, req.SourceVolumeId,
+				offset, maxEntries)
 			if err != nil {
 				return nil, logger.LogNewErrorCodef(log, codes.Internal, " failed to retrieve the snapshots, err: %+v", err)
 			}
+			if offset != 0 {
+				nextToken = common.EncodeListSnapshotsToken(vCenterHost, offset)
+			}
 		} else {
 			snapshots, nextToken, err = queryAllVolumeSnapshotsForMultiVC(ctx, c, req.StartingToken, maxEntries)
 			if err != nil {
@@ -2779,164 +2972,68 @@ func (c *controller) ListSnapshots(ctx context.Context, req *csi.ListSnapshotsRe
 	return resp, err
 }
 
-// getSnapshots(): This func queries snapshot for given VC and returns the query results along with corresponding
-// source volume details
-func getSnapshotsAndSourceVolumeDetails(ctx context.Context, vCenterManager cnsvsphere.VirtualCenterManager,
-	volManager cnsvolume.Manager, vcHost string) ([]cnstypes.CnsSnapshotQueryResultEntry,
-	map[string]*utils.CnsVolumeDetails, error) {
-	var (
-		err                 error
-		snapshotQueryFilter cnstypes.CnsSnapshotQueryFilter
-		snapQueryEntries    = make([]cnstypes.CnsSnapshotQueryResultEntry, 0)
-		volumeIds           []cnstypes.CnsVolumeId
-	)
-	log := logger.GetLogger(ctx)
-	// Check for snapshot support
-	isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vcHost)
-	if err != nil {
-		return nil, nil, logger.LogNewErrorCodef(log, codes.Internal,
-			"failed to check if cns snapshot is supported on VC %s due to error: %v", vcHost, err)
-	}
-	if !isCnsSnapshotSupported {
-		return nil, nil, logger.LogNewErrorCodef(log, codes.Unimplemented,
-			"VC %s version does not support snapshot operations", vcHost)
-	}
-	// fetch all snapshot records for each VC
-	snapshotQueryFilter = cnstypes.CnsSnapshotQueryFilter{
-		Cursor: &cnstypes.CnsCursor{
-			Offset: 0,
-			Limit:  utils.DefaultQuerySnapshotLimit,
-		},
-	}
-	for {
-		queryResult, err := volManager.QuerySnapshots(ctx, snapshotQueryFilter)
-		if err != nil {
-			log.Errorf("getSnapshots failed for vCenter %s with snapfilter %v. Err=%+v",
-				vcHost, snapshotQueryFilter, err)
-			return nil, nil, err
-		}
-		log.Debugf("querySnapshots returned %d entries for VC %s", len(queryResult.Entries), vcHost)
-		snapQueryEntries = append(snapQueryEntries, queryResult.Entries...)
-		// If cursor offset < total snapshot records found, then there are more snapshots
-		// to be queried, so continue the loop to fetch remaining records
-		if queryResult.Cursor.Offset >= queryResult.Cursor.TotalRecords {
-			break
-		}
-		// Assign cursor for next iteration based on previous query result
-		snapshotQueryFilter.Cursor = &queryResult.Cursor
-	}
-	// populate list of volume-ids to retrieve the volume size.
-	for _, queryResult := range snapQueryEntries {
-		if queryResult.Error != nil {
-			return nil, nil, logger.LogNewErrorCodef(log, codes.Internal,
-				"faults are not expected when invoking QuerySnapshots without volume-id and snapshot-id, fault: %+v",
-				queryResult.Error.Fault)
-		}
-		volumeIds = append(volumeIds, queryResult.Snapshot.VolumeId)
-	}
-	// TODO: Retrieve Snapshot size directly from CnsQuerySnapshot once supported.
-	volumeDetails, err := utils.QueryVolumeDetailsUtil(ctx, volManager, volumeIds)
-	if err != nil {
-		log.Errorf("failed to retrieve volume details for volume-ids: %v for vCenter %s, err: %+v",
-			volumeIds, vcHost, err)
-		return nil, nil, err
-	}
-	return snapQueryEntries, volumeDetails, nil
-}
-
-// queryAllVolumeSnapshotsForMultiVC(): This func fetches all volume snapshots from all vCenters configured
-// and filters the result as per token provided
+// queryAllVolumeSnapshotsForMultiVC(): This func fetches at most maxEntries volume snapshots from the
+// configured vCenters, starting at the vCenter and CNS cursor offset encoded in the token. vCenters are
+// listed in sorted order so that tokens remain valid across calls. Snapshots are paged by CNS, so only the
+// snapshots served in this call are loaded.
 func queryAllVolumeSnapshotsForMultiVC(ctx context.Context, c *controller, token string,
 	maxEntries int64) ([]*csi.Snapshot, string, error) {
 	var (
-		err              error
-		csiSnapshots     []*csi.Snapshot
-		nextToken        string
-		finalSnapEntries []cnstypes.CnsSnapshotQueryResultEntry
-		snapQueryEntries = make([]cnstypes.CnsSnapshotQueryResultEntry, 0)
+		err          error
+		csiSnapshots []*csi.Snapshot
+		tokenVCHost  string
+		offset       int64
 	)
 	log := logger.GetLogger(ctx)
-	startingToken := 0
+	vcHosts := make([]string, 0, len(c.managers.VolumeManagers))
+	for vcHost := range c.managers.VolumeManagers {
+		vcHosts = append(vcHosts, vcHost)
+	}
+	slices.Sort(vcHosts)
+	startIndex := 0
 	if token != "" {
-		startingToken, err = strconv.Atoi(token)
+		tokenVCHost, offset, err = common.DecodeListSnapshotsToken(token)
 		if err != nil {
-			log.Errorf("failed to parse the token: %s err: %v", token, err)
-			return nil, "", err
+			return nil, "", logger.LogNewErrorCodef(log, codes.Aborted, "invalid starting token: %v", err)
 		}
-	}
-	// Get all snapshot entries from available vCenters
-	// startingToken = 0 indicates listSnapshots called as a new request and not part of
-	// previous request. Hence fetch all snapshots from CNS
-	if startingToken == 0 || len(CNSSnapshotsForListSnapshots) == 0 {
-		// For multi-VC configuration, query volumes from all vCenters
-		vCenterManager := getVCenterManagerForVCenter(ctx, c)
-		var cnsVolumeDetailsMap = make([]map[string]*utils.CnsVolumeDetails, 0)
-		for vcHost, volManager := range c.managers.VolumeManagers {
-			// fetch snapshots and associated source volume details
-			perVCSnapQueryEntries, volumeDetails, err := getSnapshotsAndSourceVolumeDetails(ctx, vCenterManager,
-				volManager, vcHost)
-			if err != nil {
-				log.Errorf("failed to retrieve snapshots/source volume info for vCenter %s, err: %+v", vcHost, err)
-				return nil, "", err
-			}
-			snapQueryEntries = append(snapQueryEntries, perVCSnapQueryEntries...)
-			cnsVolumeDetailsMap = append(cnsVolumeDetailsMap, volumeDetails)
+		startIndex = slices.Index(vcHosts, tokenVCHost)
+		if startIndex < 0 {
+			return nil, "", logger.LogNewErrorCodef(log, codes.Aborted,
+				"starting token refers to VC %q which is not configured", tokenVCHost)
 		}
-		CNSSnapshotsForListSnapshots = snapQueryEntries
-		CNSVolumeDetailsMap = cnsVolumeDetailsMap
 	}
-	// Process snapshot query result obtained from configured vCenter(s) and return final response
-	finalSnapEntries, nextToken, err = processQueryResultsListSnapshots(ctx, startingToken,
-		maxEntries, CNSSnapshotsForListSnapshots)
-	if err != nil {
-		return nil, csifault.CSIInternalFault, fmt.Errorf("error while processing query results for list "+
-			" snapshots, err: %v", err)
-	}
-	for _, queryResult := range finalSnapEntries {
-		snapshotCreateTimeInProto := timestamppb.New(queryResult.Snapshot.CreateTime)
-		csiSnapshotId := queryResult.Snapshot.VolumeId.Id +
-			common.VSphereCSISnapshotIdDelimiter + queryResult.Snapshot.SnapshotId.Id
-		// For every snapshot, find corresponding source volume details and fill the CSISnapshot response entry
-		for _, cnsVolumeDetailsMap := range CNSVolumeDetailsMap {
-			if _, ok := cnsVolumeDetailsMap[queryResult.Snapshot.VolumeId.Id]; !ok {
-				continue
-			}
-			csiSnapshotSize := cnsVolumeDetailsMap[queryResult.Snapshot.VolumeId.Id].SizeInMB * common.MbInBytes
-			csiSnapshotInfo := &csi.Snapshot{
-				SnapshotId:     csiSnapshotId,
-				SourceVolumeId: queryResult.Snapshot.VolumeId.Id,
-				CreationTime:   snapshotCreateTimeInProto,
-				SizeBytes:      csiSnapshotSize,
-				ReadyToUse:     true,
-			}
-			csiSnapshots = append(csiSnapshots, csiSnapshotInfo)
-			break
+	vCenterManager := getVCenterManagerForVCenter(ctx, c)
+	for i := startIndex; i < len(vcHosts); i++ {
+		vcHost := vcHosts[i]
+		// Check for snapshot support
+		isCnsSnapshotSupported, err := vCenterManager.IsCnsSnapshotSupported(ctx, vcHost)
+		if err != nil {
+			return nil, "", logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to check if cns snapshot is supported on VC %s due to error: %v", vcHost, err)
 		}
-	}
-	log.Debugf("queryAllVolumeSnapshotsForMultiVC served %d results out of total %d entries, token for next set: %s",
-		len(csiSnapshots), len(CNSSnapshotsForListSnapshots), nextToken)
-	return csiSnapshots, nextToken, nil
-}
-
-// processQueryResultsListSnapshots(): This func filters the list of snapshots as per token given
-func processQueryResultsListSnapshots(ctx context.Context, startingToken int,
-	maxEntries int64,
-	snapshotQueryResultEntries []cnstypes.CnsSnapshotQueryResultEntry) ([]cnstypes.CnsSnapshotQueryResultEntry,
-	string, error) {
-	nextToken := ""
-	nextTokenCounter := 0
-	var snapEntries = make([]cnstypes.CnsSnapshotQueryResultEntry, 0)
-	for i := startingToken; i < len(snapshotQueryResultEntries); i++ {
-		if len(snapEntries) == int(maxEntries) {
-			nextTokenCounter = i
-			break
+		if !isCnsSnapshotSupported {
+			return nil, "", logger.LogNewErrorCodef(log, codes.Unimplemented,
+				"VC %s version does not support snapshot operations", vcHost)
+		}
+		perVCSnapshots, nextOffset, err := common.QueryVolumeSnapshotsPage(ctx, c.managers.VolumeManagers[vcHost],
+			"", offset, maxEntries-int64(len(csiSnapshots)))
+		if err != nil {
+			log.Errorf("failed to retrieve snapshots for vCenter %s, err: %+v", vcHost, err)
+			return nil, "", err
+		}
+		csiSnapshots = append(csiSnapshots, perVCSnapshots...)
+		offset = 0
+		if nextOffset != 0 {
+			// More snapshots remain on this vCenter.
+			return csiSnapshots, common.EncodeListSnapshotsToken(vcHost, nextOffset), nil
+		}
+		if int64(len(csiSnapshots)) >= maxEntries && i+1 < len(vcHosts) {
+			// Continue with the next vCenter in the next call.
+			return csiSnapshots, common.EncodeListSnapshotsToken(vcHosts[i+1], 0), nil
 		}
-		snapEntries = append(snapEntries, snapshotQueryResultEntries[i])
-	}
-	if nextTokenCounter != 0 && len(snapshotQueryResultEntries) > nextTokenCounter {
-		nextToken = strconv.Itoa(nextTokenCounter)
 	}
-	return snapEntries, nextToken, nil
+	log.Debugf("queryAllVolumeSnapshotsForMultiVC served %d results", len(csiSnapshots))
+	return csiSnapshots, "", nil
 }
 
 func (c *controller) ControllerGetVolume(ctx context.Context, req *csi.ControllerGetVolumeRequest) (
@@ -19,26 +19,41 @@ package vanilla
 import (
 	"context"
 	"fmt"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	vsantypes "github.com/vmware/govmomi/vsan/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	v1 "k8s.io/api/core/v1"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/node"
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/placementengine"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
 )
 
+// volumeAttachLimitRefreshInterval is the interval at which the volume attach
+// limits of all node VMs are published again.
+const volumeAttachLimitRefreshInterval = 10 * time.Minute
+
+// isDiskAttachedToVM returns the disk UUID of the volume if it is attached to
+// the VM. It is a variable so that unit tests can replace it.
+var isDiskAttachedToVM = cnsvolume.IsDiskAttached
+
 // validateVanillaDeleteVolumeRequest is the helper function to validate
 // DeleteVolumeRequest for Vanilla CSI driver.
 // Function returns error if validation fails otherwise returns nil.
@@ -67,11 +82,16 @@ func validateVanillaControllerUnpublishVolumeRequest(ctx context.Context,
 // validate ExpandVolumeRequest for Vanilla CSI driver.
 // Function returns error if validation fails otherwise returns nil.
 func validateVanillaControllerExpandVolumeRequest(ctx context.Context,
-	req *csi.ControllerExpandVolumeRequest, isOnlineExpansionEnabled, isOnlineExpansionSupported bool) error {
+	req *csi.ControllerExpandVolumeRequest, isOnlineExpansionEnabled, isOnlineExpansionSupported,
+	isFileVolumeExpansionEnabled bool) error {
 	log := logger.GetLogger(ctx)
-	if err := common.ValidateControllerExpandVolumeRequest(ctx, req); err != nil {
+	if err := common.ValidateControllerExpandVolumeRequest(ctx, req, isFileVolumeExpansionEnabled); err != nil {
 		return err
 	}
+	// vSAN file shares can be expanded while they are mounted on nodes.
+	if common.IsFileVolumeRequest(ctx, []*csi.VolumeCapability{req.GetVolumeCapability()}) {
+		return nil
+	}
 
 	// Check online extend FSS and vCenter support.
 	if isOnlineExpansionEnabled && isOnlineExpansionSupported {
@@ -114,11 +134,11 @@ func validateVanillaListSnapshotRequest(ctx context.Context, req *csi.ListSnapsh
 		return logger.LogNewErrorCodef(log, codes.InvalidArgument,
 			"ListSnapshots MaxEntries: %d cannot be negative", maxEntries)
 	}
-	// validate the starting token by verifying that it can be converted to a int
+	// validate the starting token by verifying that it can be decoded
 	if req.StartingToken != "" {
-		_, err := strconv.Atoi(req.StartingToken)
+		_, _, err := common.DecodeListSnapshotsToken(req.StartingToken)
 		if err != nil {
-			return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			return logger.LogNewErrorCodef(log, codes.Aborted,
 				"ListSnapshots StartingToken: %s cannot be parsed", req.StartingToken)
 		}
 	}
@@ -272,3 +292,428 @@ func GetVolumeManagerFromVCHost(ctx context.Context, managers *common.Managers,
 	}
 	return volumeMgr, nil
 }
+
+// createVolumeInfoIfNotExists creates the CNSVolumeInfo CR which maps the given
+// volumeID to vCenterHost, if such a CR does not already exist.
+func createVolumeInfoIfNotExists(ctx context.Context, volumeID string, vCenterHost string) error {
+	log := logger.GetLogger(ctx)
+	if volumeInfoService == nil {
+		return logger.LogNewErrorf(log, "VolumeInfoService is not initialized")
+	}
+	crExists, err := volumeInfoService.VolumeInfoCrExistsForVolume(ctx, volumeID)
+	if err != nil {
+		return err
+	}
+	if crExists {
+		log.Debugf("CNSVolumeInfo CR already exists for volume %q", volumeID)
+		return nil
+	}
+	return volumeInfoService.CreateVolumeInfo(ctx, volumeID, vCenterHost)
+}
+
+// publishNodeVolumeAttachLimits publishes the volume attach limit of every
+// registered node VM, so that the scheduler honors the limit before the first
+// attach or detach on a node. It is repeated every volumeAttachLimitRefreshInterval
+// to cover nodes which register later, controllers changed outside of the driver
+// and file volumes published to the nodes. It returns when ctx is done.
+func (c *controller) publishNodeVolumeAttachLimits(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	ticker := time.NewTicker(volumeAttachLimitRefreshInterval)
+	defer ticker.Stop()
+	for {
+		nodeVMs, err := c.nodeMgr.GetAllNodes(ctx)
+		if err != nil {
+			log.Warnf("failed to get node VMs to publish volume attach limits. Error: %v", err)
+		} else {
+			for _, nodevm := range nodeVMs {
+				c.refreshNodeVolumeAttachLimit(ctx, nodevm.UUID, nodevm)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			log.Infof("Stopped publishing volume attach limits. Reason: %v", ctx.Err())
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// refreshNodeVolumeAttachLimit publishes the number of volumes which can be
+// attached to the given node VM as the allocatable count on the node's CSINode.
+// The count includes the volumes already attached, the free slots on the
+// existing and hot-addable PVSCSI controllers and the free namespaces on the
+// existing NVMe controllers. The scheduler counts every volume of the driver on
+// the node, so the file volumes published to the node, which use no controller
+// slot, are added as well. Failures are only logged as the allocatable count
+// is refreshed again on the next attach or detach.
+func (c *controller) refreshNodeVolumeAttachLimit(ctx context.Context, nodeID string,
+	nodevm *vsphere.VirtualMachine) {
+	log := logger.GetLogger(ctx)
+	capacity, err := nodevm.GetVolumeAttachCapacity(ctx)
+	if err != nil {
+		log.Warnf("failed to get volume attach capacity for node %q. Error: %v", nodeID, err)
+		return
+	}
+	nodeName := c.getNodeNameForNodeID(ctx, nodeID)
+	fileVolumeCount, err := commonco.ContainerOrchestratorUtility.GetNodeFileVolumeCount(ctx, nodeName)
+	if err != nil {
+		log.Warnf("failed to get file volumes published to node %q. Error: %v", nodeName, err)
+		return
+	}
+	count := int32(len(capacity.AttachedVolumes) + capacity.FreeSlots() + fileVolumeCount)
+	err = commonco.ContainerOrchestratorUtility.UpdateCSINodeAllocatableCount(ctx, nodeName, count)
+	if err != nil {
+		log.Warnf("failed to update allocatable count on CSINode %q to %d. Error: %v", nodeName, count, err)
+	}
+}
+
+// attachVolumeToController attaches the volume to the given controller of the
+// node VM and returns the disk UUID of the attached volume. If the volume is
+// already attached to the node VM, the disk UUID is returned without attaching
+// it again.
+func attachVolumeToController(ctx context.Context, volumeManager cnsvolume.Manager,
+	nodevm *vsphere.VirtualMachine, volumeID string, controllerKey int32) (string, string, error) {
+	log := logger.GetLogger(ctx)
+	diskUUID, err := cnsvolume.IsDiskAttached(ctx, nodevm, volumeID, false)
+	if err != nil {
+		return "", csifault.CSIInternalFault, err
+	}
+	if diskUUID != "" {
+		log.Infof("volume %q is already attached to node VM %v", volumeID, nodevm)
+		return diskUUID, "", nil
+	}
+	log.Debugf("Attaching volume %q to controller %d of node VM %v", volumeID, controllerKey, nodevm)
+	attachResults, faultType, err := volumeManager.BatchAttachVolumes(ctx, nodevm,
+		[]cnsvolume.BatchAttachRequest{{VolumeID: volumeID, ControllerKey: &controllerKey}})
+	if err != nil {
+		return "", faultType, err
+	}
+	for _, result := range attachResults {
+		if result.VolumeID == volumeID {
+			return result.DiskUUID, "", nil
+		}
+	}
+	return "", csifault.CSIInternalFault, logger.LogNewErrorf(log,
+		"attach result not found for volume %q on node VM %v", volumeID, nodevm)
+}
+
+// getNodeNameForNodeID returns the name of the K8s node with the given node ID.
+// Node ID is the node name for nodes which are not yet publishing the node VM
+// UUID as node ID.
+func (c *controller) getNodeNameForNodeID(ctx context.Context, nodeID string) string {
+	log := logger.GetLogger(ctx)
+	nodeName, err := c.nodeMgr.GetNodeNameByUUID(ctx, nodeID)
+	if err != nil {
+		log.Debugf("failed to get node name for node ID %q, using it as node name. Error: %v", nodeID, err)
+		return nodeID
+	}
+	return nodeName
+}
+
+// getNodeGuestIPs returns the guest IPs of the node VM with the given node ID.
+// Node ID is looked up as node name first for nodes which are not yet
+// publishing the node VM UUID as node ID.
+func (c *controller) getNodeGuestIPs(ctx context.Context, nodeID string) ([]string, error) {
+	nodevm, err := c.nodeMgr.GetNodeVMByNameOrUUID(ctx, nodeID)
+	if err == node.ErrNodeNotFound {
+		nodevm, err = c.nodeMgr.GetNodeVMByUuid(ctx, nodeID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return nodevm.GetGuestIPAddresses(ctx)
+}
+
+// forceDetachVolumeIfNodeOutOfService detaches the volume from the node VM
+// through CNS, if the node VM is powered off, orphaned or on a disconnected
+// host and the node is tainted with node.kubernetes.io/out-of-service.
+// It returns true if the volume was force detached. An event is recorded on
+// the node for each forced detach.
+func (c *controller) forceDetachVolumeIfNodeOutOfService(ctx context.Context, volumeManager cnsvolume.Manager,
+	nodeID string, nodevm *vsphere.VirtualMachine, volumeID string) (bool, string, error) {
+	log := logger.GetLogger(ctx)
+	reason, err := nodevm.GetUnavailabilityReason(ctx)
+	if err != nil {
+		log.Warnf("failed to check availability of node VM %v. Error: %v", nodevm, err)
+		return false, "", nil
+	}
+	if reason == "" {
+		return false, "", nil
+	}
+	nodeName := c.getNodeNameForNodeID(ctx, nodeID)
+	outOfService, err := commonco.ContainerOrchestratorUtility.IsNodeOutOfService(ctx, nodeName)
+	if err != nil {
+		log.Warnf("failed to check out-of-service taint on node %q. Error: %v", nodeName, err)
+		return false, "", nil
+	}
+	if !outOfService {
+		log.Infof("node VM %v for node %q is %s, but the node is not tainted with %q. "+
+			"Detaching volume %q through CNS", nodevm, nodeName, reason, v1.TaintNodeOutOfService, volumeID)
+		return false, "", nil
+	}
+	log.Infof("node VM %v for node %q is %s and the node is tainted with %q. Force detaching volume %q",
+		nodevm, nodeName, reason, v1.TaintNodeOutOfService, volumeID)
+	faultType, err := forceDetachVolume(ctx, volumeManager, nodeName, nodevm, volumeID, reason)
+	if err != nil {
+		return false, faultType, err
+	}
+	return true, "", nil
+}
+
+// forceDetachVolume detaches the volume from the unavailable node VM through
+// CNS. The configuration of a VM which is orphaned or on a disconnected host
+// can not be changed, and such a VM may still be running on a host which is
+// only isolated from vCenter. So if CNS fails to detach the volume from such a
+// VM, the volume is only considered detached once the disk is confirmed gone
+// from the VM, e.g. because the VM was unregistered from vCenter. Otherwise the
+// error is returned and the detach is retried.
+func forceDetachVolume(ctx context.Context, volumeManager cnsvolume.Manager, nodeName string,
+	nodevm *vsphere.VirtualMachine, volumeID string, reason string) (string, error) {
+	log := logger.GetLogger(ctx)
+	faultType, err := common.DetachVolumeUtil(ctx, volumeManager, nodevm, volumeID)
+	if err == nil {
+		commonco.ContainerOrchestratorUtility.RecordNodeEvent(ctx, nodeName, v1.EventTypeNormal,
+			"ForceDetachVolume", fmt.Sprintf("Force detached volume %q from node VM %q which is %s",
+				volumeID, nodevm.UUID, reason))
+		return "", nil
+	}
+	if reason != string(types.VirtualMachinePowerStatePoweredOff) {
+		diskUUID, checkErr := isDiskAttachedToVM(ctx, nodevm, volumeID, false)
+		if (checkErr == nil && diskUUID == "") ||
+			(checkErr != nil && vsphere.IsManagedObjectNotFound(checkErr, nodevm.Reference())) {
+			log.Infof("volume %q is no longer attached to node VM %v which is %s. Considering it detached",
+				volumeID, nodevm, reason)
+			commonco.ContainerOrchestratorUtility.RecordNodeEvent(ctx, nodeName, v1.EventTypeNormal,
+				"ForceDetachVolume", fmt.Sprintf("Volume %q is no longer attached to node VM %q which is %s",
+					volumeID, nodevm.UUID, reason))
+			return "", nil
+		}
+		err = fmt.Errorf("%w. The disk is still in the configuration of the VM, unregister the VM from "+
+			"vCenter or reconnect its host to release the volume", err)
+	}
+	commonco.ContainerOrchestratorUtility.RecordNodeEvent(ctx, nodeName, v1.EventTypeWarning,
+		"ForceDetachVolumeFailed", fmt.Sprintf("Failed to force detach volume %q from node VM %q which is %s: %v",
+			volumeID, nodevm.UUID, reason, err))
+	return faultType, err
+}
+
+// fileShareTopologyCandidate holds the vSAN file service enabled datastores
+// accessible from the hosts of a requested topology segment.
+type fileShareTopologyCandidate struct {
+	segment    map[string]string
+	datastores []*vsphere.DatastoreInfo
+}
+
+// getFileShareCandidatesByTopology maps each of the requested topology
+// segments, in the order of preference, to the vSAN file service enabled
+// datastores accessible from the hosts in that segment. Segments without such
+// datastores are left out.
+func getFileShareCandidatesByTopology(ctx context.Context, topologySegments []map[string]string,
+	fsEnabledClusterToDsInfoMap map[string][]*vsphere.DatastoreInfo,
+	getAccessibleDatastores func(ctx context.Context, segments []map[string]string) (
+		[]*vsphere.DatastoreInfo, error)) ([]fileShareTopologyCandidate, error) {
+	log := logger.GetLogger(ctx)
+	fsEnabledDsURLs := make(map[string]struct{})
+	for _, datastores := range fsEnabledClusterToDsInfoMap {
+		for _, ds := range datastores {
+			fsEnabledDsURLs[ds.Info.Url] = struct{}{}
+		}
+	}
+	var candidates []fileShareTopologyCandidate
+	for _, segment := range topologySegments {
+		accessibleDatastores, err := getAccessibleDatastores(ctx, []map[string]string{segment})
+		if err != nil {
+			return nil, err
+		}
+		candidate := fileShareTopologyCandidate{segment: segment}
+		for _, ds := range accessibleDatastores {
+			if _, ok := fsEnabledDsURLs[ds.Info.Url]; ok {
+				candidate.datastores = append(candidate.datastores, ds)
+			}
+		}
+		if len(candidate.datastores) == 0 {
+			log.Infof("No vSAN file service enabled datastores accessible in topology segment %+v", segment)
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// getFileVolumeAccessibleTopology returns the topology segments of the given
+// candidates from which the datastore serving the file share is accessible.
+func getFileVolumeAccessibleTopology(candidates []fileShareTopologyCandidate,
+	datastoreURL string) []*csi.Topology {
+	var accessibleTopology []*csi.Topology
+	for _, candidate := range candidates {
+		for _, ds := range candidate.datastores {
+			if ds.Info.Url == datastoreURL {
+				accessibleTopology = append(accessibleTopology, &csi.Topology{Segments: candidate.segment})
+				break
+			}
+		}
+	}
+	return accessibleTopology
+}
+
+// queryVolumeDatastoreURL returns the URL of the datastore the volume with the
+// given ID is placed on.
+func queryVolumeDatastoreURL(ctx context.Context, volumeManager cnsvolume.Manager, volumeID string,
+	vcHost string) (string, error) {
+	log := logger.GetLogger(ctx)
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypeDataStoreUrl)},
+	}
+	queryResult, err := utils.QueryVolumeUtil(ctx, volumeManager, queryFilter, &querySelection)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"queryVolumeUtil failed for volumeID: %s in vCenter %q. Error: %+v", volumeID, vcHost, err)
+	}
+	if len(queryResult.Volumes) == 0 || queryResult.Volumes[0].DatastoreUrl == "" {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"queryVolumeUtil could not retrieve volume information for volume ID: %q in vCenter %q",
+			volumeID, vcHost)
+	}
+	return queryResult.Volumes[0].DatastoreUrl, nil
+}
+
+// getFileShareCandidatesForVC maps the given topology segments of the VC to the
+// vSAN file service enabled datastores accessible from them. The auth manager
+// for each VC tracks the vSAN FS enabled clusters of that VC only.
+func (c *controller) getFileShareCandidatesForVC(ctx context.Context, vcenter *vsphere.VirtualCenter,
+	topologySegments []map[string]string) ([]fileShareTopologyCandidate, error) {
+	log := logger.GetLogger(ctx)
+	authMgr, found := c.authMgrs[vcenter.Config.Host]
+	if !found {
+		return nil, logger.LogNewErrorf(log, "authorization service not found for VC %q", vcenter.Config.Host)
+	}
+	candidates, err := getFileShareCandidatesByTopology(ctx, topologySegments, authMgr.GetFsEnabledClusterToDsMap(ctx),
+		func(ctx context.Context, segments []map[string]string) ([]*vsphere.DatastoreInfo, error) {
+			return placementengine.GetAllAccessibleDSInTopology(ctx, segments, vcenter)
+		})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "error finding candidate datastores in topology %+v for VC: %q. "+
+			"Error: %+v", topologySegments, vcenter.Config.Host, err)
+	}
+	return candidates, nil
+}
+
+// calculateFileVolumeAccessibleTopology returns the requested topology segments
+// from which the file share of the volume is served. The candidates and the
+// datastore URL are looked up again if the volume was created in a previous call.
+func (c *controller) calculateFileVolumeAccessibleTopology(ctx context.Context,
+	topologyRequirement *csi.TopologyRequirement, volumeID string, datastoreURL string, vcHost string,
+	candidates []fileShareTopologyCandidate) ([]*csi.Topology, error) {
+	log := logger.GetLogger(ctx)
+	if candidates == nil {
+		vcTopologySegmentsMap, err := common.GetAccessibilityRequirementsByVC(ctx, topologyRequirement)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get accessibility requirements by VC. Error: %+v", err)
+		}
+		vcenter, err := common.GetVCenterFromVCHost(ctx, c.managers.VcenterManager, vcHost)
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get vCenter instance for host %q. Error: %+v", vcHost, err)
+		}
+		candidates, err = c.getFileShareCandidatesForVC(ctx, vcenter, vcTopologySegmentsMap[vcHost])
+		if err != nil {
+			return nil, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get candidate datastores for file volume %q. Error: %v", volumeID, err)
+		}
+	}
+	if datastoreURL == "" {
+		volumeMgr, err := GetVolumeManagerFromVCHost(ctx, c.managers, vcHost)
+		if err != nil {
+			return nil, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+		}
+		datastoreURL, err = queryVolumeDatastoreURL(ctx, volumeMgr, volumeID, vcHost)
+		if err != nil {
+			return nil, err
+		}
+	}
+	accessibleTopology := getFileVolumeAccessibleTopology(candidates, datastoreURL)
+	if len(accessibleTopology) == 0 {
+		return nil, logger.LogNewErrorCodef(log, codes.Internal,
+			"file share of volume %q on datastore %q is not accessible from any of the requested topology segments",
+			volumeID, datastoreURL)
+	}
+	log.Infof("File volume %q on datastore %q is accessible from topology %+v", volumeID, datastoreURL,
+		accessibleTopology)
+	return accessibleTopology, nil
+}
+
+// isFileServiceKerberosEnabled returns true if the vSAN file service of the
+// cluster of the given vSAN datastore supports Kerberos security.
+var isFileServiceKerberosEnabled = func(ctx context.Context, vcenter *vsphere.VirtualCenter,
+	datastore types.ManagedObjectReference) (bool, error) {
+	return vcenter.IsFileServiceKerberosEnabled(ctx, datastore)
+}
+
+// filterKerberosEnabledDatastores returns the given vSAN datastores whose
+// cluster has a vSAN file service domain joined to a directory service, as
+// file shares with Kerberos NFS security can only be served from them.
+func filterKerberosEnabledDatastores(ctx context.Context, vcenter *vsphere.VirtualCenter,
+	datastores []*vsphere.DatastoreInfo) ([]*vsphere.DatastoreInfo, error) {
+	log := logger.GetLogger(ctx)
+	var filtered []*vsphere.DatastoreInfo
+	for _, ds := range datastores {
+		enabled, err := isFileServiceKerberosEnabled(ctx, vcenter, ds.Reference())
+		if err != nil {
+			return nil, err
+		}
+		if !enabled {
+			log.Infof("Skipping datastore %q as Kerberos is not enabled on its vSAN file service domain",
+				ds.Info.Url)
+			continue
+		}
+		filtered = append(filtered, ds)
+	}
+	return filtered, nil
+}
+
+// setFileShareNfsSecurity sets the NFS security type of the vSAN file share of
+// the given file volume to the NFS security flavor given in the Storage Class.
+// The CNS file share create spec carries no NFS security settings.
+func setFileShareNfsSecurity(ctx context.Context, vcenter *vsphere.VirtualCenter, volumeManager cnsvolume.Manager,
+	volumeID string, nfsSecurity string) error {
+	log := logger.GetLogger(ctx)
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: volumeID}},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
+			string(cnstypes.QuerySelectionNameTypeDataStoreUrl),
+		},
+	}
+	queryResult, err := volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		return logger.LogNewErrorf(log, "queryVolume failed for volumeID: %q with err=%+v", volumeID, err)
+	}
+	if len(queryResult.Volumes) == 0 {
+		return logger.LogNewErrorf(log, "volumeID %q not found in QueryVolume", volumeID)
+	}
+	volume := queryResult.Volumes[0]
+	details, ok := volume.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails)
+	if !ok || details.Name == "" {
+		return logger.LogNewErrorf(log, "no vSAN file share backing found for volume %q", volumeID)
+	}
+	dcs, err := vcenter.GetDatacenters(ctx)
+	if err != nil {
+		return err
+	}
+	vsanDatastores, err := vcenter.GetVsanDatastores(ctx, dcs)
+	if err != nil {
+		return err
+	}
+	dsInfo, ok := vsanDatastores[volume.DatastoreUrl]
+	if !ok {
+		return logger.LogNewErrorf(log, "vSAN datastore %q of volume %q not found", volume.DatastoreUrl, volumeID)
+	}
+	return vcenter.SetFileShareNfsSecurity(ctx, dsInfo.Datastore.Reference(), details.Name,
+		vsantypes.VsanFileShareNfsSecType(strings.ToUpper(nfsSecurity)))
+}
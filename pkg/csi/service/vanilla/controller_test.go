@@ -30,9 +30,12 @@ import (
 	"github.com/vmware/govmomi/cns"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/pbm"
 	"github.com/vmware/govmomi/pbm/types"
 	"github.com/vmware/govmomi/vim25"
+	"github.com/vmware/govmomi/vim25/soap"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 	"google.golang.org/protobuf/encoding/prototext"
@@ -47,6 +50,7 @@ import (
 	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
@@ -876,6 +880,163 @@ func TestMigratedExtendVolume(t *testing.T) {
 	}
 }
 
+// TestExtendVolumeWithOfflineExpansionMode helps test that the "expansionmode"
+// StorageClass param is recorded in the volume context and that a detached
+// volume can still be expanded.
+func TestExtendVolumeWithOfflineExpansionMode(t *testing.T) {
+	ct := getControllerTest(t)
+
+	params := map[string]string{
+		common.AttributeExpansionMode: common.ExpansionModeOffline,
+	}
+	if v := os.Getenv("VSPHERE_DATASTORE_URL"); v != "" {
+		params[common.AttributeDatastoreURL] = v
+	}
+	capabilities := []*csi.VolumeCapability{
+		{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+	reqCreate := &csi.CreateVolumeRequest{
+		Name: testVolumeName + "-" + uuid.New().String(),
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1 * common.GbInBytes,
+		},
+		Parameters:         params,
+		VolumeCapabilities: capabilities,
+	}
+	respCreate, err := ct.controller.CreateVolume(ctx, reqCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volID := respCreate.Volume.VolumeId
+	if mode := respCreate.Volume.VolumeContext[common.AttributeExpansionMode]; mode != common.ExpansionModeOffline {
+		t.Fatalf("expected expansion mode %q in volume context, got %q", common.ExpansionModeOffline, mode)
+	}
+	fakeCO, ok := commonco.ContainerOrchestratorUtility.(*unittestcommon.FakeK8SOrchestrator)
+	if !ok {
+		t.Fatal("failed to get the fake container orchestrator")
+	}
+	fakeCO.SetVolumeAttributes(volID, respCreate.Volume.VolumeContext)
+	defer fakeCO.SetVolumeAttributes(volID, nil)
+
+	newSize := 2 * common.GbInBytes
+	reqExpand := &csi.ControllerExpandVolumeRequest{
+		VolumeId: volID,
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: newSize,
+		},
+		VolumeCapability: capabilities[0],
+	}
+	respExpand, err := ct.controller.ControllerExpandVolume(ctx, reqExpand)
+	if err != nil {
+		t.Fatalf("failed to expand detached volume %q. Error: %v", volID, err)
+	}
+	if respExpand.CapacityBytes < newSize || !respExpand.NodeExpansionRequired {
+		t.Fatalf("unexpected ControllerExpandVolume response %+v for volume with ID: %s", respExpand, volID)
+	}
+
+	_, err = ct.controller.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volID})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestGetBlockVolumeAttributes helps test that the "expansionmode" StorageClass
+// param is only recorded in the volume context when it is set to offline.
+func TestGetBlockVolumeAttributes(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		expected string
+	}{
+		{name: "Offline", mode: common.ExpansionModeOffline, expected: common.ExpansionModeOffline},
+		{name: "Online", mode: common.ExpansionModeOnline, expected: ""},
+		{name: "Unset", mode: "", expected: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			attributes := getBlockVolumeAttributes(&common.StorageClassParams{ExpansionMode: test.mode})
+			if attributes[common.AttributeDiskType] != common.DiskTypeBlockVolume {
+				t.Errorf("expected disk type %q, got %q", common.DiskTypeBlockVolume,
+					attributes[common.AttributeDiskType])
+			}
+			if mode := attributes[common.AttributeExpansionMode]; mode != test.expected {
+				t.Errorf("expected expansion mode %q, got %q", test.expected, mode)
+			}
+		})
+	}
+}
+
+// TestFilterKerberosEnabledDatastores helps test that file shares with
+// Kerberos NFS security are only placed on datastores whose vSAN file service
+// supports Kerberos.
+func TestFilterKerberosEnabledDatastores(t *testing.T) {
+	ctx := context.Background()
+	newDatastore := func(moid string) *cnsvsphere.DatastoreInfo {
+		ref := vimtypes.ManagedObjectReference{Type: "Datastore", Value: moid}
+		return &cnsvsphere.DatastoreInfo{
+			Datastore: &cnsvsphere.Datastore{Datastore: object.NewDatastore(nil, ref)},
+			Info:      &vimtypes.DatastoreInfo{Url: "ds:///vmfs/volumes/" + moid + "/"},
+		}
+	}
+	kerberosDs, sysDs := newDatastore("vsan-krb"), newDatastore("vsan-sys")
+	origIsFileServiceKerberosEnabled := isFileServiceKerberosEnabled
+	defer func() { isFileServiceKerberosEnabled = origIsFileServiceKerberosEnabled }()
+	isFileServiceKerberosEnabled = func(ctx context.Context, vcenter *cnsvsphere.VirtualCenter,
+		datastore vimtypes.ManagedObjectReference) (bool, error) {
+		switch datastore.Value {
+		case "vsan-krb":
+			return true, nil
+		case "vsan-sys":
+			return false, nil
+		}
+		return false, errors.New("failed to query file service domains")
+	}
+
+	filtered, err := filterKerberosEnabledDatastores(ctx, nil, []*cnsvsphere.DatastoreInfo{sysDs, kerberosDs})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(filtered) != 1 || filtered[0] != kerberosDs {
+		t.Errorf("expected only datastore %v, got %v", kerberosDs, filtered)
+	}
+	filtered, err = filterKerberosEnabledDatastores(ctx, nil, []*cnsvsphere.DatastoreInfo{sysDs})
+	if err != nil || len(filtered) != 0 {
+		t.Errorf("expected no datastores, got %v with error %v", filtered, err)
+	}
+	_, err = filterKerberosEnabledDatastores(ctx, nil, []*cnsvsphere.DatastoreInfo{newDatastore("vsan-err")})
+	if err == nil {
+		t.Errorf("expected error when file service domains can not be queried")
+	}
+}
+
+// TestValidateFileVolumeExpandRequest helps test that file volume expansion
+// requests are only accepted when the file-volume-expansion FSS is enabled.
+func TestValidateFileVolumeExpandRequest(t *testing.T) {
+	req := &csi.ControllerExpandVolumeRequest{
+		VolumeId: "file:6d2a4f7e-2b7a-4b9e-9d4b-2c8f1b3f8a11",
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 2 * common.GbInBytes,
+		},
+		VolumeCapability: &csi.VolumeCapability{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_MULTI_NODE_MULTI_WRITER,
+			},
+		},
+	}
+	err := validateVanillaControllerExpandVolumeRequest(ctx, req, true, true, false)
+	if status.Code(err) != codes.Unimplemented {
+		t.Fatalf("expected Unimplemented error when file volume expansion is disabled, got %v", err)
+	}
+	err = validateVanillaControllerExpandVolumeRequest(ctx, req, false, false, true)
+	if err != nil {
+		t.Fatalf("expected file volume expand request to be valid, got %v", err)
+	}
+}
+
 func TestCompleteControllerFlow(t *testing.T) {
 	ct := getControllerTest(t)
 
@@ -2111,6 +2272,125 @@ func TestListSnapshotsWithToken(t *testing.T) {
 	}
 }
 
+func TestListSnapshotsOnSpecificVolumeWithToken(t *testing.T) {
+	ct := getControllerTest(t)
+	numOfSnapshots := ct.config.Snapshot.GlobalMaxSnapshotsPerBlockVolume
+	// Create.
+	params := make(map[string]string)
+	if v := os.Getenv("VSPHERE_DATASTORE_URL"); v != "" {
+		params[common.AttributeDatastoreURL] = v
+	}
+	capabilities := []*csi.VolumeCapability{
+		{
+			AccessMode: &csi.VolumeCapability_AccessMode{
+				Mode: csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER,
+			},
+		},
+	}
+
+	reqCreate := &csi.CreateVolumeRequest{
+		Name: testVolumeName + "-" + uuid.New().String(),
+		CapacityRange: &csi.CapacityRange{
+			RequiredBytes: 1 * common.GbInBytes,
+		},
+		Parameters:         params,
+		VolumeCapabilities: capabilities,
+	}
+
+	respCreate, err := ct.controller.CreateVolume(ctx, reqCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	volID := respCreate.Volume.VolumeId
+
+	// Map to track all the snapshots created.
+	snapshots := make(map[string]string)
+	var deleteSnapshotList []string
+
+	for i := 0; i < numOfSnapshots; i++ {
+		// Snapshot a volume
+		reqCreateSnapshot := &csi.CreateSnapshotRequest{
+			SourceVolumeId: volID,
+			Name:           "snapshot-" + uuid.New().String(),
+		}
+
+		respCreateSnapshot, err := ct.controller.CreateSnapshot(ctx, reqCreateSnapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+		snapshots[respCreateSnapshot.Snapshot.SnapshotId] = ""
+		deleteSnapshotList = append(deleteSnapshotList, respCreateSnapshot.Snapshot.SnapshotId)
+	}
+
+	tok := ""
+	numOfPages := 0
+	for {
+		// Specify max entries as 1 to trigger paginated results.
+		listSnapshotRequest := &csi.ListSnapshotsRequest{
+			MaxEntries:     1,
+			StartingToken:  tok,
+			SourceVolumeId: volID,
+		}
+
+		listSnapshotsResponse, err := ct.controller.ListSnapshots(ctx, listSnapshotRequest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(listSnapshotsResponse.Entries) > 1 {
+			t.Fatalf("ListSnapshot returned %d entries, expected at most 1", len(listSnapshotsResponse.Entries))
+		}
+		for _, entry := range listSnapshotsResponse.Entries {
+			if entry.Snapshot.SourceVolumeId != volID {
+				t.Fatalf("ListSnapshot returned snapshot %s of volume %s, expected volume %s",
+					entry.Snapshot.SnapshotId, entry.Snapshot.SourceVolumeId, volID)
+			}
+			delete(snapshots, entry.Snapshot.SnapshotId)
+		}
+		numOfPages++
+		// Use the next token returned.
+		tok = listSnapshotsResponse.NextToken
+		if len(tok) == 0 {
+			break
+		}
+	}
+	if numOfPages != numOfSnapshots {
+		t.Fatalf("ListSnapshot returned %d pages, expected %d", numOfPages, numOfSnapshots)
+	}
+	// Expect returned snapshots to be deleted from map, the remaining snapshots were not returned in response.
+	if len(snapshots) != 0 {
+		t.Fatalf("Not all snapshots were returned, missing snapshots: %+v", snapshots)
+	}
+
+	// An invalid token is expected to be rejected with Aborted.
+	_, err = ct.controller.ListSnapshots(ctx, &csi.ListSnapshotsRequest{
+		StartingToken:  "invalid-token",
+		SourceVolumeId: volID,
+	})
+	if status.Code(err) != codes.Aborted {
+		t.Fatalf("expected Aborted for invalid starting token, received: %v", err)
+	}
+
+	// delete snapshots as part of cleanup.
+	for i := len(deleteSnapshotList) - 1; i >= 0; i-- {
+		// Delete the snapshot
+		reqDeleteSnapshot := &csi.DeleteSnapshotRequest{
+			SnapshotId: deleteSnapshotList[i],
+		}
+		_, err = ct.controller.DeleteSnapshot(ctx, reqDeleteSnapshot)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Delete the volume.
+	reqDelete := &csi.DeleteVolumeRequest{
+		VolumeId: volID,
+	}
+	_, err = ct.controller.DeleteVolume(ctx, reqDelete)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestExpandVolumeWithSnapshots(t *testing.T) {
 	ct := getControllerTest(t)
 
@@ -2601,3 +2881,81 @@ func TestCreateSnapshotWithCnsSnapshotCreatedFault(t *testing.T) {
 		t.Fatal("expected error was not received for create snapshot operation.")
 	}
 }
+
+// fakeDetachManager fails the DetachVolume calls made to it with detachErr.
+type fakeDetachManager struct {
+	cnsvolume.Manager
+	detachErr error
+	calls     int
+}
+
+func (m *fakeDetachManager) DetachVolume(ctx context.Context, vm *cnsvsphere.VirtualMachine,
+	volumeID string) (string, error) {
+	m.calls++
+	if m.detachErr != nil {
+		return csifault.CSIInternalFault, m.detachErr
+	}
+	return "", nil
+}
+
+func TestForceDetachVolume(t *testing.T) {
+	ctx := context.Background()
+	var err error
+	commonco.ContainerOrchestratorUtility, err =
+		unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("Failed to create co agnostic interface. err=%v", err)
+	}
+	nodevm := &cnsvsphere.VirtualMachine{
+		UUID: "node-vm-uuid",
+		VirtualMachine: object.NewVirtualMachine(nil,
+			vimtypes.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-1"}),
+	}
+	vmNotFoundFault := &soap.Fault{}
+	vmNotFoundFault.Detail.Fault = vimtypes.ManagedObjectNotFound{Obj: nodevm.Reference()}
+	vmNotFoundErr := soap.WrapSoapFault(vmNotFoundFault)
+	defer func() { isDiskAttachedToVM = cnsvolume.IsDiskAttached }()
+	tests := []struct {
+		name      string
+		reason    string
+		detachErr error
+		diskUUID  string
+		diskErr   error
+		expectErr bool
+	}{
+		{name: "detached through CNS", reason: "poweredOff"},
+		{name: "CNS detach fails on powered off VM", reason: "poweredOff",
+			detachErr: errors.New("detach failed"), expectErr: true},
+		{name: "CNS detach fails on VM of disconnected host with disk attached", reason: "disconnected",
+			detachErr: errors.New("detach failed"), diskUUID: "disk-uuid", expectErr: true},
+		{name: "CNS detach fails on orphaned VM with disk attached", reason: "orphaned",
+			detachErr: errors.New("detach failed"), diskUUID: "disk-uuid", expectErr: true},
+		{name: "CNS detach fails on orphaned VM without disk", reason: "orphaned",
+			detachErr: errors.New("detach failed")},
+		{name: "CNS detach fails on unregistered VM", reason: "orphaned",
+			detachErr: errors.New("detach failed"), diskErr: vmNotFoundErr},
+		{name: "CNS detach fails on VM with unknown disks", reason: "disconnected",
+			detachErr: errors.New("detach failed"), diskErr: errors.New("failed to get devices"), expectErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumeManager := &fakeDetachManager{detachErr: test.detachErr}
+			isDiskAttachedToVM = func(ctx context.Context, vm *cnsvsphere.VirtualMachine, volumeID string,
+				checkNVMeController bool) (string, error) {
+				return test.diskUUID, test.diskErr
+			}
+			faultType, err := forceDetachVolume(ctx, volumeManager, "node1", nodevm, "volume-1", test.reason)
+			if volumeManager.calls != 1 {
+				t.Errorf("expected volume to be detached through CNS once, got %d calls", volumeManager.calls)
+			}
+			if test.expectErr {
+				if err == nil || faultType != csifault.CSIInternalFault {
+					t.Errorf("expected error with fault %q, got fault %q and error %v",
+						csifault.CSIInternalFault, faultType, err)
+				}
+			} else if err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
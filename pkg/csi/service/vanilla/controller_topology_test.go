@@ -35,6 +35,7 @@ import (
 	"github.com/vmware/govmomi/view"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -808,3 +809,71 @@ func setupRealTagsInVCSim(ctx context.Context, vcenter *cnsvsphere.VirtualCenter
 	log.Infof("Successfully set up real tags in vcsim")
 	return nil
 }
+
+// newFakeDatastoreInfo returns a DatastoreInfo with the given URL.
+func newFakeDatastoreInfo(url string) *cnsvsphere.DatastoreInfo {
+	return &cnsvsphere.DatastoreInfo{Info: &types.DatastoreInfo{Url: url}}
+}
+
+// TestFileVolumeTopologyPlacement verifies that requested topology segments
+// are mapped to the vSAN file service datastores within them and that a file
+// volume is only accessible from the segments its datastore is in.
+func TestFileVolumeTopologyPlacement(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	zoneA := map[string]string{"topology.csi.vmware.com/k8s-zone": "zone-a"}
+	zoneB := map[string]string{"topology.csi.vmware.com/k8s-zone": "zone-b"}
+	zoneC := map[string]string{"topology.csi.vmware.com/k8s-zone": "zone-c"}
+	vsanA := newFakeDatastoreInfo("ds:///vmfs/volumes/vsan:a/")
+	vsanB := newFakeDatastoreInfo("ds:///vmfs/volumes/vsan:b/")
+	localA := newFakeDatastoreInfo("ds:///vmfs/volumes/local-a/")
+	localC := newFakeDatastoreInfo("ds:///vmfs/volumes/local-c/")
+	// Fake topology: each zone has its own cluster with a vSAN datastore,
+	// except zone-c, which has no vSAN file service enabled cluster.
+	accessibleDatastores := map[string][]*cnsvsphere.DatastoreInfo{
+		"zone-a": {vsanA, localA},
+		"zone-b": {vsanB},
+		"zone-c": {localC},
+	}
+	getAccessibleDatastores := func(ctx context.Context, segments []map[string]string) (
+		[]*cnsvsphere.DatastoreInfo, error) {
+		var datastores []*cnsvsphere.DatastoreInfo
+		for _, segment := range segments {
+			datastores = append(datastores, accessibleDatastores[segment["topology.csi.vmware.com/k8s-zone"]]...)
+		}
+		return datastores, nil
+	}
+	fsEnabledClusterToDsInfoMap := map[string][]*cnsvsphere.DatastoreInfo{
+		"domain-c1": {vsanA},
+		"domain-c2": {vsanB},
+	}
+
+	candidates, err := getFileShareCandidatesByTopology(ctx, []map[string]string{zoneC, zoneB, zoneA},
+		fsEnabledClusterToDsInfoMap, getAccessibleDatastores)
+	if err != nil {
+		t.Fatalf("failed to get file share candidates. Error: %v", err)
+	}
+	if len(candidates) != 2 {
+		t.Fatalf("expected candidates in 2 segments, got %d", len(candidates))
+	}
+	// Segments keep the order of preference and only hold vSAN FS datastores.
+	if candidates[0].segment["topology.csi.vmware.com/k8s-zone"] != "zone-b" ||
+		len(candidates[0].datastores) != 1 || candidates[0].datastores[0] != vsanB {
+		t.Errorf("unexpected first candidate %+v", candidates[0])
+	}
+	if candidates[1].segment["topology.csi.vmware.com/k8s-zone"] != "zone-a" ||
+		len(candidates[1].datastores) != 1 || candidates[1].datastores[0] != vsanA {
+		t.Errorf("unexpected second candidate %+v", candidates[1])
+	}
+
+	accessibleTopology := getFileVolumeAccessibleTopology(candidates, vsanA.Info.Url)
+	if len(accessibleTopology) != 1 ||
+		accessibleTopology[0].Segments["topology.csi.vmware.com/k8s-zone"] != "zone-a" {
+		t.Errorf("expected file volume on %q to be accessible from zone-a only, got %+v",
+			vsanA.Info.Url, accessibleTopology)
+	}
+	if accessibleTopology := getFileVolumeAccessibleTopology(candidates, localC.Info.Url); accessibleTopology != nil {
+		t.Errorf("expected no accessible topology for datastore %q, got %+v", localC.Info.Url, accessibleTopology)
+	}
+}
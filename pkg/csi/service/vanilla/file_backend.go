@@ -0,0 +1,322 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"strings"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	"github.com/vmware/govmomi/units"
+	"google.golang.org/grpc/codes"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo"
+)
+
+// fileVolumeBackend provisions and publishes RWX file volumes on a storage
+// backend. Like the internal functions of the controller RPCs, all methods
+// return the CSI fault type along with the error.
+type fileVolumeBackend interface {
+	// CreateVolume creates a file volume.
+	CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (*csi.CreateVolumeResponse, string, error)
+	// DeleteVolume deletes a file volume created by this backend.
+	DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (string, error)
+	// ControllerPublishVolume grants the node access to the file volume and
+	// returns the publish context the node mounts the volume with.
+	ControllerPublishVolume(ctx context.Context, req *csi.ControllerPublishVolumeRequest) (
+		map[string]string, string, error)
+	// ControllerUnpublishVolume revokes the access of the node to the file volume.
+	ControllerUnpublishVolume(ctx context.Context, req *csi.ControllerUnpublishVolumeRequest) (string, error)
+	// ControllerExpandVolume expands a file volume.
+	ControllerExpandVolume(ctx context.Context, req *csi.ControllerExpandVolumeRequest) (
+		*csi.ControllerExpandVolumeResponse, string, error)
+}
+
+// getFileVolumeBackend returns the file volume backend with the given name,
+// or nil if there is none.
+func (c *controller) getFileVolumeBackend(name string) fileVolumeBackend {
+	c.fileBackendsOnce.Do(func() {
+		c.fileBackends = map[string]fileVolumeBackend{
+			common.FileBackendVsanFileService: &vsanFileServiceBackend{c: c, getNodeIPs: c.getNodeGuestIPs},
+			common.FileBackendNFSSubdir:       newNFSSubdirBackend(c),
+		}
+	})
+	return c.fileBackends[name]
+}
+
+// getFileVolumeBackendForCreate returns the file volume backend selected by
+// the "filebackend" param of the given StorageClass parameters. vSAN File
+// Service is used when the param is not set.
+func (c *controller) getFileVolumeBackendForCreate(ctx context.Context, params map[string]string) (
+	fileVolumeBackend, error) {
+	log := logger.GetLogger(ctx)
+	backendName := common.FileBackendVsanFileService
+	for param, value := range params {
+		if strings.ToLower(param) == common.AttributeFileBackend {
+			backendName = strings.ToLower(value)
+		}
+	}
+	if backendName != common.FileBackendVsanFileService &&
+		!commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.PluggableFileBackends) {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameter %q is not supported as %q feature is disabled",
+			common.AttributeFileBackend, common.PluggableFileBackends)
+	}
+	backend := c.getFileVolumeBackend(backendName)
+	if backend == nil {
+		return nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid value %q for param %q. Supported values are %q and %q", backendName,
+			common.AttributeFileBackend, common.FileBackendVsanFileService, common.FileBackendNFSSubdir)
+	}
+	return backend, nil
+}
+
+// getFileVolumeBackendForVolumeID returns the file volume backend which
+// created the volume with the given ID, or nil if it is not a file volume.
+func (c *controller) getFileVolumeBackendForVolumeID(volumeID string) fileVolumeBackend {
+	if common.IsNFSSubdirVolumeID(volumeID) {
+		return c.getFileVolumeBackend(common.FileBackendNFSSubdir)
+	}
+	if strings.HasPrefix(volumeID, cnsvolumeinfo.FileVolumePrefix) {
+		return c.getFileVolumeBackend(common.FileBackendVsanFileService)
+	}
+	return nil
+}
+
+// vsanFileServiceBackend provisions file volumes as vSAN file shares through CNS.
+type vsanFileServiceBackend struct {
+	c *controller
+	// getNodeIPs returns the guest IPs of the node VM with the given node ID.
+	getNodeIPs func(ctx context.Context, nodeID string) ([]string, error)
+}
+
+// CreateVolume creates a vSAN file share on one of the vCenters with vSAN
+// file services enabled.
+func (b *vsanFileServiceBackend) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
+	*csi.CreateVolumeResponse, string, error) {
+	log := logger.GetLogger(ctx)
+	c := b.c
+	if len(c.managers.VcenterConfigs) > 1 {
+		isvSANFileServicesDisabledInAllVCs := true
+		for _, vcconfig := range c.managers.VcenterConfigs {
+			isvSANFileServicesSupported, err := c.managers.VcenterManager.IsvSANFileServicesSupported(ctx,
+				vcconfig.Host)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorf(log,
+					"failed to verify if vSAN file services is supported or not for vCenter: %s. "+
+						"Error:%+v", vcconfig.Host, err)
+			}
+			if isvSANFileServicesSupported {
+				isvSANFileServicesDisabledInAllVCs = false
+			}
+		}
+
+		if isvSANFileServicesDisabledInAllVCs {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
+				"fileshare volume creation is not supported on vSAN 67u3 release")
+		}
+		return c.createFileVolume(ctx, req)
+	}
+	isvSANFileServicesSupported, err := c.managers.VcenterManager.IsvSANFileServicesSupported(ctx,
+		c.managers.CnsConfig.Global.VCenterIP)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to verify if vSAN file services is supported or not. Error:%+v", err)
+	}
+	if !isvSANFileServicesSupported {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.FailedPrecondition,
+			"fileshare volume creation is not supported on vSAN 67u3 release")
+	}
+	return c.createFileVolume(ctx, req)
+}
+
+// DeleteVolume deletes the vSAN file share of the volume.
+func (b *vsanFileServiceBackend) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (string, error) {
+	log := logger.GetLogger(ctx)
+	_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, b.c, req.VolumeId, volumeInfoService)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get vCenter/volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
+	}
+	faultType, err := common.DeleteVolumeUtil(ctx, volumeManager, req.VolumeId, true)
+	if err != nil {
+		return faultType, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to delete volume: %q. Error: %+v", req.VolumeId, err)
+	}
+	// If this is multi-VC configuration, delete CnsVolumeInfo CR.
+	if len(b.c.managers.VcenterConfigs) > 1 {
+		err = volumeInfoService.DeleteVolumeInfo(ctx, req.VolumeId)
+		if err != nil {
+			return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to delete cnsvolumeInfo CR for volume: %q. Error: %+v", req.VolumeId, err)
+		}
+	}
+	return "", nil
+}
+
+// ControllerPublishVolume returns the access point of the vSAN file share and
+// adds the node to the ACL of the file share when FileVolumeNodeACL is enabled.
+func (b *vsanFileServiceBackend) ControllerPublishVolume(ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (map[string]string, string, error) {
+	log := logger.GetLogger(ctx)
+	_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, b.c, req.VolumeId, volumeInfoService)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
+	}
+	queryFilter := cnstypes.CnsQueryFilter{
+		VolumeIds: []cnstypes.CnsVolumeId{{Id: req.VolumeId}},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
+		},
+	}
+	// Select only the backing object details.
+	queryResult, err := volumeManager.QueryAllVolume(ctx, queryFilter, querySelection)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"queryVolume failed for volumeID: %q with err=%+v", req.VolumeId, err)
+	}
+	if len(queryResult.Volumes) == 0 {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"volumeID %s not found in QueryVolume", req.VolumeId)
+	}
+
+	publishInfo := make(map[string]string)
+	vSANFileBackingDetails :=
+		queryResult.Volumes[0].BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails)
+	publishInfo[common.AttributeDiskType] = common.DiskTypeFileVolume
+	// Publish the access point of the NFS version the volume is mounted with.
+	accessPointKey, accessPoint, nfsVersion := common.Nfsv4AccessPointKey, common.Nfsv4AccessPoint, "NFSv4"
+	if req.VolumeContext[common.AttributeNfsVersion] == common.NfsVersion3 {
+		accessPointKey, accessPoint, nfsVersion = common.Nfsv3AccessPointKey, common.Nfsv3AccessPoint, "NFSv3"
+	}
+	accessPointFound := false
+	for _, kv := range vSANFileBackingDetails.AccessPoints {
+		if kv.Key == accessPointKey {
+			publishInfo[accessPoint] = kv.Value
+			accessPointFound = true
+			break
+		}
+	}
+	if !accessPointFound {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get %s access point for volume: %q. Returned vSAN file backing details: %+v",
+			nfsVersion, req.VolumeId, vSANFileBackingDetails)
+	}
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACL) {
+		// Grant the node access to the file share.
+		nodeIPs, err := b.getNodeIPs(ctx, req.NodeId)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get IPs of node %q. Error: %v", req.NodeId, err)
+		}
+		if len(nodeIPs) == 0 {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Unavailable,
+				"no guest IPs reported for node %q to grant access to file volume %q",
+				req.NodeId, req.VolumeId)
+		}
+		err = common.ConfigureFileVolumeNodeACLUtil(ctx, volumeManager, req.VolumeId, nodeIPs,
+			req.Readonly, false)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to grant node %q access to file volume %q. Error: %v", req.NodeId, req.VolumeId, err)
+		}
+		publishInfo[common.NfsClientIPs] = strings.Join(nodeIPs, ",")
+	}
+	return publishInfo, "", nil
+}
+
+// ControllerUnpublishVolume removes the node from the ACL of the vSAN file
+// share when FileVolumeNodeACL is enabled.
+func (b *vsanFileServiceBackend) ControllerUnpublishVolume(ctx context.Context,
+	req *csi.ControllerUnpublishVolumeRequest) (string, error) {
+	log := logger.GetLogger(ctx)
+	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeNodeACL) {
+		log.Infof("Skipping ControllerUnpublish for file volume %q", req.VolumeId)
+		return "", nil
+	}
+	_, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, b.c, req.VolumeId, volumeInfoService)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
+	}
+	// Revoke the node's access to the file share.
+	nodeIPs, err := b.getNodeIPs(ctx, req.NodeId)
+	if err != nil {
+		if err == cnsvsphere.ErrVMNotFound {
+			// Entries of nodes which are gone are removed from the ACL by full sync.
+			log.Infof("Virtual Machine for Node ID: %v is not present in the VC Inventory. "+
+				"Leaving removal of its access to file volume %q to full sync.", req.NodeId, req.VolumeId)
+			return "", nil
+		}
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get IPs of node %q to revoke its access to file volume %q. Error: %v",
+			req.NodeId, req.VolumeId, err)
+	}
+	if len(nodeIPs) == 0 {
+		// A powered off node VM reports no IPs. Its entries are removed from
+		// the ACL by full sync.
+		log.Infof("No guest IPs reported for node %q. Leaving removal of its access to file volume %q "+
+			"to full sync.", req.NodeId, req.VolumeId)
+		return "", nil
+	}
+	err = common.ConfigureFileVolumeNodeACLUtil(ctx, volumeManager, req.VolumeId, nodeIPs, false, true)
+	if err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to revoke access of node %q to file volume %q. Error: %v",
+			req.NodeId, req.VolumeId, err)
+	}
+	return "", nil
+}
+
+// ControllerExpandVolume resizes the vSAN file share of the volume.
+func (b *vsanFileServiceBackend) ControllerExpandVolume(ctx context.Context,
+	req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, string, error) {
+	log := logger.GetLogger(ctx)
+	err := common.ValidateControllerExpandVolumeRequest(ctx, req,
+		commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.FileVolumeExpansion))
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	vCenterHost, volumeManager, err := getVCenterAndVolumeManagerForVolumeID(ctx, b.c, req.VolumeId,
+		volumeInfoService)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to get vCenter/volume manager for volume Id: %q. Error: %v", req.VolumeId, err)
+	}
+	volSizeMB := int64(common.RoundUpSize(req.GetCapacityRange().GetRequiredBytes(), common.MbInBytes))
+	faultType, err := common.ExpandVolumeUtil(ctx, getVCenterManagerForVCenter(ctx, b.c),
+		vCenterHost, volumeManager, req.VolumeId, volSizeMB, nil)
+	if err != nil {
+		return nil, faultType, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to expand volume: %q to size: %d with error: %+v", req.VolumeId, volSizeMB, err)
+	}
+	// vSAN file shares are resized on the file server, so NFS clients see
+	// the new capacity without any node side operation.
+	return &csi.ControllerExpandVolumeResponse{
+		CapacityBytes:         int64(units.FileSize(volSizeMB * common.MbInBytes)),
+		NodeExpansionRequired: false,
+	}, "", nil
+}
@@ -0,0 +1,320 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"google.golang.org/grpc/codes"
+	"k8s.io/kubernetes/pkg/volume/util/fs"
+	"k8s.io/mount-utils"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// nfsSubdirMountRoot is the directory under which the controller mounts the
+// configured NFS exports to create and delete volume directories on them.
+const nfsSubdirMountRoot = "/var/lib/vsphere-csi/nfs-exports"
+
+// nfsSubdirBackend provisions file volumes as directories on the NFS exports
+// given in the NFSExport sections of the config. Every volume is a directory
+// named after the volume on the export, which nodes mount directly with the
+// same publish context keys as vSAN file shares. A generic NFS export offers
+// no API to set per directory quotas, so volumes are only created when the
+// export has the requested capacity available, and capacity limits and volume
+// expansion are rejected. Mounting the exports requires the controller
+// container to run privileged, which the default manifest does not do; see
+// manifests/vanilla/nfs-subdir-controller-patch.yaml.
+type nfsSubdirBackend struct {
+	c         *controller
+	mounter   mount.Interface
+	mountRoot string
+	// getAvailableBytes returns the bytes available on the file system
+	// mounted at the given path.
+	getAvailableBytes func(path string) (int64, error)
+	// mountLock serializes mounting the exports.
+	mountLock sync.Mutex
+}
+
+// newNFSSubdirBackend returns the nfs-subdir backend of the controller.
+func newNFSSubdirBackend(c *controller) *nfsSubdirBackend {
+	return &nfsSubdirBackend{
+		c:         c,
+		mounter:   mount.New(""),
+		mountRoot: nfsSubdirMountRoot,
+		getAvailableBytes: func(path string) (int64, error) {
+			available, _, _, _, _, _, err := fs.Info(path)
+			return available, err
+		},
+	}
+}
+
+// CreateVolume creates the directory of the volume on the NFS export given in
+// the "nfsexport" StorageClass parameter.
+func (b *nfsSubdirBackend) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequest) (
+	*csi.CreateVolumeResponse, string, error) {
+	log := logger.GetLogger(ctx)
+	scParams, err := common.ParseStorageClassParams(ctx, req.Parameters, false)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"parsing storage class parameters failed with error: %+v", err)
+	}
+	if err := common.ValidateFileVolumeStorageClassParams(scParams); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid storage class parameters for file volume. Error: %+v", err)
+	}
+	if scParams.DatastoreURL != "" || scParams.StoragePolicyName != "" || common.HasFileShareParams(scParams) {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"storage class parameters %q, %q, %q, %q, %q and %q are not supported with param %q set to %q",
+			common.AttributeDatastoreURL, common.AttributeStoragePolicyName, common.AttributeNetPermissionIPs,
+			common.AttributeNetPermission, common.AttributeRootSquash, common.AttributeSoftQuotaPercent,
+			common.AttributeFileBackend, common.FileBackendNFSSubdir)
+	}
+	exportName, export, err := b.getExport(ctx, scParams.NFSExport)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, err
+	}
+	if err := validateNFSSubdirName(req.Name); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid volume name %q. Error: %v", req.Name, err)
+	}
+	if req.GetCapacityRange().GetLimitBytes() != 0 {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"capacity limit %d is not supported with param %q set to %q, as quotas can not be enforced "+
+				"on directories of NFS exports", req.GetCapacityRange().GetLimitBytes(),
+			common.AttributeFileBackend, common.FileBackendNFSSubdir)
+	}
+	volSizeBytes := int64(common.DefaultGbDiskSize * common.GbInBytes)
+	if req.GetCapacityRange() != nil && req.GetCapacityRange().RequiredBytes != 0 {
+		volSizeBytes = req.GetCapacityRange().GetRequiredBytes()
+	}
+	exportMountPath, err := b.mountExport(ctx, exportName, export)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	volumeDir := filepath.Join(exportMountPath, req.Name)
+	if _, err := os.Stat(volumeDir); os.IsNotExist(err) {
+		availableBytes, err := b.getAvailableBytes(exportMountPath)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+				"failed to get available capacity of NFS export %q. Error: %v", exportName, err)
+		}
+		if availableBytes < volSizeBytes {
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.OutOfRange,
+				"requested capacity %d bytes exceeds the %d bytes available on NFS export %q",
+				volSizeBytes, availableBytes, exportName)
+		}
+	}
+	if err := os.MkdirAll(volumeDir, 0777); err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create directory %q on NFS export %q. Error: %v", req.Name, exportName, err)
+	}
+	// Let any user of the pods mounting the volume write to it, regardless of
+	// the umask of the controller.
+	if err := os.Chmod(volumeDir, 0777); err != nil {
+		return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to set permissions of directory %q on NFS export %q. Error: %v", req.Name, exportName, err)
+	}
+	volumeID := common.NFSSubdirVolumeIDPrefix + exportName + "/" + req.Name
+	log.Infof("Created directory %q on NFS export %q for volume %q", req.Name, exportName, volumeID)
+
+	attributes := map[string]string{
+		common.AttributeDiskType: common.DiskTypeFileVolume,
+	}
+	if scParams.NfsVersion != "" {
+		attributes[common.AttributeNfsVersion] = scParams.NfsVersion
+	}
+	if scParams.NfsSecurity != "" {
+		attributes[common.AttributeNfsSecurity] = scParams.NfsSecurity
+	}
+	return &csi.CreateVolumeResponse{
+		Volume: &csi.Volume{
+			VolumeId:      volumeID,
+			CapacityBytes: volSizeBytes,
+			VolumeContext: attributes,
+		},
+	}, "", nil
+}
+
+// DeleteVolume removes the directory of the volume with all its content from
+// the NFS export.
+func (b *nfsSubdirBackend) DeleteVolume(ctx context.Context, req *csi.DeleteVolumeRequest) (string, error) {
+	log := logger.GetLogger(ctx)
+	exportName, dirName, err := parseNFSSubdirVolumeID(req.VolumeId)
+	if err != nil {
+		return csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid volume ID %q. Error: %v", req.VolumeId, err)
+	}
+	exportName, export, err := b.getExport(ctx, exportName)
+	if err != nil {
+		return csifault.CSIInternalFault, err
+	}
+	exportMountPath, err := b.mountExport(ctx, exportName, export)
+	if err != nil {
+		return csifault.CSIInternalFault, err
+	}
+	if err := os.RemoveAll(filepath.Join(exportMountPath, dirName)); err != nil {
+		return csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to remove directory %q from NFS export %q. Error: %v", dirName, exportName, err)
+	}
+	log.Infof("Removed directory %q from NFS export %q", dirName, exportName)
+	return "", nil
+}
+
+// ControllerPublishVolume returns the path of the directory of the volume on
+// the NFS export as the access point of the NFS version the volume is mounted with.
+func (b *nfsSubdirBackend) ControllerPublishVolume(ctx context.Context,
+	req *csi.ControllerPublishVolumeRequest) (map[string]string, string, error) {
+	log := logger.GetLogger(ctx)
+	exportName, dirName, err := parseNFSSubdirVolumeID(req.VolumeId)
+	if err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid volume ID %q. Error: %v", req.VolumeId, err)
+	}
+	_, export, err := b.getExport(ctx, exportName)
+	if err != nil {
+		return nil, csifault.CSIInternalFault, err
+	}
+	accessPoint := common.Nfsv4AccessPoint
+	if req.VolumeContext[common.AttributeNfsVersion] == common.NfsVersion3 {
+		accessPoint = common.Nfsv3AccessPoint
+	}
+	return map[string]string{
+		common.AttributeDiskType: common.DiskTypeFileVolume,
+		accessPoint:              export.Server + ":" + path.Join(export.Path, dirName),
+	}, "", nil
+}
+
+// ControllerUnpublishVolume is a no-op, as access to the NFS export is
+// managed on the NFS server.
+func (b *nfsSubdirBackend) ControllerUnpublishVolume(ctx context.Context,
+	req *csi.ControllerUnpublishVolumeRequest) (string, error) {
+	log := logger.GetLogger(ctx)
+	log.Infof("Skipping ControllerUnpublish for file volume %q", req.VolumeId)
+	return "", nil
+}
+
+// ControllerExpandVolume fails, as the capacity of the directories of the
+// volumes can not be enforced.
+func (b *nfsSubdirBackend) ControllerExpandVolume(ctx context.Context,
+	req *csi.ControllerExpandVolumeRequest) (*csi.ControllerExpandVolumeResponse, string, error) {
+	log := logger.GetLogger(ctx)
+	if _, _, err := parseNFSSubdirVolumeID(req.VolumeId); err != nil {
+		return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"invalid volume ID %q. Error: %v", req.VolumeId, err)
+	}
+	return nil, csifault.CSIUnimplementedFault, logger.LogNewErrorCodef(log, codes.Unimplemented,
+		"volume expansion is not supported for volume %q with param %q set to %q, as quotas can not be "+
+			"enforced on directories of NFS exports", req.VolumeId, common.AttributeFileBackend,
+		common.FileBackendNFSSubdir)
+}
+
+// getExport returns the name and the config of the NFS export with the given
+// name. If the name is empty, the only configured NFS export is returned.
+func (b *nfsSubdirBackend) getExport(ctx context.Context, name string) (string, *cnsconfig.NFSExportConfig,
+	error) {
+	log := logger.GetLogger(ctx)
+	exports := b.c.managers.CnsConfig.NFSExport
+	if name == "" {
+		if len(exports) != 1 {
+			return "", nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+				"storage class parameter %q is required when %d NFS exports are configured",
+				common.AttributeNFSExport, len(exports))
+		}
+		for name, export := range exports {
+			return name, export, nil
+		}
+	}
+	export, ok := exports[name]
+	if !ok {
+		return "", nil, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+			"NFS export %q is not configured", name)
+	}
+	return name, export, nil
+}
+
+// mountExport mounts the NFS export under the mount root of the backend if it
+// is not mounted yet, and returns its mount path.
+func (b *nfsSubdirBackend) mountExport(ctx context.Context, name string, export *cnsconfig.NFSExportConfig) (
+	string, error) {
+	log := logger.GetLogger(ctx)
+	b.mountLock.Lock()
+	defer b.mountLock.Unlock()
+	mountPath := filepath.Join(b.mountRoot, name)
+	if err := os.MkdirAll(mountPath, 0750); err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to create mount path %q for NFS export %q. Error: %v", mountPath, name, err)
+	}
+	notMnt, err := b.mounter.IsLikelyNotMountPoint(mountPath)
+	if err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to check if NFS export %q is mounted at %q. Error: %v", name, mountPath, err)
+	}
+	if !notMnt {
+		return mountPath, nil
+	}
+	var options []string
+	for _, option := range strings.Split(export.MountOptions, ",") {
+		if option = strings.TrimSpace(option); option != "" {
+			options = append(options, option)
+		}
+	}
+	source := export.Server + ":" + export.Path
+	if err := b.mounter.Mount(source, mountPath, common.NfsFsType, options); err != nil {
+		return "", logger.LogNewErrorCodef(log, codes.Internal,
+			"failed to mount NFS export %q from %q at %q. Error: %v", name, source, mountPath, err)
+	}
+	log.Infof("Mounted NFS export %q from %q at %q", name, source, mountPath)
+	return mountPath, nil
+}
+
+// parseNFSSubdirVolumeID returns the NFS export name and the directory name
+// in the ID of a volume created by the nfs-subdir backend.
+func parseNFSSubdirVolumeID(volumeID string) (string, string, error) {
+	exportAndDir, ok := strings.CutPrefix(volumeID, common.NFSSubdirVolumeIDPrefix)
+	if !ok {
+		return "", "", fmt.Errorf("volume ID does not start with %q", common.NFSSubdirVolumeIDPrefix)
+	}
+	exportName, dirName, ok := strings.Cut(exportAndDir, "/")
+	if !ok || exportName == "" {
+		return "", "", fmt.Errorf("volume ID is not of the form %q",
+			common.NFSSubdirVolumeIDPrefix+"<export name>/<directory name>")
+	}
+	if err := validateNFSSubdirName(dirName); err != nil {
+		return "", "", err
+	}
+	return exportName, dirName, nil
+}
+
+// validateNFSSubdirName checks that the directory name of a volume stays
+// within the NFS export.
+func validateNFSSubdirName(dirName string) error {
+	if dirName == "" || dirName == "." || dirName == ".." || strings.ContainsAny(dirName, "/\\") {
+		return fmt.Errorf("invalid directory name %q", dirName)
+	}
+	return nil
+}
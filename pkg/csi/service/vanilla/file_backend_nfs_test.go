@@ -0,0 +1,193 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"k8s.io/mount-utils"
+
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	csifault "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/fault"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func newTestNFSSubdirBackend(t *testing.T, exports map[string]*cnsconfig.NFSExportConfig) *nfsSubdirBackend {
+	c := &controller{
+		managers: &common.Managers{
+			CnsConfig: &cnsconfig.Config{NFSExport: exports},
+		},
+	}
+	return &nfsSubdirBackend{
+		c:         c,
+		mounter:   mount.NewFakeMounter(nil),
+		mountRoot: t.TempDir(),
+		getAvailableBytes: func(path string) (int64, error) {
+			return 10 * common.GbInBytes, nil
+		},
+	}
+}
+
+func TestNFSSubdirBackendVolumeLifecycle(t *testing.T) {
+	ctx := context.Background()
+	b := newTestNFSSubdirBackend(t, map[string]*cnsconfig.NFSExportConfig{
+		"export1": {Server: "10.0.0.1", Path: "/exports/k8s"},
+	})
+
+	createReq := &csi.CreateVolumeRequest{
+		Name: "pvc-1234",
+		Parameters: map[string]string{
+			common.AttributeFileBackend: common.FileBackendNFSSubdir,
+			common.AttributeNfsVersion:  common.NfsVersion3,
+		},
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 2 * common.GbInBytes},
+	}
+	createResp, faultType, err := b.CreateVolume(ctx, createReq)
+	assert.NoError(t, err)
+	assert.Empty(t, faultType)
+	volumeID := createResp.Volume.VolumeId
+	assert.Equal(t, common.NFSSubdirVolumeIDPrefix+"export1/pvc-1234", volumeID)
+	assert.Equal(t, int64(2*common.GbInBytes), createResp.Volume.CapacityBytes)
+	assert.Equal(t, common.NfsVersion3, createResp.Volume.VolumeContext[common.AttributeNfsVersion])
+	volumeDir := filepath.Join(b.mountRoot, "export1", "pvc-1234")
+	info, err := os.Stat(volumeDir)
+	assert.NoError(t, err)
+	assert.True(t, info.IsDir())
+
+	publishContext, _, err := b.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+		VolumeId:      volumeID,
+		VolumeContext: createResp.Volume.VolumeContext,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:/exports/k8s/pvc-1234", publishContext[common.Nfsv3AccessPoint])
+	assert.Equal(t, common.DiskTypeFileVolume, publishContext[common.AttributeDiskType])
+
+	_, err = b.DeleteVolume(ctx, &csi.DeleteVolumeRequest{VolumeId: volumeID})
+	assert.NoError(t, err)
+	_, err = os.Stat(volumeDir)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestNFSSubdirBackendCreateVolumeInvalidParams(t *testing.T) {
+	ctx := context.Background()
+	b := newTestNFSSubdirBackend(t, map[string]*cnsconfig.NFSExportConfig{
+		"export1": {Server: "10.0.0.1", Path: "/exports/a"},
+		"export2": {Server: "10.0.0.2", Path: "/exports/b"},
+	})
+	tests := []struct {
+		name   string
+		params map[string]string
+	}{
+		{
+			name:   "export required with multiple exports",
+			params: map[string]string{common.AttributeFileBackend: common.FileBackendNFSSubdir},
+		},
+		{
+			name: "unknown export",
+			params: map[string]string{
+				common.AttributeFileBackend: common.FileBackendNFSSubdir,
+				common.AttributeNFSExport:   "export3",
+			},
+		},
+		{
+			name: "datastore not supported",
+			params: map[string]string{
+				common.AttributeFileBackend:  common.FileBackendNFSSubdir,
+				common.AttributeNFSExport:    "export1",
+				common.AttributeDatastoreURL: "ds:///vmfs/volumes/vsan:1234/",
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := b.CreateVolume(ctx, &csi.CreateVolumeRequest{Name: "pvc-1234", Parameters: test.params})
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNFSSubdirBackendCapacity(t *testing.T) {
+	ctx := context.Background()
+	b := newTestNFSSubdirBackend(t, map[string]*cnsconfig.NFSExportConfig{
+		"export1": {Server: "10.0.0.1", Path: "/exports/k8s"},
+	})
+	params := map[string]string{common.AttributeFileBackend: common.FileBackendNFSSubdir}
+	tests := []struct {
+		name          string
+		capacityRange *csi.CapacityRange
+		expected      codes.Code
+	}{
+		{
+			name:          "capacity available",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 10 * common.GbInBytes},
+			expected:      codes.OK,
+		},
+		{
+			name:          "capacity exceeds available bytes",
+			capacityRange: &csi.CapacityRange{RequiredBytes: 11 * common.GbInBytes},
+			expected:      codes.OutOfRange,
+		},
+		{
+			name:          "capacity limit not supported",
+			capacityRange: &csi.CapacityRange{RequiredBytes: common.GbInBytes, LimitBytes: 2 * common.GbInBytes},
+			expected:      codes.InvalidArgument,
+		},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := b.CreateVolume(ctx, &csi.CreateVolumeRequest{
+				Name:          fmt.Sprintf("pvc-%d", i),
+				Parameters:    params,
+				CapacityRange: test.capacityRange,
+			})
+			assert.Equal(t, test.expected, status.Code(err))
+		})
+	}
+
+	_, faultType, err := b.ControllerExpandVolume(ctx, &csi.ControllerExpandVolumeRequest{
+		VolumeId:      common.NFSSubdirVolumeIDPrefix + "export1/pvc-1234",
+		CapacityRange: &csi.CapacityRange{RequiredBytes: 20 * common.GbInBytes},
+	})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+	assert.Equal(t, csifault.CSIUnimplementedFault, faultType)
+}
+
+func TestParseNFSSubdirVolumeID(t *testing.T) {
+	exportName, dirName, err := parseNFSSubdirVolumeID(common.NFSSubdirVolumeIDPrefix + "export1/pvc-1234")
+	assert.NoError(t, err)
+	assert.Equal(t, "export1", exportName)
+	assert.Equal(t, "pvc-1234", dirName)
+
+	for _, volumeID := range []string{
+		"file:1234",
+		common.NFSSubdirVolumeIDPrefix + "export1",
+		common.NFSSubdirVolumeIDPrefix + "/pvc-1234",
+		common.NFSSubdirVolumeIDPrefix + "export1/..",
+		common.NFSSubdirVolumeIDPrefix + "export1/a/b",
+	} {
+		_, _, err := parseNFSSubdirVolumeID(volumeID)
+		assert.Error(t, err, volumeID)
+	}
+}
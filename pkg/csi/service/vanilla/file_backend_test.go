@@ -0,0 +1,213 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package vanilla
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	cnsvolume "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+)
+
+const testFileVolumeID = "file:1234"
+
+// fakeACLManager returns a vSAN file share for QueryAllVolume and records the
+// ACL specs passed to ConfigureVolumeACLs.
+type fakeACLManager struct {
+	cnsvolume.Manager
+	aclErr   error
+	aclSpecs []cnstypes.CnsVolumeACLConfigureSpec
+}
+
+func (m *fakeACLManager) QueryAllVolume(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
+	querySelection cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
+	return &cnstypes.CnsQueryResult{
+		Volumes: []cnstypes.CnsVolume{{
+			VolumeId: cnstypes.CnsVolumeId{Id: testFileVolumeID},
+			BackingObjectDetails: &cnstypes.CnsVsanFileShareBackingDetails{
+				AccessPoints: []vimtypes.KeyValue{{Key: common.Nfsv4AccessPointKey, Value: "10.0.0.100:/share"}},
+			},
+		}},
+	}, nil
+}
+
+func (m *fakeACLManager) ConfigureVolumeACLs(ctx context.Context, spec cnstypes.CnsVolumeACLConfigureSpec) error {
+	m.aclSpecs = append(m.aclSpecs, spec)
+	return m.aclErr
+}
+
+func newTestVsanFileServiceBackend(t *testing.T, volumeManager cnsvolume.Manager,
+	getNodeIPs func(ctx context.Context, nodeID string) ([]string, error)) *vsanFileServiceBackend {
+	ctx := context.Background()
+	co, err := unittestcommon.GetFakeContainerOrchestratorInterface(common.Kubernetes)
+	if err != nil {
+		t.Fatalf("Failed to create co agnostic interface. err=%v", err)
+	}
+	if err := co.EnableFSS(ctx, common.FileVolumeNodeACL); err != nil {
+		t.Fatalf("Failed to enable %s. err=%v", common.FileVolumeNodeACL, err)
+	}
+	prevCO := commonco.ContainerOrchestratorUtility
+	commonco.ContainerOrchestratorUtility = co
+	t.Cleanup(func() { commonco.ContainerOrchestratorUtility = prevCO })
+	cfg := &cnsconfig.Config{}
+	cfg.Global.VCenterIP = "vc1"
+	c := &controller{
+		managers: &common.Managers{
+			CnsConfig:      cfg,
+			VcenterConfigs: map[string]*cnsvsphere.VirtualCenterConfig{"vc1": {Host: "vc1"}},
+			VolumeManagers: map[string]cnsvolume.Manager{"vc1": volumeManager},
+		},
+	}
+	return &vsanFileServiceBackend{c: c, getNodeIPs: getNodeIPs}
+}
+
+// getACLSpecIPs returns the IPs of the net permissions in the given ACL spec
+// and whether they are removed.
+func getACLSpecIPs(spec cnstypes.CnsVolumeACLConfigureSpec) ([]string, bool) {
+	var ips []string
+	for _, perm := range spec.AccessControlSpecList[0].Permission {
+		ips = append(ips, perm.Ips)
+	}
+	return ips, spec.AccessControlSpecList[0].Delete
+}
+
+func TestVsanFileServiceBackendPublishGrantsNodeAccess(t *testing.T) {
+	ctx := context.Background()
+	volumeManager := &fakeACLManager{}
+	b := newTestVsanFileServiceBackend(t, volumeManager, func(ctx context.Context, nodeID string) ([]string, error) {
+		return []string{"10.0.0.1", "fd00::1"}, nil
+	})
+	publishInfo, _, err := b.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+		VolumeId: testFileVolumeID,
+		NodeId:   "node1",
+		Readonly: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if publishInfo[common.NfsClientIPs] != "10.0.0.1,fd00::1" {
+		t.Errorf("expected recorded node IPs %q, got %q", "10.0.0.1,fd00::1", publishInfo[common.NfsClientIPs])
+	}
+	if publishInfo[common.Nfsv4AccessPoint] != "10.0.0.100:/share" {
+		t.Errorf("expected NFSv4 access point, got publish info %v", publishInfo)
+	}
+	if len(volumeManager.aclSpecs) != 1 {
+		t.Fatalf("expected 1 ACL update, got %d", len(volumeManager.aclSpecs))
+	}
+	ips, deleted := getACLSpecIPs(volumeManager.aclSpecs[0])
+	if deleted || len(ips) != 2 {
+		t.Errorf("expected 2 node IPs to be added, got %v with delete %t", ips, deleted)
+	}
+	for _, perm := range volumeManager.aclSpecs[0].AccessControlSpecList[0].Permission {
+		if perm.Permissions != vsanfstypes.VsanFileShareAccessTypeREAD_ONLY {
+			t.Errorf("expected read-only access for IP %q, got %q", perm.Ips, perm.Permissions)
+		}
+	}
+}
+
+func TestVsanFileServiceBackendPublishFailures(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name     string
+		nodeIPs  []string
+		nodeErr  error
+		aclErr   error
+		expected codes.Code
+	}{
+		{name: "node lookup fails", nodeErr: errors.New("vCenter unreachable"), expected: codes.Internal},
+		{name: "node reports no IPs", expected: codes.Unavailable},
+		{name: "ACL update fails", nodeIPs: []string{"10.0.0.1"}, aclErr: errors.New("CNS fault"),
+			expected: codes.Internal},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumeManager := &fakeACLManager{aclErr: test.aclErr}
+			b := newTestVsanFileServiceBackend(t, volumeManager,
+				func(ctx context.Context, nodeID string) ([]string, error) {
+					return test.nodeIPs, test.nodeErr
+				})
+			_, _, err := b.ControllerPublishVolume(ctx, &csi.ControllerPublishVolumeRequest{
+				VolumeId: testFileVolumeID,
+				NodeId:   "node1",
+			})
+			if status.Code(err) != test.expected {
+				t.Errorf("expected error code %v, got %v", test.expected, err)
+			}
+		})
+	}
+}
+
+func TestVsanFileServiceBackendUnpublishRevokesNodeAccess(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name        string
+		nodeIPs     []string
+		nodeErr     error
+		aclErr      error
+		expected    codes.Code
+		expectedACL bool
+	}{
+		{name: "node access revoked", nodeIPs: []string{"10.0.0.1"}, expected: codes.OK, expectedACL: true},
+		{name: "node lookup fails", nodeErr: errors.New("vCenter unreachable"), expected: codes.Internal},
+		{name: "node VM is gone", nodeErr: cnsvsphere.ErrVMNotFound, expected: codes.OK},
+		{name: "node reports no IPs", expected: codes.OK},
+		{name: "ACL update fails", nodeIPs: []string{"10.0.0.1"}, aclErr: errors.New("CNS fault"),
+			expected: codes.Internal, expectedACL: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			volumeManager := &fakeACLManager{aclErr: test.aclErr}
+			b := newTestVsanFileServiceBackend(t, volumeManager,
+				func(ctx context.Context, nodeID string) ([]string, error) {
+					return test.nodeIPs, test.nodeErr
+				})
+			_, err := b.ControllerUnpublishVolume(ctx, &csi.ControllerUnpublishVolumeRequest{
+				VolumeId: testFileVolumeID,
+				NodeId:   "node1",
+			})
+			if status.Code(err) != test.expected {
+				t.Errorf("expected error code %v, got %v", test.expected, err)
+			}
+			if !test.expectedACL {
+				if len(volumeManager.aclSpecs) != 0 {
+					t.Errorf("expected no ACL update, got %+v", volumeManager.aclSpecs)
+				}
+				return
+			}
+			if len(volumeManager.aclSpecs) != 1 {
+				t.Fatalf("expected 1 ACL update, got %d", len(volumeManager.aclSpecs))
+			}
+			ips, deleted := getACLSpecIPs(volumeManager.aclSpecs[0])
+			if !deleted || len(ips) != 1 || ips[0] != "10.0.0.1" {
+				t.Errorf("expected IP 10.0.0.1 to be removed, got %v with delete %t", ips, deleted)
+			}
+		})
+	}
+}
@@ -208,6 +208,7 @@ func (c *controller) Init(config *cnsconfig.Config, version string) error {
 		}
 	}
 
+	cnsvolume.SetOperationTimeouts(ctx, config.VolumeOperationTimeout)
 	volumeManager, err := cnsvolume.GetManager(ctx, vcenter, operationStore,
 		idempotencyHandlingEnabled, false,
 		false, cnstypes.CnsClusterFlavorWorkload, config.Global.SupervisorID, config.Global.ClusterDistribution)
@@ -480,6 +481,7 @@ func (c *controller) ReloadConfiguration(reconnectToVCFromNewConfig bool) error
 		}
 	}
 	if cfg != nil {
+		cnsvolume.SetOperationTimeouts(ctx, cfg.VolumeOperationTimeout)
 		c.manager.CnsConfig = cfg
 		log.Debugf("Updated manager.CnsConfig")
 	}
@@ -2532,7 +2534,7 @@ func (c *controller) CreateSnapshot(ctx context.Context, req *csi.CreateSnapshot
 		}
 
 		// Get snapshot limit from namespace ConfigMap
-		snapshotLimit, err := getSnapshotLimitForNamespace(ctx, c.k8sClient, volumeSnapshotNamespace)
+		snapshotLimit, err := common.GetSnapshotLimitForNamespace(ctx, c.k8sClient, volumeSnapshotNamespace)
 		if err != nil {
 			return nil, logger.LogNewErrorCodef(log, codes.Internal,
 				"failed to get snapshot limit for namespace %q: %v", volumeSnapshotNamespace, err)
@@ -2816,21 +2818,11 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		}
 		if cnsVolumeType == common.BlockVolumeType &&
 			commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.BlockVolumeSnapshot) {
-			snapshots, _, err := common.QueryVolumeSnapshotsByVolumeID(ctx, c.manager.VolumeManager, req.VolumeId,
-				common.QuerySnapshotLimit)
+			faultType, err := common.ValidateVolumeSnapshotsForExpansion(ctx, c.manager.VcenterManager,
+				c.manager.VcenterConfig.Host, c.manager.VolumeManager, req.VolumeId)
 			if err != nil {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
-					"failed to retrieve snapshots for volume: %s. Error: %+v", req.VolumeId, err)
+				return nil, faultType, err
 			}
-			if len(snapshots) == 0 {
-				log.Infof("no CNS snapshots found for volume: %s, the volume can be safely expanded",
-					req.VolumeId)
-			} else {
-				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.FailedPrecondition,
-					"volume: %s with existing snapshots %v cannot be expanded, "+
-						"please delete snapshots before deleting the volume", req.VolumeId, snapshots)
-			}
-
 		}
 		isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
 		err = validateWCPControllerExpandVolumeRequest(ctx, req, c.manager, isOnlineExpansionEnabled)
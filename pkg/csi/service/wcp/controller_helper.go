@@ -34,11 +34,9 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/status"
 	v1 "k8s.io/api/core/v1"
-	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/client-go/kubernetes"
 	api "k8s.io/kubernetes/pkg/apis/core"
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 	spv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/storagepool/cns/v1alpha1"
@@ -751,10 +749,11 @@ func validateVolumeCapabilitiesInWcp(ctx context.Context, volCaps []*csi.VolumeC
 
 		if volCap.AccessMode.Mode == csi.VolumeCapability_AccessMode_SINGLE_NODE_WRITER {
 			// For ReadWriteOnce access mode we only support following filesystems:
-			// ext3, ext4, xfs for Linux and ntfs for Windows.
+			// ext3, ext4, xfs for Linux and ntfs, refs for Windows.
 			if volCap.GetMount() != nil && !(volCap.GetMount().FsType == common.Ext4FsType ||
 				volCap.GetMount().FsType == common.Ext3FsType || volCap.GetMount().FsType == common.XFSType ||
-				strings.ToLower(volCap.GetMount().FsType) == common.NTFSFsType || volCap.GetMount().FsType == "") {
+				strings.ToLower(volCap.GetMount().FsType) == common.NTFSFsType ||
+				strings.ToLower(volCap.GetMount().FsType) == common.ReFSFsType || volCap.GetMount().FsType == "") {
 				return fmt.Errorf("fstype %s not supported for ReadWriteOnce volume creation",
 					volCap.GetMount().FsType)
 			}
@@ -952,56 +951,3 @@ func GetZonesFromAccessibilityRequirements(ctx context.Context,
 	}
 	return zones, nil
 }
-
-// getSnapshotLimitForNamespace reads the snapshot limit from ConfigMap in the namespace.
-// Returns the effective limit after applying defaults and absolute max clamping.
-func getSnapshotLimitForNamespace(ctx context.Context, k8sClient kubernetes.Interface, namespace string) (int, error) {
-	log := logger.GetLogger(ctx)
-
-	// Get ConfigMap from the namespace
-	cm, err := k8sClient.CoreV1().ConfigMaps(namespace).Get(ctx, common.ConfigMapCSILimits, metav1.GetOptions{})
-	if err != nil {
-		if apierrors.IsNotFound(err) {
-			// ConfigMap not found, use default
-			log.Infof("getSnapshotLimitForNamespace: ConfigMap %s not found in namespace %s, using default: %d",
-				common.ConfigMapCSILimits, namespace, common.DefaultMaxSnapshotsPerVolume)
-			return common.DefaultMaxSnapshotsPerVolume, nil
-		}
-		// Other error occurred
-		log.Errorf("getSnapshotLimitForNamespace: failed to get ConfigMap %s in namespace %s, err: %v",
-			common.ConfigMapCSILimits, namespace, err)
-		return 0, err
-	}
-
-	// Check if the key exists in ConfigMap data
-	limitStr, exists := cm.Data[common.ConfigMapKeyMaxSnapshotsPerVolume]
-	if !exists {
-		// Key not found in ConfigMap, fail the request
-		errMsg := fmt.Sprintf("ConfigMap %s exists in namespace %s but missing required key '%s'",
-			common.ConfigMapCSILimits, namespace, common.ConfigMapKeyMaxSnapshotsPerVolume)
-		log.Errorf("getSnapshotLimitForNamespace: %s", errMsg)
-		return 0, errors.New(errMsg)
-	}
-
-	// Parse the limit value
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit < 0 {
-		// Invalid value, fail the request
-		errMsg := fmt.Sprintf(
-			"ConfigMap %s in namespace %s has invalid value '%s' for key '%s': must be a non-negative integer",
-			common.ConfigMapCSILimits, namespace, limitStr, common.ConfigMapKeyMaxSnapshotsPerVolume)
-		log.Errorf("getSnapshotLimitForNamespace: %s", errMsg)
-		return 0, errors.New(errMsg)
-	}
-
-	// Clamp to absolute max if exceeded
-	if limit > common.AbsoluteMaxSnapshotsPerVolume {
-		log.Warnf(
-			"getSnapshotLimitForNamespace: namespace %s ConfigMap limit %d exceeds absolute max %d, clamping to absolute max",
-			namespace, limit, common.AbsoluteMaxSnapshotsPerVolume)
-		return common.AbsoluteMaxSnapshotsPerVolume, nil
-	}
-
-	log.Infof("getSnapshotLimitForNamespace: namespace %s snapshot limit from ConfigMap: %d", namespace, limit)
-	return limit, nil
-}
@@ -12,7 +12,6 @@ import (
 	"k8s.io/client-go/kubernetes/fake"
 	k8stesting "k8s.io/client-go/testing"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
-	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 )
 
@@ -159,164 +158,3 @@ func newMockPod(name, namespace, nodeName string, volumes []string,
 		},
 	}
 }
-
-func TestGetSnapshotLimitForNamespace(t *testing.T) {
-	t.Run("WhenConfigMapExists_ValidValue", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "5",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		limit, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.Nil(t, err)
-		assert.Equal(t, 5, limit)
-	})
-
-	t.Run("WhenConfigMapExists_ValueEqualsMax", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "32",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		limit, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.Nil(t, err)
-		assert.Equal(t, 32, limit)
-	})
-
-	t.Run("WhenConfigMapExists_ValueExceedsMax", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "50",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		limit, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.Nil(t, err)
-		assert.Equal(t, 32, limit) // Should be capped to absolute max
-	})
-
-	t.Run("WhenConfigMapExists_ValueIsZero", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "0",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		limit, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.Nil(t, err)
-		assert.Equal(t, 0, limit) // 0 means block all snapshots
-	})
-
-	t.Run("WhenConfigMapExists_ValueIsNegative", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "-5",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		_, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "invalid value")
-		assert.Contains(t, err.Error(), "must be a non-negative integer")
-	})
-
-	t.Run("WhenConfigMapExists_InvalidFormat", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{
-				common.ConfigMapKeyMaxSnapshotsPerVolume: "abc",
-			},
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		_, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "invalid value")
-		assert.Contains(t, err.Error(), "must be a non-negative integer")
-	})
-
-	t.Run("WhenConfigMapExists_MissingKey", func(t *testing.T) {
-		// Setup
-		cm := &v1.ConfigMap{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      common.ConfigMapCSILimits,
-				Namespace: "test-namespace",
-			},
-			Data: map[string]string{}, // ConfigMap exists but key is missing
-		}
-		fakeClient := fake.NewClientset(cm)
-
-		// Execute
-		_, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.NotNil(t, err)
-		assert.Contains(t, err.Error(), "missing required key")
-	})
-
-	t.Run("WhenConfigMapNotFound", func(t *testing.T) {
-		// Setup
-		fakeClient := fake.NewClientset() // Empty clientset
-
-		// Execute
-		limit, err := getSnapshotLimitForNamespace(context.Background(), fakeClient, "test-namespace")
-
-		// Verify
-		assert.Nil(t, err)
-		assert.Equal(t, common.DefaultMaxSnapshotsPerVolume, limit) // Should return default (4)
-	})
-}
@@ -344,12 +344,15 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 		}
 
 		// Get supervisorStorageClass and accessMode
-		var supervisorStorageClass string
+		var supervisorStorageClass, expansionMode string
 		for param := range req.Parameters {
 			paramName := strings.ToLower(param)
 			if paramName == common.AttributeSupervisorStorageClass {
 				supervisorStorageClass = req.Parameters[param]
 			}
+			if paramName == common.AttributeExpansionMode {
+				expansionMode = strings.ToLower(req.Parameters[param])
+			}
 			if paramName == common.AttributePvcName {
 				pvcName = req.Parameters[param]
 			}
@@ -480,6 +483,9 @@ func (c *controller) CreateVolume(ctx context.Context, req *csi.CreateVolumeRequ
 			attributes[common.AttributeDiskType] = common.DiskTypeFileVolume
 		} else {
 			attributes[common.AttributeDiskType] = common.DiskTypeBlockVolume
+			if expansionMode == common.ExpansionModeOffline {
+				attributes[common.AttributeExpansionMode] = expansionMode
+			}
 		}
 
 		if isLinkedCloneRequest {
@@ -1355,7 +1361,19 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 		volumeID := req.GetVolumeId()
 		volSizeBytes := int64(req.GetCapacityRange().GetRequiredBytes())
 
-		if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend) {
+		isOnlineExpansionEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.OnlineVolumeExtend)
+		isOfflineExpansionRequired := false
+		if isOnlineExpansionEnabled {
+			// Honour the StorageClass "expansionmode" param of the volume, which
+			// restricts expansion to detached volumes.
+			volumeAttributes, err := commonco.ContainerOrchestratorUtility.GetVolumeAttributes(ctx, volumeID)
+			if err != nil {
+				return nil, csifault.CSIInternalFault, logger.LogNewErrorCodef(log, codes.Internal,
+					"failed to get volume attributes for volume: %q. Error: %v", volumeID, err)
+			}
+			isOfflineExpansionRequired = volumeAttributes[common.AttributeExpansionMode] == common.ExpansionModeOffline
+		}
+		if !isOnlineExpansionEnabled || isOfflineExpansionRequired {
 			vmList, err := utils.ListVirtualMachines(ctx, c.vmOperatorClient, c.supervisorNamespace)
 			if err != nil {
 				msg := fmt.Sprintf("failed to list virtualmachines with error: %+v", err)
@@ -1368,6 +1386,11 @@ func (c *controller) ControllerExpandVolume(ctx context.Context, req *csi.Contro
 					if vmVolume.Name == volumeID && vmVolume.Attached {
 						msg := fmt.Sprintf("failed to expand volume: %q. Volume is attached to pod. "+
 							"Only offline volume expansion is supported", volumeID)
+						if isOfflineExpansionRequired {
+							msg = fmt.Sprintf("volume %q can only be expanded while detached from all nodes "+
+								"as StorageClass param %q is set to %q", volumeID, common.AttributeExpansionMode,
+								common.ExpansionModeOffline)
+						}
 						log.Error(msg)
 						return nil, csifault.CSIInvalidArgumentFault, status.Error(codes.FailedPrecondition, msg)
 					}
@@ -1492,13 +1515,84 @@ func (c *controller) ValidateVolumeCapabilities(ctx context.Context, req *csi.Va
 	}, nil
 }
 
+// ListVolumes returns the volumes of the guest cluster along with the guest
+// cluster nodes they are attached to in the supervisor cluster. StartingToken
+// and NextToken are indexes into the list of volumes sorted by volume ID.
 func (c *controller) ListVolumes(ctx context.Context, req *csi.ListVolumesRequest) (
 	*csi.ListVolumesResponse, error) {
-
+	start := time.Now()
 	ctx = logger.NewContextWithLogger(ctx)
 	log := logger.GetLogger(ctx)
+	volumeType := prometheus.PrometheusBlockVolumeType
 	log.Infof("ListVolumes: called with args %+v", req)
-	return nil, status.Error(codes.Unimplemented, "")
+	if !commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ListVolumes) {
+		return nil, logger.LogNewErrorCode(log, codes.Unimplemented, "List Volumes")
+	}
+
+	listVolumesInternal := func() (*csi.ListVolumesResponse, string, error) {
+		startingToken := 0
+		if req.StartingToken != "" {
+			var err error
+			startingToken, err = strconv.Atoi(req.StartingToken)
+			if err != nil || startingToken < 0 {
+				return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.InvalidArgument,
+					"startingToken %q not a valid integer", req.StartingToken)
+			}
+		}
+		volumeIDs, err := getGuestClusterVolumeIDs(ctx, c.guestClient)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+		}
+		if startingToken > len(volumeIDs) {
+			return nil, csifault.CSIInvalidArgumentFault, logger.LogNewErrorCodef(log, codes.Aborted,
+				"startingToken %d is greater than the number of volumes %d", startingToken, len(volumeIDs))
+		}
+		publishedNodes, err := getPublishedNodesForSupervisorVolumes(ctx, c.vmOperatorClient,
+			c.cnsOperatorClient, c.supervisorNamespace)
+		if err != nil {
+			return nil, csifault.CSIInternalFault, logger.LogNewErrorCode(log, codes.Internal, err.Error())
+		}
+
+		end := len(volumeIDs)
+		if req.MaxEntries > 0 && startingToken+int(req.MaxEntries) < end {
+			end = startingToken + int(req.MaxEntries)
+		}
+		var entries []*csi.ListVolumesResponse_Entry
+		for _, volumeID := range volumeIDs[startingToken:end] {
+			entries = append(entries, &csi.ListVolumesResponse_Entry{
+				Volume: &csi.Volume{
+					VolumeId: volumeID,
+				},
+				Status: &csi.ListVolumesResponse_VolumeStatus{
+					PublishedNodeIds: publishedNodes[volumeID],
+				},
+			})
+		}
+		nextToken := ""
+		if end < len(volumeIDs) {
+			nextToken = strconv.Itoa(end)
+		}
+		log.Debugf("ListVolumes served %d results, token for next set: %s", len(entries), nextToken)
+		return &csi.ListVolumesResponse{
+			Entries:   entries,
+			NextToken: nextToken,
+		}, "", nil
+	}
+	resp, faultType, err := listVolumesInternal()
+	if err != nil {
+		if csifault.IsNonStorageFault(faultType) {
+			faultType = csifault.AddCsiNonStoragePrefix(ctx, faultType)
+		}
+		log.Errorf("Operation failed, reporting failure status to Prometheus."+
+			" Operation Type: %q, Volume Type: %q, Fault Type: %q",
+			prometheus.PrometheusListVolumeOpType, volumeType, faultType)
+		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusListVolumeOpType,
+			prometheus.PrometheusFailStatus, faultType).Observe(time.Since(start).Seconds())
+	} else {
+		prometheus.CsiControlOpsHistVec.WithLabelValues(volumeType, prometheus.PrometheusListVolumeOpType,
+			prometheus.PrometheusPassStatus, faultType).Observe(time.Since(start).Seconds())
+	}
+	return resp, err
 }
 
 func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityRequest) (
@@ -1511,6 +1605,14 @@ func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityReques
 	totalcapacity := int64(math.MaxInt64)
 	maxvolumesize := int64(math.MaxInt64)
 
+	var supervisorStorageClass string
+	for param, value := range req.Parameters {
+		if strings.ToLower(param) == common.AttributeSupervisorStorageClass {
+			supervisorStorageClass = value
+		}
+	}
+	zone := req.GetAccessibleTopology().GetSegments()[corev1.LabelTopologyZone]
+
 	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.WorkloadDomainIsolationFSS) {
 		zonesMap := commonco.ContainerOrchestratorUtility.GetZonesForNamespace(c.supervisorNamespace)
 		if req.AccessibleTopology != nil {
@@ -1519,18 +1621,51 @@ func (c *controller) GetCapacity(ctx context.Context, req *csi.GetCapacityReques
 					"aware but CSI controller could not find zone instances in supervisor cluster.")
 			}
 
-			segments := req.AccessibleTopology.GetSegments()
-			if _, exists := zonesMap[segments["topology.kubernetes.io/zone"]]; !exists {
-				totalcapacity = 0
-				maxvolumesize = 0
+			if _, exists := zonesMap[zone]; !exists {
 				log.Infof("Zone %q is either marked for deletion or is not part of the namespace %q. "+
-					"Setting capacity to 0.", segments["topology.kubernetes.io/zone"], c.supervisorNamespace)
+					"Setting capacity to 0.", zone, c.supervisorNamespace)
+				return &csi.GetCapacityResponse{
+					AvailableCapacity: 0,
+					MaximumVolumeSize: &wrapperspb.Int64Value{Value: 0},
+				}, nil
 			}
 		} else {
 			log.Debug("Not a topology aware guest cluster")
 		}
 	}
 
+	if supervisorStorageClass != "" {
+		// A zonal supervisor storage class can only provision volumes in the
+		// zones of its allowed topologies.
+		if zone != "" {
+			accessible, err := isStorageClassAccessibleInZone(ctx, c.supervisorClient, supervisorStorageClass, zone)
+			if err != nil {
+				log.Warnf("failed to check if storage class %q is accessible in zone %q. Error: %v",
+					supervisorStorageClass, zone, err)
+			} else if !accessible {
+				log.Infof("Storage class %q is not accessible in zone %q. Setting capacity to 0.",
+					supervisorStorageClass, zone)
+				totalcapacity = 0
+				maxvolumesize = 0
+			}
+		}
+		// If a storage quota applies to the supervisor storage class, report
+		// the quota left for it in the supervisor namespace as the capacity.
+		// Storage quotas are not set per zone, so every zone in which the
+		// storage class is accessible reports the same remaining quota.
+		if totalcapacity != 0 {
+			remainingQuota, found, err := getRemainingStorageQuota(ctx, c.cnsOperatorClient, c.supervisorClient,
+				c.supervisorNamespace, supervisorStorageClass)
+			if err != nil {
+				log.Warnf("failed to get storage quota for storage class %q in namespace %q, "+
+					"reporting unlimited capacity. Error: %v", supervisorStorageClass, c.supervisorNamespace, err)
+			} else if found {
+				totalcapacity = remainingQuota
+				maxvolumesize = remainingQuota
+			}
+		}
+	}
+
 	return &csi.GetCapacityResponse{
 		AvailableCapacity: totalcapacity,
 		MaximumVolumeSize: &wrapperspb.Int64Value{Value: maxvolumesize},
@@ -1544,7 +1679,12 @@ func (c *controller) ControllerGetCapabilities(ctx context.Context, req *csi.Con
 	log := logger.GetLogger(ctx)
 	log.Infof("ControllerGetCapabilities: called with args %+v", req)
 	var caps []*csi.ControllerServiceCapability
-	for _, cap := range controllerCaps {
+	capTypes := slices.Clone(controllerCaps)
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.ListVolumes) {
+		capTypes = append(capTypes, csi.ControllerServiceCapability_RPC_LIST_VOLUMES,
+			csi.ControllerServiceCapability_RPC_LIST_VOLUMES_PUBLISHED_NODES)
+	}
+	for _, cap := range capTypes {
 		c := &csi.ControllerServiceCapability{
 			Type: &csi.ControllerServiceCapability_Rpc{
 				Rpc: &csi.ControllerServiceCapability_RPC{
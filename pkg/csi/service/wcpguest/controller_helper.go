@@ -21,6 +21,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -31,13 +32,19 @@ import (
 	snap "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
 	"google.golang.org/grpc/codes"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/fields"
 	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	cnsnodevmattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmattachment/v1alpha1"
+	cnsnodevmbatchattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmbatchattachment/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	csitypes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/types"
 )
 
 const (
@@ -56,6 +63,10 @@ const (
 	// Default timeout for create snapshot, used unless overridden by user in
 	// csi-controller YAML.
 	defaultSnapshotTimeoutInMin = 4
+
+	// Suffix of the ResourceQuota resource which limits the requested storage
+	// of a storage class in a namespace.
+	storageClassQuotaResourceSuffix = ".storageclass.storage.k8s.io/requests.storage"
 )
 
 // validateGuestClusterCreateVolumeRequest is the helper function to validate
@@ -89,6 +100,13 @@ func validateGuestClusterCreateVolumeRequest(ctx context.Context, req *csi.Creat
 				return logger.LogNewErrorCodef(log, codes.InvalidArgument,
 					"Volume parameter %s is not a valid GC CSI parameter", param)
 			}
+		case common.AttributeExpansionMode:
+			expansionMode := strings.ToLower(val)
+			if expansionMode != common.ExpansionModeOnline && expansionMode != common.ExpansionModeOffline {
+				return logger.LogNewErrorCodef(log, codes.InvalidArgument,
+					"invalid value %q received for %q parameter. Supported values are %q and %q",
+					val, param, common.ExpansionModeOnline, common.ExpansionModeOffline)
+			}
 		case common.AttributePvcNamespace:
 		case common.AttributePvcName:
 		case common.AttributePvName:
@@ -131,7 +149,7 @@ func validateGuestClusterControllerUnpublishVolumeRequest(ctx context.Context,
 
 func validateGuestClusterControllerExpandVolumeRequest(ctx context.Context,
 	req *csi.ControllerExpandVolumeRequest) error {
-	return common.ValidateControllerExpandVolumeRequest(ctx, req)
+	return common.ValidateControllerExpandVolumeRequest(ctx, req, false)
 }
 
 // checkForSupervisorPVCCondition returns nil if the PVC condition is set as
@@ -465,3 +483,198 @@ func constructListSnapshotEntry(vs snap.VolumeSnapshot) *csi.ListSnapshotsRespon
 	}
 	return entry
 }
+
+// getRemainingStorageQuota returns the storage quota left in the supervisor
+// namespace for the given supervisor storage class. Two limits apply:
+// the StoragePolicyQuota of the storage policy of the storage class, which is
+// consumed by the used and reserved storage of all storage classes of the
+// policy, and the ResourceQuota set on the storage class itself. The smaller
+// remaining value is returned. The returned bool is false if no quota limit
+// applies to the storage class.
+func getRemainingStorageQuota(ctx context.Context, cnsOperatorClient client.Client,
+	supervisorClient clientset.Interface, namespace string, storageClassName string) (int64, bool, error) {
+	log := logger.GetLogger(ctx)
+	remaining, found, err := getRemainingStoragePolicyQuota(ctx, cnsOperatorClient, namespace, storageClassName)
+	if err != nil {
+		return 0, false, err
+	}
+	scRemaining, scFound, err := getRemainingStorageClassQuota(ctx, supervisorClient, namespace, storageClassName)
+	if err != nil {
+		return 0, false, err
+	}
+	if scFound && (!found || scRemaining < remaining) {
+		remaining = scRemaining
+		found = true
+	}
+	if found {
+		log.Debugf("storage class %q in namespace %q has %d bytes of storage quota remaining",
+			storageClassName, namespace, remaining)
+	}
+	return remaining, found, nil
+}
+
+// getRemainingStoragePolicyQuota returns the quota left in the
+// StoragePolicyQuota of the storage policy of the given storage class.
+func getRemainingStoragePolicyQuota(ctx context.Context, cnsOperatorClient client.Client, namespace string,
+	storageClassName string) (int64, bool, error) {
+	_, limit, consumed, err := utils.GetStoragePolicyQuotaUsage(ctx, cnsOperatorClient, namespace,
+		storageClassName)
+	if err != nil || limit == nil {
+		return 0, false, err
+	}
+	remaining := limit.Value() - consumed.Value()
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true, nil
+}
+
+// getRemainingStorageClassQuota returns the quota left in the ResourceQuotas
+// of the supervisor namespace which limit the requested storage of the given
+// storage class.
+func getRemainingStorageClassQuota(ctx context.Context, supervisorClient clientset.Interface, namespace string,
+	storageClassName string) (int64, bool, error) {
+	log := logger.GetLogger(ctx)
+	quotaList, err := supervisorClient.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to list ResourceQuotas in namespace %q. Error: %+v", namespace, err)
+	}
+	resourceName := v1.ResourceName(storageClassName + storageClassQuotaResourceSuffix)
+	var remaining int64
+	found := false
+	for _, quota := range quotaList.Items {
+		hard, ok := quota.Spec.Hard[resourceName]
+		if !ok {
+			continue
+		}
+		quotaRemaining := hard.Value()
+		if used, ok := quota.Status.Used[resourceName]; ok {
+			quotaRemaining -= used.Value()
+		}
+		if quotaRemaining < 0 {
+			quotaRemaining = 0
+		}
+		log.Debugf("ResourceQuota %q in namespace %q limits storage class %q to %s, remaining %d",
+			quota.Name, namespace, storageClassName, hard.String(), quotaRemaining)
+		if !found || quotaRemaining < remaining {
+			remaining = quotaRemaining
+			found = true
+		}
+	}
+	return remaining, found, nil
+}
+
+// isStorageClassAccessibleInZone returns true if volumes of the given
+// supervisor storage class can be provisioned in the given zone, i.e. the
+// storage class has no allowed topologies or one of them contains the zone.
+func isStorageClassAccessibleInZone(ctx context.Context, supervisorClient clientset.Interface,
+	storageClassName string, zone string) (bool, error) {
+	sc, err := supervisorClient.StorageV1().StorageClasses().Get(ctx, storageClassName, metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("failed to get supervisor storage class %q. Error: %+v", storageClassName, err)
+	}
+	if len(sc.AllowedTopologies) == 0 {
+		return true, nil
+	}
+	for _, term := range sc.AllowedTopologies {
+		for _, expression := range term.MatchLabelExpressions {
+			if expression.Key != v1.LabelTopologyZone {
+				continue
+			}
+			for _, value := range expression.Values {
+				if value == zone {
+					return true, nil
+				}
+			}
+		}
+	}
+	return false, nil
+}
+
+// getGuestClusterVolumeIDs returns the sorted volume IDs of the PVs in the
+// guest cluster provisioned by the driver. The volume ID of a guest cluster
+// volume is the name of its PVC in the supervisor namespace.
+func getGuestClusterVolumeIDs(ctx context.Context, guestClient clientset.Interface) ([]string, error) {
+	pvList, err := guestClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PVs in the guest cluster. Error: %+v", err)
+	}
+	var volumeIDs []string
+	for _, pv := range pvList.Items {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+			volumeIDs = append(volumeIDs, pv.Spec.CSI.VolumeHandle)
+		}
+	}
+	slices.Sort(volumeIDs)
+	return volumeIDs, nil
+}
+
+// getPublishedNodesForSupervisorVolumes returns the names of the guest
+// cluster nodes to which each supervisor PVC is attached, as reported by the
+// CnsNodeVmAttachment and CnsNodeVMBatchAttachment instances in the
+// supervisor namespace. Node names of the guest cluster are the names of the
+// VirtualMachines backing them in the supervisor namespace.
+func getPublishedNodesForSupervisorVolumes(ctx context.Context, vmOperatorClient client.Client,
+	cnsOperatorClient client.Client, namespace string) (map[string][]string, error) {
+	log := logger.GetLogger(ctx)
+	vmList, err := utils.ListVirtualMachines(ctx, vmOperatorClient, namespace)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list VirtualMachines in namespace %q. Error: %+v", namespace, err)
+	}
+	biosUUIDToNodeName := make(map[string]string)
+	instanceUUIDToNodeName := make(map[string]string)
+	for _, vm := range vmList.Items {
+		if vm.Status.BiosUUID != "" {
+			biosUUIDToNodeName[vm.Status.BiosUUID] = vm.Name
+		}
+		if vm.Status.InstanceUUID != "" {
+			instanceUUIDToNodeName[vm.Status.InstanceUUID] = vm.Name
+		}
+	}
+
+	publishedNodes := make(map[string][]string)
+	attachmentList := &cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentList{}
+	err = cnsOperatorClient.List(ctx, attachmentList, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CnsNodeVmAttachment instances in namespace %q. Error: %+v",
+			namespace, err)
+	}
+	for _, attachment := range attachmentList.Items {
+		if !attachment.Status.Attached {
+			continue
+		}
+		nodeName, found := biosUUIDToNodeName[attachment.Spec.NodeUUID]
+		if !found {
+			log.Debugf("no VirtualMachine found with bios UUID %q for CnsNodeVmAttachment %q",
+				attachment.Spec.NodeUUID, attachment.Name)
+			continue
+		}
+		publishedNodes[attachment.Spec.VolumeName] = append(publishedNodes[attachment.Spec.VolumeName], nodeName)
+	}
+
+	batchAttachmentList := &cnsnodevmbatchattachmentv1alpha1.CnsNodeVMBatchAttachmentList{}
+	err = cnsOperatorClient.List(ctx, batchAttachmentList, client.InNamespace(namespace))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list CnsNodeVMBatchAttachment instances in namespace %q. Error: %+v",
+			namespace, err)
+	}
+	for _, batchAttachment := range batchAttachmentList.Items {
+		nodeName, found := instanceUUIDToNodeName[batchAttachment.Spec.InstanceUUID]
+		if !found {
+			log.Debugf("no VirtualMachine found with instance UUID %q for CnsNodeVMBatchAttachment %q",
+				batchAttachment.Spec.InstanceUUID, batchAttachment.Name)
+			continue
+		}
+		for _, volumeStatus := range batchAttachment.Status.VolumeStatus {
+			pvcStatus := volumeStatus.PersistentVolumeClaim
+			if !pvcStatus.Attached && !meta.IsStatusConditionTrue(pvcStatus.Conditions,
+				cnsnodevmbatchattachmentv1alpha1.ConditionAttached) {
+				continue
+			}
+			if !slices.Contains(publishedNodes[pvcStatus.ClaimName], nodeName) {
+				publishedNodes[pvcStatus.ClaimName] = append(publishedNodes[pvcStatus.ClaimName], nodeName)
+			}
+		}
+	}
+	return publishedNodes, nil
+}
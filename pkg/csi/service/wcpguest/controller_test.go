@@ -26,7 +26,9 @@ import (
 
 	vmoperatortypes "github.com/vmware-tanzu/vm-operator/api/v1alpha2"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientset "k8s.io/client-go/kubernetes"
@@ -35,6 +37,10 @@ import (
 	ctrlclientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
 
 	"github.com/container-storage-interface/spec/lib/go/csi"
+	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	cnsnodevmattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmattachment/v1alpha1"
+	cnsnodevmbatchattachmentv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/cnsnodevmbatchattachment/v1alpha1"
+	storagepolicyv1alpha2 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagepolicy/v1alpha2"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/unittestcommon"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
@@ -638,3 +644,224 @@ func TestVirtualMachineVolumePatchWithOptimisticMerge(t *testing.T) {
 		t.Fatalf("invalid volume name: a=%s, e=%s", a, e)
 	}
 }
+
+func TestGetRemainingStorageQuota(t *testing.T) {
+	ctx := context.Background()
+	scheme := runtime.NewScheme()
+	_ = cnsoperatorv1alpha1.AddToScheme(scheme)
+	limit := resource.MustParse("100Gi")
+	newUsage := func(name, storageClassName, used, reserved string) *storagepolicyv1alpha2.StoragePolicyUsage {
+		usedQuantity := resource.MustParse(used)
+		reservedQuantity := resource.MustParse(reserved)
+		return &storagepolicyv1alpha2.StoragePolicyUsage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec: storagepolicyv1alpha2.StoragePolicyUsageSpec{
+				StoragePolicyId:  "policy-1",
+				StorageClassName: storageClassName,
+			},
+			Status: storagepolicyv1alpha2.StoragePolicyUsageStatus{
+				ResourceTypeLevelQuotaUsage: &storagepolicyv1alpha2.QuotaUsageDetails{
+					Used:     &usedQuantity,
+					Reserved: &reservedQuantity,
+				},
+			},
+		}
+	}
+	client := ctrlclientfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&storagepolicyv1alpha2.StoragePolicyQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy-1-quota", Namespace: testNamespace},
+				Spec: storagepolicyv1alpha2.StoragePolicyQuotaSpec{
+					StoragePolicyId: "policy-1",
+					Limit:           &limit,
+				},
+			},
+			newUsage(testStorageClass+"-pvc-usage", testStorageClass, "40Gi", "10Gi"),
+			newUsage(testStorageClass+"-latebinding-pvc-usage", testStorageClass+"-latebinding", "20Gi", "0"),
+		).
+		Build()
+
+	supervisorClient := testclient.NewClientset()
+
+	remaining, found, err := getRemainingStorageQuota(ctx, client, supervisorClient, testNamespace, testStorageClass)
+	if err != nil {
+		t.Fatalf("failed to get remaining storage quota. Error: %v", err)
+	}
+	if !found || remaining != 30*1024*1024*1024 {
+		t.Errorf("expected 30Gi remaining quota, got %d (found: %t)", remaining, found)
+	}
+
+	_, found, err = getRemainingStorageQuota(ctx, client, supervisorClient, testNamespace, "unknown-storageclass")
+	if err != nil {
+		t.Fatalf("failed to get remaining storage quota. Error: %v", err)
+	}
+	if found {
+		t.Errorf("expected no quota for a storage class without StoragePolicyUsage")
+	}
+
+	// A ResourceQuota on the storage class caps the remaining quota below
+	// the storage policy quota.
+	scResource := v1.ResourceName(testStorageClass + storageClassQuotaResourceSuffix)
+	supervisorClient = testclient.NewClientset(&v1.ResourceQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: testNamespace + "-storagequota", Namespace: testNamespace},
+		Spec: v1.ResourceQuotaSpec{
+			Hard: v1.ResourceList{scResource: resource.MustParse("50Gi")},
+		},
+		Status: v1.ResourceQuotaStatus{
+			Used: v1.ResourceList{scResource: resource.MustParse("40Gi")},
+		},
+	})
+	remaining, found, err = getRemainingStorageQuota(ctx, client, supervisorClient, testNamespace, testStorageClass)
+	if err != nil {
+		t.Fatalf("failed to get remaining storage quota. Error: %v", err)
+	}
+	if !found || remaining != 10*1024*1024*1024 {
+		t.Errorf("expected 10Gi remaining quota, got %d (found: %t)", remaining, found)
+	}
+	_, found, err = getRemainingStorageQuota(ctx, client, supervisorClient, testNamespace,
+		testStorageClass+"-latebinding")
+	if err != nil {
+		t.Fatalf("failed to get remaining storage quota. Error: %v", err)
+	}
+	if !found {
+		t.Errorf("expected the storage policy quota to apply to %q", testStorageClass+"-latebinding")
+	}
+}
+
+func TestIsStorageClassAccessibleInZone(t *testing.T) {
+	ctx := context.Background()
+	supervisorClient := testclient.NewClientset(
+		&storagev1.StorageClass{
+			ObjectMeta: metav1.ObjectMeta{Name: "zonal-sc"},
+			AllowedTopologies: []v1.TopologySelectorTerm{{
+				MatchLabelExpressions: []v1.TopologySelectorLabelRequirement{{
+					Key:    v1.LabelTopologyZone,
+					Values: []string{"zone-a"},
+				}},
+			}},
+		},
+		&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "cross-zonal-sc"}},
+	)
+	tests := []struct {
+		storageClass string
+		zone         string
+		expected     bool
+	}{
+		{"zonal-sc", "zone-a", true},
+		{"zonal-sc", "zone-b", false},
+		{"cross-zonal-sc", "zone-b", true},
+	}
+	for _, test := range tests {
+		accessible, err := isStorageClassAccessibleInZone(ctx, supervisorClient, test.storageClass, test.zone)
+		if err != nil {
+			t.Fatalf("failed to check storage class %q in zone %q. Error: %v", test.storageClass, test.zone, err)
+		}
+		if accessible != test.expected {
+			t.Errorf("expected storage class %q accessible in zone %q to be %t, got %t",
+				test.storageClass, test.zone, test.expected, accessible)
+		}
+	}
+	if _, err := isStorageClassAccessibleInZone(ctx, supervisorClient, "unknown-sc", "zone-a"); err == nil {
+		t.Errorf("expected an error for an unknown storage class")
+	}
+}
+
+func TestGetPublishedNodesForSupervisorVolumes(t *testing.T) {
+	vmScheme := runtime.NewScheme()
+	_ = vmoperatortypes.AddToScheme(vmScheme)
+	vmOperatorClient := ctrlclientfake.NewClientBuilder().
+		WithScheme(vmScheme).
+		WithObjects(
+			&vmoperatortypes.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1", Namespace: testNamespace},
+				Status:     vmoperatortypes.VirtualMachineStatus{BiosUUID: "bios-1", InstanceUUID: "instance-1"},
+			},
+			&vmoperatortypes.VirtualMachine{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-2", Namespace: testNamespace},
+				Status:     vmoperatortypes.VirtualMachineStatus{BiosUUID: "bios-2", InstanceUUID: "instance-2"},
+			},
+		).
+		Build()
+
+	cnsScheme := runtime.NewScheme()
+	_ = cnsoperatorv1alpha1.AddToScheme(cnsScheme)
+	cnsOperatorClient := ctrlclientfake.NewClientBuilder().
+		WithScheme(cnsScheme).
+		WithObjects(
+			&cnsnodevmattachmentv1alpha1.CnsNodeVmAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1-pvc-1", Namespace: testNamespace},
+				Spec:       cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentSpec{NodeUUID: "bios-1", VolumeName: "pvc-1"},
+				Status:     cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentStatus{Attached: true},
+			},
+			&cnsnodevmattachmentv1alpha1.CnsNodeVmAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-1-pvc-2", Namespace: testNamespace},
+				Spec:       cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentSpec{NodeUUID: "bios-1", VolumeName: "pvc-2"},
+				Status:     cnsnodevmattachmentv1alpha1.CnsNodeVmAttachmentStatus{Attached: false},
+			},
+			&cnsnodevmbatchattachmentv1alpha1.CnsNodeVMBatchAttachment{
+				ObjectMeta: metav1.ObjectMeta{Name: "node-2", Namespace: testNamespace},
+				Spec:       cnsnodevmbatchattachmentv1alpha1.CnsNodeVMBatchAttachmentSpec{InstanceUUID: "instance-2"},
+				Status: cnsnodevmbatchattachmentv1alpha1.CnsNodeVMBatchAttachmentStatus{
+					VolumeStatus: []cnsnodevmbatchattachmentv1alpha1.VolumeStatus{
+						{
+							Name: "pvc-1",
+							PersistentVolumeClaim: cnsnodevmbatchattachmentv1alpha1.PersistentVolumeClaimStatus{
+								ClaimName: "pvc-1",
+								Conditions: []metav1.Condition{{
+									Type:   cnsnodevmbatchattachmentv1alpha1.ConditionAttached,
+									Status: metav1.ConditionTrue,
+								}},
+							},
+						},
+						{
+							Name: "pvc-3",
+							PersistentVolumeClaim: cnsnodevmbatchattachmentv1alpha1.PersistentVolumeClaimStatus{
+								ClaimName: "pvc-3",
+								Attached:  true,
+							},
+						},
+					},
+				},
+			},
+		).
+		Build()
+
+	publishedNodes, err := getPublishedNodesForSupervisorVolumes(ctx, vmOperatorClient, cnsOperatorClient,
+		testNamespace)
+	if err != nil {
+		t.Fatalf("failed to get published nodes. Error: %v", err)
+	}
+	expected := map[string][]string{
+		"pvc-1": {"node-1", "node-2"},
+		"pvc-3": {"node-2"},
+	}
+	if !reflect.DeepEqual(expected, publishedNodes) {
+		t.Errorf("expected published nodes %v, got %v", expected, publishedNodes)
+	}
+}
+
+func TestGetGuestClusterVolumeIDs(t *testing.T) {
+	newPV := func(name, driver, volumeHandle string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{Driver: driver, VolumeHandle: volumeHandle},
+				},
+			},
+		}
+	}
+	guestClient := testclient.NewClientset(
+		newPV("pv-b", "csi.vsphere.vmware.com", "pvc-b"),
+		newPV("pv-a", "csi.vsphere.vmware.com", "pvc-a"),
+		newPV("pv-c", "other.csi.driver", "pvc-c"),
+	)
+	volumeIDs, err := getGuestClusterVolumeIDs(ctx, guestClient)
+	if err != nil {
+		t.Fatalf("failed to get guest cluster volume IDs. Error: %v", err)
+	}
+	if !reflect.DeepEqual([]string{"pvc-a", "pvc-b"}, volumeIDs) {
+		t.Errorf("unexpected volume IDs %v", volumeIDs)
+	}
+}
@@ -62,6 +62,22 @@ type CNSVolumeInfoSpec struct {
 
 	// IsLinkedClone reports if the volume is linked clone volume
 	IsLinkedClone bool `json:"isLinkedClone"`
+
+	// HealthHistory records the most recent health transitions of the volume, oldest first.
+	HealthHistory []VolumeHealthTransition `json:"healthHistory,omitempty"`
+}
+
+// VolumeHealthTransition records a change in the health status of a volume.
+type VolumeHealthTransition struct {
+	// Status is the health status of the volume after the transition,
+	// either "accessible" or "inaccessible".
+	Status string `json:"status"`
+
+	// Reason is the CNS health status or fault that caused the transition.
+	Reason string `json:"reason,omitempty"`
+
+	// TransitionTime is the time at which the syncer observed the transition.
+	TransitionTime metav1.Time `json:"transitionTime"`
 }
 
 //+kubebuilder:object:root=true
@@ -30,7 +30,7 @@ func (in *CNSVolumeInfo) DeepCopyInto(out *CNSVolumeInfo) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNSVolumeInfo.
@@ -86,6 +86,29 @@ func (in *CNSVolumeInfoList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CNSVolumeInfoSpec) DeepCopyInto(out *CNSVolumeInfoSpec) {
 	*out = *in
+	if in.Capacity != nil {
+		in, out := &in.Capacity, &out.Capacity
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.AggregatedSnapshotSize != nil {
+		in, out := &in.AggregatedSnapshotSize, &out.AggregatedSnapshotSize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.Zones != nil {
+		in, out := &in.Zones, &out.Zones
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.SnapshotLatestOperationCompleteTime.DeepCopyInto(&out.SnapshotLatestOperationCompleteTime)
+	if in.HealthHistory != nil {
+		in, out := &in.HealthHistory, &out.HealthHistory
+		*out = make([]VolumeHealthTransition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CNSVolumeInfoSpec.
@@ -97,3 +120,19 @@ func (in *CNSVolumeInfoSpec) DeepCopy() *CNSVolumeInfoSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeHealthTransition) DeepCopyInto(out *VolumeHealthTransition) {
+	*out = *in
+	in.TransitionTime.DeepCopyInto(&out.TransitionTime)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeHealthTransition.
+func (in *VolumeHealthTransition) DeepCopy() *VolumeHealthTransition {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeHealthTransition)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -65,6 +65,8 @@ var (
 	featureFileVolumesWithVmServiceEnabled    bool
 	featureIsSharedDiskEnabled                bool
 	featureIsLinkedCloneSupportEnabled        bool
+	featureGateStoragePolicyQuotaEnabled      bool
+	featureGateFileSharePermissionsEnabled    bool
 )
 
 // watchConfigChange watches on the webhook configuration directory for changes
@@ -243,8 +245,13 @@ func StartWebhookServer(ctx context.Context, enableWebhookClientCertVerification
 			common.TopologyAwareFileVolume)
 		featureFileVolumesWithVmServiceEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
 			common.FileVolumesWithVmService)
+		featureGateStoragePolicyQuotaEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.VanillaStoragePolicyQuota)
+		featureGateFileSharePermissionsEnabled = containerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.FileSharePermissions)
 
-		if featureGateCsiMigrationEnabled || featureGateBlockVolumeSnapshotEnabled {
+		if featureGateCsiMigrationEnabled || featureGateBlockVolumeSnapshotEnabled ||
+			featureGateStoragePolicyQuotaEnabled || featureGateFileSharePermissionsEnabled {
 			certs, err := tls.LoadX509KeyPair(cfg.WebHookConfig.CertFile, cfg.WebHookConfig.KeyFile)
 			if err != nil {
 				log.Errorf("failed to load key pair. certFile: %q, keyFile: %q err: %v",
@@ -194,6 +194,17 @@ func (m *MockCOCommonInterface) GetPVNameFromCSIVolumeID(volumeID string) (strin
 	return args.String(0), args.Bool(1)
 }
 
+func (m *MockCOCommonInterface) GetVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error) {
+	args := m.Called(ctx, volumeID)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
+func (m *MockCOCommonInterface) GetPersistentVolumeAnnotations(ctx context.Context,
+	pvName string) (map[string]string, error) {
+	args := m.Called(ctx, pvName)
+	return args.Get(0).(map[string]string), args.Error(1)
+}
+
 func (m *MockCOCommonInterface) GetPVCNameFromCSIVolumeID(volumeID string) (string, string, bool) {
 	args := m.Called(volumeID)
 	return args.String(0), args.String(1), args.Bool(2)
@@ -209,6 +220,27 @@ func (m *MockCOCommonInterface) InitializeCSINodes(ctx context.Context) error {
 	return args.Error(0)
 }
 
+func (m *MockCOCommonInterface) UpdateCSINodeAllocatableCount(ctx context.Context, nodeName string,
+	count int32) error {
+	args := m.Called(ctx, nodeName, count)
+	return args.Error(0)
+}
+
+func (m *MockCOCommonInterface) GetNodeFileVolumeCount(ctx context.Context, nodeName string) (int, error) {
+	args := m.Called(ctx, nodeName)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockCOCommonInterface) IsNodeOutOfService(ctx context.Context, nodeName string) (bool, error) {
+	args := m.Called(ctx, nodeName)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockCOCommonInterface) RecordNodeEvent(ctx context.Context, nodeName string, eventType string,
+	reason string, message string) {
+	m.Called(ctx, nodeName, eventType, reason, message)
+}
+
 func (m *MockCOCommonInterface) StartZonesInformer(ctx context.Context,
 	restClientConfig *rest.Config, namespace string) error {
 	args := m.Called(ctx, restClientConfig, namespace)
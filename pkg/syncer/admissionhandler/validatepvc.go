@@ -6,10 +6,14 @@ import (
 	"fmt"
 	"slices"
 	"strings"
+	"sync"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco/k8sorchestrator"
 
 	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 
 	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
@@ -22,14 +26,21 @@ import (
 )
 
 const (
-	ExpandVolumeWithSnapshotErrorMessage   = "Expanding volume with snapshots is not allowed"
 	ExpandLinkedCloneVolumeErrorMessage    = "Expanding linked clone volume is not allowed"
 	UpdateLinkedCloneVolumeAnnErrorMessage = "Cannot update linked clone volume annotations after creation"
 	DeleteVolumeWithSnapshotErrorMessage   = "Deleting volume with snapshots is not allowed"
+	StoragePolicyQuotaExceededErrorMessage = "Storage policy quota exceeded"
 )
 
 // validatePVC helps validate AdmissionReview requests for PersistentVolumeClaim.
 func validatePVC(ctx context.Context, req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	if featureGateStoragePolicyQuotaEnabled {
+		quotaResponse := validatePVCStoragePolicyQuota(ctx, req)
+		if !quotaResponse.Allowed {
+			return quotaResponse
+		}
+	}
+
 	if !featureGateBlockVolumeSnapshotEnabled {
 		// If CSI block volume snapshot is disabled and webhook is running,
 		// skip validation for PersistentVolumeClaim.
@@ -118,26 +129,26 @@ func validatePVC(ctx context.Context, req *admissionv1.AdmissionRequest) *admiss
 				}
 			}
 
-			snapshots, err := getSnapshotsForPVC(ctx, oldPVC.Namespace, oldPVC.Name)
-			if err != nil {
-				log.Warnf("error getting snapshots for pvc: %v. skipping validation.", err)
-				return &admissionv1.AdmissionResponse{
-					// skip validation if there is any error in getting volume snapshots associated with the pvc
-					Allowed: true,
-				}
-			}
-			if len(snapshots) != 0 {
-				allowed = false
-				if req.Operation == admissionv1.Update {
-					result = &metav1.Status{
-						Reason: ExpandVolumeWithSnapshotErrorMessage,
+			// Expansion of volumes with snapshots is not rejected here. Whether it is
+			// supported depends on the vCenter version, which the webhook cannot query,
+			// so the controller decides in ValidateVolumeSnapshotsForExpansion.
+			if req.Operation == admissionv1.Delete {
+				snapshots, err := getSnapshotsForPVC(ctx, oldPVC.Namespace, oldPVC.Name)
+				if err != nil {
+					log.Warnf("error getting snapshots for pvc: %v. skipping validation.", err)
+					return &admissionv1.AdmissionResponse{
+						// skip validation if there is any error in getting volume snapshots associated with the pvc
+						Allowed: true,
 					}
-				} else if req.Operation == admissionv1.Delete {
+				}
+				if len(snapshots) != 0 {
+					allowed = false
 					result = &metav1.Status{
 						Reason: DeleteVolumeWithSnapshotErrorMessage,
 					}
 				}
-			} else {
+			}
+			if allowed {
 				// Determine the state of the linked clone annotation on the old PVC
 				oldPVCHasLinkedCloneAnn := metav1.HasAnnotation(oldPVC.ObjectMeta, common.AttributeIsLinkedClone)
 				oldPVCLinkedCloneIsFalse := oldPVCHasLinkedCloneAnn && oldPVC.Annotations[common.AnnKeyLinkedClone] == "false"
@@ -203,6 +214,121 @@ func validatePVC(ctx context.Context, req *admissionv1.AdmissionRequest) *admiss
 	}
 }
 
+// validatePVCStoragePolicyQuota rejects the creation or expansion of a PVC if
+// the requested capacity exceeds the StoragePolicyQuota set in the PVC
+// namespace for the storage policy of its StorageClass.
+func validatePVCStoragePolicyQuota(ctx context.Context,
+	req *admissionv1.AdmissionRequest) *admissionv1.AdmissionResponse {
+	log := logger.GetLogger(ctx)
+	if req.Kind.Kind != "PersistentVolumeClaim" ||
+		(req.Operation != admissionv1.Create && req.Operation != admissionv1.Update) {
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+
+	newPVC := corev1.PersistentVolumeClaim{}
+	if err := json.Unmarshal(req.Object.Raw, &newPVC); err != nil {
+		log.Errorf("error deserializing pvc: %v. skipping storage policy quota validation.", err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+	requested := newPVC.Spec.Resources.Requests[corev1.ResourceStorage]
+	if req.Operation == admissionv1.Update {
+		oldPVC := corev1.PersistentVolumeClaim{}
+		if err := json.Unmarshal(req.OldObject.Raw, &oldPVC); err != nil {
+			log.Errorf("error deserializing old pvc: %v. skipping storage policy quota validation.", err)
+			return &admissionv1.AdmissionResponse{
+				Allowed: true,
+			}
+		}
+		// Only the additional capacity of an expansion is checked against the quota.
+		requested.Sub(oldPVC.Spec.Resources.Requests[corev1.ResourceStorage])
+	}
+	if requested.Sign() <= 0 || newPVC.Spec.StorageClassName == nil || *newPVC.Spec.StorageClassName == "" {
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+
+	cnsOperatorClient, err := getCnsOperatorClient(ctx)
+	if err != nil {
+		log.Warnf("error getting CnsOperator client: %v. skipping storage policy quota validation.", err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+	exceeded, message, err := isStoragePolicyQuotaExceeded(ctx, cnsOperatorClient, newPVC.Namespace,
+		*newPVC.Spec.StorageClassName, requested)
+	if err != nil {
+		log.Warnf("error checking storage policy quota for pvc %s/%s: %v. skipping validation.",
+			newPVC.Namespace, newPVC.Name, err)
+		return &admissionv1.AdmissionResponse{
+			Allowed: true,
+		}
+	}
+	if exceeded {
+		log.Infof("Rejecting pvc %s/%s: %s", newPVC.Namespace, newPVC.Name, message)
+		return &admissionv1.AdmissionResponse{
+			Allowed: false,
+			Result: &metav1.Status{
+				Reason:  StoragePolicyQuotaExceededErrorMessage,
+				Message: message,
+			},
+		}
+	}
+	return &admissionv1.AdmissionResponse{
+		Allowed: true,
+	}
+}
+
+var (
+	// cnsOperatorClient is the CnsOperator client shared by the admission
+	// requests, created on first use.
+	cnsOperatorClient     client.Client
+	cnsOperatorClientLock sync.Mutex
+)
+
+// getCnsOperatorClient returns a client for the CnsOperator API group.
+var getCnsOperatorClient = func(ctx context.Context) (client.Client, error) {
+	cnsOperatorClientLock.Lock()
+	defer cnsOperatorClientLock.Unlock()
+	if cnsOperatorClient != nil {
+		return cnsOperatorClient, nil
+	}
+	config, err := k8s.GetKubeConfig(ctx)
+	if err != nil {
+		return nil, err
+	}
+	operatorClient, err := k8s.NewClientForGroup(ctx, config, cnsoperatorv1alpha1.GroupName)
+	if err != nil {
+		return nil, err
+	}
+	cnsOperatorClient = operatorClient
+	return cnsOperatorClient, nil
+}
+
+// isStoragePolicyQuotaExceeded returns true, along with a message describing
+// the quota, if requesting the given capacity of the StorageClass in the
+// namespace exceeds the StoragePolicyQuota of its storage policy.
+func isStoragePolicyQuotaExceeded(ctx context.Context, cnsOperatorClient client.Client, namespace string,
+	storageClassName string, requested resource.Quantity) (bool, string, error) {
+	storagePolicyID, limit, consumed, err := utils.GetStoragePolicyQuotaUsage(ctx, cnsOperatorClient, namespace,
+		storageClassName)
+	if err != nil || limit == nil {
+		return false, "", err
+	}
+	total := requested.DeepCopy()
+	total.Add(*consumed)
+	if total.Cmp(*limit) > 0 {
+		return true, fmt.Sprintf("requesting %s of StorageClass %q in namespace %q exceeds the quota of %s "+
+			"for storage policy %q", requested.String(), storageClassName, namespace, limit.String(),
+			storagePolicyID), nil
+	}
+	return false, "", nil
+}
+
 func getPVReclaimPolicyForPVC(ctx context.Context, pvc corev1.PersistentVolumeClaim) (
 	corev1.PersistentVolumeReclaimPolicy, error) {
 	log := logger.GetLogger(ctx)
@@ -20,6 +20,10 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	clientset "k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	ctrlclientfake "sigs.k8s.io/controller-runtime/pkg/client/fake"
+	cnsoperatorv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	storagepolicyv1alpha2 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/storagepolicy/v1alpha2"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
 )
@@ -110,6 +114,12 @@ func getPVCAdmissionTest(t *testing.T) *pvcAdmissionTest {
 func TestValidatePVC(t *testing.T) {
 	testInstance := getPVCAdmissionTest(t)
 	featureGateBlockVolumeSnapshotEnabled = true
+	linkedCloneNewPVC := newPVC.DeepCopy()
+	linkedCloneNewPVC.Annotations = map[string]string{common.AnnKeyLinkedClone: "true"}
+	linkedCloneNewPVCRaw, err := json.Marshal(linkedCloneNewPVC)
+	if err != nil {
+		t.Fatalf("Failed to marshall the new PVC, %v: %v", linkedCloneNewPVC, err)
+	}
 	tests := []struct {
 		name             string
 		kubeObjs         []runtime.Object
@@ -201,7 +211,7 @@ func TestValidatePVC(t *testing.T) {
 			},
 		},
 		{
-			name: "TestExpandPVCwithSnapshotShouldFail",
+			name: "TestExpandPVCwithSnapshotShouldPass",
 			snapshotObjs: []runtime.Object{
 				&snapshotv1.VolumeSnapshot{
 					ObjectMeta: metav1.ObjectMeta{
@@ -230,10 +240,45 @@ func TestValidatePVC(t *testing.T) {
 					},
 				},
 			},
+			// The controller decides whether the vCenter supports the expansion.
+			expectedResponse: &admissionv1.AdmissionResponse{
+				Allowed: true,
+			},
+		},
+		{
+			name: "TestExpandPVCwithSnapshotAddingLinkedCloneAnnShouldFail",
+			snapshotObjs: []runtime.Object{
+				&snapshotv1.VolumeSnapshot{
+					ObjectMeta: metav1.ObjectMeta{
+						Namespace: testNamespace,
+						Name:      testVolumeSnapshotName,
+					},
+					Spec: snapshotv1.VolumeSnapshotSpec{
+						Source: snapshotv1.VolumeSnapshotSource{
+							PersistentVolumeClaimName: &testFirstPVCName,
+						},
+						VolumeSnapshotClassName: &testVolumeSnapshotClassName,
+					},
+				},
+			},
+			admissionReview: &admissionv1.AdmissionReview{
+				Request: &admissionv1.AdmissionRequest{
+					Kind: metav1.GroupVersionKind{
+						Kind: "PersistentVolumeClaim",
+					},
+					Operation: admissionv1.Update,
+					OldObject: runtime.RawExtension{
+						Raw: testInstance.oldPVCRaw,
+					},
+					Object: runtime.RawExtension{
+						Raw: linkedCloneNewPVCRaw,
+					},
+				},
+			},
 			expectedResponse: &admissionv1.AdmissionResponse{
 				Allowed: false,
 				Result: &metav1.Status{
-					Reason: ExpandVolumeWithSnapshotErrorMessage,
+					Reason: UpdateLinkedCloneVolumeAnnErrorMessage,
 				},
 			},
 		},
@@ -1146,3 +1191,177 @@ func TestValidateGuestPVCOperation_LinkedClone_StorageClass(t *testing.T) {
 		})
 	}
 }
+
+func newTestStoragePolicyQuotaClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	if err := cnsoperatorv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatalf("Failed to add CnsOperator types to scheme: %v", err)
+	}
+	limit := resource.MustParse("20Gi")
+	newUsage := func(name, storageClassName, resourceKind, used string) *storagepolicyv1alpha2.StoragePolicyUsage {
+		usedQuantity := resource.MustParse(used)
+		return &storagepolicyv1alpha2.StoragePolicyUsage{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: testNamespace},
+			Spec: storagepolicyv1alpha2.StoragePolicyUsageSpec{
+				StoragePolicyId:  "policy-1",
+				StorageClassName: storageClassName,
+				ResourceKind:     resourceKind,
+			},
+			Status: storagepolicyv1alpha2.StoragePolicyUsageStatus{
+				ResourceTypeLevelQuotaUsage: &storagepolicyv1alpha2.QuotaUsageDetails{
+					Used: &usedQuantity,
+				},
+			},
+		}
+	}
+	return ctrlclientfake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			&storagepolicyv1alpha2.StoragePolicyQuota{
+				ObjectMeta: metav1.ObjectMeta{Name: "policy-1-quota", Namespace: testNamespace},
+				Spec: storagepolicyv1alpha2.StoragePolicyQuotaSpec{
+					StoragePolicyId: "policy-1",
+					Limit:           &limit,
+				},
+			},
+			newUsage(testStorageClassName+"-pvc-usage", testStorageClassName, "PersistentVolumeClaim", "8Gi"),
+			newUsage(testStorageClassName+"-snapshot-usage", testStorageClassName, "VolumeSnapshot", "2Gi"),
+			newUsage("other-sc-pvc-usage", "other-sc", "PersistentVolumeClaim", "4Gi"),
+		).
+		Build()
+}
+
+func TestIsStoragePolicyQuotaExceeded(t *testing.T) {
+	ctx := context.Background()
+	cnsOperatorClient := newTestStoragePolicyQuotaClient(t)
+	tests := []struct {
+		name             string
+		storageClassName string
+		requested        string
+		expectedExceeded bool
+	}{
+		{
+			name:             "RequestWithinQuota",
+			storageClassName: testStorageClassName,
+			requested:        "6Gi",
+			expectedExceeded: false,
+		},
+		{
+			// 8Gi + 2Gi + 4Gi is used across both StorageClasses of the policy.
+			name:             "RequestExceedingQuota",
+			storageClassName: testStorageClassName,
+			requested:        "7Gi",
+			expectedExceeded: true,
+		},
+		{
+			name:             "StorageClassWithoutQuota",
+			storageClassName: "sc-without-quota",
+			requested:        "100Gi",
+			expectedExceeded: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			exceeded, message, err := isStoragePolicyQuotaExceeded(ctx, cnsOperatorClient, testNamespace,
+				test.storageClassName, resource.MustParse(test.requested))
+			assert.NoError(t, err)
+			assert.Equal(t, test.expectedExceeded, exceeded)
+			assert.Equal(t, test.expectedExceeded, message != "")
+		})
+	}
+}
+
+func TestValidatePVCStoragePolicyQuota(t *testing.T) {
+	ctx := context.Background()
+	cnsOperatorClient := newTestStoragePolicyQuotaClient(t)
+	origGetCnsOperatorClient := getCnsOperatorClient
+	getCnsOperatorClient = func(ctx context.Context) (client.Client, error) {
+		return cnsOperatorClient, nil
+	}
+	featureGateStoragePolicyQuotaEnabled = true
+	defer func() {
+		getCnsOperatorClient = origGetCnsOperatorClient
+		featureGateStoragePolicyQuotaEnabled = false
+	}()
+
+	newPVCWithSize := func(size string) []byte {
+		pvc := newPVC.DeepCopy()
+		pvc.Spec.Resources.Requests[corev1.ResourceStorage] = resource.MustParse(size)
+		raw, err := json.Marshal(pvc)
+		if err != nil {
+			t.Fatalf("Failed to marshall the PVC, %v: %v", pvc, err)
+		}
+		return raw
+	}
+	tests := []struct {
+		name            string
+		operation       admissionv1.Operation
+		oldObject       []byte
+		object          []byte
+		expectedAllowed bool
+	}{
+		{
+			name:            "CreatePVCWithinQuotaShouldPass",
+			operation:       admissionv1.Create,
+			object:          newPVCWithSize("6Gi"),
+			expectedAllowed: true,
+		},
+		{
+			name:            "CreatePVCExceedingQuotaShouldFail",
+			operation:       admissionv1.Create,
+			object:          newPVCWithSize("7Gi"),
+			expectedAllowed: false,
+		},
+		{
+			// Expanding from 5Gi to 11Gi requests an additional 6Gi.
+			name:            "ExpandPVCWithinQuotaShouldPass",
+			operation:       admissionv1.Update,
+			oldObject:       getPVCAdmissionTest(t).oldPVCRaw,
+			object:          newPVCWithSize("11Gi"),
+			expectedAllowed: true,
+		},
+		{
+			name:            "ExpandPVCExceedingQuotaShouldFail",
+			operation:       admissionv1.Update,
+			oldObject:       getPVCAdmissionTest(t).oldPVCRaw,
+			object:          newPVCWithSize("12Gi"),
+			expectedAllowed: false,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response := validatePVCStoragePolicyQuota(ctx, &admissionv1.AdmissionRequest{
+				Kind: metav1.GroupVersionKind{
+					Kind: "PersistentVolumeClaim",
+				},
+				Operation: test.operation,
+				OldObject: runtime.RawExtension{
+					Raw: test.oldObject,
+				},
+				Object: runtime.RawExtension{
+					Raw: test.object,
+				},
+			})
+			assert.Equal(t, test.expectedAllowed, response.Allowed)
+			if !test.expectedAllowed {
+				assert.Equal(t, metav1.StatusReason(StoragePolicyQuotaExceededErrorMessage), response.Result.Reason)
+			}
+		})
+	}
+}
+
+func TestGetCnsOperatorClientReusesClient(t *testing.T) {
+	ctx := context.Background()
+	cachedClient := newTestStoragePolicyQuotaClient(t)
+	cnsOperatorClientLock.Lock()
+	cnsOperatorClient = cachedClient
+	cnsOperatorClientLock.Unlock()
+	defer func() {
+		cnsOperatorClientLock.Lock()
+		cnsOperatorClient = nil
+		cnsOperatorClientLock.Unlock()
+	}()
+	operatorClient, err := getCnsOperatorClient(ctx)
+	assert.NoError(t, err)
+	assert.Same(t, cachedClient, operatorClient)
+}
@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 
-	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 
 	admissionv1 "k8s.io/api/admission/v1"
@@ -157,25 +156,18 @@ func checkIfLinkedClonesExist(ctx context.Context, vs snap.VolumeSnapshot) admis
 	if err != nil {
 		return admission.Denied("failed to get k8s client. Error: " + err.Error())
 	}
-	vsUID := string(vs.UID)
-	// List all the PV with the VolumeSnapshot label.
-	// If the label is present on the PV it indicates that a LinkedClone was created from this
-	// specific volumesnapshot.
-	labelSelector := labels.SelectorFromSet(map[string]string{
-		common.VolumeContextAttributeLinkedCloneVolumeSnapshotSourceUID: vsUID,
-	})
-	pvList, err := k8sClient.CoreV1().PersistentVolumes().List(ctx, metav1.ListOptions{
-		LabelSelector: labelSelector.String(),
-	})
+	// If the VolumeSnapshot UID label is present on a PV it indicates that a LinkedClone
+	// was created from this specific volumesnapshot.
+	linkedClonesCount, err := common.GetLinkedClonesCountForSnapshot(ctx, k8sClient, string(vs.UID))
 	if err != nil {
 		errMsg := fmt.Sprintf("error when checking if there are linkedclones created from volume snapshot, "+
 			"failed to list PVs with error: %v", err)
 		return admission.Denied(errMsg)
 	}
 
-	if len(pvList.Items) != 0 {
+	if linkedClonesCount != 0 {
 		errMsg := fmt.Sprintf("deleting volumesnapshot from which linked clones are created is not allowed. "+
-			"There are %d linked clones created from this volumesnapshot", len(pvList.Items))
+			"There are %d linked clones created from this volumesnapshot", linkedClonesCount)
 		return admission.Denied(errMsg)
 	}
 	return admission.Allowed("")
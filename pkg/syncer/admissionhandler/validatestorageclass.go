@@ -45,13 +45,15 @@ var (
 const (
 	migrationParamErrorMessage = "Invalid StorageClass Parameters. " +
 		"Migration specific parameters should not be used in the StorageClass"
+	fileShareParamErrorMessage = "Invalid StorageClass Parameters. " +
+		"File share permission or quota parameters are invalid"
 )
 
 // validateStorageClass helps validate AdmissionReview requests for StroageClass.
 func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview) *admissionv1.AdmissionResponse {
-	if !featureGateCsiMigrationEnabled {
-		// If CSI migration is disabled and webhook is running,
-		// skip validation for StorageClass.
+	if !featureGateCsiMigrationEnabled && !featureGateFileSharePermissionsEnabled {
+		// If CSI migration and file share permissions are disabled and webhook
+		// is running, skip validation for StorageClass.
 		return &admissionv1.AdmissionResponse{
 			Allowed: true,
 		}
@@ -74,7 +76,7 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 			}
 		}
 		log.Infof("Validating StorageClass: %q", sc.Name)
-		if sc.Provisioner == "csi.vsphere.vmware.com" {
+		if sc.Provisioner == "csi.vsphere.vmware.com" && featureGateCsiMigrationEnabled {
 			// Migration parameters check for csi.vsphere.vmware.com provisioner.
 			for param := range sc.Parameters {
 				if unSupportedParameters.Has(param) {
@@ -86,6 +88,16 @@ func validateStorageClass(ctx context.Context, ar *admissionv1.AdmissionReview)
 				}
 			}
 		}
+		if sc.Provisioner == "csi.vsphere.vmware.com" && featureGateFileSharePermissionsEnabled && allowed {
+			// File share net permission and soft quota parameters check.
+			if err := common.ValidateFileShareParams(sc.Parameters); err != nil {
+				allowed = false
+				result = &metav1.Status{
+					Reason:  fileShareParamErrorMessage,
+					Message: err.Error(),
+				}
+			}
+		}
 		if allowed {
 			log.Infof("Validation of StorageClass: %q Passed", sc.Name)
 		} else {
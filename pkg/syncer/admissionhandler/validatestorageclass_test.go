@@ -119,3 +119,47 @@ func TestValidateStorageClassForValidStorageClass(t *testing.T) {
 	}
 	t.Log("TestValidateStorageClassForValidStorageClass Passed")
 }
+
+// TestValidateStorageClassForFileShareParameters is the unit test for
+// validating admissionReview request containing StorageClass with file share
+// permission and quota parameters.
+func TestValidateStorageClassForFileShareParameters(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	featureGateCsiMigrationEnabled = false
+	featureGateFileSharePermissionsEnabled = true
+	defer func() {
+		featureGateFileSharePermissionsEnabled = false
+	}()
+	scWithParams := func(params string) runtime.RawExtension {
+		return runtime.RawExtension{
+			Raw: []byte("{\n  \"kind\": \"StorageClass\",\n  \"apiVersion\": \"storage.k8s.io/v1\",\n  \"metadata\": " +
+				"{\n    \"name\": \"sc\",\n    \"uid\": \"1c2f3f9e-4f1d-4b0c-9f65-a3e1e2c5d7b8\",\n    " +
+				"\"creationTimestamp\": \"2025-01-27T20:57:00Z\"\n  },\n  " +
+				"\"provisioner\": \"csi.vsphere.vmware.com\",\n  " +
+				"\"parameters\": {" + params + "},\n  " +
+				"\"reclaimPolicy\": \"Delete\",\n  \"volumeBindingMode\": \"Immediate\"\n}"),
+		}
+	}
+	admissionReview.Request.Object = scWithParams("\"netpermissionips\": \"10.20.0.0/16\", " +
+		"\"netpermission\": \"READ_ONLY\", \"rootsquash\": \"true\", \"softquotapercent\": \"80\"")
+	admissionResponse := validateStorageClass(ctx, &admissionReview)
+	if admissionResponse.Result != nil || !admissionResponse.Allowed {
+		t.Fatalf("expected StorageClass with valid file share parameters to be allowed. "+
+			"admissionResponse: %v", admissionResponse)
+	}
+	for _, params := range []string{
+		"\"netpermission\": \"FULL_ACCESS\"",
+		"\"rootsquash\": \"maybe\"",
+		"\"softquotapercent\": \"120\"",
+		"\"netpermissionips\": \"10.20.0.0/16,\"",
+	} {
+		admissionReview.Request.Object = scWithParams(params)
+		admissionResponse = validateStorageClass(ctx, &admissionReview)
+		if admissionResponse.Allowed || admissionResponse.Result == nil ||
+			!strings.Contains(string(admissionResponse.Result.Reason), fileShareParamErrorMessage) {
+			t.Fatalf("expected StorageClass with parameters %s to be denied. admissionResponse: %v",
+				params, admissionResponse)
+		}
+	}
+}
@@ -197,6 +197,11 @@ func (m *mockVolumeManager) BatchAttachVolumes(ctx context.Context,
 	return []cnsvolume.BatchAttachResult{}, "", nil
 }
 
+func (m *mockVolumeManager) ExecuteBulkOperation(ctx context.Context,
+	request *cnsvolume.BulkOperationRequest) ([]cnsvolume.BulkOperationResult, error) {
+	return []cnsvolume.BulkOperationResult{}, nil
+}
+
 func (m *mockVolumeManager) QueryVolumeAsync(ctx context.Context, queryFilter cnstypes.CnsQueryFilter,
 	querySelection *cnstypes.CnsQuerySelection) (*cnstypes.CnsQueryResult, error) {
 	return &cnstypes.CnsQueryResult{
@@ -359,6 +364,14 @@ func (m *mockCOCommon) GetPVNameFromCSIVolumeID(volumeID string) (string, bool)
 	return "", false
 }
 
+func (m *mockCOCommon) GetVolumeAttributes(ctx context.Context, volumeID string) (map[string]string, error) {
+	return nil, nil
+}
+
+func (m *mockCOCommon) GetPersistentVolumeAnnotations(ctx context.Context, pvName string) (map[string]string, error) {
+	return nil, nil
+}
+
 func (m *mockCOCommon) GetPVCNameFromCSIVolumeID(volumeID string) (string, string, bool) {
 	//TODO implement me
 	panic("implement me")
@@ -369,6 +382,27 @@ func (m *mockCOCommon) InitializeCSINodes(ctx context.Context) error {
 	panic("implement me")
 }
 
+func (m *mockCOCommon) UpdateCSINodeAllocatableCount(ctx context.Context, nodeName string, count int32) error {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockCOCommon) GetNodeFileVolumeCount(ctx context.Context, nodeName string) (int, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockCOCommon) IsNodeOutOfService(ctx context.Context, nodeName string) (bool, error) {
+	//TODO implement me
+	panic("implement me")
+}
+
+func (m *mockCOCommon) RecordNodeEvent(ctx context.Context, nodeName string, eventType string,
+	reason string, message string) {
+	//TODO implement me
+	panic("implement me")
+}
+
 func (m *mockCOCommon) StartZonesInformer(ctx context.Context,
 	restClientConfig *restclient.Config, namespace string) error {
 	//TODO implement me
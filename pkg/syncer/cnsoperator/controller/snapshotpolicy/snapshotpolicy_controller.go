@@ -0,0 +1,626 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshotpolicy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	snapshotterClientSet "github.com/kubernetes-csi/external-snapshotter/client/v8/clientset/versioned"
+	"github.com/robfig/cron/v3"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	snapshotpolicyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/snapshotpolicy/v1alpha1"
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/cnsoperator/util"
+)
+
+const (
+	workerThreadsEnvVar     = "WORKER_THREADS_SNAPSHOT_POLICY"
+	defaultMaxWorkerThreads = 4
+	// snapshotPolicyLabel is set on every VolumeSnapshot created by a SnapshotPolicy
+	// with the name of the policy as value.
+	snapshotPolicyLabel = "cns.vmware.com/snapshot-policy"
+	// maxBackOffDuration is the maximum time after which a failed reconcile of a
+	// SnapshotPolicy instance is retried.
+	maxBackOffDuration = 5 * time.Minute
+)
+
+var (
+	// backOffDuration is a map of snapshotpolicy name's to the time after which
+	// a request for this instance will be requeued.
+	// Initialized to 1 second for new instances and for instances whose latest
+	// reconcile operation succeeded.
+	// If the reconcile fails, backoff is incremented exponentially.
+	backOffDuration         map[apitypes.NamespacedName]time.Duration
+	backOffDurationMapMutex = sync.Mutex{}
+)
+
+// Add creates a new SnapshotPolicy Controller and adds it to the Manager,
+// ConfigurationInfo and VirtualCenterTypes. The Manager will set fields on the
+// Controller and start it when the Manager is Started.
+func Add(mgr manager.Manager, clusterFlavor cnstypes.CnsClusterFlavor,
+	configInfo *config.ConfigurationInfo, volumeManager volumes.Manager) error {
+	ctx, log := logger.GetNewContextWithLogger()
+	if clusterFlavor != cnstypes.CnsClusterFlavorWorkload && clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		log.Info("Not initializing the SnapshotPolicy Controller as guest cluster is detected.")
+		return nil
+	}
+	coCommonInterface, err := commonco.GetContainerOrchestratorInterface(ctx,
+		common.Kubernetes, clusterFlavor, &syncer.COInitParams)
+	if err != nil {
+		log.Errorf("failed to create CO agnostic interface. Err: %v", err)
+		return err
+	}
+	if !coCommonInterface.IsFSSEnabled(ctx, common.SnapshotPolicy) {
+		log.Info("Not initializing the SnapshotPolicy Controller as this feature is disabled on the cluster")
+		return nil
+	}
+
+	// Initializes kubernetes client.
+	k8sclient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Kubernetes client failed. Err: %v", err)
+		return err
+	}
+	snapshotterClient, err := k8s.NewSnapshotterClient(ctx)
+	if err != nil {
+		log.Errorf("Creating Snapshotter client failed. Err: %v", err)
+		return err
+	}
+
+	// eventBroadcaster broadcasts events on snapshotpolicy instances to the
+	// event sink.
+	eventBroadcaster := record.NewBroadcaster()
+	eventBroadcaster.StartRecordingToSink(
+		&typedcorev1.EventSinkImpl{
+			Interface: k8sclient.CoreV1().Events(""),
+		},
+	)
+	recorder := eventBroadcaster.NewRecorder(scheme.Scheme, v1.EventSource{Component: apis.GroupName})
+	return add(mgr, &ReconcileSnapshotPolicy{
+		client:            mgr.GetClient(),
+		scheme:            mgr.GetScheme(),
+		clusterFlavor:     clusterFlavor,
+		configInfo:        configInfo,
+		volumeManager:     volumeManager,
+		recorder:          recorder,
+		k8sClient:         k8sclient,
+		snapshotterClient: snapshotterClient,
+	})
+}
+
+// add adds a new Controller to mgr with r as the reconcile.Reconciler.
+func add(mgr manager.Manager, r reconcile.Reconciler) error {
+	ctx, log := logger.GetNewContextWithLogger()
+
+	maxWorkerThreads := util.GetMaxWorkerThreads(ctx,
+		workerThreadsEnvVar, defaultMaxWorkerThreads)
+	// Create a new controller.
+	c, err := controller.New("snapshotpolicy-controller", mgr,
+		controller.Options{Reconciler: r, MaxConcurrentReconciles: maxWorkerThreads})
+	if err != nil {
+		log.Errorf("Failed to create new SnapshotPolicy controller with error: %+v", err)
+		return err
+	}
+
+	backOffDuration = make(map[apitypes.NamespacedName]time.Duration)
+
+	// Watch for changes to the spec of primary resource SnapshotPolicy. Status
+	// updates made by the controller itself are ignored, the next scheduled run
+	// is driven by the RequeueAfter returned from Reconcile.
+	err = c.Watch(source.Kind(mgr.GetCache(),
+		&snapshotpolicyv1alpha1.SnapshotPolicy{},
+		&handler.TypedEnqueueRequestForObject[*snapshotpolicyv1alpha1.SnapshotPolicy]{},
+		predicate.TypedGenerationChangedPredicate[*snapshotpolicyv1alpha1.SnapshotPolicy]{}))
+	if err != nil {
+		log.Errorf("Failed to watch for changes to SnapshotPolicy resource with error: %+v", err)
+		return err
+	}
+	return nil
+}
+
+// blank assignment to verify that ReconcileSnapshotPolicy implements
+// reconcile.Reconciler.
+var _ reconcile.Reconciler = &ReconcileSnapshotPolicy{}
+
+// ReconcileSnapshotPolicy reconciles a SnapshotPolicy object.
+type ReconcileSnapshotPolicy struct {
+	// This client, initialized using mgr.Client() above, is a split client
+	// that reads objects from the cache and writes to the apiserver.
+	client            client.Client
+	scheme            *runtime.Scheme
+	clusterFlavor     cnstypes.CnsClusterFlavor
+	configInfo        *config.ConfigurationInfo
+	volumeManager     volumes.Manager
+	recorder          record.EventRecorder
+	k8sClient         clientset.Interface
+	snapshotterClient snapshotterClientSet.Interface
+}
+
+// Reconcile reads that state of the cluster for a SnapshotPolicy object and
+// creates VolumeSnapshots of the selected PVCs when the schedule is due and
+// prunes the VolumeSnapshots created by the policy as per its retention rules.
+// Note:
+// The Controller will requeue the Request to be processed again if the returned
+// error is non-nil or Result.Requeue is true. Otherwise, upon completion it
+// will remove the work from the queue.
+func (r *ReconcileSnapshotPolicy) Reconcile(ctx context.Context,
+	request reconcile.Request) (reconcile.Result, error) {
+	log := logger.GetLogger(ctx)
+	// Fetch the SnapshotPolicy instance.
+	instance := &snapshotpolicyv1alpha1.SnapshotPolicy{}
+	err := r.client.Get(ctx, request.NamespacedName, instance)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			log.Infof("SnapshotPolicy resource %q not found. Ignoring since object must be deleted.",
+				request.NamespacedName)
+			backOffDurationMapMutex.Lock()
+			delete(backOffDuration, request.NamespacedName)
+			backOffDurationMapMutex.Unlock()
+			return reconcile.Result{}, nil
+		}
+		log.Errorf("Error reading the SnapshotPolicy with name: %q. Err: %+v",
+			request.NamespacedName, err)
+		// Error reading the object - return with err.
+		return reconcile.Result{}, err
+	}
+	if instance.DeletionTimestamp != nil {
+		return reconcile.Result{}, nil
+	}
+	// Initialize backOffDuration for the instance, if required.
+	backOffDurationMapMutex.Lock()
+	if _, exists := backOffDuration[request.NamespacedName]; !exists {
+		backOffDuration[request.NamespacedName] = time.Second
+	}
+	timeout := backOffDuration[request.NamespacedName]
+	backOffDurationMapMutex.Unlock()
+
+	schedule, err := cron.ParseStandard(instance.Spec.Schedule)
+	if err != nil {
+		// Retrying will not help until the spec is fixed, which triggers a new reconcile.
+		setInstanceError(ctx, r, instance,
+			fmt.Sprintf("invalid schedule %q. Err: %v", instance.Spec.Schedule, err))
+		return reconcile.Result{}, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(&instance.Spec.Selector)
+	if err != nil {
+		setInstanceError(ctx, r, instance, fmt.Sprintf("invalid selector. Err: %v", err))
+		return reconcile.Result{}, nil
+	}
+
+	now := time.Now()
+	lastScheduleTime := instance.CreationTimestamp.Time
+	if instance.Status.LastScheduleTime != nil {
+		lastScheduleTime = instance.Status.LastScheduleTime.Time
+	}
+	scheduledTime := schedule.Next(lastScheduleTime)
+	isDue := !scheduledTime.After(now)
+
+	pvcList, err := r.k8sClient.CoreV1().PersistentVolumeClaims(instance.Namespace).List(ctx,
+		metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		setInstanceError(ctx, r, instance, fmt.Sprintf("failed to list PVCs. Err: %v", err))
+		return reconcile.Result{RequeueAfter: incrementBackOff(request.NamespacedName, timeout)}, nil
+	}
+	snapshotsByPVC, err := r.listSnapshotsByPVC(ctx, instance.Namespace)
+	if err != nil {
+		setInstanceError(ctx, r, instance, fmt.Sprintf("failed to list VolumeSnapshots. Err: %v", err))
+		return reconcile.Result{RequeueAfter: incrementBackOff(request.NamespacedName, timeout)}, nil
+	}
+
+	var errMsgs []string
+	for _, pvc := range pvcList.Items {
+		if pvc.Status.Phase != v1.ClaimBound {
+			log.Debugf("Skipping PVC %s/%s as it is not bound", pvc.Namespace, pvc.Name)
+			continue
+		}
+		err := r.reconcilePVC(ctx, instance, &pvc, snapshotsByPVC[pvc.Name], isDue, scheduledTime, now)
+		if err != nil {
+			log.Errorf("SnapshotPolicy %q failed to reconcile PVC %q. Err: %v",
+				request.NamespacedName, pvc.Name, err)
+			errMsgs = append(errMsgs, fmt.Sprintf("PVC %q: %v", pvc.Name, err))
+		}
+	}
+	// Snapshots whose source PVC no longer matches the selector are only pruned by age.
+	for pvcName, snapshots := range snapshotsByPVC {
+		if matchesPVC(pvcList.Items, pvcName) {
+			continue
+		}
+		policySnapshots, _ := splitPolicySnapshots(instance, snapshots)
+		_, err := r.pruneSnapshots(ctx, selectSnapshotsToPrune(policySnapshots, len(policySnapshots),
+			instance.Spec.Retention.MaxAge, now))
+		if err != nil {
+			errMsgs = append(errMsgs, fmt.Sprintf("PVC %q: %v", pvcName, err))
+		}
+	}
+
+	if len(errMsgs) != 0 {
+		// LastScheduleTime is not advanced, so the scheduled run is retried
+		// for the PVCs which failed. PVCs which already have a snapshot of
+		// this run are skipped.
+		setInstanceError(ctx, r, instance, strings.Join(errMsgs, "; "))
+		return reconcile.Result{RequeueAfter: incrementBackOff(request.NamespacedName, timeout)}, nil
+	}
+	if isDue || instance.Status.Error != "" {
+		if isDue {
+			instance.Status.LastScheduleTime = &metav1.Time{Time: now}
+		}
+		instance.Status.Error = ""
+		if err := updateSnapshotPolicyStatus(ctx, r.client, instance); err != nil {
+			return reconcile.Result{RequeueAfter: incrementBackOff(request.NamespacedName, timeout)}, nil
+		}
+		if isDue {
+			recordEvent(ctx, r, instance, v1.EventTypeNormal,
+				fmt.Sprintf("Created VolumeSnapshots of the PVCs selected by SnapshotPolicy %q", instance.Name))
+		}
+	}
+	backOffDurationMapMutex.Lock()
+	backOffDuration[request.NamespacedName] = time.Second
+	backOffDurationMapMutex.Unlock()
+	// Requeue for the next scheduled run.
+	requeueAfter := schedule.Next(now).Sub(now)
+	log.Infof("SnapshotPolicy %q reconciled successfully. Next run in %v", request.NamespacedName, requeueAfter)
+	return reconcile.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// reconcilePVC prunes the VolumeSnapshots created by the policy for the given PVC
+// and creates a new VolumeSnapshot when the schedule is due. All VolumeSnapshots
+// of the PVC count against the snapshot limit of the volume, but only those
+// created by the policy are pruned. The snapshots beyond the retention count are
+// pruned once the new snapshot is created, so a failed create does not cost a
+// backup. Only when the snapshot limit of the volume is reached is a snapshot
+// pruned before the new one is created. No snapshot is created if the policy
+// already created one for the PVC at or after scheduledTime, as happens when a
+// run which failed for other PVCs is retried.
+func (r *ReconcileSnapshotPolicy) reconcilePVC(ctx context.Context,
+	instance *snapshotpolicyv1alpha1.SnapshotPolicy, pvc *v1.PersistentVolumeClaim,
+	snapshots []snapv1.VolumeSnapshot, isDue bool, scheduledTime time.Time, now time.Time) error {
+	log := logger.GetLogger(ctx)
+	maxCount, err := r.getMaxSnapshotsForPVC(ctx, pvc)
+	if err != nil {
+		return err
+	}
+	if maxCount <= 0 {
+		return fmt.Errorf("snapshots are not allowed for the volume")
+	}
+	policySnapshots, otherCount := splitPolicySnapshots(instance, snapshots)
+	if isDue && hasSnapshotSince(policySnapshots, scheduledTime) {
+		log.Debugf("SnapshotPolicy %q already created a VolumeSnapshot of PVC %q for the run scheduled at %v",
+			instance.Name, pvc.Name, scheduledTime)
+		isDue = false
+	}
+	// limit is the number of policy snapshots the volume can hold.
+	limit := maxCount - otherCount
+	if limit <= 0 {
+		// The VolumeSnapshots not created by the policy already use up the
+		// snapshot limit of the volume, so the policy snapshots are only
+		// pruned by age.
+		_, err = r.pruneSnapshots(ctx, selectSnapshotsToPrune(policySnapshots, len(policySnapshots),
+			instance.Spec.Retention.MaxAge, now))
+		if err != nil {
+			return err
+		}
+		if !isDue {
+			return nil
+		}
+		return fmt.Errorf("the limit of %d snapshots of the volume is used up by %d VolumeSnapshots "+
+			"not created by the policy", maxCount, otherCount)
+	}
+	retainCount := limit
+	if instance.Spec.Retention.MaxCount > 0 && instance.Spec.Retention.MaxCount < retainCount {
+		retainCount = instance.Spec.Retention.MaxCount
+	}
+	pruned, err := r.pruneSnapshots(ctx, selectSnapshotsToPrune(policySnapshots, retainCount,
+		instance.Spec.Retention.MaxAge, now))
+	if err != nil {
+		return err
+	}
+	if !isDue {
+		return nil
+	}
+	remaining := excludeSnapshots(policySnapshots, pruned)
+	if len(remaining) >= limit {
+		// The new snapshot can not be created without exceeding the snapshot
+		// limit of the volume.
+		remaining, err = r.freeSnapshotSlots(ctx, remaining, len(remaining)-limit+1)
+		if err != nil {
+			return err
+		}
+	}
+	snapshotClassName := instance.Spec.VolumeSnapshotClassName
+	pvcName := pvc.Name
+	snapshot := &snapv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s-%s-", instance.Name, pvc.Name),
+			Namespace:    pvc.Namespace,
+			Labels: map[string]string{
+				snapshotPolicyLabel: instance.Name,
+			},
+		},
+		Spec: snapv1.VolumeSnapshotSpec{
+			Source: snapv1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &pvcName,
+			},
+			VolumeSnapshotClassName: &snapshotClassName,
+		},
+	}
+	snapshot, err = r.snapshotterClient.SnapshotV1().VolumeSnapshots(pvc.Namespace).Create(ctx,
+		snapshot, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create VolumeSnapshot. Err: %v", err)
+	}
+	log.Infof("SnapshotPolicy %q created VolumeSnapshot %s/%s of PVC %q",
+		instance.Name, snapshot.Namespace, snapshot.Name, pvc.Name)
+	// The new snapshot takes one of the retained slots.
+	_, err = r.pruneSnapshots(ctx, selectSnapshotsToPrune(remaining, retainCount-1, nil, now))
+	return err
+}
+
+// getMaxSnapshotsForPVC returns the maximum number of snapshots allowed for the
+// volume bound to the PVC. On supervisor clusters the limit is read from the
+// cns-csi-limits ConfigMap in the namespace, on vanilla clusters the global or
+// the granular vSAN/VVOL limit applies depending on the datastore of the volume.
+func (r *ReconcileSnapshotPolicy) getMaxSnapshotsForPVC(ctx context.Context,
+	pvc *v1.PersistentVolumeClaim) (int, error) {
+	if r.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
+		return common.GetSnapshotLimitForNamespace(ctx, r.k8sClient, pvc.Namespace)
+	}
+	pv, err := r.k8sClient.CoreV1().PersistentVolumes().Get(ctx, pvc.Spec.VolumeName, metav1.GetOptions{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to get PV %q. Err: %v", pvc.Spec.VolumeName, err)
+	}
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != common.VSphereCSIDriverName {
+		return 0, fmt.Errorf("PV %q is not provisioned by %s", pv.Name, common.VSphereCSIDriverName)
+	}
+	volumeID := pv.Spec.CSI.VolumeHandle
+	volumeDetailsMap, err := utils.QueryVolumeDetailsUtil(ctx, r.volumeManager,
+		[]cnstypes.CnsVolumeId{{Id: volumeID}})
+	if err != nil {
+		return 0, err
+	}
+	volumeDetails, ok := volumeDetailsMap[volumeID]
+	if !ok {
+		return 0, fmt.Errorf("cns query volume did not return the volume: %s", volumeID)
+	}
+	if volumeDetails.VolumeType != common.BlockVolumeType {
+		return 0, fmt.Errorf("volume %s of type %s does not support snapshots", volumeID, volumeDetails.VolumeType)
+	}
+	return common.GetMaxSnapshotsPerBlockVolume(ctx, r.configInfo.Cfg.Snapshot, volumeDetails.DatastoreUrl), nil
+}
+
+// listSnapshotsByPVC returns the VolumeSnapshots in the namespace grouped by
+// the name of their source PVC.
+func (r *ReconcileSnapshotPolicy) listSnapshotsByPVC(ctx context.Context,
+	namespace string) (map[string][]snapv1.VolumeSnapshot, error) {
+	snapshotList, err := r.snapshotterClient.SnapshotV1().VolumeSnapshots(namespace).List(ctx,
+		metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	snapshotsByPVC := make(map[string][]snapv1.VolumeSnapshot)
+	for _, snapshot := range snapshotList.Items {
+		if snapshot.DeletionTimestamp != nil || snapshot.Spec.Source.PersistentVolumeClaimName == nil {
+			continue
+		}
+		pvcName := *snapshot.Spec.Source.PersistentVolumeClaimName
+		snapshotsByPVC[pvcName] = append(snapshotsByPVC[pvcName], snapshot)
+	}
+	return snapshotsByPVC, nil
+}
+
+// splitPolicySnapshots returns the given VolumeSnapshots created by the policy
+// and the number of the other VolumeSnapshots.
+func splitPolicySnapshots(instance *snapshotpolicyv1alpha1.SnapshotPolicy,
+	snapshots []snapv1.VolumeSnapshot) ([]snapv1.VolumeSnapshot, int) {
+	var policySnapshots []snapv1.VolumeSnapshot
+	for _, snapshot := range snapshots {
+		if snapshot.Labels[snapshotPolicyLabel] == instance.Name {
+			policySnapshots = append(policySnapshots, snapshot)
+		}
+	}
+	return policySnapshots, len(snapshots) - len(policySnapshots)
+}
+
+// hasSnapshotSince returns true if one of the given VolumeSnapshots was created
+// at or after the given time.
+func hasSnapshotSince(snapshots []snapv1.VolumeSnapshot, since time.Time) bool {
+	for _, snapshot := range snapshots {
+		if !snapshot.CreationTimestamp.Time.Before(since) {
+			return true
+		}
+	}
+	return false
+}
+
+// pruneSnapshots deletes the given VolumeSnapshots and returns the ones it
+// deleted. VolumeSnapshots from which LinkedClone volumes were created are
+// retained, as they cannot be deleted until those volumes are deleted.
+func (r *ReconcileSnapshotPolicy) pruneSnapshots(ctx context.Context,
+	snapshots []snapv1.VolumeSnapshot) ([]snapv1.VolumeSnapshot, error) {
+	log := logger.GetLogger(ctx)
+	var pruned []snapv1.VolumeSnapshot
+	for _, snapshot := range snapshots {
+		linkedClonesCount, err := common.GetLinkedClonesCountForSnapshot(ctx, r.k8sClient, string(snapshot.UID))
+		if err != nil {
+			return pruned, fmt.Errorf("failed to check linked clones of VolumeSnapshot %q. Err: %v",
+				snapshot.Name, err)
+		}
+		if linkedClonesCount != 0 {
+			log.Infof("Not pruning VolumeSnapshot %s/%s as %d linked clones are created from it",
+				snapshot.Namespace, snapshot.Name, linkedClonesCount)
+			continue
+		}
+		err = r.snapshotterClient.SnapshotV1().VolumeSnapshots(snapshot.Namespace).Delete(ctx,
+			snapshot.Name, metav1.DeleteOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return pruned, fmt.Errorf("failed to delete VolumeSnapshot %q. Err: %v", snapshot.Name, err)
+		}
+		log.Infof("Pruned VolumeSnapshot %s/%s", snapshot.Namespace, snapshot.Name)
+		pruned = append(pruned, snapshot)
+	}
+	return pruned, nil
+}
+
+// freeSnapshotSlots prunes count of the given VolumeSnapshots, oldest first,
+// and returns the VolumeSnapshots which remain. VolumeSnapshots with linked
+// clones are skipped in favor of the next oldest one.
+func (r *ReconcileSnapshotPolicy) freeSnapshotSlots(ctx context.Context, snapshots []snapv1.VolumeSnapshot,
+	count int) ([]snapv1.VolumeSnapshot, error) {
+	var pruned []snapv1.VolumeSnapshot
+	for _, snapshot := range selectSnapshotsToPrune(snapshots, 0, nil, time.Time{}) {
+		if len(pruned) == count {
+			break
+		}
+		prunedSnapshot, err := r.pruneSnapshots(ctx, []snapv1.VolumeSnapshot{snapshot})
+		if err != nil {
+			return nil, err
+		}
+		pruned = append(pruned, prunedSnapshot...)
+	}
+	if len(pruned) < count {
+		return nil, fmt.Errorf("the snapshot limit of the volume is reached and the remaining %d "+
+			"VolumeSnapshots of the policy have linked clones", len(snapshots)-len(pruned))
+	}
+	return excludeSnapshots(snapshots, pruned), nil
+}
+
+// excludeSnapshots returns the snapshots which are not in excluded.
+func excludeSnapshots(snapshots, excluded []snapv1.VolumeSnapshot) []snapv1.VolumeSnapshot {
+	excludedUIDs := make(map[apitypes.UID]struct{}, len(excluded))
+	for _, snapshot := range excluded {
+		excludedUIDs[snapshot.UID] = struct{}{}
+	}
+	var remaining []snapv1.VolumeSnapshot
+	for _, snapshot := range snapshots {
+		if _, ok := excludedUIDs[snapshot.UID]; !ok {
+			remaining = append(remaining, snapshot)
+		}
+	}
+	return remaining
+}
+
+// selectSnapshotsToPrune returns the snapshots, oldest first, which are older
+// than maxAge or which have to be deleted so that at most retainCount snapshots
+// remain. A nil maxAge disables pruning by age.
+func selectSnapshotsToPrune(snapshots []snapv1.VolumeSnapshot, retainCount int,
+	maxAge *metav1.Duration, now time.Time) []snapv1.VolumeSnapshot {
+	sorted := make([]snapv1.VolumeSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+	})
+	if retainCount < 0 {
+		retainCount = 0
+	}
+	var toPrune []snapv1.VolumeSnapshot
+	for i, snapshot := range sorted {
+		remaining := len(sorted) - i
+		if remaining > retainCount ||
+			(maxAge != nil && now.Sub(snapshot.CreationTimestamp.Time) > maxAge.Duration) {
+			toPrune = append(toPrune, snapshot)
+		}
+	}
+	return toPrune
+}
+
+// matchesPVC returns true if a PVC with the given name is present in pvcs.
+func matchesPVC(pvcs []v1.PersistentVolumeClaim, pvcName string) bool {
+	for _, pvc := range pvcs {
+		if pvc.Name == pvcName {
+			return true
+		}
+	}
+	return false
+}
+
+// incrementBackOff doubles the backoff duration of the instance up to
+// maxBackOffDuration and returns the current backoff duration.
+func incrementBackOff(name apitypes.NamespacedName, timeout time.Duration) time.Duration {
+	backOffDurationMapMutex.Lock()
+	defer backOffDurationMapMutex.Unlock()
+	backOffDuration[name] = min(backOffDuration[name]*2, maxBackOffDuration)
+	return timeout
+}
+
+// setInstanceError sets error and records an event on the SnapshotPolicy
+// instance.
+func setInstanceError(ctx context.Context, r *ReconcileSnapshotPolicy,
+	instance *snapshotpolicyv1alpha1.SnapshotPolicy, errMsg string) {
+	log := logger.GetLogger(ctx)
+	log.Error(errMsg)
+	instance.Status.Error = errMsg
+	err := updateSnapshotPolicyStatus(ctx, r.client, instance)
+	if err != nil {
+		log.Errorf("updateSnapshotPolicyStatus failed. err: %v", err)
+	}
+	recordEvent(ctx, r, instance, v1.EventTypeWarning, errMsg)
+}
+
+// recordEvent records the event.
+func recordEvent(ctx context.Context, r *ReconcileSnapshotPolicy,
+	instance *snapshotpolicyv1alpha1.SnapshotPolicy, eventtype string, msg string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("Event type is %s", eventtype)
+	switch eventtype {
+	case v1.EventTypeWarning:
+		r.recorder.Event(instance, v1.EventTypeWarning, "SnapshotPolicyFailed", msg)
+	case v1.EventTypeNormal:
+		r.recorder.Event(instance, v1.EventTypeNormal, "SnapshotPolicySucceeded", msg)
+	}
+}
+
+// updateSnapshotPolicyStatus updates the status of the SnapshotPolicy instance in K8S.
+func updateSnapshotPolicyStatus(ctx context.Context, client client.Client,
+	instance *snapshotpolicyv1alpha1.SnapshotPolicy) error {
+	log := logger.GetLogger(ctx)
+	err := client.Status().Update(ctx, instance)
+	if err != nil {
+		log.Errorf("Failed to update status of SnapshotPolicy instance: %s/%s. Error: %+v",
+			instance.Namespace, instance.Name, err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,414 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package snapshotpolicy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	snapv1 "github.com/kubernetes-csi/external-snapshotter/client/v8/apis/volumesnapshot/v1"
+	snapshotfake "github.com/kubernetes-csi/external-snapshotter/client/v8/clientset/versioned/fake"
+	"github.com/stretchr/testify/assert"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	apitypes "k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	apis "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator"
+	snapshotpolicyv1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/cnsoperator/snapshotpolicy/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+const (
+	testNamespace  = "test-namespace"
+	testPolicyName = "test-policy"
+	testPVCName    = "test-pvc"
+)
+
+func newTestSnapshot(name string, uid string, createdAt time.Time) snapv1.VolumeSnapshot {
+	pvcName := testPVCName
+	return snapv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			Namespace:         testNamespace,
+			UID:               apitypes.UID(uid),
+			CreationTimestamp: metav1.Time{Time: createdAt},
+			Labels:            map[string]string{snapshotPolicyLabel: testPolicyName},
+		},
+		Spec: snapv1.VolumeSnapshotSpec{
+			Source: snapv1.VolumeSnapshotSource{PersistentVolumeClaimName: &pvcName},
+		},
+	}
+}
+
+func snapshotNames(snapshots []snapv1.VolumeSnapshot) []string {
+	var names []string
+	for _, snapshot := range snapshots {
+		names = append(names, snapshot.Name)
+	}
+	return names
+}
+
+func TestSelectSnapshotsToPrune(t *testing.T) {
+	now := time.Now()
+	snapshots := []snapv1.VolumeSnapshot{
+		newTestSnapshot("snap-2", "uid-2", now.Add(-2*time.Hour)),
+		newTestSnapshot("snap-4", "uid-4", now.Add(-4*time.Hour)),
+		newTestSnapshot("snap-1", "uid-1", now.Add(-1*time.Hour)),
+		newTestSnapshot("snap-3", "uid-3", now.Add(-3*time.Hour)),
+	}
+	tests := []struct {
+		name        string
+		retainCount int
+		maxAge      *metav1.Duration
+		expected    []string
+	}{
+		{
+			name:        "WithinRetainCount",
+			retainCount: 4,
+			expected:    nil,
+		},
+		{
+			name:        "ExceedsRetainCount",
+			retainCount: 2,
+			expected:    []string{"snap-4", "snap-3"},
+		},
+		{
+			name:        "OlderThanMaxAge",
+			retainCount: 4,
+			maxAge:      &metav1.Duration{Duration: 150 * time.Minute},
+			expected:    []string{"snap-4", "snap-3"},
+		},
+		{
+			name:        "ExceedsRetainCountAndMaxAge",
+			retainCount: 3,
+			maxAge:      &metav1.Duration{Duration: 90 * time.Minute},
+			expected:    []string{"snap-4", "snap-3", "snap-2"},
+		},
+		{
+			name:        "ZeroRetainCount",
+			retainCount: 0,
+			expected:    []string{"snap-4", "snap-3", "snap-2", "snap-1"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			toPrune := selectSnapshotsToPrune(snapshots, test.retainCount, test.maxAge, now)
+			assert.Equal(t, test.expected, snapshotNames(toPrune))
+		})
+	}
+}
+
+func TestReconcileSnapshotPolicy(t *testing.T) {
+	now := time.Now()
+	// The fake snapshotter clientset does not generate names, so the VolumeSnapshot
+	// created by the policy shows up with an empty name.
+	tests := []struct {
+		name              string
+		linkedCloneSource string
+		userSnapshot      bool
+		expectedSnapshots []string
+	}{
+		{
+			name:              "PrunesOldestSnapshot",
+			expectedSnapshots: []string{"", "snap-1", "snap-2"},
+		},
+		{
+			// The oldest VolumeSnapshot has linked clones, so the next oldest
+			// one is pruned to stay within the snapshot limit of the volume.
+			name:              "RetainsSnapshotWithLinkedClones",
+			linkedCloneSource: "uid-3",
+			expectedSnapshots: []string{"", "snap-1", "snap-3"},
+		},
+		{
+			// The VolumeSnapshot not created by the policy counts against the
+			// snapshot limit of the volume but is never pruned.
+			name:              "CountsSnapshotsNotCreatedByPolicy",
+			userSnapshot:      true,
+			expectedSnapshots: []string{"", "snap-1", "user-snap"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			backOffDuration = make(map[apitypes.NamespacedName]time.Duration)
+
+			policy := &snapshotpolicyv1alpha1.SnapshotPolicy{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:              testPolicyName,
+					Namespace:         testNamespace,
+					CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)},
+				},
+				Spec: snapshotpolicyv1alpha1.SnapshotPolicySpec{
+					Selector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+					Schedule: "*/5 * * * *",
+
+					VolumeSnapshotClassName: "test-snapshot-class",
+					Retention:               snapshotpolicyv1alpha1.SnapshotRetention{MaxCount: 5},
+				},
+			}
+			s := runtime.NewScheme()
+			s.AddKnownTypes(apis.SchemeGroupVersion, policy)
+			fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(policy).
+				WithStatusSubresource(policy).Build()
+
+			k8sObjs := []runtime.Object{
+				&v1.PersistentVolumeClaim{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      testPVCName,
+						Namespace: testNamespace,
+						Labels:    map[string]string{"app": "test"},
+					},
+					Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+				},
+				// The snapshot limit of the namespace is lower than the retention count
+				// of the policy.
+				&v1.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{
+						Name:      common.ConfigMapCSILimits,
+						Namespace: testNamespace,
+					},
+					Data: map[string]string{common.ConfigMapKeyMaxSnapshotsPerVolume: "3"},
+				},
+			}
+			if test.linkedCloneSource != "" {
+				k8sObjs = append(k8sObjs, &v1.PersistentVolume{
+					ObjectMeta: metav1.ObjectMeta{
+						Name: "linked-clone-pv",
+						Labels: map[string]string{
+							common.VolumeContextAttributeLinkedCloneVolumeSnapshotSourceUID: test.linkedCloneSource,
+						},
+					},
+				})
+			}
+			var snapshotObjs []runtime.Object
+			for i, name := range []string{"snap-1", "snap-2", "snap-3"} {
+				snapshot := newTestSnapshot(name, "uid-"+name[len(name)-1:],
+					now.Add(-time.Duration(i+1)*time.Hour))
+				snapshotObjs = append(snapshotObjs, &snapshot)
+			}
+			if test.userSnapshot {
+				snapshot := newTestSnapshot("user-snap", "uid-user", now.Add(-4*time.Hour))
+				snapshot.Labels = nil
+				snapshotObjs = append(snapshotObjs, &snapshot)
+			}
+			snapshotterClient := snapshotfake.NewSimpleClientset(snapshotObjs...)
+
+			r := &ReconcileSnapshotPolicy{
+				client:            fakeClient,
+				scheme:            s,
+				clusterFlavor:     cnstypes.CnsClusterFlavorWorkload,
+				recorder:          record.NewFakeRecorder(10),
+				k8sClient:         k8sfake.NewClientset(k8sObjs...),
+				snapshotterClient: snapshotterClient,
+			}
+			res, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: apitypes.NamespacedName{
+				Namespace: testNamespace, Name: testPolicyName}})
+			assert.NoError(t, err)
+			assert.True(t, res.RequeueAfter > 0 && res.RequeueAfter <= 5*time.Minute)
+
+			snapshotList, err := snapshotterClient.SnapshotV1().VolumeSnapshots(testNamespace).List(ctx,
+				metav1.ListOptions{})
+			assert.NoError(t, err)
+			assert.ElementsMatch(t, test.expectedSnapshots, snapshotNames(snapshotList.Items))
+
+			updatedPolicy := &snapshotpolicyv1alpha1.SnapshotPolicy{}
+			err = fakeClient.Get(ctx, apitypes.NamespacedName{Namespace: testNamespace, Name: testPolicyName},
+				updatedPolicy)
+			assert.NoError(t, err)
+			assert.NotNil(t, updatedPolicy.Status.LastScheduleTime)
+			assert.Empty(t, updatedPolicy.Status.Error)
+		})
+	}
+}
+
+func TestReconcileSnapshotPolicyRetriesFailedPVCs(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backOffDuration = make(map[apitypes.NamespacedName]time.Duration)
+	policy := &snapshotpolicyv1alpha1.SnapshotPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              testPolicyName,
+			Namespace:         testNamespace,
+			CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)},
+		},
+		Spec: snapshotpolicyv1alpha1.SnapshotPolicySpec{
+			Selector:                metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			Schedule:                "*/5 * * * *",
+			VolumeSnapshotClassName: "test-snapshot-class",
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(apis.SchemeGroupVersion, policy)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(policy).WithStatusSubresource(policy).Build()
+	var k8sObjs []runtime.Object
+	for _, pvcName := range []string{"pvc-ok", "pvc-fail"} {
+		k8sObjs = append(k8sObjs, &v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      pvcName,
+				Namespace: testNamespace,
+				Labels:    map[string]string{"app": "test"},
+			},
+			Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+		})
+	}
+	k8sObjs = append(k8sObjs, &v1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: common.ConfigMapCSILimits, Namespace: testNamespace},
+		Data:       map[string]string{common.ConfigMapKeyMaxSnapshotsPerVolume: "3"},
+	})
+	snapshotterClient := snapshotfake.NewSimpleClientset()
+	// The fake clientset neither generates names nor sets the creation time.
+	created := 0
+	failPVC := true
+	snapshotterClient.PrependReactor("create", "volumesnapshots",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			snapshot := action.(k8stesting.CreateAction).GetObject().(*snapv1.VolumeSnapshot)
+			if failPVC && *snapshot.Spec.Source.PersistentVolumeClaimName == "pvc-fail" {
+				return true, nil, fmt.Errorf("snapshot creation failed")
+			}
+			created++
+			snapshot.Name = fmt.Sprintf("snap-%d", created)
+			snapshot.CreationTimestamp = metav1.Time{Time: time.Now()}
+			return false, nil, nil
+		})
+	r := &ReconcileSnapshotPolicy{
+		client:            fakeClient,
+		scheme:            s,
+		clusterFlavor:     cnstypes.CnsClusterFlavorWorkload,
+		recorder:          record.NewFakeRecorder(10),
+		k8sClient:         k8sfake.NewClientset(k8sObjs...),
+		snapshotterClient: snapshotterClient,
+	}
+	request := reconcile.Request{NamespacedName: apitypes.NamespacedName{
+		Namespace: testNamespace, Name: testPolicyName}}
+	getPolicy := func() *snapshotpolicyv1alpha1.SnapshotPolicy {
+		updatedPolicy := &snapshotpolicyv1alpha1.SnapshotPolicy{}
+		assert.NoError(t, fakeClient.Get(ctx, request.NamespacedName, updatedPolicy))
+		return updatedPolicy
+	}
+	getSnapshotSources := func() []string {
+		snapshotList, err := snapshotterClient.SnapshotV1().VolumeSnapshots(testNamespace).List(ctx,
+			metav1.ListOptions{})
+		assert.NoError(t, err)
+		var sources []string
+		for _, snapshot := range snapshotList.Items {
+			sources = append(sources, *snapshot.Spec.Source.PersistentVolumeClaimName)
+		}
+		return sources
+	}
+
+	// The run fails for one PVC, so it is not marked as done.
+	_, err := r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"pvc-ok"}, getSnapshotSources())
+	assert.Nil(t, getPolicy().Status.LastScheduleTime)
+	assert.NotEmpty(t, getPolicy().Status.Error)
+
+	// The retry only creates the snapshot of the failed PVC.
+	failPVC = false
+	_, err = r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"pvc-ok", "pvc-fail"}, getSnapshotSources())
+	assert.NotNil(t, getPolicy().Status.LastScheduleTime)
+	assert.Empty(t, getPolicy().Status.Error)
+}
+
+func TestReconcileSnapshotPolicyPrunesAfterCreate(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	backOffDuration = make(map[apitypes.NamespacedName]time.Duration)
+	policy := &snapshotpolicyv1alpha1.SnapshotPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              testPolicyName,
+			Namespace:         testNamespace,
+			CreationTimestamp: metav1.Time{Time: now.Add(-time.Hour)},
+		},
+		Spec: snapshotpolicyv1alpha1.SnapshotPolicySpec{
+			Selector:                metav1.LabelSelector{MatchLabels: map[string]string{"app": "test"}},
+			Schedule:                "*/5 * * * *",
+			VolumeSnapshotClassName: "test-snapshot-class",
+			Retention:               snapshotpolicyv1alpha1.SnapshotRetention{MaxCount: 2},
+		},
+	}
+	s := runtime.NewScheme()
+	s.AddKnownTypes(apis.SchemeGroupVersion, policy)
+	fakeClient := fake.NewClientBuilder().WithScheme(s).WithObjects(policy).WithStatusSubresource(policy).Build()
+	k8sObjs := []runtime.Object{
+		&v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      testPVCName,
+				Namespace: testNamespace,
+				Labels:    map[string]string{"app": "test"},
+			},
+			Status: v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+		},
+		// The snapshot limit of the volume is above the retention count of the policy.
+		&v1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: common.ConfigMapCSILimits, Namespace: testNamespace},
+			Data:       map[string]string{common.ConfigMapKeyMaxSnapshotsPerVolume: "5"},
+		},
+	}
+	snap1 := newTestSnapshot("snap-1", "uid-1", now.Add(-time.Hour))
+	snap2 := newTestSnapshot("snap-2", "uid-2", now.Add(-2*time.Hour))
+	snapshotterClient := snapshotfake.NewSimpleClientset(&snap1, &snap2)
+	failCreate := true
+	snapshotterClient.PrependReactor("create", "volumesnapshots",
+		func(action k8stesting.Action) (bool, runtime.Object, error) {
+			if failCreate {
+				return true, nil, fmt.Errorf("snapshot creation failed")
+			}
+			snapshot := action.(k8stesting.CreateAction).GetObject().(*snapv1.VolumeSnapshot)
+			snapshot.Name = "snap-new"
+			return false, nil, nil
+		})
+	r := &ReconcileSnapshotPolicy{
+		client:            fakeClient,
+		scheme:            s,
+		clusterFlavor:     cnstypes.CnsClusterFlavorWorkload,
+		recorder:          record.NewFakeRecorder(10),
+		k8sClient:         k8sfake.NewClientset(k8sObjs...),
+		snapshotterClient: snapshotterClient,
+	}
+	request := reconcile.Request{NamespacedName: apitypes.NamespacedName{
+		Namespace: testNamespace, Name: testPolicyName}}
+	listSnapshots := func() []string {
+		snapshotList, err := snapshotterClient.SnapshotV1().VolumeSnapshots(testNamespace).List(ctx,
+			metav1.ListOptions{})
+		assert.NoError(t, err)
+		return snapshotNames(snapshotList.Items)
+	}
+
+	// The create fails, so no snapshot is pruned.
+	_, err := r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"snap-1", "snap-2"}, listSnapshots())
+
+	// Once the create succeeds, the oldest snapshot is pruned.
+	failCreate = false
+	_, err = r.Reconcile(ctx, request)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"snap-new", "snap-1"}, listSnapshots())
+}
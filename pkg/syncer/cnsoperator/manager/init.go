@@ -95,6 +95,7 @@ func InitCnsOperator(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavo
 		} else {
 			clusterId = cnsOperator.configInfo.Cfg.Global.ClusterID
 		}
+		volumes.SetOperationTimeouts(ctx, cnsOperator.configInfo.Cfg.VolumeOperationTimeout)
 		volumeManager, err = volumes.GetManager(ctx, vCenter, nil, false, false, false,
 			clusterFlavor, clusterId, cnsOperator.configInfo.Cfg.Global.ClusterDistribution)
 		if err != nil {
@@ -287,6 +288,21 @@ func InitCnsOperator(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavo
 			log.Errorf("Failed to create %q CRD. Error: %+v", csinodetopology.CRDSingular, err)
 			return err
 		}
+		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.VanillaStoragePolicyQuota) {
+			// Create StoragePolicyQuota and StoragePolicyUsage CRDs.
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx, cnsoperatorconfig.EmbedStoragePolicyQuotaCRFile,
+				cnsoperatorconfig.EmbedStoragePolicyQuotaCRFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Error: %+v", cnsoperatorv1alpha1.CnsStoragePolicyQuotaSingular, err)
+				return err
+			}
+			err = k8s.CreateCustomResourceDefinitionFromManifest(ctx, cnsoperatorconfig.EmbedStoragePolicyUsageCRFile,
+				cnsoperatorconfig.EmbedStoragePolicyUsageCRFileName)
+			if err != nil {
+				log.Errorf("Failed to create %q CRD. Error: %+v", cnsoperatorv1alpha1.CnsStoragePolicyUsageSingular, err)
+				return err
+			}
+		}
 	} else if clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		if cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.TKGsHA) {
 			// Create CSINodeTopology CRD.
@@ -299,6 +315,17 @@ func InitCnsOperator(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavo
 		}
 	}
 
+	if (clusterFlavor == cnstypes.CnsClusterFlavorWorkload || clusterFlavor == cnstypes.CnsClusterFlavorVanilla) &&
+		cnsOperator.coCommonInterface.IsFSSEnabled(ctx, common.SnapshotPolicy) {
+		// Create SnapshotPolicy CRD.
+		err = k8s.CreateCustomResourceDefinitionFromManifest(ctx, cnsoperatorconfig.EmbedSnapshotPolicyCRFile,
+			cnsoperatorconfig.EmbedSnapshotPolicyCRFileName)
+		if err != nil {
+			log.Errorf("Failed to create %q CRD. Error: %+v", cnsoperatorv1alpha1.SnapshotPolicySingular, err)
+			return err
+		}
+	}
+
 	// Create a new operator to provide shared dependencies and start components
 	// Setting namespace to empty would let operator watch all namespaces.
 	mgr, err := manager.New(restConfig, manager.Options{
@@ -0,0 +1,330 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strings"
+
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	clientset "k8s.io/client-go/kubernetes"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+)
+
+// provisionerReservedParamPrefix is the prefix of the StorageClass parameters
+// reserved for the external-provisioner, which are not passed on to the driver.
+const provisionerReservedParamPrefix = "csi.storage.k8s.io/"
+
+// fileVolumeACLFullSync reconciles the ACLs of the file volumes in the given
+// PVs with the nodes they are published to. The current guest IPs of every
+// node with an attached VolumeAttachment are (re-)added to the ACL. Entries of
+// the ACL of the file share which neither belong to a node with a
+// VolumeAttachment of the volume nor to the net permissions the volume is
+// created with are removed, so that access of nodes which are gone, or whose
+// VolumeAttachment was deleted before their access was revoked, does not leak.
+func fileVolumeACLFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, k8sPVs []*v1.PersistentVolume,
+	volManager volumes.Manager, vc string) {
+	log := logger.GetLogger(ctx)
+	if nodeMgr == nil {
+		log.Debugf("fileVolumeACLFullSync: node manager is not initialized. Skipping ACL sync.")
+		return
+	}
+	fileVolumePVs := make(map[string]*v1.PersistentVolume)
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI != nil && IsFileVolume(pv) {
+			fileVolumePVs[pv.Name] = pv
+		}
+	}
+	if len(fileVolumePVs) == 0 {
+		return
+	}
+	// Query the ACLs of the file shares before listing the VolumeAttachments,
+	// as IPs are only added to the ACL on publish after the VolumeAttachment
+	// of the node is created.
+	volumeIDToShare, shareNamesByDatastore, err := getFileShareBackings(ctx, volManager)
+	if err != nil {
+		log.Errorf("fileVolumeACLFullSync: failed to query file volumes. Err: %+v", err)
+		return
+	}
+	shareNetPerms, err := queryFileShareNetPermissions(ctx, vc, shareNamesByDatastore)
+	if err != nil {
+		log.Errorf("fileVolumeACLFullSync: failed to query ACLs of file shares. Err: %+v", err)
+		return
+	}
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("fileVolumeACLFullSync: failed to create kubernetes client. Err: %+v", err)
+		return
+	}
+	vaList, err := k8sClient.StorageV1().VolumeAttachments().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		log.Errorf("fileVolumeACLFullSync: failed to list VolumeAttachments. Err: %+v", err)
+		return
+	}
+	scParams, err := getStorageClassParams(ctx, k8sClient,
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration))
+	if err != nil {
+		log.Errorf("fileVolumeACLFullSync: failed to list StorageClasses. Err: %+v", err)
+		return
+	}
+	// desiredIPs maps volume ID to the IPs of the nodes it is published to.
+	desiredIPs := make(map[string]map[string]struct{})
+	for _, va := range vaList.Items {
+		if va.Spec.Attacher != common.VSphereCSIDriverName || va.DeletionTimestamp != nil ||
+			va.Spec.Source.PersistentVolumeName == nil {
+			continue
+		}
+		pv, ok := fileVolumePVs[*va.Spec.Source.PersistentVolumeName]
+		if !ok {
+			continue
+		}
+		volumeID := pv.Spec.CSI.VolumeHandle
+		if _, ok := desiredIPs[volumeID]; !ok {
+			desiredIPs[volumeID] = make(map[string]struct{})
+		}
+		// Keep the IPs recorded on publish until the current IPs of the node
+		// are known.
+		recordedIPs := parseNfsClientIPs(va.Status.AttachmentMetadata[common.NfsClientIPs])
+		for _, ip := range recordedIPs {
+			desiredIPs[volumeID][ip] = struct{}{}
+		}
+		nodeVM, err := nodeMgr.GetNodeVMByNameOrUUID(ctx, va.Spec.NodeName)
+		if err != nil {
+			log.Warnf("fileVolumeACLFullSync: failed to find VirtualMachine for node %q. Err: %v",
+				va.Spec.NodeName, err)
+			continue
+		}
+		nodeIPs, err := nodeVM.GetGuestIPAddresses(ctx)
+		if err != nil {
+			log.Warnf("fileVolumeACLFullSync: failed to get IPs of node %q. Err: %v", va.Spec.NodeName, err)
+			continue
+		}
+		for _, ip := range nodeIPs {
+			desiredIPs[volumeID][ip] = struct{}{}
+		}
+		if !va.Status.Attached {
+			// The node is granted access on publish.
+			continue
+		}
+		err = common.ConfigureFileVolumeNodeACLUtil(ctx, volManager, volumeID, nodeIPs, pv.Spec.CSI.ReadOnly, false)
+		if err != nil {
+			log.Errorf("fileVolumeACLFullSync: failed to add IPs of node %q to ACL of volume %q. Err: %v",
+				va.Spec.NodeName, volumeID, err)
+		}
+	}
+	for _, pv := range fileVolumePVs {
+		volumeID := pv.Spec.CSI.VolumeHandle
+		share, ok := volumeIDToShare[volumeID]
+		if !ok {
+			continue
+		}
+		netPerms, ok := shareNetPerms[share.Name]
+		if !ok {
+			continue
+		}
+		params, ok := scParams[pv.Spec.StorageClassName]
+		if !ok {
+			if pv.Spec.StorageClassName != "" {
+				// The net permissions the volume is created with are unknown.
+				log.Debugf("fileVolumeACLFullSync: StorageClass %q of PV %q not found. Skipping removal of "+
+					"stale net permissions of volume %q.", pv.Spec.StorageClassName, pv.Name, volumeID)
+				continue
+			}
+			params = &common.StorageClassParams{}
+		}
+		allowedIPs := make(map[string]struct{})
+		maps.Copy(allowedIPs, desiredIPs[volumeID])
+		baseNetPerms := common.GetFileVolumeBaseNetPermissions(params, metadataSyncer.configInfo.Cfg.NetPermissions,
+			true)
+		for _, netPerm := range baseNetPerms {
+			allowedIPs[netPerm.Ips] = struct{}{}
+		}
+		staleNetPerms := getStaleNetPermissions(netPerms, allowedIPs)
+		if len(staleNetPerms) == 0 {
+			continue
+		}
+		log.Infof("fileVolumeACLFullSync: removing stale net permissions %+v from ACL of volume %q",
+			staleNetPerms, volumeID)
+		err = common.ConfigureFileVolumeNetPermissionsUtil(ctx, volManager, volumeID, staleNetPerms, true)
+		if err != nil {
+			log.Errorf("fileVolumeACLFullSync: failed to remove stale net permissions from ACL of volume %q. "+
+				"Err: %v", volumeID, err)
+		}
+	}
+}
+
+// fileSharePermissionsFullSync re-applies the net permissions given in the
+// StorageClass of each file volume in the given PVs onto its file share, so
+// that permissions changed out of band are restored. Entries added to the ACL
+// out of band are removed, unless nodes are granted access to the volumes they
+// are published to, in which case fileVolumeACLFullSync removes them. Soft
+// quotas can not be changed on existing file shares through CNS and are not
+// reconciled.
+func fileSharePermissionsFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	k8sPVs []*v1.PersistentVolume, volManager volumes.Manager, vc string) {
+	log := logger.GetLogger(ctx)
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("fileSharePermissionsFullSync: failed to create kubernetes client. Err: %+v", err)
+		return
+	}
+	scParams, err := getStorageClassParams(ctx, k8sClient,
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration))
+	if err != nil {
+		log.Errorf("fileSharePermissionsFullSync: failed to list StorageClasses. Err: %+v", err)
+		return
+	}
+	nodeACLEnabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileVolumeNodeACL)
+	scNetPerms := make(map[string][]vsanfstypes.VsanFileShareNetPermission)
+	for scName, params := range scParams {
+		if netPerms := common.GetFileShareNetPermissions(params, nodeACLEnabled); netPerms != nil {
+			scNetPerms[scName] = netPerms
+		}
+	}
+	if len(scNetPerms) == 0 {
+		return
+	}
+	// volumeIDToNetPerms maps volume ID to the net permissions of its StorageClass.
+	volumeIDToNetPerms := make(map[string][]vsanfstypes.VsanFileShareNetPermission)
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI == nil || !IsFileVolume(pv) {
+			continue
+		}
+		netPerms, ok := scNetPerms[pv.Spec.StorageClassName]
+		if !ok {
+			continue
+		}
+		volumeIDToNetPerms[pv.Spec.CSI.VolumeHandle] = netPerms
+		if len(netPerms) == 0 {
+			continue
+		}
+		err = common.ConfigureFileVolumeNetPermissionsUtil(ctx, volManager, pv.Spec.CSI.VolumeHandle, netPerms, false)
+		if err != nil {
+			log.Errorf("fileSharePermissionsFullSync: failed to apply net permissions of StorageClass %q "+
+				"to volume %q. Err: %v", pv.Spec.StorageClassName, pv.Spec.CSI.VolumeHandle, err)
+		}
+	}
+	if nodeACLEnabled || len(volumeIDToNetPerms) == 0 {
+		return
+	}
+	volumeIDToShare, shareNamesByDatastore, err := getFileShareBackings(ctx, volManager)
+	if err != nil {
+		log.Errorf("fileSharePermissionsFullSync: failed to query file volumes. Err: %+v", err)
+		return
+	}
+	shareNetPerms, err := queryFileShareNetPermissions(ctx, vc, shareNamesByDatastore)
+	if err != nil {
+		log.Errorf("fileSharePermissionsFullSync: failed to query ACLs of file shares. Err: %+v", err)
+		return
+	}
+	for volumeID, netPerms := range volumeIDToNetPerms {
+		share, ok := volumeIDToShare[volumeID]
+		if !ok {
+			continue
+		}
+		allowedIPs := make(map[string]struct{})
+		for _, netPerm := range netPerms {
+			allowedIPs[netPerm.Ips] = struct{}{}
+		}
+		staleNetPerms := getStaleNetPermissions(shareNetPerms[share.Name], allowedIPs)
+		if len(staleNetPerms) == 0 {
+			continue
+		}
+		log.Infof("fileSharePermissionsFullSync: removing net permissions %+v not in the StorageClass from "+
+			"ACL of volume %q", staleNetPerms, volumeID)
+		err = common.ConfigureFileVolumeNetPermissionsUtil(ctx, volManager, volumeID, staleNetPerms, true)
+		if err != nil {
+			log.Errorf("fileSharePermissionsFullSync: failed to remove net permissions from ACL of volume %q. "+
+				"Err: %v", volumeID, err)
+		}
+	}
+}
+
+// getStorageClassParams returns the parameters of the StorageClasses of the
+// driver keyed by StorageClass name. StorageClasses with invalid parameters
+// are skipped.
+func getStorageClassParams(ctx context.Context, k8sClient clientset.Interface,
+	csiMigrationEnabled bool) (map[string]*common.StorageClassParams, error) {
+	log := logger.GetLogger(ctx)
+	scList, err := k8sClient.StorageV1().StorageClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	scParams := make(map[string]*common.StorageClassParams)
+	for _, sc := range scList.Items {
+		if sc.Provisioner != common.VSphereCSIDriverName {
+			continue
+		}
+		params, err := parseStorageClassParams(ctx, sc.Parameters, csiMigrationEnabled)
+		if err != nil {
+			log.Warnf("skipping StorageClass %q with invalid parameters. Err: %v", sc.Name, err)
+			continue
+		}
+		scParams[sc.Name] = params
+	}
+	return scParams, nil
+}
+
+// parseStorageClassParams parses the given StorageClass parameters like the
+// controller parses the parameters of CreateVolume requests, which the
+// external-provisioner passes on without its reserved parameters.
+func parseStorageClassParams(ctx context.Context, params map[string]string,
+	csiMigrationEnabled bool) (*common.StorageClassParams, error) {
+	createVolumeParams := make(map[string]string)
+	for param, value := range params {
+		if !strings.HasPrefix(strings.ToLower(param), provisionerReservedParamPrefix) {
+			createVolumeParams[param] = value
+		}
+	}
+	return common.ParseStorageClassParams(ctx, createVolumeParams, csiMigrationEnabled)
+}
+
+// parseNfsClientIPs returns the IPs in the comma separated list recorded in
+// the publish context of a file volume.
+func parseNfsClientIPs(value string) []string {
+	var ips []string
+	for _, ip := range strings.Split(value, ",") {
+		if ip = strings.TrimSpace(ip); ip != "" {
+			ips = append(ips, ip)
+		}
+	}
+	return ips
+}
+
+// getStaleNetPermissions returns the net permissions, sorted by IPs, whose IPs
+// are not among the allowed IPs.
+func getStaleNetPermissions(netPerms []vsanfstypes.VsanFileShareNetPermission,
+	allowedIPs map[string]struct{}) []vsanfstypes.VsanFileShareNetPermission {
+	var staleNetPerms []vsanfstypes.VsanFileShareNetPermission
+	for _, netPerm := range netPerms {
+		if _, ok := allowedIPs[netPerm.Ips]; !ok {
+			staleNetPerms = append(staleNetPerms, netPerm)
+		}
+	}
+	slices.SortFunc(staleNetPerms, func(a, b vsanfstypes.VsanFileShareNetPermission) int {
+		return strings.Compare(a.Ips, b.Ips)
+	})
+	return staleNetPerms
+}
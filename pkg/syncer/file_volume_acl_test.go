@@ -0,0 +1,81 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+)
+
+func TestParseNfsClientIPs(t *testing.T) {
+	tests := map[string][]string{
+		"":                       nil,
+		"10.0.0.1":               {"10.0.0.1"},
+		"10.0.0.1, 10.0.0.2,":    {"10.0.0.1", "10.0.0.2"},
+		"10.0.0.1,fd00::1,,,   ": {"10.0.0.1", "fd00::1"},
+	}
+	for value, expected := range tests {
+		if ips := parseNfsClientIPs(value); !reflect.DeepEqual(ips, expected) {
+			t.Errorf("expected IPs %v for %q, got %v", expected, value, ips)
+		}
+	}
+}
+
+func TestGetStaleNetPermissions(t *testing.T) {
+	netPerms := []vsanfstypes.VsanFileShareNetPermission{
+		{Ips: "10.0.0.3", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_WRITE, AllowRoot: true},
+		{Ips: "*", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_WRITE, AllowRoot: true},
+		{Ips: "10.0.0.1", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_ONLY, AllowRoot: true},
+		{Ips: "10.20.0.0/16", Permissions: vsanfstypes.VsanFileShareAccessTypeREAD_WRITE},
+	}
+	allowed := map[string]struct{}{"10.0.0.1": {}, "10.20.0.0/16": {}, "10.0.0.4": {}}
+	expected := []vsanfstypes.VsanFileShareNetPermission{netPerms[1], netPerms[0]}
+	if stale := getStaleNetPermissions(netPerms, allowed); !reflect.DeepEqual(stale, expected) {
+		t.Errorf("expected stale net permissions %+v, got %+v", expected, stale)
+	}
+	allowed["*"] = struct{}{}
+	allowed["10.0.0.3"] = struct{}{}
+	if stale := getStaleNetPermissions(netPerms, allowed); len(stale) != 0 {
+		t.Errorf("expected no stale net permissions, got %+v", stale)
+	}
+}
+
+func TestParseStorageClassParams(t *testing.T) {
+	ctx := context.Background()
+	params := map[string]string{
+		"NetPermissionIPs":          "10.20.0.0/16",
+		"netpermission":             "read_only",
+		"RootSquash":                "TRUE",
+		"softquotapercent":          "75",
+		"csi.storage.k8s.io/fstype": "nfs4",
+	}
+	scParams, err := parseStorageClassParams(ctx, params, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if scParams.NetPermissionIPs != "10.20.0.0/16" || scParams.NetPermission != "READ_ONLY" ||
+		scParams.RootSquash != "true" || scParams.SoftQuotaPercent != "75" {
+		t.Errorf("unexpected file share params %+v", scParams)
+	}
+	params["softquotapercent"] = "150"
+	if _, err := parseStorageClassParams(ctx, params, false); err == nil {
+		t.Errorf("expected error for invalid soft quota percent")
+	}
+}
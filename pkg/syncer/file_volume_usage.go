@@ -0,0 +1,259 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"strconv"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vimtypes "github.com/vmware/govmomi/vim25/types"
+	vsanfstypes "github.com/vmware/govmomi/vsan/vsanfs/types"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clientset "k8s.io/client-go/kubernetes"
+
+	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+)
+
+// fileVolumeUsageReported tracks, per vCenter host, the PVCs whose file
+// volume usage metrics were set in the previous cycle so that the metrics of
+// PVCs which are gone can be deleted.
+var fileVolumeUsageReported = make(map[string]map[types.NamespacedName]struct{})
+
+// fileShareUsage holds the used capacity and the hard quota of a file share.
+type fileShareUsage struct {
+	usedBytes  int64
+	quotaBytes int64
+}
+
+// csiGetFileVolumeUsage queries the used capacity of the vSAN file shares
+// backing the file volumes on the given vCenter, exports it as metrics per
+// PVC and records it on the annotations of the PVs for NodeGetVolumeStats.
+func csiGetFileVolumeUsage(ctx context.Context, k8sclient clientset.Interface,
+	metadataSyncer *metadataSyncInformer, vc string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("csiGetFileVolumeUsage for %s: start", vc)
+	cnsVolumeMgr, err := getVolManagerForVcHost(ctx, vc, metadataSyncer)
+	if err != nil {
+		log.Errorf("csiGetFileVolumeUsage for %s: Failed to get volume manager. Err: %v", vc, err)
+		return
+	}
+	volumeIDToShare, shareNamesByDatastore, err := getFileShareBackings(ctx, cnsVolumeMgr)
+	if err != nil {
+		log.Errorf("csiGetFileVolumeUsage for %s: failed to QueryAllVolume with err=%+v", vc, err)
+		return
+	}
+
+	var usedCapacityMB map[string]int64
+	if len(volumeIDToShare) != 0 {
+		usedCapacityMB, err = queryFileShareUsedCapacity(ctx, vc, shareNamesByDatastore)
+		if err != nil {
+			log.Errorf("csiGetFileVolumeUsage for %s: failed to query file share usage. Err: %v", vc, err)
+			return
+		}
+	}
+
+	k8sPVs, err := getBoundPVs(ctx, metadataSyncer)
+	if err != nil {
+		log.Errorf("csiGetFileVolumeUsage for %s: Failed to get PVs from kubernetes. Err: %+v", vc, err)
+		return
+	}
+	reported := make(map[types.NamespacedName]struct{})
+	for _, pv := range k8sPVs {
+		if pv.Spec.CSI == nil || pv.Spec.ClaimRef == nil || !IsFileVolume(pv) {
+			continue
+		}
+		share, ok := volumeIDToShare[pv.Spec.CSI.VolumeHandle]
+		if !ok {
+			continue
+		}
+		usedMB, ok := usedCapacityMB[share.Name]
+		if !ok {
+			log.Debugf("csiGetFileVolumeUsage for %s: no usage found for file share %q of volume %q",
+				vc, share.Name, pv.Spec.CSI.VolumeHandle)
+			continue
+		}
+		usage := fileShareUsage{
+			usedBytes:  usedMB * common.MbInBytes,
+			quotaBytes: share.CapacityInMb * common.MbInBytes,
+		}
+		pvc := types.NamespacedName{Namespace: pv.Spec.ClaimRef.Namespace, Name: pv.Spec.ClaimRef.Name}
+		prometheus.FileVolumeUsedBytesGaugeVec.WithLabelValues(pvc.Namespace, pvc.Name).Set(
+			float64(usage.usedBytes))
+		prometheus.FileVolumeQuotaBytesGaugeVec.WithLabelValues(pvc.Namespace, pvc.Name).Set(
+			float64(usage.quotaBytes))
+		reported[pvc] = struct{}{}
+
+		patchBytes, err := getFileVolumeUsagePatch(pv, usage)
+		if err != nil {
+			log.Errorf("csiGetFileVolumeUsage for %s: failed to create patch for PV %q. Err: %v", vc, pv.Name, err)
+			continue
+		}
+		if patchBytes == nil {
+			continue
+		}
+		_, err = k8sclient.CoreV1().PersistentVolumes().Patch(ctx, pv.Name, types.MergePatchType,
+			patchBytes, metav1.PatchOptions{})
+		if err != nil {
+			log.Errorf("csiGetFileVolumeUsage for %s: failed to update usage annotations on PV %q. Err: %v",
+				vc, pv.Name, err)
+		}
+	}
+	for pvc := range fileVolumeUsageReported[vc] {
+		if _, ok := reported[pvc]; !ok {
+			prometheus.FileVolumeUsedBytesGaugeVec.DeleteLabelValues(pvc.Namespace, pvc.Name)
+			prometheus.FileVolumeQuotaBytesGaugeVec.DeleteLabelValues(pvc.Namespace, pvc.Name)
+		}
+	}
+	fileVolumeUsageReported[vc] = reported
+	log.Debugf("csiGetFileVolumeUsage for %s: end", vc)
+}
+
+// getFileShareBackings returns the vSAN file share backing details of the file
+// volumes of the cluster in CNS keyed by volume ID, and the names of the file
+// shares grouped by the URL of the vSAN datastore they are on.
+func getFileShareBackings(ctx context.Context, cnsVolumeMgr volumes.Manager) (
+	map[string]*cnstypes.CnsVsanFileShareBackingDetails, map[string][]string, error) {
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{
+			string(cnstypes.QuerySelectionNameTypeVolumeType),
+			string(cnstypes.QuerySelectionNameTypeBackingObjectDetails),
+			string(cnstypes.QuerySelectionNameTypeDataStoreUrl),
+		},
+	}
+	queryAllResult, err := utils.QueryAllVolumesForCluster(ctx, cnsVolumeMgr,
+		clusterIDforVolumeMetadata, querySelection)
+	if err != nil {
+		return nil, nil, err
+	}
+	volumeIDToShare := make(map[string]*cnstypes.CnsVsanFileShareBackingDetails)
+	shareNamesByDatastore := make(map[string][]string)
+	for _, vol := range queryAllResult.Volumes {
+		if vol.VolumeType != common.FileVolumeType {
+			continue
+		}
+		details, ok := vol.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails)
+		if !ok || details.Name == "" {
+			continue
+		}
+		shareNamesByDatastore[vol.DatastoreUrl] = append(shareNamesByDatastore[vol.DatastoreUrl], details.Name)
+		volumeIDToShare[vol.VolumeId.Id] = details
+	}
+	return volumeIDToShare, shareNamesByDatastore, nil
+}
+
+// queryFileShareUsedCapacity returns the used capacity in MB of the given file
+// shares, keyed by file share name. The file shares are grouped by the URL of
+// the vSAN datastore they are on.
+func queryFileShareUsedCapacity(ctx context.Context, vc string,
+	shareNamesByDatastore map[string][]string) (map[string]int64, error) {
+	usedCapacityMB := make(map[string]int64)
+	err := forEachVsanDatastore(ctx, vc, shareNamesByDatastore,
+		func(vCenter *cnsvsphere.VirtualCenter, dsRef vimtypes.ManagedObjectReference, shareNames []string) error {
+			used, err := vCenter.QueryFileShareUsedCapacity(ctx, dsRef, shareNames)
+			if err != nil {
+				return err
+			}
+			maps.Copy(usedCapacityMB, used)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return usedCapacityMB, nil
+}
+
+// queryFileShareNetPermissions returns the net permissions in the ACL of the
+// given file shares, keyed by file share name. The file shares are grouped by
+// the URL of the vSAN datastore they are on.
+func queryFileShareNetPermissions(ctx context.Context, vc string,
+	shareNamesByDatastore map[string][]string) (map[string][]vsanfstypes.VsanFileShareNetPermission, error) {
+	netPerms := make(map[string][]vsanfstypes.VsanFileShareNetPermission)
+	err := forEachVsanDatastore(ctx, vc, shareNamesByDatastore,
+		func(vCenter *cnsvsphere.VirtualCenter, dsRef vimtypes.ManagedObjectReference, shareNames []string) error {
+			perms, err := vCenter.QueryFileShareNetPermissions(ctx, dsRef, shareNames)
+			if err != nil {
+				return err
+			}
+			maps.Copy(netPerms, perms)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	return netPerms, nil
+}
+
+// forEachVsanDatastore calls fn with each vSAN datastore of the given vCenter
+// in shareNamesByDatastore and the names of the file shares on it. Datastores
+// which are not found on the vCenter are skipped.
+func forEachVsanDatastore(ctx context.Context, vc string, shareNamesByDatastore map[string][]string,
+	fn func(vCenter *cnsvsphere.VirtualCenter, dsRef vimtypes.ManagedObjectReference, shareNames []string) error) error {
+	log := logger.GetLogger(ctx)
+	vCenter, err := cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vc, true)
+	if err != nil {
+		return err
+	}
+	dcs, err := vCenter.GetDatacenters(ctx)
+	if err != nil {
+		return err
+	}
+	vsanDatastores, err := vCenter.GetVsanDatastores(ctx, dcs)
+	if err != nil {
+		return err
+	}
+	for dsURL, shareNames := range shareNamesByDatastore {
+		dsInfo, ok := vsanDatastores[dsURL]
+		if !ok {
+			log.Debugf("vSAN datastore %q not found on vCenter %q", dsURL, vc)
+			continue
+		}
+		if err := fn(vCenter, dsInfo.Datastore.Reference(), shareNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getFileVolumeUsagePatch returns the merge patch setting the file share usage
+// annotations on the given PV, or nil if they already hold the given usage.
+func getFileVolumeUsagePatch(pv *v1.PersistentVolume, usage fileShareUsage) ([]byte, error) {
+	usedBytes := strconv.FormatInt(usage.usedBytes, 10)
+	quotaBytes := strconv.FormatInt(usage.quotaBytes, 10)
+	if pv.Annotations[common.AnnFileShareUsedBytes] == usedBytes &&
+		pv.Annotations[common.AnnFileShareQuotaBytes] == quotaBytes {
+		return nil, nil
+	}
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				common.AnnFileShareUsedBytes:  usedBytes,
+				common.AnnFileShareQuotaBytes: quotaBytes,
+			},
+		},
+	}
+	return json.Marshal(patch)
+}
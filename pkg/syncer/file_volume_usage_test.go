@@ -0,0 +1,63 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func TestGetFileVolumeUsagePatch(t *testing.T) {
+	usage := fileShareUsage{usedBytes: 512 * common.MbInBytes, quotaBytes: 1024 * common.MbInBytes}
+	pv := &v1.PersistentVolume{ObjectMeta: metav1.ObjectMeta{Name: "pv-1"}}
+
+	patchBytes, err := getFileVolumeUsagePatch(pv, usage)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var patch struct {
+		Metadata struct {
+			Annotations map[string]string `json:"annotations"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(patchBytes, &patch); err != nil {
+		t.Fatalf("failed to unmarshal patch %s: %v", patchBytes, err)
+	}
+	if patch.Metadata.Annotations[common.AnnFileShareUsedBytes] != "536870912" ||
+		patch.Metadata.Annotations[common.AnnFileShareQuotaBytes] != "1073741824" {
+		t.Errorf("unexpected annotations in patch: %v", patch.Metadata.Annotations)
+	}
+
+	// No patch is needed once the PV holds the same usage.
+	pv.Annotations = patch.Metadata.Annotations
+	patchBytes, err = getFileVolumeUsagePatch(pv, usage)
+	if err != nil || patchBytes != nil {
+		t.Errorf("expected no patch for unchanged usage, got %s, err: %v", patchBytes, err)
+	}
+
+	// A change of the used capacity requires a new patch.
+	usage.usedBytes += common.MbInBytes
+	patchBytes, err = getFileVolumeUsagePatch(pv, usage)
+	if err != nil || patchBytes == nil {
+		t.Errorf("expected patch for changed usage, err: %v", err)
+	}
+}
@@ -81,24 +81,23 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 		}
 	}
 	// Attempt to create StoragePolicyUsage CRs.
-	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
-		if IsPodVMOnStretchSupervisorFSSEnabled {
-			createStoragePolicyUsageCRS(ctx, metadataSyncer)
-		}
+	if isStoragePolicyQuotaEnabled(metadataSyncer) {
+		createStoragePolicyUsageCRS(ctx, metadataSyncer)
 	}
 	// Sync VolumeInfo CRs for the below conditions:
-	// Either it is a Vanilla k8s deployment with Multi-VC configuration or, it's a StretchSupervisor cluster
-	if len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 ||
-		(metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload && IsPodVMOnStretchSupervisorFSSEnabled) {
+	// Either it is a Vanilla k8s deployment with Multi-VC configuration or with storage policy quotas enabled,
+	// or, it's a StretchSupervisor cluster
+	if len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 || isStoragePolicyQuotaEnabled(metadataSyncer) {
 		volumeInfoCRFullSync(ctx, metadataSyncer, vc)
 		cleanUpVolumeInfoCrDeletionMap(ctx, metadataSyncer, vc)
 	}
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla && IsVanillaStoragePolicyQuotaFSSEnabled {
+		volumeInfoSnapshotSizeSync(ctx, metadataSyncer, vc)
+	}
 	// Attempt to patch StoragePolicyUsage CRs. For storagePolicyUsageCRSync to work,
 	// we need CNSVolumeInfo CRs to be present for all existing volumes.
-	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
-		if IsPodVMOnStretchSupervisorFSSEnabled {
-			storagePolicyUsageCRSync(ctx, metadataSyncer)
-		}
+	if isStoragePolicyQuotaEnabled(metadataSyncer) {
+		storagePolicyUsageCRSync(ctx, metadataSyncer)
 	}
 
 	// On Supervisor cluster, if SVPVCSnapshotProtectionFinalizer FSS is enabled,
@@ -179,6 +178,17 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 		return err
 	}
 
+	// Reconcile the ACLs of file volumes with the nodes they are published to.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileVolumeNodeACL) {
+		fileVolumeACLFullSync(ctx, metadataSyncer, k8sPVs, volManager, vc)
+	}
+	// Reconcile the net permissions given in StorageClasses onto file shares.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileSharePermissions) {
+		fileSharePermissionsFullSync(ctx, metadataSyncer, k8sPVs, volManager, vc)
+	}
+
 	var vcenter *cnsvsphere.VirtualCenter
 	// Get VC instance.
 	vcenter, err = cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vc, true)
@@ -284,6 +294,7 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 				return err
 			}
 			var updateMetadataSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec
+			var deleteMetadataSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec
 			for _, queryResult := range queryAllResult {
 				for _, volume := range queryResult.Volumes {
 					log.Infof("observed volume %q with old cluster Id: %q", volume.VolumeId,
@@ -341,21 +352,17 @@ func CsiFullSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc s
 						}
 					}
 					updateMetadataSpecArray = append(updateMetadataSpecArray, updateSpecToAddMetadata)
-					updateMetadataSpecArray = append(updateMetadataSpecArray, updateSpecToDeleteMetadata)
+					deleteMetadataSpecArray = append(deleteMetadataSpecArray, updateSpecToDeleteMetadata)
 				}
 				if len(updateMetadataSpecArray) > 0 {
 					log.Infof("FullSync for VC %s: Replacing ClusterID: %q with new SupervisorID: %q",
 						vc, metadataSyncer.configInfo.Cfg.Global.ClusterID,
 						metadataSyncer.configInfo.Cfg.Global.SupervisorID)
 				}
-				for _, updateSpec := range updateMetadataSpecArray {
-					log.Debugf("Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
-						updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
-					if err := volManager.UpdateVolumeMetadata(ctx, &updateSpec); err != nil {
-						log.Warnf("FullSync for VC %s: UpdateVolumeMetadata failed while replacing clusterID "+
-							"with supervisorID. Error: %+v", vc, err)
-					}
-				}
+				// The metadata for the new SupervisorID is added to all the
+				// volumes before the metadata for the old ClusterID is deleted.
+				updateVolumesMetadataInBulk(ctx, volManager, vc, updateMetadataSpecArray)
+				updateVolumesMetadataInBulk(ctx, volManager, vc, deleteMetadataSpecArray)
 			}
 		}
 		querySelection := cnstypes.CnsQuerySelection{
@@ -772,7 +779,7 @@ func volumeInfoCRFullSync(ctx context.Context, metadataSyncer *metadataSyncInfor
 
 	volumeIdTok8sPVMap := make(map[string]*v1.PersistentVolume)
 	scNameToPolicyIdMap := make(map[string]string)
-	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload && IsPodVMOnStretchSupervisorFSSEnabled {
+	if isStoragePolicyQuotaEnabled(metadataSyncer) {
 		// Create volumeIdTok8sPVMap map for easy lookup of PVs
 		for _, pv := range currentK8sPV {
 			if pv.Spec.CSI != nil {
@@ -799,8 +806,7 @@ func volumeInfoCRFullSync(ctx context.Context, metadataSyncer *metadataSyncInfor
 		// Create scNameToPolicyIdMap map for easy lookup of PolicyIds for a given storageclass name
 		for _, sc := range storageClassList.Items {
 			if _, ok := scNameToPolicyIdMap[sc.Parameters[scParamStoragePolicyID]]; !ok {
-				scNameToPolicyIdMap[sc.Name] =
-					sc.Parameters[scParamStoragePolicyID]
+				scNameToPolicyIdMap[sc.Name] = getStoragePolicyIDForStorageClass(ctx, metadataSyncer, &sc)
 			}
 		}
 	}
@@ -812,52 +818,58 @@ func volumeInfoCRFullSync(ctx context.Context, metadataSyncer *metadataSyncInfor
 				"Error: %+v", vc, volumeID, err)
 			continue
 		}
-		// Create VolumeInfo CR if not found.
-		if !crExists {
-			if len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
+		if !isStoragePolicyQuotaEnabled(metadataSyncer) {
+			// Create VolumeInfo CR if not found.
+			if !crExists && len(metadataSyncer.configInfo.Cfg.VirtualCenter) > 1 {
 				err := volumeInfoService.CreateVolumeInfo(ctx, volumeID, vc)
 				if err != nil {
 					log.Errorf("FullSync for VC %s: failed to create VolumeInfo CR for volume %s."+
 						"Error: %+v", vc, volumeID, err)
-					continue
-				}
-			} else if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload &&
-				IsPodVMOnStretchSupervisorFSSEnabled {
-				isLinkedCloneVolume := false
-				pv := volumeIdTok8sPVMap[volumeID]
-				// claimref will be nil when volume is static provisioned or any available/released pv
-				// which are not claimed by pvc. added a check to handle such cases.
-				if pv.Spec.ClaimRef == nil {
-					log.Warnf("Claimref is not available for pv %s", pv.Name)
-					continue
-				} else {
-					pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(
-						pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
-					if err != nil {
-						log.Warnf("Failed to get pvc for namespace %s and name %s. err=%+v",
-							pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
-						continue
-					}
-					if IsLinkedCloneSupportFSSEnabled && metav1.HasAnnotation(pvc.ObjectMeta, common.AnnKeyLinkedClone) {
-						isLinkedCloneVolume = true
-					}
-					pvcCapacity := pvc.Status.Capacity[v1.ResourceStorage]
-					if pvc.Spec.StorageClassName != nil {
-						err = volumeInfoService.CreateVolumeInfoWithPolicyInfo(ctx, volumeID, pvc.Namespace,
-							scNameToPolicyIdMap[*pvc.Spec.StorageClassName], *pvc.Spec.StorageClassName, vc,
-							&pvcCapacity, isLinkedCloneVolume)
-						if err != nil {
-							log.Warnf("FullSync for VC %s: failed to create VolumeInfo CR for volume %s."+
-								"Error: %+v", vc, volumeID, err)
-							continue
-						}
-					} else {
-						log.Warnf("FullSync for VC %s: failed to create VolumeInfo CR for volume %s."+
-							"StorageClassName not found in the PVC spec %v.", vc, volumeID, pvc.Spec)
-						continue
-					}
 				}
 			}
+			continue
+		}
+		isLinkedCloneVolume := false
+		pv := volumeIdTok8sPVMap[volumeID]
+		// claimref will be nil when volume is static provisioned or any available/released pv
+		// which are not claimed by pvc. added a check to handle such cases.
+		if pv.Spec.ClaimRef == nil {
+			log.Warnf("Claimref is not available for pv %s", pv.Name)
+			continue
+		}
+		pvc, err := metadataSyncer.pvcLister.PersistentVolumeClaims(
+			pv.Spec.ClaimRef.Namespace).Get(pv.Spec.ClaimRef.Name)
+		if err != nil {
+			log.Warnf("Failed to get pvc for namespace %s and name %s. err=%+v",
+				pv.Spec.ClaimRef.Namespace, pv.Spec.ClaimRef.Name, err)
+			continue
+		}
+		if IsLinkedCloneSupportFSSEnabled && metav1.HasAnnotation(pvc.ObjectMeta, common.AnnKeyLinkedClone) {
+			isLinkedCloneVolume = true
+		}
+		pvcCapacity := pvc.Status.Capacity[v1.ResourceStorage]
+		if pvc.Spec.StorageClassName == nil {
+			log.Warnf("FullSync for VC %s: failed to create VolumeInfo CR for volume %s."+
+				"StorageClassName not found in the PVC spec %v.", vc, volumeID, pvc.Spec)
+			continue
+		}
+		if crExists {
+			// On multi VC deployments, the CRs of volumes created before storage
+			// policy quotas were enabled only hold the vCenter of the volume.
+			_, err = backfillVolumeInfoPolicyInfo(ctx, volumeID, pvc.Namespace,
+				scNameToPolicyIdMap[*pvc.Spec.StorageClassName], *pvc.Spec.StorageClassName, &pvcCapacity)
+			if err != nil {
+				log.Warnf("FullSync for VC %s: failed to update storage policy of VolumeInfo CR for volume %s."+
+					"Error: %+v", vc, volumeID, err)
+			}
+			continue
+		}
+		err = volumeInfoService.CreateVolumeInfoWithPolicyInfo(ctx, volumeID, pvc.Namespace,
+			scNameToPolicyIdMap[*pvc.Spec.StorageClassName], *pvc.Spec.StorageClassName, vc,
+			&pvcCapacity, isLinkedCloneVolume)
+		if err != nil {
+			log.Warnf("FullSync for VC %s: failed to create VolumeInfo CR for volume %s."+
+				"Error: %+v", vc, volumeID, err)
 		}
 	}
 	volumeInfoCRList := volumeInfoService.ListAllVolumeInfos()
@@ -890,6 +902,119 @@ func volumeInfoCRFullSync(ctx context.Context, metadataSyncer *metadataSyncInfor
 	log.Debugf("FullSync for VC %s: volumeInfoCrDeletionMap: %v", vc, volumeInfoCrDeletionMap)
 }
 
+// backfillVolumeInfoPolicyInfo sets the PVC namespace, storage policy,
+// StorageClass and capacity of a volume on its CNSVolumeInfo CR if the CR was
+// created without a storage policy. It returns true if the CR was updated.
+func backfillVolumeInfoPolicyInfo(ctx context.Context, volumeID, namespace, storagePolicyID,
+	storageClassName string, capacity *resource.Quantity) (bool, error) {
+	log := logger.GetLogger(ctx)
+	cnsVolumeInfo, err := volumeInfoService.GetVolumeInfoForVolumeID(ctx, volumeID)
+	if err != nil {
+		return false, err
+	}
+	if cnsVolumeInfo.Spec.StoragePolicyID != "" || storagePolicyID == "" {
+		return false, nil
+	}
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"namespace":        namespace,
+			"storagePolicyID":  storagePolicyID,
+			"storageClassName": storageClassName,
+			"capacity":         capacity,
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		return false, logger.LogNewErrorf(log, "failed to create patch for CNSVolumeInfo of volume %s. Err: %v",
+			volumeID, err)
+	}
+	err = volumeInfoService.PatchVolumeInfo(ctx, volumeID, patchBytes, allowedRetriesToPatchCNSVolumeInfo)
+	if err != nil {
+		return false, err
+	}
+	log.Infof("Set storage policy %q, StorageClass %q and capacity %s of volume %s in namespace %q "+
+		"on its CNSVolumeInfo CR", storagePolicyID, storageClassName, capacity.String(), volumeID, namespace)
+	return true, nil
+}
+
+// volumeInfoSnapshotSizeSync updates the aggregated snapshot capacity in the
+// CNSVolumeInfo CRs of the block volumes on the given vCenter with the capacity
+// reported by CNS. On vanilla clusters, snapshot operations do not update the
+// CNSVolumeInfo CRs, so this sync keeps the snapshot usage of
+// StoragePolicyUsage CRs up to date.
+func volumeInfoSnapshotSizeSync(ctx context.Context, metadataSyncer *metadataSyncInformer, vc string) {
+	log := logger.GetLogger(ctx)
+	log.Debugf("FullSync for VC %s: Starting volumeInfo snapshot size sync.", vc)
+	cnsVolumeInfoMap := make(map[string]*cnsvolumeinfov1alpha1.CNSVolumeInfo)
+	var volumeIds []cnstypes.CnsVolumeId
+	for _, volumeInfo := range volumeInfoService.ListAllVolumeInfos() {
+		cnsVolumeInfo := &cnsvolumeinfov1alpha1.CNSVolumeInfo{}
+		err := runtime.DefaultUnstructuredConverter.FromUnstructured(volumeInfo.(*unstructured.Unstructured).Object,
+			&cnsVolumeInfo)
+		if err != nil {
+			log.Errorf("FullSync for VC %s: failed to parse cnsvolumeinfo object: %v, err: %v", vc, volumeInfo, err)
+			continue
+		}
+		if cnsVolumeInfo.Spec.VCenterServer != vc || strings.HasPrefix(cnsVolumeInfo.Spec.VolumeID, FileVolumePrefix) {
+			continue
+		}
+		cnsVolumeInfoMap[cnsVolumeInfo.Spec.VolumeID] = cnsVolumeInfo
+		volumeIds = append(volumeIds, cnstypes.CnsVolumeId{Id: cnsVolumeInfo.Spec.VolumeID})
+	}
+	if len(volumeIds) == 0 {
+		return
+	}
+	volManager, err := getVolManagerForVcHost(ctx, vc, metadataSyncer)
+	if err != nil {
+		log.Errorf("FullSync for VC %s: failed to get volume manager. Err: %v", vc, err)
+		return
+	}
+	queryResults, err := fullSyncGetQueryResults(ctx, volumeIds, "", volManager, metadataSyncer)
+	if err != nil {
+		log.Errorf("FullSync for VC %s: failed to query volumes for snapshot size sync. Err: %v", vc, err)
+		return
+	}
+	for _, queryResult := range queryResults {
+		for _, volume := range queryResult.Volumes {
+			backingDetails, ok := volume.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails)
+			if !ok {
+				continue
+			}
+			cnsVolumeInfo, ok := cnsVolumeInfoMap[volume.VolumeId.Id]
+			if !ok {
+				continue
+			}
+			aggregatedSnapshotSize := resource.NewQuantity(
+				backingDetails.AggregatedSnapshotCapacityInMb*common.MbInBytes, resource.BinarySI)
+			if cnsVolumeInfo.Spec.ValidAggregatedSnapshotSize && cnsVolumeInfo.Spec.AggregatedSnapshotSize != nil &&
+				cnsVolumeInfo.Spec.AggregatedSnapshotSize.Cmp(*aggregatedSnapshotSize) == 0 {
+				continue
+			}
+			patch := map[string]interface{}{
+				"spec": map[string]interface{}{
+					"validaggregatedsnapshotsize": true,
+					"aggregatedsnapshotsize":      aggregatedSnapshotSize,
+				},
+			}
+			patchBytes, err := json.Marshal(patch)
+			if err != nil {
+				log.Errorf("FullSync for VC %s: failed to create patch for CNSVolumeInfo of volume %s. Err: %v",
+					vc, volume.VolumeId.Id, err)
+				continue
+			}
+			err = volumeInfoService.PatchVolumeInfo(ctx, volume.VolumeId.Id, patchBytes,
+				allowedRetriesToPatchCNSVolumeInfo)
+			if err != nil {
+				log.Errorf("FullSync for VC %s: failed to update aggregated snapshot size of volume %s. Err: %v",
+					vc, volume.VolumeId.Id, err)
+				continue
+			}
+			log.Infof("FullSync for VC %s: updated aggregated snapshot size of volume %s to %s",
+				vc, volume.VolumeId.Id, aggregatedSnapshotSize.String())
+		}
+	}
+}
+
 // validateAndCorrectVolumeInfoSnapshotDetails sync cnsvolumeinfo snapshot details with by comparing
 // the aggregatedSnapshotSize of CNS volume.
 // validate aggregated snapshot size: compare aggregated snapshot size of individual volume
@@ -1025,7 +1150,6 @@ func fullSyncCreateVolumes(ctx context.Context, createSpecArray []cnstypes.CnsVo
 			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsBlockBackingDetails).BackingDiskId
 		} else if createSpec.VolumeType == common.FileVolumeType && createSpec.BackingObjectDetails != nil &&
 			createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails) != nil {
-			// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
 			volumeID = createSpec.BackingObjectDetails.(*cnstypes.CnsVsanFileShareBackingDetails).BackingFileId
 		} else {
 			log.Warnf("Skipping createSpec: %+v as VolumeType is unknown or BackingObjectDetails is not valid",
@@ -1177,8 +1301,41 @@ func fullSyncUpdateVolumes(ctx context.Context, updateSpecArray []cnstypes.CnsVo
 	metadataSyncer *metadataSyncInformer, wg *sync.WaitGroup, volManager volumes.Manager,
 	vc string) {
 	defer wg.Done()
+	updateVolumesMetadataInBulk(ctx, volManager, vc, updateSpecArray)
+}
+
+// updateVolumesMetadataInBulk updates the metadata of the volumes with the
+// given specs through batched CNS calls. Volumes whose update failed in the
+// bulk operation are updated one at a time, so that volumes which are not
+// registered with CNS are re-registered by UpdateVolumeMetadata.
+func updateVolumesMetadataInBulk(ctx context.Context, volManager volumes.Manager, vc string,
+	updateSpecArray []cnstypes.CnsVolumeMetadataUpdateSpec) {
 	log := logger.GetLogger(ctx)
+	if len(updateSpecArray) == 0 {
+		return
+	}
+	log.Debugf("FullSync for VC %s: Calling bulk UpdateVolumeMetadata with updateSpecs: %+v",
+		vc, spew.Sdump(updateSpecArray))
+	results, err := volManager.ExecuteBulkOperation(ctx, &volumes.BulkOperationRequest{
+		OperationType:       volumes.BulkOperationUpdateVolumeMetadata,
+		MetadataUpdateSpecs: updateSpecArray,
+	})
+	if err == nil {
+		return
+	}
+	log.Warnf("FullSync for VC %s: bulk UpdateVolumeMetadata failed with err %v", vc, err)
+	failedVolumes := make(map[string]bool)
+	for _, result := range results {
+		if result.Error != nil {
+			failedVolumes[result.VolumeID] = true
+		}
+	}
 	for _, updateSpec := range updateSpecArray {
+		// All the volumes are retried if the bulk operation failed before
+		// returning a result for each volume.
+		if len(results) != 0 && !failedVolumes[updateSpec.VolumeId.Id] {
+			continue
+		}
 		log.Debugf("FullSync for VC %s: Calling UpdateVolumeMetadata for volume %s with updateSpec: %+v",
 			vc, updateSpec.VolumeId.Id, spew.Sdump(updateSpec))
 		if err := volManager.UpdateVolumeMetadata(ctx, &updateSpec); err != nil {
@@ -1373,7 +1530,6 @@ func fullSyncGetVolumeSpecs(ctx context.Context, vCenterVersion string, pvList [
 		case "createVolume":
 			var volumeType string
 			if IsFileVolume(pv) {
-				// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
 				volumeType = common.FileVolumeType
 			} else {
 				volumeType = common.BlockVolumeType
@@ -1406,7 +1562,6 @@ func fullSyncGetVolumeSpecs(ctx context.Context, vCenterVersion string, pvList [
 			log.Debugf("FullSync for VC %s: Volume with id %q added to volume update list", vc, volumeHandle)
 			var volumeType string
 			if IsFileVolume(pv) {
-				// We should never reach here in case of multi VC deployment as file share volumes are already filtered out.
 				volumeType = common.FileVolumeType
 			} else {
 				volumeType = common.BlockVolumeType
@@ -26,6 +26,7 @@ import (
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	"github.com/vmware/govmomi/vim25/types"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	k8sfake "k8s.io/client-go/kubernetes/fake"
@@ -33,6 +34,7 @@ import (
 	volumes "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/volume"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 )
 
 func TestSetFileShareAnnotationsOnPVC_Success(t *testing.T) {
@@ -459,3 +461,37 @@ func TestSetFileShareAnnotationsOnPVC_ExistingAnnotations(t *testing.T) {
 	assert.Equal(t, "192.168.1.100:/nfs/v3/path", pvc.Annotations[common.Nfsv3ExportPathAnnotationKey])
 	assert.Empty(t, pvc.Annotations[common.Nfsv4ExportPathAnnotationKey])
 }
+
+func TestBackfillVolumeInfoPolicyInfo(t *testing.T) {
+	ctx := context.Background()
+	origVolumeInfoService := volumeInfoService
+	defer func() {
+		volumeInfoService = origVolumeInfoService
+	}()
+	fakeService := &fakeVolumeInfoService{volumeInfos: map[string]*cnsvolumeinfov1alpha1.CNSVolumeInfo{}}
+	volumeInfoService = fakeService
+	capacity := resource.MustParse("1Gi")
+
+	// A CR created on a multi VC deployment only holds the vCenter of the volume.
+	assert.NoError(t, fakeService.CreateVolumeInfo(ctx, "vol-1", "vc-1"))
+	updated, err := backfillVolumeInfoPolicyInfo(ctx, "vol-1", "ns-1", "policy-1", "sc-1", &capacity)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	spec := fakeService.volumeInfos["vol-1"].Spec
+	assert.Equal(t, "vc-1", spec.VCenterServer)
+	assert.Equal(t, "ns-1", spec.Namespace)
+	assert.Equal(t, "policy-1", spec.StoragePolicyID)
+	assert.Equal(t, "sc-1", spec.StorageClassName)
+	if assert.NotNil(t, spec.Capacity) {
+		assert.Equal(t, 0, spec.Capacity.Cmp(capacity))
+	}
+
+	// The CR already holds a storage policy, so its capacity is already counted.
+	updated, err = backfillVolumeInfoPolicyInfo(ctx, "vol-1", "ns-2", "policy-2", "sc-2", &capacity)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.Equal(t, "policy-1", fakeService.volumeInfos["vol-1"].Spec.StoragePolicyID)
+
+	_, err = backfillVolumeInfoPolicyInfo(ctx, "vol-2", "ns-1", "policy-1", "sc-1", &capacity)
+	assert.Error(t, err)
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -56,7 +57,10 @@ const (
 	// AntiAffinityRequired placement policy for storagepool.
 	spPolicyAntiRequired = "placement.beta.vmware.com/storagepool_antiAffinityRequired"
 	// StoragePool type name for vsan-direct.
-	vsanDirect                = "vsanD"
+	vsanDirect = "vsanD"
+	// anySPType is used as the StoragePool type for placements which are not
+	// restricted to vSAN Direct or vSAN SNA StoragePools.
+	anySPType                 = ""
 	invalidParamsErr          = "FAILED_PLACEMENT-InvalidParams"
 	genericErr                = "FAILED_PLACEMENT-Generic"
 	notEnoughResErr           = "FAILED_PLACEMENT-NotEnoughResources"
@@ -124,15 +128,19 @@ type relaxedFitMigrationPlanner struct {
 	storagePoolList v1alpha1.StoragePoolList
 	pvcList         []v1.PersistentVolumeClaim
 	sourceHostNames []string
+	// spType restricts the target StoragePools. When set to anySPType, target
+	// StoragePools of any type accessible from all source hosts are considered.
+	spType string
 }
 
 func newRelaxedFitMigrationPlanner(volumeList []VolumeInfo, spList v1alpha1.StoragePoolList,
-	allPVCList []v1.PersistentVolumeClaim, accessibleNodeNames []string) migrationPlanner {
+	allPVCList []v1.PersistentVolumeClaim, accessibleNodeNames []string, spType string) migrationPlanner {
 	return relaxedFitMigrationPlanner{
 		volumeList:      volumeList,
 		storagePoolList: spList,
 		pvcList:         allPVCList,
 		sourceHostNames: accessibleNodeNames,
+		spType:          spType,
 	}
 }
 
@@ -153,7 +161,7 @@ func (b relaxedFitMigrationPlanner) getMigrationPlan(ctx context.Context,
 		pvcName := vol.PVC.Name
 
 		assignedSp, err := getSPForPVCPlacement(ctx, client, &vol.PVC, vol.SizeInBytes, b.storagePoolList,
-			b.sourceHostNames, b.pvcList, vsanDirectType, false)
+			b.sourceHostNames, b.pvcList, b.spType, false)
 		if err != nil {
 			log.Errorf("Failed to assign SP to PVC %v. Error: %v", pvcName, err)
 			return nil, fmt.Errorf("PVC %v could not be migrated due to placement constraints "+
@@ -184,6 +192,9 @@ func (b relaxedFitMigrationPlanner) getMigrationPlan(ctx context.Context,
 		for index, pvc := range b.pvcList {
 			if pvc.Name == pvcName {
 				curAnnotations := pvc.GetAnnotations()
+				if curAnnotations == nil {
+					curAnnotations = make(map[string]string)
+				}
 				curAnnotations[StoragePoolAnnotationKey] = assignedSPName
 				b.pvcList[index].SetAnnotations(curAnnotations)
 				break
@@ -310,7 +321,8 @@ func GetSVMotionPlan(ctx context.Context, client kubernetes.Interface,
 	}
 
 	// For each volume assign a target sp for storage vMotion.
-	rfMigrationPlanner := newRelaxedFitMigrationPlanner(volumeInfoList, *spList, allPVCList, accessibleNodes)
+	rfMigrationPlanner := newRelaxedFitMigrationPlanner(volumeInfoList, *spList, allPVCList, accessibleNodes,
+		vsanDirectType)
 	volumesToSPMap, err = rfMigrationPlanner.getMigrationPlan(ctx, client)
 
 	if err != nil {
@@ -319,6 +331,57 @@ func GetSVMotionPlan(ctx context.Context, client kubernetes.Interface,
 	return volumesToSPMap, nil
 }
 
+// GetDatastoreDrainPlan maps each of the given volumes residing on the
+// datastore backing the given StoragePool to another StoragePool to migrate
+// into. Unlike GetSVMotionPlan, the source and target StoragePools can be of
+// any datastore type (VMFS, NFS, vSAN, vSAN Direct). Target StoragePools must
+// be compatible with the StorageClass of the volume and accessible from every
+// node the source datastore is accessible from, so that the node affinity of
+// the PVs stays valid after migration.
+func GetDatastoreDrainPlan(ctx context.Context, client kubernetes.Interface,
+	storagePoolName string, volumeInfoList []VolumeInfo) (map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	if len(volumeInfoList) == 0 {
+		log.Infof("No volume present in StoragePool %v to migrate.", storagePoolName)
+		return make(map[string]string), nil
+	}
+
+	spList, err := getStoragePoolList(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get StoragePools list. Error: %v", err)
+	}
+
+	var sourceSP *v1alpha1.StoragePool
+	targetSPList := v1alpha1.StoragePoolList{}
+	for i, sp := range spList.Items {
+		if sp.GetName() == storagePoolName {
+			sourceSP = &spList.Items[i]
+			continue
+		}
+		targetSPList.Items = append(targetSPList.Items, sp)
+	}
+	if sourceSP == nil {
+		return nil, fmt.Errorf("failed to find source StoragePool with name %v", storagePoolName)
+	}
+	if len(targetSPList.Items) == 0 {
+		return nil, fmt.Errorf("could not find any StoragePool to migrate volumes")
+	}
+	accessibleNodes := sourceSP.Status.AccessibleNodes
+	if len(accessibleNodes) == 0 {
+		return nil, fmt.Errorf("the given datastore/StoragePool is not accessible from any host. " +
+			"Maybe its unmounted or host is under maintenance mode")
+	}
+
+	pvcList, err := client.CoreV1().PersistentVolumeClaims(v1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch PVC from all namespaces. Error: %v", err)
+	}
+
+	rfMigrationPlanner := newRelaxedFitMigrationPlanner(volumeInfoList, targetSPList, pvcList.Items,
+		accessibleNodes, anySPType)
+	return rfMigrationPlanner.getMigrationPlan(ctx, client)
+}
+
 func getSPForPVCPlacement(ctx context.Context,
 	client kubernetes.Interface,
 	curPVC *v1.PersistentVolumeClaim,
@@ -342,7 +405,7 @@ func getSPForPVCPlacement(ctx context.Context,
 		return assignedSP, err
 	}
 
-	spList, err := preFilterSPList(ctx, sps, scName, hostNames, volSizeBytes)
+	spList, err := preFilterSPList(ctx, sps, scName, hostNames, volSizeBytes, spType)
 	if err != nil {
 		log.Infof("preFilterSPList failed with %+v", err)
 		if onlinePlacement {
@@ -728,7 +791,7 @@ func PlacePVConStoragePool(ctx context.Context, client kubernetes.Interface,
 }
 
 // getStoragePoolList get all storage pool list.
-func getStoragePoolList(ctx context.Context) (*v1alpha1.StoragePoolList, error) {
+var getStoragePoolList = func(ctx context.Context) (*v1alpha1.StoragePoolList, error) {
 	log := logger.GetLogger(ctx)
 
 	cfg, err := clientconfig.GetConfig()
@@ -749,11 +812,12 @@ func getStoragePoolList(ctx context.Context) (*v1alpha1.StoragePoolList, error)
 }
 
 // preFilterSPList filter out candidate storage pool list through topology and
-// capacity.
+// capacity. For anySPType, storage pools of every type are considered but
+// they must be accessible from all the given hosts.
 // XXX TODO Add health of storage pools together as a filter when related
 // metrics available.
 func preFilterSPList(ctx context.Context, sps v1alpha1.StoragePoolList,
-	storageClassName string, hostNames []string, volSizeBytes int64) ([]StoragePoolInfo, error) {
+	storageClassName string, hostNames []string, volSizeBytes int64, spType string) ([]StoragePoolInfo, error) {
 	log := logger.GetLogger(ctx)
 	spList := make([]StoragePoolInfo, 0)
 
@@ -767,8 +831,8 @@ func preFilterSPList(ctx context.Context, sps v1alpha1.StoragePoolList,
 
 	for _, sp := range sps.Items {
 		spName := sp.GetName()
-		spType, found := sp.Labels[spTypeLabelKey]
-		if !found || (spType != vsanDirect && spType != vsanSna) {
+		poolType, found := sp.Labels[spTypeLabelKey]
+		if spType != anySPType && (!found || (poolType != vsanDirect && poolType != vsanSna)) {
 			nonVsanDirectOrSna++
 			continue
 		}
@@ -790,7 +854,8 @@ func preFilterSPList(ctx context.Context, sps v1alpha1.StoragePoolList,
 			continue
 		}
 
-		if !isStoragePoolAccessibleByNodes(ctx, sp, hostNames) {
+		if !isStoragePoolAccessibleByNodes(ctx, sp, hostNames) ||
+			(spType == anySPType && !isStoragePoolAccessibleByAllNodes(sp, hostNames)) {
 			topology++
 			continue
 		}
@@ -860,6 +925,18 @@ func isStoragePoolAccessibleByNodes(ctx context.Context, sp v1alpha1.StoragePool
 	return false
 }
 
+// isStoragePoolAccessibleByAllNodes checks if every given node can access the
+// storage pool. Volumes relocated to such a storage pool stay accessible from
+// the nodes allowed by the node affinity of their PV.
+func isStoragePoolAccessibleByAllNodes(sp v1alpha1.StoragePool, hostNames []string) bool {
+	for _, host := range hostNames {
+		if !slices.Contains(sp.Status.AccessibleNodes, host) {
+			return false
+		}
+	}
+	return true
+}
+
 // Remove the sp from the given list.
 func removeSPFromList(spList []StoragePoolInfo, spName string) []StoragePoolInfo {
 	for i, sp := range spList {
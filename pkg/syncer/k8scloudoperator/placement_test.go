@@ -0,0 +1,152 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package k8scloudoperator
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/storagepool/cns/v1alpha1"
+)
+
+const testStorageClassName = "test-sc"
+
+// newTestStoragePool returns a healthy StoragePool of the given type which is
+// compatible with testStorageClassName.
+func newTestStoragePool(name, poolType string, allocatable string, nodes ...string) v1alpha1.StoragePool {
+	allocatableSpace := resource.MustParse(allocatable)
+	return v1alpha1.StoragePool{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{spTypeLabelKey: poolType},
+		},
+		Status: v1alpha1.StoragePoolStatus{
+			AccessibleNodes:          nodes,
+			CompatibleStorageClasses: []string{testStorageClassName},
+			Capacity:                 &v1alpha1.PoolCapacity{AllocatableSpace: &allocatableSpace},
+		},
+	}
+}
+
+func newTestVolumeInfo(pvcName, pvName string, sizeInBytes int64) VolumeInfo {
+	scName := testStorageClassName
+	return VolumeInfo{
+		PVC: v1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{Name: pvcName, Namespace: "test-ns"},
+			Spec:       v1.PersistentVolumeClaimSpec{StorageClassName: &scName},
+			Status:     v1.PersistentVolumeClaimStatus{Phase: v1.ClaimBound},
+		},
+		PVName:      pvName,
+		SizeInBytes: sizeInBytes,
+	}
+}
+
+func TestIsStoragePoolAccessibleByAllNodes(t *testing.T) {
+	sp := newTestStoragePool("sp", "vmfs", "10Gi", "node-1", "node-2")
+	assert.True(t, isStoragePoolAccessibleByAllNodes(sp, []string{"node-1", "node-2"}))
+	assert.True(t, isStoragePoolAccessibleByAllNodes(sp, []string{"node-2"}))
+	assert.False(t, isStoragePoolAccessibleByAllNodes(sp, []string{"node-1", "node-3"}))
+}
+
+func TestPreFilterSPList(t *testing.T) {
+	ctx := context.Background()
+	unhealthySP := newTestStoragePool("unhealthy", "vmfs", "100Gi", "node-1", "node-2")
+	unhealthySP.Status.Error = &v1alpha1.StoragePoolError{Message: "datastore inaccessible"}
+	incompatibleSP := newTestStoragePool("incompatible", "vmfs", "100Gi", "node-1", "node-2")
+	incompatibleSP.Status.CompatibleStorageClasses = []string{"other-sc"}
+	sps := v1alpha1.StoragePoolList{
+		Items: []v1alpha1.StoragePool{
+			newTestStoragePool("vsand-node-1", vsanDirect, "100Gi", "node-1"),
+			newTestStoragePool("vmfs-shared", "vmfs", "100Gi", "node-1", "node-2"),
+			newTestStoragePool("nfs-node-2", "nfs", "100Gi", "node-2"),
+			newTestStoragePool("vmfs-small", "vmfs", "1Gi", "node-1", "node-2"),
+			unhealthySP,
+			incompatibleSP,
+		},
+	}
+	hostNames := []string{"node-1", "node-2"}
+	volSizeBytes := int64(5 * 1024 * 1024 * 1024)
+
+	getNames := func(spList []StoragePoolInfo) []string {
+		names := make([]string, 0, len(spList))
+		for _, sp := range spList {
+			names = append(names, sp.Name)
+		}
+		return names
+	}
+
+	// vSAN Direct placements only consider vSAN Direct and vSAN SNA pools
+	// accessible from any of the hosts.
+	spList, err := preFilterSPList(ctx, sps, testStorageClassName, hostNames, volSizeBytes, vsanDirectType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vsand-node-1"}, getNames(spList))
+
+	// Drain placements consider pools of every type, but only those accessible
+	// from all the hosts.
+	spList, err = preFilterSPList(ctx, sps, testStorageClassName, hostNames, volSizeBytes, anySPType)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"vmfs-shared"}, getNames(spList))
+}
+
+func TestGetDatastoreDrainPlan(t *testing.T) {
+	ctx := context.Background()
+	origGetStoragePoolList := getStoragePoolList
+	defer func() {
+		getStoragePoolList = origGetStoragePoolList
+	}()
+	spList := &v1alpha1.StoragePoolList{
+		Items: []v1alpha1.StoragePool{
+			newTestStoragePool("source", "vmfs", "100Gi", "node-1", "node-2"),
+			newTestStoragePool("target-partial", "nfs", "500Gi", "node-1"),
+			newTestStoragePool("target-a", "vsan", "12Gi", "node-1", "node-2"),
+			newTestStoragePool("target-b", "vmfs", "8Gi", "node-1", "node-2", "node-3"),
+		},
+	}
+	getStoragePoolList = func(ctx context.Context) (*v1alpha1.StoragePoolList, error) {
+		return spList.DeepCopy(), nil
+	}
+	volumeInfoList := []VolumeInfo{
+		newTestVolumeInfo("pvc-small", "pv-small", 3*1024*1024*1024),
+		newTestVolumeInfo("pvc-large", "pv-large", 6*1024*1024*1024),
+	}
+	client := testclient.NewClientset(&volumeInfoList[0].PVC, &volumeInfoList[1].PVC)
+
+	// Volumes are placed largest first on the pool with the most free space,
+	// which is then updated for the next placement. The pool not accessible
+	// from all source nodes is never used.
+	plan, err := GetDatastoreDrainPlan(ctx, client, "source", volumeInfoList)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"pv-large": "target-a", "pv-small": "target-b"}, plan)
+
+	plan, err = GetDatastoreDrainPlan(ctx, client, "source", nil)
+	assert.NoError(t, err)
+	assert.Empty(t, plan)
+
+	_, err = GetDatastoreDrainPlan(ctx, client, "unknown", volumeInfoList)
+	assert.Error(t, err)
+
+	// No target pool has enough capacity for the volume.
+	_, err = GetDatastoreDrainPlan(ctx, client, "source",
+		[]VolumeInfo{newTestVolumeInfo("pvc-huge", "pv-huge", 20*1024*1024*1024)})
+	assert.Error(t, err)
+}
@@ -115,6 +115,8 @@ var (
 	nodeMgr node.Manager
 	// IsPodVMOnStretchSupervisorFSSEnabled is true when PodVMOnStretchedSupervisor FSS is enabled.
 	IsPodVMOnStretchSupervisorFSSEnabled bool
+	// IsVanillaStoragePolicyQuotaFSSEnabled is true when vanilla-storage-policy-quota FSS is enabled.
+	IsVanillaStoragePolicyQuotaFSSEnabled bool
 	// IsLinkedCloneSupportFSSEnabled is true when linked-clone-support FSS is enabled.
 	IsLinkedCloneSupportFSSEnabled bool
 	// IsCSITransactionSupportEnabled is true when csi-transaction-support FSS is enabled.
@@ -228,6 +230,30 @@ func getPVtoBackingDiskObjectIdIntervalInMin(ctx context.Context) int {
 	return pvtoBackingDiskObjectIdIntervalInMin
 }
 
+// getFileVolumeUsageIntervalInMin returns the file volume usage interval.
+// If environment variable FILE_VOLUME_USAGE_INTERVAL_MINUTES is set and valid,
+// return the interval value read from environment variable.
+// Otherwise, use the default value 5 minutes.
+func getFileVolumeUsageIntervalInMin(ctx context.Context) int {
+	log := logger.GetLogger(ctx)
+	fileVolumeUsageIntervalInMin := defaultFileVolumeUsageIntervalInMin
+	if v := os.Getenv("FILE_VOLUME_USAGE_INTERVAL_MINUTES"); v != "" {
+		if value, err := strconv.Atoi(v); err == nil {
+			if value <= 0 {
+				log.Warnf("FileVolumeUsage: FileVolumeUsage interval set in env variable "+
+					"FILE_VOLUME_USAGE_INTERVAL_MINUTES %s is equal or less than 0, will use the default interval", v)
+			} else {
+				fileVolumeUsageIntervalInMin = value
+				log.Infof("FileVolumeUsage: FileVolumeUsage interval is set to %d minutes", fileVolumeUsageIntervalInMin)
+			}
+		} else {
+			log.Warnf("FileVolumeUsage: FileVolumeUsage interval set in env variable "+
+				"FILE_VOLUME_USAGE_INTERVAL_MINUTES %s is invalid, will use the default interval", v)
+		}
+	}
+	return fileVolumeUsageIntervalInMin
+}
+
 // InitMetadataSyncer initializes the Metadata Sync Informer.
 func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFlavor,
 	configInfo *cnsconfig.ConfigurationInfo) error {
@@ -238,6 +264,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 	metadataSyncer := newInformer()
 	MetadataSyncer = metadataSyncer
 	metadataSyncer.configInfo = configInfo
+	volumes.SetBulkOperationConcurrency(configInfo.Cfg.Global.BulkOperationConcurrency)
 
 	if clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
 		IsMigrationEnabled = commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.CSIMigration)
@@ -437,6 +464,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		volumeInfoCrDeletionMap[metadataSyncer.host] = make(map[string]bool)
 		volumeOperationsLock[metadataSyncer.host] = &sync.Mutex{}
 
+		volumes.SetOperationTimeouts(ctx, configInfo.Cfg.VolumeOperationTimeout)
 		volumeManager, err := volumes.GetManager(ctx, vCenter,
 			nil, false, false, false,
 			metadataSyncer.clusterFlavor, configInfo.Cfg.Global.SupervisorID, configInfo.Cfg.Global.ClusterDistribution)
@@ -488,6 +516,14 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 				}
 			}
 		}
+		if volumeInfoService == nil && metadataSyncer.coCommonInterface.IsFSSEnabled(ctx,
+			common.VolumeHealthHistory) {
+			// CNSVolumeInfo CRs hold the health history of volumes.
+			volumeInfoService, err = cnsvolumeinfo.InitVolumeInfoService(ctx)
+			if err != nil {
+				return logger.LogNewErrorf(log, "error initializing volumeInfoService. Error: %+v", err)
+			}
+		}
 
 	} else {
 		// code block only applicable to Vanilla
@@ -501,6 +537,7 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		if len(vcconfigs) > 1 {
 			multivCenterTopologyDeployment = true
 		}
+		volumes.SetOperationTimeouts(ctx, configInfo.Cfg.VolumeOperationTimeout)
 		for _, vcconfig := range vcconfigs {
 			vcconfig.ReloadVCConfigForNewClient = true
 			vCenter, err := cnsvsphere.GetVirtualCenterInstanceForVCenterConfig(ctx, vcconfig, false)
@@ -522,8 +559,12 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 			volumeInfoCrDeletionMap[vcconfig.Host] = make(map[string]bool)
 			volumeOperationsLock[vcconfig.Host] = &sync.Mutex{}
 		}
-		// If it is a multi VC deployment, initialize volumeInfoService
-		if len(vcconfigs) > 1 && volumeInfoService == nil {
+		IsVanillaStoragePolicyQuotaFSSEnabled = commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+			common.VanillaStoragePolicyQuota)
+		// If it is a multi VC deployment, or storage policy quotas are enabled,
+		// initialize volumeInfoService. CNSVolumeInfo CRs hold the storage policy
+		// and snapshot capacity used to compute StoragePolicyUsage.
+		if (len(vcconfigs) > 1 || IsVanillaStoragePolicyQuotaFSSEnabled) && volumeInfoService == nil {
 			volumeInfoService, err = cnsvolumeinfo.InitVolumeInfoService(ctx)
 			if err != nil {
 				return logger.LogNewErrorf(log, "error initializing volumeInfoService. Error: %+v", err)
@@ -844,6 +885,28 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 		}
 	}
 
+	// Trigger get file volume usage on vanilla cluster.
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla &&
+		metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.FileVolumeUsage) {
+		fileVolumeUsageTicker := time.NewTicker(time.Duration(
+			getFileVolumeUsageIntervalInMin(ctx)) * time.Minute)
+		defer fileVolumeUsageTicker.Stop()
+		go func() {
+			for ; true; <-fileVolumeUsageTicker.C {
+				ctx, log := logger.GetNewContextWithLogger()
+				log.Info("get file volume usage is triggered")
+				vcconfigs, err := cnsvsphere.GetVirtualCenterConfigs(ctx, configInfo.Cfg)
+				if err != nil {
+					log.Errorf("failed to get VirtualCenterConfigs. err: %v", err)
+					continue
+				}
+				for _, vcconfig := range vcconfigs {
+					csiGetFileVolumeUsage(ctx, k8sClient, metadataSyncer, vcconfig.Host)
+				}
+			}
+		}()
+	}
+
 	volumeHealthTicker := time.NewTicker(time.Duration(getVolumeHealthIntervalInMin(ctx)) * time.Minute)
 	defer volumeHealthTicker.Stop()
 
@@ -884,6 +947,23 @@ func InitMetadataSyncer(ctx context.Context, clusterFlavor cnstypes.CnsClusterFl
 			}
 		}
 	}
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla && IsVanillaStoragePolicyQuotaFSSEnabled {
+		// Trigger StoragePolicyQuota reconciler to handle add/delete event on StoragePolicyQuota.
+		storageQuotaEnablementTicker := time.NewTicker(common.DefaultFeatureEnablementCheckInterval)
+		defer storageQuotaEnablementTicker.Stop()
+		go func() {
+			for ; true; <-storageQuotaEnablementTicker.C {
+				ctx, log = logger.GetNewContextWithLogger()
+				if err := initStoragePolicyQuotaReconciler(ctx, metadataSyncer); err != nil {
+					log.Warnf("Error while initializing StoragePolicyQuota reconciler. Err:%+v. "+
+						"Retry will be triggered at %v",
+						err, time.Now().Add(common.DefaultFeatureEnablementCheckInterval))
+					continue
+				}
+				break
+			}
+		}()
+	}
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorGuest {
 		volumeHealthEnablementTicker := time.NewTicker(common.DefaultFeatureEnablementCheckInterval)
 		defer volumeHealthEnablementTicker.Stop()
@@ -2369,6 +2449,7 @@ func ReloadConfiguration(metadataSyncer *metadataSyncInformer, reconnectToVCFrom
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to read config. Error: %+v", err)
 	}
+	volumes.SetBulkOperationConcurrency(cfg.Global.BulkOperationConcurrency)
 	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorWorkload {
 		if !cfg.Global.InsecureFlag && cfg.Global.CAFile != cnsconfig.SupervisorCAFilePath {
 			log.Warnf("Invalid CA file: %q is set in the vSphere Config Secret. "+
@@ -2427,6 +2508,7 @@ func ReloadConfiguration(metadataSyncer *metadataSyncInformer, reconnectToVCFrom
 				}
 			}
 			if cfg != nil {
+				volumes.SetOperationTimeouts(ctx, cfg.VolumeOperationTimeout)
 				metadataSyncer.configInfo = &cnsconfig.ConfigurationInfo{Cfg: cfg}
 				log.Infof("updated metadataSyncer.configInfo")
 			}
@@ -2487,6 +2569,7 @@ func ReloadConfiguration(metadataSyncer *metadataSyncInformer, reconnectToVCFrom
 			metadataSyncer.host = newVCConfig.Host
 		}
 		if cfg != nil {
+			volumes.SetOperationTimeouts(ctx, cfg.VolumeOperationTimeout)
 			metadataSyncer.configInfo = &cnsconfig.ConfigurationInfo{Cfg: cfg}
 			log.Infof("updated metadataSyncer.configInfo")
 		}
@@ -2584,7 +2667,7 @@ func pvcUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer
 			return
 		}
 		// Verify if pv is vsphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name || isNFSSubdirVolume(pv) {
 			log.Debugf("PVCUpdated: Not a vSphere CSI Volume")
 			return
 		}
@@ -2648,7 +2731,7 @@ func pvcDeleted(obj interface{}, metadataSyncer *metadataSyncInformer) {
 			return
 		}
 		// Verify if pv is vSphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name || isNFSSubdirVolume(pv) {
 			log.Debugf("PVCDeleted: Not a vSphere CSI Volume")
 			return
 		}
@@ -2663,6 +2746,16 @@ func pvcDeleted(obj interface{}, metadataSyncer *metadataSyncInformer) {
 
 // pvAdded updates the PV labels with linkedclone's volumesnapshot uuid
 func pvAdded(obj interface{}, metadataSyncer *metadataSyncInformer) {
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla && IsVanillaStoragePolicyQuotaFSSEnabled {
+		ctx, log := logger.GetNewContextWithLogger()
+		pv, ok := obj.(*v1.PersistentVolume)
+		if pv == nil || !ok {
+			log.Warnf("pvAdded: unrecognized object %+v", obj)
+			return
+		}
+		storagePolicyUsagePVAdded(ctx, pv, metadataSyncer)
+		return
+	}
 	if !IsLinkedCloneSupportFSSEnabled {
 		return
 	}
@@ -2696,6 +2789,121 @@ func pvAdded(obj interface{}, metadataSyncer *metadataSyncInformer) {
 	}
 }
 
+// storagePolicyUsagePVAdded records the storage policy of a new PV in its
+// CNSVolumeInfo CR and adds the capacity of the PV to the used capacity of the
+// StoragePolicyUsage of its StorageClass. The PVC webhook then accounts for the
+// volume right after it is provisioned, instead of after the next full sync.
+// Volumes whose CNSVolumeInfo CR already holds a storage policy are skipped,
+// as their capacity is already counted.
+func storagePolicyUsagePVAdded(ctx context.Context, pv *v1.PersistentVolume, metadataSyncer *metadataSyncInformer) {
+	log := logger.GetLogger(ctx)
+	if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name || isNFSSubdirVolume(pv) ||
+		pv.Spec.ClaimRef == nil || pv.Spec.StorageClassName == "" || volumeInfoService == nil {
+		return
+	}
+	volumeID := pv.Spec.CSI.VolumeHandle
+	namespace := pv.Spec.ClaimRef.Namespace
+	capacity := pv.Spec.Capacity[v1.ResourceStorage]
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to create kubernetes client. Err: %v", err)
+		return
+	}
+	sc, err := k8sClient.StorageV1().StorageClasses().Get(ctx, pv.Spec.StorageClassName, metav1.GetOptions{})
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to get StorageClass %q of PV %q. Err: %v",
+			pv.Spec.StorageClassName, pv.Name, err)
+		return
+	}
+	storagePolicyID := getStoragePolicyIDForStorageClass(ctx, metadataSyncer, sc)
+	if storagePolicyID == "" {
+		log.Debugf("storagePolicyUsagePVAdded: no storage policy found for StorageClass %q of PV %q",
+			sc.Name, pv.Name)
+		return
+	}
+	crExists, err := volumeInfoService.VolumeInfoCrExistsForVolume(ctx, volumeID)
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to find VolumeInfo CR for volume %s. Err: %v", volumeID, err)
+		return
+	}
+	if crExists {
+		// On multi VC deployments, the controller creates the CR of the volume
+		// without a storage policy.
+		updated, err := backfillVolumeInfoPolicyInfo(ctx, volumeID, namespace, storagePolicyID, sc.Name,
+			&capacity)
+		if err != nil {
+			log.Errorf("storagePolicyUsagePVAdded: failed to update VolumeInfo CR for volume %s. Err: %v",
+				volumeID, err)
+			return
+		}
+		if !updated {
+			return
+		}
+	} else {
+		vcHost, _, err := getVcHostAndVolumeManagerForVolumeID(ctx, metadataSyncer, volumeID)
+		if err != nil {
+			log.Errorf("storagePolicyUsagePVAdded: failed to get vCenter of volume %s. Err: %v", volumeID, err)
+			return
+		}
+		isLinkedCloneVolume := IsLinkedCloneSupportFSSEnabled &&
+			pv.Spec.CSI.VolumeAttributes[common.VolumeContextAttributeLinkedCloneVolumeSnapshotSourceUID] != ""
+		err = volumeInfoService.CreateVolumeInfoWithPolicyInfo(ctx, volumeID, namespace, storagePolicyID,
+			sc.Name, vcHost, &capacity, isLinkedCloneVolume)
+		if err != nil {
+			log.Errorf("storagePolicyUsagePVAdded: failed to create VolumeInfo CR for volume %s. Err: %v",
+				volumeID, err)
+			return
+		}
+	}
+
+	restConfig, err := k8s.GetKubeConfig(ctx)
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to get KubeConfig. Err: %v", err)
+		return
+	}
+	cnsOperatorClient, err := k8s.NewClientForGroup(ctx, restConfig, cnsoperatorv1alpha1.GroupName)
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to create CnsOperator client. Err: %v", err)
+		return
+	}
+	storagePolicyUsageCR := &storagepolicyv1alpha2.StoragePolicyUsage{}
+	err = cnsOperatorClient.Get(ctx, k8stypes.NamespacedName{
+		Namespace: namespace,
+		Name:      sc.Name + "-" + storagepolicyv1alpha2.NameSuffixForPVC},
+		storagePolicyUsageCR)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// No StoragePolicyQuota is set for the storage policy in the namespace.
+			log.Debugf("storagePolicyUsagePVAdded: no %s found for StorageClass %q in namespace %q",
+				storagepolicyv1alpha2.CRDSingular, sc.Name, namespace)
+			return
+		}
+		log.Errorf("storagePolicyUsagePVAdded: failed to get %s for StorageClass %q in namespace %q. Err: %v",
+			storagepolicyv1alpha2.CRDSingular, sc.Name, namespace, err)
+		return
+	}
+	patchedStoragePolicyUsageCR := storagePolicyUsageCR.DeepCopy()
+	if patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage != nil &&
+		patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage.Used != nil {
+		patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage.Used.Add(capacity)
+	} else {
+		usedQty := capacity.DeepCopy()
+		if patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage == nil {
+			patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage = &storagepolicyv1alpha2.QuotaUsageDetails{}
+		}
+		patchedStoragePolicyUsageCR.Status.ResourceTypeLevelQuotaUsage.Used = &usedQty
+	}
+	err = PatchStoragePolicyUsage(ctx, cnsOperatorClient, storagePolicyUsageCR, patchedStoragePolicyUsageCR)
+	if err != nil {
+		log.Errorf("storagePolicyUsagePVAdded: failed to add capacity %s of volume %s to %s %q in namespace %q. "+
+			"Err: %v", capacity.String(), volumeID, storagepolicyv1alpha2.CRDSingular, storagePolicyUsageCR.Name,
+			namespace, err)
+		return
+	}
+	log.Infof("storagePolicyUsagePVAdded: added capacity %s of volume %s to %s %q in namespace %q",
+		capacity.String(), volumeID, storagepolicyv1alpha2.CRDSingular, storagePolicyUsageCR.Name, namespace)
+}
+
 // pvUpdated updates volume metadata on VC when volume labels on K8S cluster
 // have been updated.
 func pvUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer) {
@@ -2761,7 +2969,7 @@ func pvUpdated(oldObj, newObj interface{}, metadataSyncer *metadataSyncInformer)
 			return
 		}
 		// Verify if pv is a vSphere csi volume.
-		if newPv.Spec.CSI == nil || newPv.Spec.CSI.Driver != csitypes.Name {
+		if newPv.Spec.CSI == nil || newPv.Spec.CSI.Driver != csitypes.Name || isNFSSubdirVolume(newPv) {
 			log.Debugf("PVUpdated: PV is not a vSphere CSI Volume: %+v", newPv)
 			return
 		}
@@ -2822,7 +3030,7 @@ func pvDeleted(obj interface{}, metadataSyncer *metadataSyncInformer) {
 			return
 		}
 		// Verify if pv is a vSphere csi volume.
-		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.Driver != csitypes.Name || isNFSSubdirVolume(pv) {
 			log.Debugf("PVDeleted: Not a vSphere CSI Volume. PV: %+v", pv)
 			return
 		}
@@ -3767,10 +3975,13 @@ func initResizeReconciler(ctx context.Context, tkgClient clientset.Interface,
 	// TODO: Refactor the code to use existing NewInformer function to get informerFactory
 	// https://github.com/kubernetes-sigs/vsphere-csi-driver/issues/585
 	informerFactory := informers.NewSharedInformerFactory(tkgClient, resizeResyncPeriod)
+	svcInformerFactory := informers.NewSharedInformerFactoryWithOptions(supervisorClient,
+		resizeResyncPeriod, informers.WithNamespace(supervisorNamespace))
 
 	rc, err := newResizeReconciler(tkgClient, supervisorClient, supervisorNamespace,
-		resizeResyncPeriod, informerFactory, workqueue.NewTypedItemExponentialFailureRateLimiter[any](
-			resizeRetryIntervalStart, resizeRetryIntervalMax), stopCh)
+		resizeResyncPeriod, informerFactory, svcInformerFactory,
+		workqueue.NewTypedItemExponentialFailureRateLimiter[any](resizeRetryIntervalStart, resizeRetryIntervalMax),
+		stopCh)
 	if err != nil {
 		return err
 	}
@@ -3859,13 +4070,13 @@ func getOrCreateStoragePolicyUsageCR(ctx context.Context, storagePolicyId string
 		log.Errorf("getOrCreateStoragePolicyUsageCR: Failed to list storageclasses. Err: %+v", err)
 		return nil, err
 	}
-	isStorageQuotaM2Enabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StorageQuotaM2)
+	isStorageQuotaM2Enabled := isSnapshotQuotaEnabled(ctx, metadataSyncer)
 	usageCR := &storagepolicyv1alpha2.StoragePolicyUsage{}
 	// For each storage class associated with storage policy id of StoragePolicyQuota CR,
 	// check if StoragePolicyUsage CR with resource type PVC or Snapshot exists.
 	// If not, create one with all parameters specified.
 	for _, sc := range storageClassList.Items {
-		if sc.Parameters[scParamStoragePolicyID] == storagePolicyId {
+		if getStoragePolicyIDForStorageClass(ctx, metadataSyncer, &sc) == storagePolicyId {
 			policyUsageList := &storagepolicyv1alpha2.StoragePolicyUsageList{}
 			err := storageQuotaClient.List(ctx, policyUsageList, &client.ListOptions{
 				Namespace: namespace,
@@ -3945,7 +4156,7 @@ func deleteStoragePolicyUsageCR(ctx context.Context, storagePolicyId string,
 			cnsoperatorv1alpha1.CnsStoragePolicyUsageSingular, namespace, err)
 		return err
 	}
-	isStorageQuotaM2Enabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StorageQuotaM2)
+	isStorageQuotaM2Enabled := isSnapshotQuotaEnabled(ctx, metadataSyncer)
 	// For each storagepolicyusage matching with the storage policy id, delete the usage CR.
 	for _, usage := range policyUsageList.Items {
 		if usage.Spec.StoragePolicyId == storagePolicyId &&
@@ -4001,7 +4212,7 @@ func createStoragePolicyUsageCRS(ctx context.Context, metadataSyncer *metadataSy
 	}
 	scPolicyIdToNameMap := make(map[string][]string)
 	for _, sc := range storageClassList.Items {
-		policyID := sc.Parameters[scParamStoragePolicyID]
+		policyID := getStoragePolicyIDForStorageClass(ctx, metadataSyncer, &sc)
 		scPolicyIdToNameMap[policyID] = append(scPolicyIdToNameMap[policyID], sc.Name)
 	}
 
@@ -4025,7 +4236,7 @@ func createStoragePolicyUsageCRS(ctx context.Context, metadataSyncer *metadataSy
 			"supervisor namespaces. Error: %+v", cnsoperatorv1alpha1.CnsStoragePolicyQuotaSingular, err)
 		return
 	}
-	isStorageQuotaM2Enabled := metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StorageQuotaM2)
+	isStorageQuotaM2Enabled := isSnapshotQuotaEnabled(ctx, metadataSyncer)
 	for _, spq := range spqList.Items {
 		// Make sure storagePolicyQuota instance is not getting deleted.
 		if spq.DeletionTimestamp != nil {
@@ -4139,6 +4350,10 @@ func storagePolicyUsageCRSync(ctx context.Context, metadataSyncer *metadataSyncI
 			cnsoperatorv1alpha1.CnsStoragePolicyUsageSingular, err)
 		return
 	}
+	snapshotQuotaEnabled := isStorageQuotaM2FSSEnabled
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		snapshotQuotaEnabled = IsVanillaStoragePolicyQuotaFSSEnabled
+	}
 	volumeInfoCRList := volumeInfoService.ListAllVolumeInfos()
 	cnsVolumeInfoMap := make(map[string]*cnsvolumeinfov1alpha1.CNSVolumeInfo)
 	spuAggregatedSumMap := make(map[string]*resource.Quantity)
@@ -4152,7 +4367,7 @@ func storagePolicyUsageCRSync(ctx context.Context, metadataSyncer *metadataSyncI
 			continue
 		}
 		cnsVolumeInfoMap[cnsVolumeInfoObj.Name] = cnsVolumeInfoObj.DeepCopy()
-		if isStorageQuotaM2FSSEnabled && cnsVolumeInfoObj.Spec.AggregatedSnapshotSize != nil {
+		if snapshotQuotaEnabled && cnsVolumeInfoObj.Spec.AggregatedSnapshotSize != nil {
 			spuKey := generateSPUKey(cnsVolumeInfoObj)
 			if usedQty := spuAggregatedSumMap[spuKey]; usedQty == nil {
 				spuAggregatedSumMap[spuKey] = cnsVolumeInfoObj.Spec.AggregatedSnapshotSize
@@ -4194,7 +4409,7 @@ func storagePolicyUsageCRSync(ctx context.Context, metadataSyncer *metadataSyncI
 					}
 					updateSpu = true
 				}
-			} else if isStorageQuotaM2FSSEnabled && storagePolicyUsage.Spec.ResourceKind == ResourceKindSnapshot {
+			} else if snapshotQuotaEnabled && storagePolicyUsage.Spec.ResourceKind == ResourceKindSnapshot {
 				spuKey := strings.Join([]string{storagePolicyUsage.Spec.StorageClassName,
 					storagePolicyUsage.Spec.StoragePolicyId, storagePolicyUsage.Namespace}, "-")
 				if usedQty, ok := spuAggregatedSumMap[spuKey]; ok {
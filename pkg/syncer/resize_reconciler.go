@@ -20,11 +20,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"strings"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/apimachinery/pkg/util/strategicpatch"
 	"k8s.io/apimachinery/pkg/util/wait"
@@ -34,6 +37,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/util/workqueue"
 
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
 )
 
@@ -61,6 +65,10 @@ type resizeReconciler struct {
 	pvLister corelisters.PersistentVolumeLister
 	// Tanzu Kubernetes Grid PV Synced.
 	pvSynced cache.InformerSynced
+	// Supervisor Cluster PVC Lister.
+	svcPVCLister corelisters.PersistentVolumeClaimLister
+	// Supervisor Cluster PVC Synced.
+	svcPVCSynced cache.InformerSynced
 }
 
 var (
@@ -70,6 +78,19 @@ var (
 	}
 )
 
+// supervisorResizeErrorPrefix prefixes the message of the ControllerResizeError
+// condition mirrored from the Supervisor Cluster PVC, to tell it apart from the
+// condition set by the external-resizer of the Tanzu Kubernetes Grid. The
+// external-resizer of the Supervisor Cluster only sets the condition when the
+// RecoverVolumeExpansionFailure feature gate is enabled, otherwise resize
+// errors are only reported as events on the Supervisor Cluster PVC.
+const supervisorResizeErrorPrefix = "supervisor cluster: "
+
+// offlineExpansionRequiredCondition is set on a Tanzu Kubernetes Grid PVC with
+// a pending resize, when the StorageClass "expansionmode" param of its volume
+// only allows expanding the volume while it is detached from all nodes.
+const offlineExpansionRequiredCondition v1.PersistentVolumeClaimConditionType = "OfflineExpansionRequired"
+
 type resizeProcessStatus struct {
 	// condition reprensents the current resize condition of PVC.
 	condition v1.PersistentVolumeClaimCondition
@@ -87,12 +108,15 @@ func newResizeReconciler(
 	supervisorNamespace string,
 	resyncPeriod time.Duration,
 	informerFactory informers.SharedInformerFactory,
+	// Informer factory of the Supervisor Cluster namespace.
+	svcInformerFactory informers.SharedInformerFactory,
 	pvcRateLimitter workqueue.TypedRateLimiter[any],
 	stopCh <-chan struct{}) (*resizeReconciler, error) {
 
 	_, log := logger.GetNewContextWithLogger()
 	pvcInformer := informerFactory.Core().V1().PersistentVolumeClaims()
 	pvInformer := informerFactory.Core().V1().PersistentVolumes()
+	svcPVCInformer := svcInformerFactory.Core().V1().PersistentVolumeClaims()
 	claimQueue := workqueue.NewNamedRateLimitingQueue(pvcRateLimitter, "resize-pvc")
 
 	rc := &resizeReconciler{
@@ -103,6 +127,8 @@ func newResizeReconciler(
 		pvcSynced:           pvcInformer.Informer().HasSynced,
 		pvLister:            pvInformer.Lister(),
 		pvSynced:            pvInformer.Informer().HasSynced,
+		svcPVCLister:        svcPVCInformer.Lister(),
+		svcPVCSynced:        svcPVCInformer.Informer().HasSynced,
 		claimQueue:          claimQueue,
 	}
 	// TODO: Need to figure out how to handle the scenario that
@@ -116,8 +142,16 @@ func newResizeReconciler(
 		return nil, logger.LogNewErrorf(log, "failed to add event handler on PVC informer. Error: %v", err)
 	}
 
+	_, err = svcPVCInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: rc.svcUpdatePVC,
+	})
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to add event handler on supervisor PVC informer. Error: %v", err)
+	}
+
 	informerFactory.Start(stopCh)
-	if !cache.WaitForCacheSync(stopCh, rc.pvcSynced, rc.pvSynced) {
+	svcInformerFactory.Start(stopCh)
+	if !cache.WaitForCacheSync(stopCh, rc.pvcSynced, rc.pvSynced, rc.svcPVCSynced) {
 		return nil, fmt.Errorf("cannot sync pv/pvc caches")
 	}
 	return rc, nil
@@ -143,9 +177,11 @@ func (rc *resizeReconciler) updatePVC(oldObj, newObj interface{}) {
 		return
 	}
 
-	// Add tkgPVC to the claim queue only when the new size is bigger or oldPVC
-	// has FileSystemResizePending condition, newPVC does not have.
-	if newPVCSize.Cmp(oldPVCSize) > 0 || (checkFileSystemPendingOnPVC(oldPVC) && !checkFileSystemPendingOnPVC(newPVC)) {
+	// Add tkgPVC to the claim queue only when the new size is bigger, oldPVC
+	// has FileSystemResizePending condition, newPVC does not have, or a resize
+	// of newPVC starts or ends, so that its resize conditions are updated.
+	if newPVCSize.Cmp(oldPVCSize) > 0 || (checkFileSystemPendingOnPVC(oldPVC) && !checkFileSystemPendingOnPVC(newPVC)) ||
+		checkResizeInProgressOnPVC(oldPVC) != checkResizeInProgressOnPVC(newPVC) {
 		objKey, err := getPVCKey(ctx, newObj)
 		if err != nil {
 			return
@@ -156,6 +192,40 @@ func (rc *resizeReconciler) updatePVC(oldObj, newObj interface{}) {
 	}
 }
 
+// svcUpdatePVC adds the Tanzu Kubernetes Grid PVCs of the volume of a
+// Supervisor Cluster PVC to the claim queue when the ControllerResizeError
+// condition of the Supervisor Cluster PVC changes, so that it is mirrored.
+func (rc *resizeReconciler) svcUpdatePVC(oldObj, newObj interface{}) {
+	_, log := logger.GetNewContextWithLogger()
+	oldPVC, ok := oldObj.(*v1.PersistentVolumeClaim)
+	if !ok || oldPVC == nil {
+		return
+	}
+	newPVC, ok := newObj.(*v1.PersistentVolumeClaim)
+	if !ok || newPVC == nil {
+		return
+	}
+	if reflect.DeepEqual(getPVCCondition(oldPVC, v1.PersistentVolumeClaimControllerResizeError),
+		getPVCCondition(newPVC, v1.PersistentVolumeClaimControllerResizeError)) {
+		return
+	}
+	pvs, err := rc.pvLister.List(labels.Everything())
+	if err != nil {
+		log.Errorf("failed to list PVs to mirror resize error of supervisor PVC %s/%s: %v",
+			newPVC.Namespace, newPVC.Name, err)
+		return
+	}
+	for _, pv := range pvs {
+		if pv.Spec.CSI == nil || pv.Spec.CSI.VolumeHandle != newPVC.Name || pv.Spec.ClaimRef == nil {
+			continue
+		}
+		objKey := pv.Spec.ClaimRef.Namespace + "/" + pv.Spec.ClaimRef.Name
+		log.Infof("Resize error of supervisor PVC %s/%s changed. Add PVC %s to the claim queue",
+			newPVC.Namespace, newPVC.Name, objKey)
+		rc.claimQueue.Add(objKey)
+	}
+}
+
 // Run starts the reconciler.
 func (rc *resizeReconciler) Run(ctx context.Context, workers int) {
 	log := logger.GetLogger(ctx)
@@ -217,10 +287,9 @@ func (rc *resizeReconciler) syncPVC(ctx context.Context, key string) error {
 
 	// Get corresponding PVC from the Supervisor Cluster given the pv in the
 	// Tanzu Kubernetes Grid.
-	svcPVC, err := rc.supervisorClient.CoreV1().PersistentVolumeClaims(rc.supervisorNamespace).Get(
-		ctx, tkgPV.Spec.CSI.VolumeHandle, metav1.GetOptions{})
+	svcPVC, err := rc.svcPVCLister.PersistentVolumeClaims(rc.supervisorNamespace).Get(tkgPV.Spec.CSI.VolumeHandle)
 	if err != nil {
-		log.Errorf("Error get supervisor cluster pvc %s from api server in the namespace %s: %v",
+		log.Errorf("Error get supervisor cluster pvc %s from cache in the namespace %s: %v",
 			tkgPV.Spec.CSI.VolumeHandle, rc.supervisorNamespace, err)
 		return err
 	}
@@ -253,24 +322,113 @@ func (rc *resizeReconciler) syncPVC(ctx context.Context, key string) error {
 		}
 		log.Infof("Updated Supervisor Cluster PVC %+v in namespace [%s]", svcUpdatedPVC, rc.supervisorNamespace)
 	}
+
+	tkgPvcClone := tkgPVC
+	updateTKGPVC := false
+	// Tell users why the resize of a volume with offline-only expansion mode
+	// does not progress while it is attached.
+	if pvcClone, update := setOfflineExpansionConditionOnPVC(tkgPvcClone, tkgPV); update {
+		tkgPvcClone = pvcClone
+		updateTKGPVC = true
+	}
+	// Mirror the ControllerResizeError condition of the Supervisor Cluster PVC,
+	// e.g. when the expansion of a volume with snapshots is rejected, on the
+	// Tanzu Kubernetes Grid PVC.
+	if pvcClone, update := mirrorControllerResizeErrorOnPVC(tkgPvcClone, svcPVC); update {
+		tkgPvcClone = pvcClone
+		updateTKGPVC = true
+	}
+	if updateTKGPVC {
+		if _, err := patchPVCStatus(ctx, tkgPVC, tkgPvcClone, rc.tkgClient); err != nil {
+			log.Errorf("cannot update resize conditions of Tanzu Kubernetes Grid PVC %s/%s: %v",
+				tkgPVC.Namespace, tkgPVC.Name, err)
+			return err
+		}
+		log.Infof("Updated resize conditions of Tanzu Kubernetes Grid PVC %s/%s", tkgPVC.Namespace, tkgPVC.Name)
+	}
 	return nil
 }
 
+// setOfflineExpansionConditionOnPVC returns a copy of tkgPVC with the
+// OfflineExpansionRequired condition set while a resize of tkgPV is pending
+// and tkgPV only allows offline expansion, or without it otherwise. The
+// returned bool is false if tkgPVC needs no update.
+func setOfflineExpansionConditionOnPVC(tkgPVC *v1.PersistentVolumeClaim,
+	tkgPV *v1.PersistentVolume) (*v1.PersistentVolumeClaim, bool) {
+	required := checkResizeInProgressOnPVC(tkgPVC) && tkgPV.Spec.CSI != nil &&
+		tkgPV.Spec.CSI.VolumeAttributes[common.AttributeExpansionMode] == common.ExpansionModeOffline
+	if required == (getPVCCondition(tkgPVC, offlineExpansionRequiredCondition) != nil) {
+		return nil, false
+	}
+	tkgPvcClone := tkgPVC.DeepCopy()
+	tkgPvcClone.Status.Conditions = nil
+	for _, condition := range tkgPVC.Status.Conditions {
+		if condition.Type != offlineExpansionRequiredCondition {
+			tkgPvcClone.Status.Conditions = append(tkgPvcClone.Status.Conditions, condition)
+		}
+	}
+	if required {
+		tkgPvcClone.Status.Conditions = append(tkgPvcClone.Status.Conditions, v1.PersistentVolumeClaimCondition{
+			Type:               offlineExpansionRequiredCondition,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Message: fmt.Sprintf("volume can only be expanded while detached from all nodes "+
+				"as StorageClass param %q is set to %q", common.AttributeExpansionMode, common.ExpansionModeOffline),
+		})
+	}
+	return tkgPvcClone, true
+}
+
+// mirrorControllerResizeErrorOnPVC returns a copy of tkgPVC with the
+// ControllerResizeError condition of svcPVC, or without a condition previously
+// mirrored if svcPVC no longer has it. A condition set by the external-resizer
+// of the Tanzu Kubernetes Grid takes precedence and is never replaced. The
+// returned bool is false if tkgPVC needs no update.
+func mirrorControllerResizeErrorOnPVC(tkgPVC *v1.PersistentVolumeClaim,
+	svcPVC *v1.PersistentVolumeClaim) (*v1.PersistentVolumeClaim, bool) {
+	svcCondition := getPVCCondition(svcPVC, v1.PersistentVolumeClaimControllerResizeError)
+	tkgCondition := getPVCCondition(tkgPVC, v1.PersistentVolumeClaimControllerResizeError)
+	if tkgCondition != nil && !strings.HasPrefix(tkgCondition.Message, supervisorResizeErrorPrefix) {
+		return nil, false
+	}
+	var mirrored *v1.PersistentVolumeClaimCondition
+	if svcCondition != nil {
+		mirrored = svcCondition.DeepCopy()
+		mirrored.Message = supervisorResizeErrorPrefix + svcCondition.Message
+		if tkgCondition != nil && tkgCondition.Message == mirrored.Message {
+			return nil, false
+		}
+	} else if tkgCondition == nil {
+		return nil, false
+	}
+	tkgPvcClone := tkgPVC.DeepCopy()
+	tkgPvcClone.Status.Conditions = nil
+	for _, condition := range tkgPVC.Status.Conditions {
+		if condition.Type != v1.PersistentVolumeClaimControllerResizeError {
+			tkgPvcClone.Status.Conditions = append(tkgPvcClone.Status.Conditions, condition)
+		}
+	}
+	if mirrored != nil {
+		tkgPvcClone.Status.Conditions = append(tkgPvcClone.Status.Conditions, *mirrored)
+	}
+	return tkgPvcClone, true
+}
+
 // patchPVCStatus patch the old pvc using new pvc's status.
 func patchPVCStatus(ctx context.Context,
 	oldPVC *v1.PersistentVolumeClaim,
 	newPVC *v1.PersistentVolumeClaim,
-	supervisorClient kubernetes.Interface) (*v1.PersistentVolumeClaim, error) {
+	client kubernetes.Interface) (*v1.PersistentVolumeClaim, error) {
 	patchBytes, err := createPVCPatch(oldPVC, newPVC)
 	if err != nil {
-		return nil, fmt.Errorf("failed to patch supervisor cluster PVC %q in namespace %s: %v",
+		return nil, fmt.Errorf("failed to patch PVC %q in namespace %s: %v",
 			oldPVC.Name, oldPVC.Namespace, err)
 	}
 
-	updatedClaim, updateErr := supervisorClient.CoreV1().PersistentVolumeClaims(oldPVC.Namespace).
+	updatedClaim, updateErr := client.CoreV1().PersistentVolumeClaims(oldPVC.Namespace).
 		Patch(ctx, oldPVC.Name, types.StrategicMergePatchType, patchBytes, metav1.PatchOptions{}, "status")
 	if updateErr != nil {
-		return nil, fmt.Errorf("failed to supervisor cluster patch PVC %q in namespace %s: %v",
+		return nil, fmt.Errorf("failed to patch PVC %q in namespace %s: %v",
 			oldPVC.Name, oldPVC.Namespace, updateErr)
 	}
 	return updatedClaim, nil
@@ -355,3 +513,23 @@ func checkFileSystemPendingOnPVC(
 	}
 	return false
 }
+
+// checkResizeInProgressOnPVC returns true if the requested size of the PVC is
+// bigger than its capacity.
+func checkResizeInProgressOnPVC(pvc *v1.PersistentVolumeClaim) bool {
+	requestedSize := pvc.Spec.Resources.Requests[v1.ResourceStorage]
+	capacity := pvc.Status.Capacity[v1.ResourceStorage]
+	return requestedSize.Cmp(capacity) > 0
+}
+
+// getPVCCondition returns the condition of the given type of the PVC, or nil
+// if the PVC does not have it.
+func getPVCCondition(pvc *v1.PersistentVolumeClaim,
+	conditionType v1.PersistentVolumeClaimConditionType) *v1.PersistentVolumeClaimCondition {
+	for i := range pvc.Status.Conditions {
+		if pvc.Status.Conditions[i].Type == conditionType {
+			return &pvc.Status.Conditions[i]
+		}
+	}
+	return nil
+}
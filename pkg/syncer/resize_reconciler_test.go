@@ -0,0 +1,134 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package syncer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+)
+
+func newPVCWithConditions(conditions ...v1.PersistentVolumeClaimCondition) *v1.PersistentVolumeClaim {
+	return &v1.PersistentVolumeClaim{
+		Status: v1.PersistentVolumeClaimStatus{Conditions: conditions},
+	}
+}
+
+func TestMirrorControllerResizeErrorOnPVC(t *testing.T) {
+	svcError := v1.PersistentVolumeClaimCondition{
+		Type:    v1.PersistentVolumeClaimControllerResizeError,
+		Status:  v1.ConditionTrue,
+		Message: "volume: vol-1 with existing snapshots [vol-1+snap-1] cannot be expanded",
+	}
+	mirroredError := *svcError.DeepCopy()
+	mirroredError.Message = supervisorResizeErrorPrefix + svcError.Message
+	tkgError := v1.PersistentVolumeClaimCondition{
+		Type:    v1.PersistentVolumeClaimControllerResizeError,
+		Status:  v1.ConditionTrue,
+		Message: "timed out waiting for supervisor PVC",
+	}
+	resizing := v1.PersistentVolumeClaimCondition{
+		Type:   v1.PersistentVolumeClaimResizing,
+		Status: v1.ConditionTrue,
+	}
+
+	// The error of the Supervisor Cluster PVC is mirrored.
+	tkgPVC, update := mirrorControllerResizeErrorOnPVC(newPVCWithConditions(resizing),
+		newPVCWithConditions(svcError))
+	assert.True(t, update)
+	assert.Equal(t, []v1.PersistentVolumeClaimCondition{resizing, mirroredError}, tkgPVC.Status.Conditions)
+
+	// An error which is already mirrored is not updated.
+	_, update = mirrorControllerResizeErrorOnPVC(newPVCWithConditions(mirroredError), newPVCWithConditions(svcError))
+	assert.False(t, update)
+
+	// A changed error of the Supervisor Cluster PVC replaces the mirrored error.
+	changedError := *svcError.DeepCopy()
+	changedError.Message = "volume: vol-1 could not be expanded"
+	tkgPVC, update = mirrorControllerResizeErrorOnPVC(newPVCWithConditions(mirroredError),
+		newPVCWithConditions(changedError))
+	assert.True(t, update)
+	assert.Len(t, tkgPVC.Status.Conditions, 1)
+	assert.Equal(t, supervisorResizeErrorPrefix+changedError.Message, tkgPVC.Status.Conditions[0].Message)
+
+	// The error set by the external-resizer of the Tanzu Kubernetes Grid is
+	// not replaced by the error of the Supervisor Cluster PVC.
+	_, update = mirrorControllerResizeErrorOnPVC(newPVCWithConditions(resizing, tkgError),
+		newPVCWithConditions(svcError))
+	assert.False(t, update)
+
+	// The mirrored error is removed once the Supervisor Cluster PVC no longer has it.
+	tkgPVC, update = mirrorControllerResizeErrorOnPVC(newPVCWithConditions(resizing, mirroredError),
+		newPVCWithConditions())
+	assert.True(t, update)
+	assert.Equal(t, []v1.PersistentVolumeClaimCondition{resizing}, tkgPVC.Status.Conditions)
+
+	// The error set by the external-resizer of the Tanzu Kubernetes Grid is kept.
+	_, update = mirrorControllerResizeErrorOnPVC(newPVCWithConditions(tkgError), newPVCWithConditions())
+	assert.False(t, update)
+}
+
+func TestSetOfflineExpansionConditionOnPVC(t *testing.T) {
+	newPV := func(expansionMode string) *v1.PersistentVolume {
+		return &v1.PersistentVolume{
+			Spec: v1.PersistentVolumeSpec{
+				PersistentVolumeSource: v1.PersistentVolumeSource{
+					CSI: &v1.CSIPersistentVolumeSource{
+						VolumeAttributes: map[string]string{common.AttributeExpansionMode: expansionMode},
+					},
+				},
+			},
+		}
+	}
+	newPVC := func(requested string, conditions ...v1.PersistentVolumeClaimCondition) *v1.PersistentVolumeClaim {
+		pvc := newPVCWithConditions(conditions...)
+		pvc.Spec.Resources.Requests = v1.ResourceList{v1.ResourceStorage: resource.MustParse(requested)}
+		pvc.Status.Capacity = v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")}
+		return pvc
+	}
+	resizing := v1.PersistentVolumeClaimCondition{
+		Type:   v1.PersistentVolumeClaimResizing,
+		Status: v1.ConditionTrue,
+	}
+
+	// The condition is set while the resize of an offline-only volume is pending.
+	tkgPVC, update := setOfflineExpansionConditionOnPVC(newPVC("2Gi", resizing), newPV(common.ExpansionModeOffline))
+	assert.True(t, update)
+	assert.Len(t, tkgPVC.Status.Conditions, 2)
+	assert.Equal(t, resizing, tkgPVC.Status.Conditions[0])
+	assert.Equal(t, offlineExpansionRequiredCondition, tkgPVC.Status.Conditions[1].Type)
+	offlineExpansionRequired := tkgPVC.Status.Conditions[1]
+
+	// A condition which is already set is not updated.
+	_, update = setOfflineExpansionConditionOnPVC(newPVC("2Gi", offlineExpansionRequired),
+		newPV(common.ExpansionModeOffline))
+	assert.False(t, update)
+
+	// The condition is removed once the resize is complete.
+	tkgPVC, update = setOfflineExpansionConditionOnPVC(newPVC("1Gi", resizing, offlineExpansionRequired),
+		newPV(common.ExpansionModeOffline))
+	assert.True(t, update)
+	assert.Equal(t, []v1.PersistentVolumeClaimCondition{resizing}, tkgPVC.Status.Conditions)
+
+	// Volumes which can be expanded online get no condition.
+	_, update = setOfflineExpansionConditionOnPVC(newPVC("2Gi"), newPV(""))
+	assert.False(t, update)
+}
@@ -0,0 +1,401 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	vim25types "github.com/vmware/govmomi/vim25/types"
+	"golang.org/x/sync/semaphore"
+	v1 "k8s.io/api/core/v1"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/storagepool/cns/v1alpha1"
+
+	cnsvsphere "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/cns-lib/vsphere"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/utils"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	k8s "sigs.k8s.io/vsphere-csi-driver/v3/pkg/kubernetes"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
+)
+
+const (
+	// Interval at which DatastoreDrain instances are listed to pick up new
+	// drain requests.
+	datastoreDrainReconcileInterval = 30 * time.Second
+	// Default number of volumes relocated in parallel for a DatastoreDrain.
+	defaultMaxConcurrentMigrations = 1
+)
+
+// datastoreDrainController is responsible for processing DatastoreDrain
+// requests. Unlike DiskDecommController, which only handles vSAN Direct
+// StoragePools, it can drain the datastore backing any StoragePool (VMFS,
+// NFS, vSAN or vSAN Direct) by relocating all its block volumes to other
+// policy compatible StoragePools.
+type datastoreDrainController struct {
+	migrationCntlr *migrationController
+	k8sClient      client.Client
+	// inProgress holds the names of DatastoreDrain instances being processed.
+	inProgress sync.Map
+
+	// queryBlockVolumes returns the IDs of the block volumes on a datastore.
+	queryBlockVolumes func(ctx context.Context, datastoreURL string) (map[string]bool, error)
+	// getDrainPlan maps the PVs to drain to their target StoragePool.
+	getDrainPlan func(ctx context.Context, spName string,
+		volumeInfoList []k8scloudoperator.VolumeInfo) (map[string]string, error)
+	// relocateCNSVolume relocates a volume to the datastore of a StoragePool.
+	relocateCNSVolume func(ctx context.Context, volumeID string, targetSPName string) error
+	// updatePVCStoragePool sets the StoragePool annotation of a PVC.
+	updatePVCStoragePool func(ctx context.Context, pvcName, pvcNamespace, spName string) error
+}
+
+func initDatastoreDrainController(ctx context.Context,
+	migrationCntlr *migrationController) (*datastoreDrainController, error) {
+	log := logger.GetLogger(ctx)
+	log.Infof("Starting datastore drain controller")
+	k8sClient, err := getK8sClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	d := newDatastoreDrainController(migrationCntlr, k8sClient)
+	go d.scheduleReconcileDatastoreDrains(ctx)
+	return d, nil
+}
+
+// newDatastoreDrainController returns a datastoreDrainController which
+// relocates volumes through the given migration controller.
+func newDatastoreDrainController(migrationCntlr *migrationController,
+	k8sClient client.Client) *datastoreDrainController {
+	d := &datastoreDrainController{
+		migrationCntlr:       migrationCntlr,
+		k8sClient:            k8sClient,
+		updatePVCStoragePool: updateSourceSPAnnotationOnPVC,
+		getDrainPlan:         getDatastoreDrainPlan,
+	}
+	d.queryBlockVolumes = d.queryBlockVolumesOnDatastore
+	if migrationCntlr != nil {
+		d.relocateCNSVolume = migrationCntlr.relocateCNSVolume
+	}
+	return d
+}
+
+// scheduleReconcileDatastoreDrains periodically starts processing of every
+// DatastoreDrain which has not completed yet.
+func (d *datastoreDrainController) scheduleReconcileDatastoreDrains(ctx context.Context) {
+	log := logger.GetLogger(ctx)
+	ticker := time.NewTicker(datastoreDrainReconcileInterval)
+	defer ticker.Stop()
+	for ; true; <-ticker.C {
+		if ctx.Err() != nil {
+			log.Info("DatastoreDrain reconciler shutdown", "ctxErr", ctx.Err())
+			return
+		}
+		drainList := &v1alpha1.DatastoreDrainList{}
+		err := d.k8sClient.List(ctx, drainList)
+		if err != nil {
+			log.Errorf("Failed to list DatastoreDrain instances. Error: %v", err)
+			continue
+		}
+		for _, drain := range drainList.Items {
+			if drain.DeletionTimestamp != nil || isDrainPhaseTerminal(drain.Status.Phase) {
+				continue
+			}
+			if _, loaded := d.inProgress.LoadOrStore(drain.Name, true); loaded {
+				continue
+			}
+			go func(name string) {
+				defer d.inProgress.Delete(name)
+				d.drainDatastore(ctx, name)
+			}(drain.Name)
+		}
+	}
+}
+
+// drainDatastore relocates all block volumes present on the datastore backing
+// the StoragePool of the given DatastoreDrain. A placement plan is computed
+// using the relaxed fit planner and volumes are relocated with at most
+// spec.maxConcurrentMigrations relocations in parallel. The migration progress
+// of every PV is recorded in the DatastoreDrain status.
+func (d *datastoreDrainController) drainDatastore(ctx context.Context, drainName string) {
+	log := logger.GetLogger(ctx)
+	drain := &v1alpha1.DatastoreDrain{}
+	err := d.k8sClient.Get(ctx, k8stypes.NamespacedName{Name: drainName}, drain)
+	if err != nil {
+		log.Errorf("Failed to get DatastoreDrain %v. Error: %v", drainName, err)
+		return
+	}
+	spName := drain.Spec.StoragePoolName
+	log.Infof("Draining datastore of StoragePool %v as requested by DatastoreDrain %v", spName, drainName)
+
+	status := drain.Status.DeepCopy()
+	status.Phase = v1alpha1.DrainPhaseInProgress
+	status.Message = ""
+	var statusLock sync.Mutex
+	updateStatus := func() {
+		statusLock.Lock()
+		defer statusLock.Unlock()
+		err := d.updateDrainStatus(ctx, drainName, status)
+		if err != nil {
+			log.Errorf("Failed to update status of DatastoreDrain %v. Error: %v", drainName, err)
+		}
+	}
+	failDrain := func(msg string) {
+		log.Errorf("Failed to drain datastore of StoragePool %v. %v", spName, msg)
+		statusLock.Lock()
+		status.Phase = v1alpha1.DrainPhaseFailed
+		status.Message = msg
+		statusLock.Unlock()
+		updateStatus()
+	}
+	updateStatus()
+
+	volumeInfoList, volumeIDs, err := d.getVolumesOnStoragePool(ctx, spName)
+	if err != nil {
+		failDrain(fmt.Sprintf("Failed to get volumes on StoragePool %v. Error: %v", spName, err))
+		return
+	}
+
+	plan, err := d.getDrainPlan(ctx, spName, volumeInfoList)
+	if err != nil {
+		failDrain(fmt.Sprintf("Failed to compute storage vMotion plan. Error: %v", err))
+		return
+	}
+
+	pvcForPV := make(map[string]v1.PersistentVolumeClaim)
+	for _, vol := range volumeInfoList {
+		pvcForPV[vol.PVName] = vol.PVC
+	}
+	for pvName, targetSPName := range plan {
+		setVolumeMigrationStatus(status, v1alpha1.VolumeMigrationStatus{
+			PVName:            pvName,
+			VolumeID:          volumeIDs[pvName],
+			TargetStoragePool: targetSPName,
+			Phase:             v1alpha1.DrainPhasePending,
+		})
+	}
+	updateStatus()
+
+	maxConcurrentMigrations := drain.Spec.MaxConcurrentMigrations
+	if maxConcurrentMigrations <= 0 {
+		maxConcurrentMigrations = defaultMaxConcurrentMigrations
+	}
+	sem := semaphore.NewWeighted(int64(maxConcurrentMigrations))
+	var wg sync.WaitGroup
+	failedMigrations := 0
+	for pvName, targetSPName := range plan {
+		if err := sem.Acquire(ctx, 1); err != nil {
+			wg.Wait()
+			failDrain(fmt.Sprintf("Datastore drain interrupted. Error: %v", err))
+			return
+		}
+		wg.Add(1)
+		go func(pvName, targetSPName string) {
+			defer wg.Done()
+			defer sem.Release(1)
+			migration := v1alpha1.VolumeMigrationStatus{
+				PVName:            pvName,
+				VolumeID:          volumeIDs[pvName],
+				TargetStoragePool: targetSPName,
+				Phase:             v1alpha1.DrainPhaseInProgress,
+			}
+			statusLock.Lock()
+			setVolumeMigrationStatus(status, migration)
+			statusLock.Unlock()
+			updateStatus()
+
+			err := d.relocateVolume(ctx, pvcForPV[pvName], volumeIDs[pvName], targetSPName)
+			migration.Phase = v1alpha1.DrainPhaseCompleted
+			if err != nil {
+				log.Errorf("Failed to migrate PV %v to StoragePool %v. Error: %v", pvName, targetSPName, err)
+				migration.Phase = v1alpha1.DrainPhaseFailed
+				migration.Message = err.Error()
+			}
+			statusLock.Lock()
+			if err != nil {
+				failedMigrations++
+			}
+			setVolumeMigrationStatus(status, migration)
+			statusLock.Unlock()
+			updateStatus()
+		}(pvName, targetSPName)
+	}
+	wg.Wait()
+
+	if failedMigrations != 0 {
+		failDrain(fmt.Sprintf("Failed to migrate %d of %d volumes from StoragePool %v",
+			failedMigrations, len(plan), spName))
+		return
+	}
+	log.Infof("Successfully drained datastore of StoragePool %v", spName)
+	statusLock.Lock()
+	status.Phase = v1alpha1.DrainPhaseCompleted
+	statusLock.Unlock()
+	updateStatus()
+}
+
+// relocateVolume relocates the given volume to the target StoragePool and
+// updates the StoragePool annotation on the PVC to reflect the new placement
+// of the volume. The node affinity of the PV is immutable and is left as is.
+// It stays valid, as the drain plan only selects target StoragePools which are
+// accessible from every node the source datastore is accessible from.
+func (d *datastoreDrainController) relocateVolume(ctx context.Context, pvc v1.PersistentVolumeClaim,
+	volumeID string, targetSPName string) error {
+	// Retry the relocateCNSVolume() if we face connectivity issues with VC.
+	relocateFn := func() error {
+		return d.relocateCNSVolume(ctx, volumeID, targetSPName)
+	}
+	initBackoff := time.Duration(100) * time.Millisecond
+	maxBackoff := time.Duration(60) * time.Second
+	err := RetryOnError(relocateFn, initBackoff, maxBackoff, 1.5, 16)
+	if err != nil {
+		return err
+	}
+	return d.updatePVCStoragePool(ctx, pvc.Name, pvc.Namespace, targetSPName)
+}
+
+// getDatastoreDrainPlan computes the placement plan of the volumes to drain
+// from the given StoragePool.
+func getDatastoreDrainPlan(ctx context.Context, spName string,
+	volumeInfoList []k8scloudoperator.VolumeInfo) (map[string]string, error) {
+	k8sClient, err := k8s.NewClient(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Kubernetes client. Error: %v", err)
+	}
+	return k8scloudoperator.GetDatastoreDrainPlan(ctx, k8sClient, spName, volumeInfoList)
+}
+
+// getVolumesOnStoragePool queries CNS for the block volumes present on the
+// datastore backing the given StoragePool and returns volume information of
+// the PVCs bound to them along with a map of PV name to volume ID.
+func (d *datastoreDrainController) getVolumesOnStoragePool(ctx context.Context,
+	spName string) ([]k8scloudoperator.VolumeInfo, map[string]string, error) {
+	log := logger.GetLogger(ctx)
+	sp := &v1alpha1.StoragePool{}
+	err := d.k8sClient.Get(ctx, k8stypes.NamespacedName{Name: spName}, sp)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get StoragePool %v. Error: %v", spName, err)
+	}
+	datastoreURL, found := sp.Spec.Parameters["datastoreUrl"]
+	if !found {
+		return nil, nil, fmt.Errorf("failed to find datastoreUrl in StoragePool %v", spName)
+	}
+	volumesOnDatastore, err := d.queryBlockVolumes(ctx, datastoreURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Infof("Found %d block volumes on datastore %v", len(volumesOnDatastore), datastoreURL)
+
+	pvList := &v1.PersistentVolumeList{}
+	err = d.k8sClient.List(ctx, pvList)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list PVs. Error: %v", err)
+	}
+	volumeInfoList := make([]k8scloudoperator.VolumeInfo, 0)
+	volumeIDs := make(map[string]string)
+	for _, pv := range pvList.Items {
+		if pv.Spec.CSI == nil || !volumesOnDatastore[pv.Spec.CSI.VolumeHandle] {
+			continue
+		}
+		claimRef := pv.Spec.ClaimRef
+		if claimRef == nil || pv.Status.Phase != v1.VolumeBound {
+			log.Infof("PV %v is not bound to any PVC. Skipping its migration", pv.Name)
+			continue
+		}
+		pvc := &v1.PersistentVolumeClaim{}
+		err = d.k8sClient.Get(ctx, k8stypes.NamespacedName{Name: claimRef.Name, Namespace: claimRef.Namespace}, pvc)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get PVC %v/%v bound to PV %v. Error: %v",
+				claimRef.Namespace, claimRef.Name, pv.Name, err)
+		}
+		volumeSize := pv.Spec.Capacity.Storage()
+		volumeInfoList = append(volumeInfoList, k8scloudoperator.VolumeInfo{
+			PVC:         *pvc,
+			PVName:      pv.Name,
+			SizeInBytes: volumeSize.Value(),
+		})
+		volumeIDs[pv.Name] = pv.Spec.CSI.VolumeHandle
+	}
+	return volumeInfoList, volumeIDs, nil
+}
+
+// queryBlockVolumesOnDatastore queries CNS for the IDs of the block volumes
+// present on the datastore with the given URL.
+func (d *datastoreDrainController) queryBlockVolumesOnDatastore(ctx context.Context,
+	datastoreURL string) (map[string]bool, error) {
+	dsInfo, err := cnsvsphere.GetDatastoreInfoByURL(ctx, d.migrationCntlr.vc, d.migrationCntlr.clusterIDs,
+		datastoreURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get datastore corresponding to URL %v. Error: %v", datastoreURL, err)
+	}
+	volManager, err := d.migrationCntlr.getVolumeManager(ctx)
+	if err != nil {
+		return nil, err
+	}
+	queryFilter := cnstypes.CnsQueryFilter{
+		Datastores: []vim25types.ManagedObjectReference{dsInfo.Reference()},
+	}
+	querySelection := cnstypes.CnsQuerySelection{
+		Names: []string{string(cnstypes.QuerySelectionNameTypeVolumeType)},
+	}
+	queryResult, err := utils.QueryVolumeUtil(ctx, volManager, queryFilter, &querySelection)
+	if err != nil {
+		return nil, err
+	}
+	volumesOnDatastore := make(map[string]bool)
+	for _, vol := range queryResult.Volumes {
+		if vol.VolumeType == string(cnstypes.CnsVolumeTypeBlock) {
+			volumesOnDatastore[vol.VolumeId.Id] = true
+		}
+	}
+	return volumesOnDatastore, nil
+}
+
+// updateDrainStatus persists the given status on the DatastoreDrain instance.
+func (d *datastoreDrainController) updateDrainStatus(ctx context.Context, drainName string,
+	status *v1alpha1.DatastoreDrainStatus) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		drain := &v1alpha1.DatastoreDrain{}
+		err := d.k8sClient.Get(ctx, k8stypes.NamespacedName{Name: drainName}, drain)
+		if err != nil {
+			return err
+		}
+		status.DeepCopyInto(&drain.Status)
+		return d.k8sClient.Update(ctx, drain)
+	})
+}
+
+// setVolumeMigrationStatus adds or replaces the migration status of a PV.
+func setVolumeMigrationStatus(status *v1alpha1.DatastoreDrainStatus, migration v1alpha1.VolumeMigrationStatus) {
+	for i := range status.Volumes {
+		if status.Volumes[i].PVName == migration.PVName {
+			status.Volumes[i] = migration
+			return
+		}
+	}
+	status.Volumes = append(status.Volumes, migration)
+}
+
+// isDrainPhaseTerminal returns true if no further processing is required for
+// a DatastoreDrain in the given phase.
+func isDrainPhaseTerminal(phase string) bool {
+	return phase == v1alpha1.DrainPhaseCompleted || phase == v1alpha1.DrainPhaseFailed
+}
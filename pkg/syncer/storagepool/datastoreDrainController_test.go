@@ -0,0 +1,219 @@
+/*
+Copyright 2025 The Kubernetes Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package storagepool
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	k8stypes "k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/apis/storagepool/cns/v1alpha1"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
+)
+
+const (
+	testSourceStoragePool = "source-sp"
+	testDatastoreURL      = "ds:///vmfs/volumes/source/"
+)
+
+// newTestPVAndPVC returns a PV backed by the given volume and, if bound is
+// true, the PVC bound to it.
+func newTestPVAndPVC(name, volumeID string, bound bool) []client.Object {
+	pv := &v1.PersistentVolume{
+		ObjectMeta: metav1.ObjectMeta{Name: "pv-" + name},
+		Spec: v1.PersistentVolumeSpec{
+			Capacity: v1.ResourceList{v1.ResourceStorage: resource.MustParse("1Gi")},
+			PersistentVolumeSource: v1.PersistentVolumeSource{
+				CSI: &v1.CSIPersistentVolumeSource{VolumeHandle: volumeID},
+			},
+		},
+		Status: v1.PersistentVolumeStatus{Phase: v1.VolumeAvailable},
+	}
+	if !bound {
+		return []client.Object{pv}
+	}
+	pv.Spec.ClaimRef = &v1.ObjectReference{Name: "pvc-" + name, Namespace: "test-ns"}
+	pv.Status.Phase = v1.VolumeBound
+	pvc := &v1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "pvc-" + name, Namespace: "test-ns"},
+		Spec:       v1.PersistentVolumeClaimSpec{VolumeName: pv.Name},
+	}
+	return []client.Object{pv, pvc}
+}
+
+// newTestDatastoreDrainController returns a datastoreDrainController whose
+// vCenter calls are replaced by stubs. The returned map holds the target
+// StoragePool of every relocated volume.
+func newTestDatastoreDrainController(t *testing.T, objects ...client.Object) (*datastoreDrainController,
+	map[string]string) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, v1.AddToScheme(scheme))
+	assert.NoError(t, v1alpha1.AddToScheme(scheme))
+	objects = append(objects, &v1alpha1.StoragePool{
+		ObjectMeta: metav1.ObjectMeta{Name: testSourceStoragePool},
+		Spec: v1alpha1.StoragePoolSpec{
+			Parameters: map[string]string{"datastoreUrl": testDatastoreURL},
+		},
+	})
+	k8sClient := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+	d := newDatastoreDrainController(nil, k8sClient)
+	d.queryBlockVolumes = func(ctx context.Context, datastoreURL string) (map[string]bool, error) {
+		if datastoreURL != testDatastoreURL {
+			return nil, fmt.Errorf("unexpected datastore %s", datastoreURL)
+		}
+		return map[string]bool{"vol-1": true, "vol-2": true, "vol-unbound": true}, nil
+	}
+	var lock sync.Mutex
+	relocated := make(map[string]string)
+	d.relocateCNSVolume = func(ctx context.Context, volumeID string, targetSPName string) error {
+		lock.Lock()
+		defer lock.Unlock()
+		relocated[volumeID] = targetSPName
+		return nil
+	}
+	d.updatePVCStoragePool = func(ctx context.Context, pvcName, pvcNamespace, spName string) error {
+		return nil
+	}
+	return d, relocated
+}
+
+func TestGetVolumesOnStoragePool(t *testing.T) {
+	ctx := context.Background()
+	var objects []client.Object
+	objects = append(objects, newTestPVAndPVC("1", "vol-1", true)...)
+	objects = append(objects, newTestPVAndPVC("unbound", "vol-unbound", false)...)
+	objects = append(objects, newTestPVAndPVC("other", "vol-other", true)...)
+	d, _ := newTestDatastoreDrainController(t, objects...)
+
+	volumeInfoList, volumeIDs, err := d.getVolumesOnStoragePool(ctx, testSourceStoragePool)
+	assert.NoError(t, err)
+	// Only the bound PV on the datastore is returned.
+	assert.Equal(t, map[string]string{"pv-1": "vol-1"}, volumeIDs)
+	if assert.Len(t, volumeInfoList, 1) {
+		assert.Equal(t, "pv-1", volumeInfoList[0].PVName)
+		assert.Equal(t, "pvc-1", volumeInfoList[0].PVC.Name)
+		assert.Equal(t, int64(1024*1024*1024), volumeInfoList[0].SizeInBytes)
+	}
+
+	_, _, err = d.getVolumesOnStoragePool(ctx, "unknown-sp")
+	assert.Error(t, err)
+}
+
+func TestRelocateVolume(t *testing.T) {
+	ctx := context.Background()
+	d, relocated := newTestDatastoreDrainController(t)
+	var annotatedPVC, annotatedSP string
+	d.updatePVCStoragePool = func(ctx context.Context, pvcName, pvcNamespace, spName string) error {
+		annotatedPVC = pvcNamespace + "/" + pvcName
+		annotatedSP = spName
+		return nil
+	}
+	pvc := v1.PersistentVolumeClaim{ObjectMeta: metav1.ObjectMeta{Name: "pvc-1", Namespace: "test-ns"}}
+
+	err := d.relocateVolume(ctx, pvc, "vol-1", "target-sp")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"vol-1": "target-sp"}, relocated)
+	assert.Equal(t, "test-ns/pvc-1", annotatedPVC)
+	assert.Equal(t, "target-sp", annotatedSP)
+
+	d.updatePVCStoragePool = func(ctx context.Context, pvcName, pvcNamespace, spName string) error {
+		return fmt.Errorf("failed to patch PVC")
+	}
+	err = d.relocateVolume(ctx, pvc, "vol-1", "target-sp")
+	assert.Error(t, err)
+}
+
+func TestDrainDatastore(t *testing.T) {
+	ctx := context.Background()
+	newDrain := func(name string) *v1alpha1.DatastoreDrain {
+		return &v1alpha1.DatastoreDrain{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Spec: v1alpha1.DatastoreDrainSpec{
+				StoragePoolName:         testSourceStoragePool,
+				MaxConcurrentMigrations: 2,
+			},
+		}
+	}
+	var objects []client.Object
+	objects = append(objects, newTestPVAndPVC("1", "vol-1", true)...)
+	objects = append(objects, newTestPVAndPVC("2", "vol-2", true)...)
+	objects = append(objects, newDrain("drain-ok"), newDrain("drain-fail"))
+	d, relocated := newTestDatastoreDrainController(t, objects...)
+	d.getDrainPlan = func(ctx context.Context, spName string,
+		volumeInfoList []k8scloudoperator.VolumeInfo) (map[string]string, error) {
+		plan := make(map[string]string)
+		for _, vol := range volumeInfoList {
+			plan[vol.PVName] = "target-" + vol.PVName
+		}
+		return plan, nil
+	}
+	getDrain := func(name string) *v1alpha1.DatastoreDrain {
+		drain := &v1alpha1.DatastoreDrain{}
+		assert.NoError(t, d.k8sClient.Get(ctx, k8stypes.NamespacedName{Name: name}, drain))
+		return drain
+	}
+
+	d.drainDatastore(ctx, "drain-ok")
+	drain := getDrain("drain-ok")
+	assert.Equal(t, v1alpha1.DrainPhaseCompleted, drain.Status.Phase)
+	assert.Equal(t, map[string]string{"vol-1": "target-pv-1", "vol-2": "target-pv-2"}, relocated)
+	assert.Len(t, drain.Status.Volumes, 2)
+	for _, vol := range drain.Status.Volumes {
+		assert.Equal(t, v1alpha1.DrainPhaseCompleted, vol.Phase)
+		assert.Equal(t, "target-"+vol.PVName, vol.TargetStoragePool)
+	}
+
+	// A volume which fails to migrate fails the drain.
+	d.updatePVCStoragePool = func(ctx context.Context, pvcName, pvcNamespace, spName string) error {
+		if pvcName == "pvc-2" {
+			return fmt.Errorf("failed to patch PVC")
+		}
+		return nil
+	}
+	d.drainDatastore(ctx, "drain-fail")
+	drain = getDrain("drain-fail")
+	assert.Equal(t, v1alpha1.DrainPhaseFailed, drain.Status.Phase)
+	for _, vol := range drain.Status.Volumes {
+		if vol.PVName == "pv-2" {
+			assert.Equal(t, v1alpha1.DrainPhaseFailed, vol.Phase)
+			assert.NotEmpty(t, vol.Message)
+		} else {
+			assert.Equal(t, v1alpha1.DrainPhaseCompleted, vol.Phase)
+		}
+	}
+
+	// A failure to compute the plan fails the drain.
+	d.getDrainPlan = func(ctx context.Context, spName string,
+		volumeInfoList []k8scloudoperator.VolumeInfo) (map[string]string, error) {
+		return nil, fmt.Errorf("no StoragePool with enough capacity")
+	}
+	assert.NoError(t, d.k8sClient.Create(ctx, newDrain("drain-no-plan")))
+	d.drainDatastore(ctx, "drain-no-plan")
+	drain = getDrain("drain-no-plan")
+	assert.Equal(t, v1alpha1.DrainPhaseFailed, drain.Status.Phase)
+	assert.Contains(t, drain.Status.Message, "no StoragePool with enough capacity")
+}
@@ -107,6 +107,7 @@ func (w *DiskDecommController) detachVolumes(ctx context.Context, storagePoolNam
 	if err != nil {
 		return logger.LogNewErrorf(log, "failed to get config. Error: %v", err)
 	}
+	volume.SetOperationTimeouts(ctx, cfg.VolumeOperationTimeout)
 	volManager, err := volume.GetManager(ctx, &vc, nil, false,
 		false, false,
 		clusterFlavor, cfg.Global.SupervisorID, cfg.Global.ClusterDistribution)
@@ -56,6 +56,27 @@ func initMigrationController(vc *cnsvsphere.VirtualCenter, clusterIDs []string)
 	}
 }
 
+// getVolumeManager returns an instance of volume manager for the vCenter
+// used by the migration controller.
+func (m *migrationController) getVolumeManager(ctx context.Context) (volume.Manager, error) {
+	log := logger.GetLogger(ctx)
+	clusterFlavor, err := config.GetClusterFlavor(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get cluster flavor. Error: %v", err)
+	}
+	cfg, err := config.GetConfig(ctx)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to get config. Error: %v", err)
+	}
+	volume.SetOperationTimeouts(ctx, cfg.VolumeOperationTimeout)
+	volManager, err := volume.GetManager(ctx, m.vc, nil, false, false, false,
+		clusterFlavor, cfg.Global.SupervisorID, cfg.Global.ClusterDistribution)
+	if err != nil {
+		return nil, logger.LogNewErrorf(log, "failed to create an instance of volume manager. err=%v", err)
+	}
+	return volManager, nil
+}
+
 func (m *migrationController) relocateCNSVolume(ctx context.Context, volumeID string, targetSPName string) error {
 	log := logger.GetLogger(ctx)
 	k8sClient, err := getK8sClient(ctx)
@@ -85,18 +106,9 @@ func (m *migrationController) relocateCNSVolume(ctx context.Context, volumeID st
 			datastoreURL)
 	}
 
-	clusterFlavor, err := config.GetClusterFlavor(ctx)
-	if err != nil {
-		return logger.LogNewErrorf(log, "failed to get cluster flavor. Error: %v", err)
-	}
-	cfg, err := config.GetConfig(ctx)
+	volManager, err := m.getVolumeManager(ctx)
 	if err != nil {
-		return logger.LogNewErrorf(log, "failed to get config. Error: %v", err)
-	}
-	volManager, err := volume.GetManager(ctx, m.vc, nil, false, false, false,
-		clusterFlavor, cfg.Global.SupervisorID, cfg.Global.ClusterDistribution)
-	if err != nil {
-		return logger.LogNewErrorf(log, "failed to create an instance of volume manager. err=%v", err)
+		return err
 	}
 
 	relocateSpec := cnstypes.NewCnsBlockVolumeRelocateSpec(volumeID, dsInfo.Reference())
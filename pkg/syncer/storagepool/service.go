@@ -143,6 +143,22 @@ func InitStoragePoolService(ctx context.Context,
 		}
 	}()
 
+	if commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx, common.DatastoreDrain) {
+		// Create DatastoreDrain CRD.
+		err = k8s.CreateCustomResourceDefinitionFromManifest(ctx, storagepoolconfig.EmbedDatastoreDrainCRFile,
+			storagepoolconfig.EmbedDatastoreDrainCRFileName)
+		if err != nil {
+			crdKind := reflect.TypeOf(spv1alpha1.DatastoreDrain{}).Name()
+			log.Errorf("Failed to create %q CRD. Err: %+v", crdKind, err)
+			return err
+		}
+		_, err = initDatastoreDrainController(ctx, migrationController)
+		if err != nil {
+			log.Errorf("Failed to initialize datastore drain controller. Err: %+v", err)
+			return err
+		}
+	}
+
 	storagePoolService := new(Service)
 	storagePoolService.spController = spController
 	storagePoolService.scWatchCntlr = scWatchCntlr
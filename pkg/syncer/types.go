@@ -63,6 +63,9 @@ const (
 
 	// default interval for pv to backingdiskobjectid mapping
 	defaultPVtoBackingDiskObjectIdIntervalInMin = 10
+
+	// default interval for file volume usage
+	defaultFileVolumeUsageIntervalInMin = 5
 )
 
 var (
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"google.golang.org/grpc/codes"
@@ -21,6 +23,7 @@ import (
 	jsonpatch "github.com/evanphx/json-patch/v5"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	v1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	apitypes "k8s.io/apimachinery/pkg/types"
 	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
@@ -66,6 +69,9 @@ var getPVsInBoundAvailableOrReleased = func(ctx context.Context,
 		return nil, err
 	}
 	for _, pv := range allPVs {
+		if isNFSSubdirVolume(pv) {
+			continue
+		}
 		if (pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name) ||
 			(metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.CSIMigration) && pv.Spec.VsphereVolume != nil &&
 				isValidvSphereVolume(ctx, pv)) {
@@ -90,7 +96,7 @@ func getBoundPVs(ctx context.Context, metadataSyncer *metadataSyncInformer) ([]*
 		return nil, err
 	}
 	for _, pv := range allPVs {
-		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name {
+		if pv.Spec.CSI != nil && pv.Spec.CSI.Driver == csitypes.Name && !isNFSSubdirVolume(pv) {
 			log.Debugf("getBoundPVs: pv %s with volumeHandle %s is in state %v",
 				pv.Name, pv.Spec.CSI.VolumeHandle, pv.Status.Phase)
 			if pv.Status.Phase == v1.VolumeBound {
@@ -176,6 +182,11 @@ func IsValidVolume(ctx context.Context, volume v1.Volume, pod *v1.Pod,
 				pod.Name, pod.Namespace, pv.Name)
 			return false, nil, nil
 		}
+		if isNFSSubdirVolume(pv) {
+			log.Debugf("Pod %s in namespace %s has a volume %s which is not a CNS volume",
+				pod.Name, pod.Namespace, pv.Name)
+			return false, nil, nil
+		}
 	}
 	return true, pv, pvc
 }
@@ -309,6 +320,12 @@ func isValidvSphereVolume(ctx context.Context, pv *v1.PersistentVolume) bool {
 	return false
 }
 
+// isNFSSubdirVolume checks whether the PV is a file volume provisioned by the
+// nfs-subdir backend, which is not a CNS volume and has no metadata to sync.
+func isNFSSubdirVolume(pv *v1.PersistentVolume) bool {
+	return pv.Spec.CSI != nil && common.IsNFSSubdirVolumeID(pv.Spec.CSI.VolumeHandle)
+}
+
 // IsFileVolume returns true for PVs that have accessMode as RWX or ROM
 // and volumeMode as FileSystem.
 func IsFileVolume(pv *v1.PersistentVolume) bool {
@@ -584,8 +601,9 @@ func getVcHostAndVolumeManagerFromPvNodeAffinity(ctx context.Context, pv *v1.Per
 
 // getPVsInBoundAvailableOrReleasedForVc sends back all K8s volumes in "Bound", "Available"
 // or "Released" states, associated with the given VC.
-// In case of a multi VC setup, it will also filter out all the
-// in-tree PVs as well as file share volumes.
+// In case of a multi VC setup, it fails on in-tree PVs, and on file share
+// volumes unless topology aware file volume support is enabled. So the callers
+// only see file share volumes of a multi VC setup when that support is enabled.
 // For all K8s volumes, the corresponding VC is looked up from the in-memory map.
 // In case this info is not available, it is obtained from PV's nodeAffinity rules.
 func getPVsInBoundAvailableOrReleasedForVc(ctx context.Context, metadataSyncer *metadataSyncInformer,
@@ -652,21 +670,20 @@ func getPVsInBoundAvailableOrReleasedForVc(ctx context.Context, metadataSyncer *
 
 	if len(leftOutPvs) != 0 {
 		for _, volume := range leftOutPvs {
-			if !IsFileVolume(volume) {
-				// Try to locate the VC for all the left out PVs from their nodeAffinity rules.
-				topologySegments := getTopologySegmentsFromNodeAffinityRules(ctx, volume)
-				vCenter, err := getVcHostFromTopologySegments(ctx, topologySegments, volume.Name)
-				if err != nil {
-					log.Debugf("Failed to find which VC volume %+v belongs to from nodeAffinityRules",
-						volume.Spec.CSI.VolumeHandle)
-					continue
-				}
-
-				if vCenter == vc {
-					k8svolumes = append(k8svolumes, volume)
-				}
+			// Try to locate the VC for all the left out PVs from their nodeAffinity rules.
+			// Topology aware file volumes carry the same nodeAffinity rules as block
+			// volumes, so the VC for such file volumes is discovered the same way.
+			topologySegments := getTopologySegmentsFromNodeAffinityRules(ctx, volume)
+			vCenter, err := getVcHostFromTopologySegments(ctx, topologySegments, volume.Name)
+			if err != nil {
+				log.Debugf("Failed to find which VC volume %+v belongs to from nodeAffinityRules",
+					volume.Spec.CSI.VolumeHandle)
+				continue
 			}
 
+			if vCenter == vc {
+				k8svolumes = append(k8svolumes, volume)
+			}
 		}
 	}
 
@@ -970,3 +987,91 @@ func generateVolumeAccessibleTopologyFromPVCAnnotation(claim *v1.PersistentVolum
 	}
 	return volumeAccessibleTopologyArray, nil
 }
+
+// getStoragePolicyParamsFromStorageClass returns the storage policy ID and the
+// storage policy name set in the parameters of a vanilla StorageClass. The
+// parameter keys are case-insensitive.
+func getStoragePolicyParamsFromStorageClass(sc *storagev1.StorageClass) (string, string) {
+	var storagePolicyID, storagePolicyName string
+	for param, value := range sc.Parameters {
+		switch strings.ToLower(param) {
+		case common.AttributeStoragePolicyID:
+			storagePolicyID = value
+		case common.AttributeStoragePolicyName:
+			storagePolicyName = value
+		}
+	}
+	return storagePolicyID, storagePolicyName
+}
+
+var (
+	// storageClassPolicyIDCache holds the storage policy IDs resolved from
+	// the storage policy names of vanilla StorageClasses, keyed by the UID of
+	// the StorageClass. StorageClass parameters are immutable, so an entry
+	// only has to be resolved again if the StorageClass is re-created.
+	storageClassPolicyIDCache     = make(map[apitypes.UID]string)
+	storageClassPolicyIDCacheLock sync.Mutex
+)
+
+// getStoragePolicyIDForStorageClass returns the storage policy ID of the given
+// StorageClass. On vanilla clusters, a StorageClass which only specifies the
+// storage policy name is resolved to the policy ID on vCenter. Policy names are
+// only resolved on single vCenter deployments, as the policy ID of a policy
+// name differs across vCenters. Resolved policy IDs are cached per
+// StorageClass, so vCenter is only queried once per StorageClass.
+func getStoragePolicyIDForStorageClass(ctx context.Context, metadataSyncer *metadataSyncInformer,
+	sc *storagev1.StorageClass) string {
+	log := logger.GetLogger(ctx)
+	if metadataSyncer.clusterFlavor != cnstypes.CnsClusterFlavorVanilla {
+		return sc.Parameters[scParamStoragePolicyID]
+	}
+	storagePolicyID, storagePolicyName := getStoragePolicyParamsFromStorageClass(sc)
+	if storagePolicyID != "" || storagePolicyName == "" {
+		return storagePolicyID
+	}
+	if len(metadataSyncer.configInfo.Cfg.VirtualCenter) != 1 {
+		log.Debugf("Skipping storage policy name lookup for StorageClass %q on multi vCenter deployment", sc.Name)
+		return ""
+	}
+	storageClassPolicyIDCacheLock.Lock()
+	defer storageClassPolicyIDCacheLock.Unlock()
+	if cachedPolicyID, ok := storageClassPolicyIDCache[sc.UID]; ok {
+		return cachedPolicyID
+	}
+	for vcHost := range metadataSyncer.configInfo.Cfg.VirtualCenter {
+		vc, err := cnsvsphere.GetVirtualCenterInstanceForVCenterHost(ctx, vcHost, true)
+		if err != nil {
+			log.Errorf("failed to get vCenter instance for host %q. Err: %v", vcHost, err)
+			return ""
+		}
+		storagePolicyID, err = vc.GetStoragePolicyIDByName(ctx, storagePolicyName)
+		if err != nil {
+			log.Errorf("failed to get storage policy ID for storage policy %q of StorageClass %q. Err: %v",
+				storagePolicyName, sc.Name, err)
+			return ""
+		}
+	}
+	storageClassPolicyIDCache[sc.UID] = storagePolicyID
+	return storagePolicyID
+}
+
+// isSnapshotQuotaEnabled returns true if the capacity used by snapshots is
+// tracked in StoragePolicyUsage CRs of resource kind VolumeSnapshot.
+func isSnapshotQuotaEnabled(ctx context.Context, metadataSyncer *metadataSyncInformer) bool {
+	if metadataSyncer.clusterFlavor == cnstypes.CnsClusterFlavorVanilla {
+		return IsVanillaStoragePolicyQuotaFSSEnabled
+	}
+	return metadataSyncer.coCommonInterface.IsFSSEnabled(ctx, common.StorageQuotaM2)
+}
+
+// isStoragePolicyQuotaEnabled returns true if StoragePolicyUsage CRs are
+// maintained by the syncer for StoragePolicyQuota CRs in the cluster.
+func isStoragePolicyQuotaEnabled(metadataSyncer *metadataSyncInformer) bool {
+	switch metadataSyncer.clusterFlavor {
+	case cnstypes.CnsClusterFlavorWorkload:
+		return IsPodVMOnStretchSupervisorFSSEnabled
+	case cnstypes.CnsClusterFlavorVanilla:
+		return IsVanillaStoragePolicyQuotaFSSEnabled
+	}
+	return false
+}
@@ -10,7 +10,9 @@ import (
 	"github.com/stretchr/testify/assert"
 	cnstypes "github.com/vmware/govmomi/cns/types"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	cnsconfig "sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/config"
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/syncer/k8scloudoperator"
 )
@@ -476,3 +478,84 @@ func TestHasClusterDistributionSet(t *testing.T) {
 		})
 	}
 }
+
+func TestGetStoragePolicyIDForStorageClass(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name             string
+		clusterFlavor    cnstypes.CnsClusterFlavor
+		parameters       map[string]string
+		expectedPolicyID string
+	}{
+		{
+			name:             "WorkloadStorageClass",
+			clusterFlavor:    cnstypes.CnsClusterFlavorWorkload,
+			parameters:       map[string]string{"storagePolicyID": "policy-1"},
+			expectedPolicyID: "policy-1",
+		},
+		{
+			name:             "VanillaStorageClassWithPolicyID",
+			clusterFlavor:    cnstypes.CnsClusterFlavorVanilla,
+			parameters:       map[string]string{"StoragePolicyId": "policy-2", "fstype": "ext4"},
+			expectedPolicyID: "policy-2",
+		},
+		{
+			name:             "VanillaStorageClassWithoutPolicy",
+			clusterFlavor:    cnstypes.CnsClusterFlavorVanilla,
+			parameters:       map[string]string{"datastoreurl": "ds:///vmfs/volumes/vsan:1/"},
+			expectedPolicyID: "",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			metadataSyncer := &metadataSyncInformer{clusterFlavor: test.clusterFlavor}
+			sc := &storagev1.StorageClass{
+				ObjectMeta: metav1.ObjectMeta{Name: "test-sc"},
+				Parameters: test.parameters,
+			}
+			assert.Equal(t, test.expectedPolicyID, getStoragePolicyIDForStorageClass(ctx, metadataSyncer, sc))
+		})
+	}
+}
+
+func TestGetStoragePolicyIDForStorageClassCachesPolicyName(t *testing.T) {
+	ctx := context.Background()
+	metadataSyncer := &metadataSyncInformer{
+		clusterFlavor: cnstypes.CnsClusterFlavorVanilla,
+		configInfo: &cnsconfig.ConfigurationInfo{
+			Cfg: &cnsconfig.Config{
+				VirtualCenter: map[string]*cnsconfig.VirtualCenterConfig{"vc-1": {}},
+			},
+		},
+	}
+	sc := &storagev1.StorageClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-sc", UID: "test-sc-uid"},
+		Parameters: map[string]string{"storagepolicyname": "gold"},
+	}
+	storageClassPolicyIDCacheLock.Lock()
+	storageClassPolicyIDCache[sc.UID] = "policy-gold"
+	storageClassPolicyIDCacheLock.Unlock()
+	defer func() {
+		storageClassPolicyIDCacheLock.Lock()
+		delete(storageClassPolicyIDCache, sc.UID)
+		storageClassPolicyIDCacheLock.Unlock()
+	}()
+	// The cached policy ID is returned without looking up the policy name on vCenter.
+	assert.Equal(t, "policy-gold", getStoragePolicyIDForStorageClass(ctx, metadataSyncer, sc))
+}
+
+func TestIsStoragePolicyQuotaEnabled(t *testing.T) {
+	origStretchSupervisor := IsPodVMOnStretchSupervisorFSSEnabled
+	origVanillaQuota := IsVanillaStoragePolicyQuotaFSSEnabled
+	defer func() {
+		IsPodVMOnStretchSupervisorFSSEnabled = origStretchSupervisor
+		IsVanillaStoragePolicyQuotaFSSEnabled = origVanillaQuota
+	}()
+	IsPodVMOnStretchSupervisorFSSEnabled = false
+	IsVanillaStoragePolicyQuotaFSSEnabled = true
+	assert.True(t, isStoragePolicyQuotaEnabled(&metadataSyncInformer{clusterFlavor: cnstypes.CnsClusterFlavorVanilla}))
+	assert.True(t, isSnapshotQuotaEnabled(context.Background(),
+		&metadataSyncInformer{clusterFlavor: cnstypes.CnsClusterFlavorVanilla}))
+	assert.False(t, isStoragePolicyQuotaEnabled(&metadataSyncInformer{clusterFlavor: cnstypes.CnsClusterFlavorWorkload}))
+	assert.False(t, isStoragePolicyQuotaEnabled(&metadataSyncInformer{clusterFlavor: cnstypes.CnsClusterFlavorGuest}))
+}
@@ -18,6 +18,8 @@ package syncer
 
 import (
 	"context"
+	"encoding/json"
+	"strings"
 	"time"
 
 	cnstypes "github.com/vmware/govmomi/cns/types"
@@ -30,18 +32,51 @@ import (
 
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common/commonco"
 	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/logger"
+	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 )
 
+const (
+	// maxVolumeHealthHistoryEntries is the number of health transitions kept
+	// on the CNSVolumeInfo CR of a volume.
+	maxVolumeHealthHistoryEntries = 10
+	// volumeHealthReasonNotFoundInCNS is the health transition reason of
+	// volumes whose PVC exists but which are not returned by CNS.
+	volumeHealthReasonNotFoundInCNS = "VolumeNotFoundInCNS"
+)
+
+// volumeHealthObservation is the health status of a volume observed by a
+// csiGetVolumeHealthStatus run.
+type volumeHealthObservation struct {
+	status     string
+	observedAt time.Time
+}
+
+// lastVolumeHealthObservations maps volume ID to the health status observed by
+// the previous csiGetVolumeHealthStatus run. It is only accessed from the
+// volume health ticker goroutine.
+var lastVolumeHealthObservations = make(map[string]volumeHealthObservation)
+
 func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface,
 	metadataSyncer *metadataSyncInformer) {
 	log := logger.GetLogger(ctx)
 	log.Infof("csiGetVolumeHealthStatus: start")
+	isVolumeHealthHistoryEnabled := commonco.ContainerOrchestratorUtility.IsFSSEnabled(ctx,
+		common.VolumeHealthHistory)
 	querySelection := cnstypes.CnsQuerySelection{
 		Names: []string{
 			string(cnstypes.QuerySelectionNameTypeHealthStatus),
+			string(cnstypes.QuerySelectionNameTypeDataStoreUrl),
 		},
 	}
+	if isVolumeHealthHistoryEnabled {
+		// The datastore accessibility and compliance status of a volume are
+		// recorded as the reason of its health transitions.
+		querySelection.Names = append(querySelection.Names,
+			string(cnstypes.QuerySelectionNameTypeDataStoreAccessibility),
+			string(cnstypes.QuerySelectionNameTypeComplianceStatus))
+	}
 	queryAllResult, err := utils.QueryAllVolumesForCluster(ctx, metadataSyncer.volumeManager,
 		clusterIDforVolumeMetadata, querySelection)
 	if err != nil {
@@ -77,16 +112,26 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 
 	// volumeIdToHealthStatusMap maps vol.VolumeId.Id to vol.HealthStatus.
 	volumeIdToHealthStatusMap := make(volumeIdHealthStatusMap, len(queryAllResult.Volumes))
+	// volumeIdToDatastoreMap maps vol.VolumeId.Id to vol.DatastoreUrl.
+	volumeIdToDatastoreMap := make(map[string]string, len(queryAllResult.Volumes))
+	// volumeIdToHealthReasonMap maps vol.VolumeId.Id to the reason of its health status.
+	volumeIdToHealthReasonMap := make(map[string]string, len(queryAllResult.Volumes))
 
 	for _, vol := range queryAllResult.Volumes {
 		volumeIdToHealthStatusMap[vol.VolumeId.Id] = vol.HealthStatus
+		volumeIdToDatastoreMap[vol.VolumeId.Id] = vol.DatastoreUrl
+		volumeIdToHealthReasonMap[vol.VolumeId.Id] = getVolumeHealthReason(vol)
 	}
 
+	observations := make(map[string]volumeHealthObservation, len(volumeHandleToPvcMap))
+	now := time.Now()
 	accessibleVolumeCount := 0
 	inaccessibleVolumeCount := 0
 	for volID, pvc := range volumeHandleToPvcMap {
 		var volHealthStatusAnn string
+		volHealthReason := volumeHealthReasonNotFoundInCNS
 		if volHealthStatus, ok := volumeIdToHealthStatusMap[volID]; ok {
+			volHealthReason = volumeIdToHealthReasonMap[volID]
 			// Only update PVC health annotation if the HealthStatus of volume is
 			// not "unknown".
 			if volHealthStatus != string(pbmtypes.PbmHealthStatusForEntityUnknown) {
@@ -117,8 +162,16 @@ func csiGetVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 			accessibleVolumeCount += 1
 		case common.VolHealthStatusInaccessible:
 			inaccessibleVolumeCount += 1
+		default:
+			continue
+		}
+		if isVolumeHealthHistoryEnabled {
+			recordVolumeHealth(ctx, volID, pvc.Namespace, volumeIdToDatastoreMap[volID], metadataSyncer.host,
+				volHealthStatusAnn, volHealthReason, now, observations)
 		}
 	}
+	// Volumes which are no longer bound to a PVC are dropped from the observations.
+	lastVolumeHealthObservations = observations
 	prometheus.VolumeHealthGaugeVec.WithLabelValues(
 		prometheus.PrometheusAccessibleVolumes).Set(float64(accessibleVolumeCount))
 	prometheus.VolumeHealthGaugeVec.WithLabelValues(
@@ -175,3 +228,120 @@ func updateVolumeHealthStatus(ctx context.Context, k8sclient clientset.Interface
 		}
 	}
 }
+
+// recordVolumeHealth updates the inaccessibility metrics of a volume and, when
+// its health status changed since the previous observation, records the
+// transition in the health history of its CNSVolumeInfo CR.
+func recordVolumeHealth(ctx context.Context, volID, namespace, datastoreURL, vCenter, status, reason string,
+	now time.Time, observations map[string]volumeHealthObservation) {
+	log := logger.GetLogger(ctx)
+	last, observed := lastVolumeHealthObservations[volID]
+	observations[volID] = volumeHealthObservation{status: status, observedAt: now}
+	if observed && last.status == common.VolHealthStatusInaccessible {
+		prometheus.VolumeInaccessibleSecondsCounterVec.WithLabelValues(namespace, datastoreURL).Add(
+			now.Sub(last.observedAt).Seconds())
+	}
+	if observed && last.status == status {
+		return
+	}
+	// The previous status is unknown after a syncer restart, so the CR
+	// history decides whether the status actually changed.
+	if updateVolumeHealthHistory(ctx, volID, vCenter, status, reason, now) {
+		log.Infof("recordVolumeHealth: volume %q in namespace %q transitioned to health status %q. Reason: %q",
+			volID, namespace, status, reason)
+		prometheus.VolumeHealthLastTransitionGaugeVec.WithLabelValues(namespace, datastoreURL, status).Set(
+			float64(now.Unix()))
+	}
+}
+
+// updateVolumeHealthHistory appends a health transition to the CNSVolumeInfo CR
+// of the volume unless it already ends with the given status. The CR is created
+// if no other feature maintains CNSVolumeInfo CRs. Returns true if the status
+// differs from the last recorded transition.
+func updateVolumeHealthHistory(ctx context.Context, volID, vCenter, status, reason string, now time.Time) bool {
+	log := logger.GetLogger(ctx)
+	crExists, err := volumeInfoService.VolumeInfoCrExistsForVolume(ctx, volID)
+	if err != nil {
+		log.Errorf("updateVolumeHealthHistory: failed to find CNSVolumeInfo of volume %q. Err: %v", volID, err)
+		return true
+	}
+	if !crExists {
+		if IsPodVMOnStretchSupervisorFSSEnabled {
+			// Full sync creates the CR along with the storage policy details.
+			log.Debugf("updateVolumeHealthHistory: CNSVolumeInfo of volume %q is not created yet", volID)
+			return true
+		}
+		// The CR is deleted along with the PV of the volume.
+		err = volumeInfoService.CreateVolumeInfo(ctx, volID, vCenter)
+		if err != nil {
+			log.Errorf("updateVolumeHealthHistory: failed to create CNSVolumeInfo of volume %q. Err: %v",
+				volID, err)
+			return true
+		}
+	}
+	volumeInfo, err := volumeInfoService.GetVolumeInfoForVolumeID(ctx, volID)
+	if err != nil {
+		log.Errorf("updateVolumeHealthHistory: failed to get CNSVolumeInfo of volume %q. Err: %v", volID, err)
+		return true
+	}
+	history, changed := appendVolumeHealthTransition(volumeInfo.Spec.HealthHistory,
+		cnsvolumeinfov1alpha1.VolumeHealthTransition{
+			Status:         status,
+			Reason:         reason,
+			TransitionTime: metav1.NewTime(now),
+		})
+	if !changed {
+		return false
+	}
+	patch := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"healthHistory": history,
+		},
+	}
+	patchBytes, err := json.Marshal(patch)
+	if err != nil {
+		log.Errorf("updateVolumeHealthHistory: failed to create patch for CNSVolumeInfo of volume %q. Err: %v",
+			volID, err)
+		return true
+	}
+	err = volumeInfoService.PatchVolumeInfo(ctx, volID, patchBytes, allowedRetriesToPatchCNSVolumeInfo)
+	if err != nil {
+		log.Errorf("updateVolumeHealthHistory: failed to update health history of volume %q. Err: %v",
+			volID, err)
+	}
+	return true
+}
+
+// appendVolumeHealthTransition appends transition to history unless the last
+// entry of history has the same status, keeping at most
+// maxVolumeHealthHistoryEntries entries. Returns the new history and whether
+// it was changed.
+func appendVolumeHealthTransition(history []cnsvolumeinfov1alpha1.VolumeHealthTransition,
+	transition cnsvolumeinfov1alpha1.VolumeHealthTransition) ([]cnsvolumeinfov1alpha1.VolumeHealthTransition, bool) {
+	if len(history) > 0 && history[len(history)-1].Status == transition.Status {
+		return history, false
+	}
+	history = append(history, transition)
+	if len(history) > maxVolumeHealthHistoryEntries {
+		history = history[len(history)-maxVolumeHealthHistoryEntries:]
+	}
+	return history, true
+}
+
+// getVolumeHealthReason returns the reason of the health status CNS reports for
+// vol: its datastore accessibility and compliance status when either is
+// degraded, its health status otherwise.
+func getVolumeHealthReason(vol cnstypes.CnsVolume) string {
+	var faults []string
+	if vol.DatastoreAccessibilityStatus == string(pbmtypes.PbmHealthStatusForEntityRed) ||
+		vol.DatastoreAccessibilityStatus == string(pbmtypes.PbmHealthStatusForEntityYellow) {
+		faults = append(faults, "datastoreAccessibilityStatus: "+vol.DatastoreAccessibilityStatus)
+	}
+	if vol.ComplianceStatus == string(pbmtypes.PbmComplianceStatusNonCompliant) {
+		faults = append(faults, "complianceStatus: "+vol.ComplianceStatus)
+	}
+	if len(faults) == 0 {
+		return "healthStatus: " + vol.HealthStatus
+	}
+	return strings.Join(faults, ", ")
+}
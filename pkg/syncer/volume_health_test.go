@@ -18,14 +18,24 @@ package syncer
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"testing"
 	"time"
 
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
+	cnstypes "github.com/vmware/govmomi/cns/types"
+	pbmtypes "github.com/vmware/govmomi/pbm/types"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	testclient "k8s.io/client-go/kubernetes/fake"
+
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/common/prometheus"
+	"sigs.k8s.io/vsphere-csi-driver/v3/pkg/csi/service/common"
+	cnsvolumeinfov1alpha1 "sigs.k8s.io/vsphere-csi-driver/v3/pkg/internalapis/cnsvolumeinfo/v1alpha1"
 )
 
 func TestUpdateVolumeHealthStatus(t *testing.T) {
@@ -231,3 +241,157 @@ func TestVolumeHealthStatusUpdate_EmptyStatus(t *testing.T) {
 	// Verify timestamp annotation is still set
 	assert.NotEmpty(t, updatedPVC.Annotations[annVolumeHealthTS])
 }
+
+func TestAppendVolumeHealthTransition(t *testing.T) {
+	now := time.Now()
+	var history []cnsvolumeinfov1alpha1.VolumeHealthTransition
+	history, changed := appendVolumeHealthTransition(history, cnsvolumeinfov1alpha1.VolumeHealthTransition{
+		Status: common.VolHealthStatusAccessible, TransitionTime: metav1.NewTime(now)})
+	assert.True(t, changed)
+	assert.Len(t, history, 1)
+
+	// Same status is not recorded again.
+	history, changed = appendVolumeHealthTransition(history, cnsvolumeinfov1alpha1.VolumeHealthTransition{
+		Status: common.VolHealthStatusAccessible, TransitionTime: metav1.NewTime(now.Add(time.Minute))})
+	assert.False(t, changed)
+	assert.Len(t, history, 1)
+
+	// History is bounded to the most recent transitions.
+	statuses := []string{common.VolHealthStatusInaccessible, common.VolHealthStatusAccessible}
+	for i := 0; i < 2*maxVolumeHealthHistoryEntries; i++ {
+		history, changed = appendVolumeHealthTransition(history, cnsvolumeinfov1alpha1.VolumeHealthTransition{
+			Status:         statuses[i%2],
+			Reason:         "red",
+			TransitionTime: metav1.NewTime(now.Add(time.Duration(i+2) * time.Minute)),
+		})
+		assert.True(t, changed)
+	}
+	assert.Len(t, history, maxVolumeHealthHistoryEntries)
+	assert.Equal(t, common.VolHealthStatusAccessible, history[len(history)-1].Status)
+	assert.True(t, history[0].TransitionTime.Before(&history[len(history)-1].TransitionTime))
+}
+
+// fakeVolumeInfoService keeps CNSVolumeInfo CRs in memory.
+type fakeVolumeInfoService struct {
+	volumeInfos map[string]*cnsvolumeinfov1alpha1.CNSVolumeInfo
+}
+
+func (f *fakeVolumeInfoService) GetvCenterForVolumeID(ctx context.Context, volumeID string) (string, error) {
+	volumeInfo, err := f.GetVolumeInfoForVolumeID(ctx, volumeID)
+	if err != nil {
+		return "", err
+	}
+	return volumeInfo.Spec.VCenterServer, nil
+}
+
+func (f *fakeVolumeInfoService) CreateVolumeInfo(ctx context.Context, volumeID string, vCenter string) error {
+	f.volumeInfos[volumeID] = &cnsvolumeinfov1alpha1.CNSVolumeInfo{
+		Spec: cnsvolumeinfov1alpha1.CNSVolumeInfoSpec{VolumeID: volumeID, VCenterServer: vCenter},
+	}
+	return nil
+}
+
+func (f *fakeVolumeInfoService) CreateVolumeInfoWithPolicyInfo(ctx context.Context, volumeID, pvcnamespace,
+	storagePolicyId, storageClassName, vCenter string, capacity *resource.Quantity, isLinkedClone bool) error {
+	return f.CreateVolumeInfo(ctx, volumeID, vCenter)
+}
+
+func (f *fakeVolumeInfoService) DeleteVolumeInfo(ctx context.Context, volumeID string) error {
+	delete(f.volumeInfos, volumeID)
+	return nil
+}
+
+func (f *fakeVolumeInfoService) ListAllVolumeInfos() []interface{} {
+	return nil
+}
+
+func (f *fakeVolumeInfoService) VolumeInfoCrExistsForVolume(ctx context.Context, volumeID string) (bool, error) {
+	_, ok := f.volumeInfos[volumeID]
+	return ok, nil
+}
+
+func (f *fakeVolumeInfoService) GetVolumeInfoForVolumeID(ctx context.Context,
+	volumeID string) (*cnsvolumeinfov1alpha1.CNSVolumeInfo, error) {
+	volumeInfo, ok := f.volumeInfos[volumeID]
+	if !ok {
+		return nil, fmt.Errorf("CNSVolumeInfo of volume %q not found", volumeID)
+	}
+	return volumeInfo.DeepCopy(), nil
+}
+
+func (f *fakeVolumeInfoService) PatchVolumeInfo(ctx context.Context, volumeID string, patchBytes []byte,
+	retries int) error {
+	volumeInfo, ok := f.volumeInfos[volumeID]
+	if !ok {
+		return fmt.Errorf("CNSVolumeInfo of volume %q not found", volumeID)
+	}
+	return json.Unmarshal(patchBytes, volumeInfo)
+}
+
+func TestRecordVolumeHealth(t *testing.T) {
+	ctx := context.Background()
+	origVolumeInfoService := volumeInfoService
+	origObservations := lastVolumeHealthObservations
+	origPodVMOnStretchSupervisorFSSEnabled := IsPodVMOnStretchSupervisorFSSEnabled
+	fakeService := &fakeVolumeInfoService{volumeInfos: map[string]*cnsvolumeinfov1alpha1.CNSVolumeInfo{}}
+	volumeInfoService = fakeService
+	IsPodVMOnStretchSupervisorFSSEnabled = false
+	defer func() {
+		volumeInfoService = origVolumeInfoService
+		lastVolumeHealthObservations = origObservations
+		IsPodVMOnStretchSupervisorFSSEnabled = origPodVMOnStretchSupervisorFSSEnabled
+	}()
+
+	namespace, datastore, vCenter := "test-health-ns", "ds:///vmfs/volumes/test-health/", "vc-1"
+	start := time.Now()
+	lastVolumeHealthObservations = map[string]volumeHealthObservation{}
+
+	// First observation of an inaccessible volume records a transition, on a
+	// CNSVolumeInfo CR created for it.
+	observations := map[string]volumeHealthObservation{}
+	recordVolumeHealth(ctx, "vol-1", namespace, datastore, vCenter, common.VolHealthStatusInaccessible,
+		volumeHealthReasonNotFoundInCNS, start, observations)
+	lastVolumeHealthObservations = observations
+	assert.Equal(t, float64(start.Unix()), testutil.ToFloat64(
+		prometheus.VolumeHealthLastTransitionGaugeVec.WithLabelValues(namespace, datastore,
+			common.VolHealthStatusInaccessible)))
+	assert.Equal(t, float64(0), testutil.ToFloat64(
+		prometheus.VolumeInaccessibleSecondsCounterVec.WithLabelValues(namespace, datastore)))
+	volumeInfo := fakeService.volumeInfos["vol-1"]
+	assert.NotNil(t, volumeInfo)
+	assert.Equal(t, vCenter, volumeInfo.Spec.VCenterServer)
+	assert.Len(t, volumeInfo.Spec.HealthHistory, 1)
+	assert.Equal(t, volumeHealthReasonNotFoundInCNS, volumeInfo.Spec.HealthHistory[0].Reason)
+
+	// The volume stays inaccessible for 5 minutes and then becomes accessible.
+	observations = map[string]volumeHealthObservation{}
+	recordVolumeHealth(ctx, "vol-1", namespace, datastore, vCenter, common.VolHealthStatusInaccessible,
+		"healthStatus: red", start.Add(5*time.Minute), observations)
+	lastVolumeHealthObservations = observations
+	observations = map[string]volumeHealthObservation{}
+	recordVolumeHealth(ctx, "vol-1", namespace, datastore, vCenter, common.VolHealthStatusAccessible,
+		"healthStatus: green", start.Add(10*time.Minute), observations)
+	assert.Equal(t, float64(600), testutil.ToFloat64(
+		prometheus.VolumeInaccessibleSecondsCounterVec.WithLabelValues(namespace, datastore)))
+	assert.Equal(t, float64(start.Add(10*time.Minute).Unix()), testutil.ToFloat64(
+		prometheus.VolumeHealthLastTransitionGaugeVec.WithLabelValues(namespace, datastore,
+			common.VolHealthStatusAccessible)))
+	history := fakeService.volumeInfos["vol-1"].Spec.HealthHistory
+	assert.Len(t, history, 2)
+	assert.Equal(t, common.VolHealthStatusAccessible, history[1].Status)
+	assert.Equal(t, "healthStatus: green", history[1].Reason)
+}
+
+func TestGetVolumeHealthReason(t *testing.T) {
+	assert.Equal(t, "healthStatus: green", getVolumeHealthReason(cnstypes.CnsVolume{
+		HealthStatus:                 string(pbmtypes.PbmHealthStatusForEntityGreen),
+		DatastoreAccessibilityStatus: string(pbmtypes.PbmHealthStatusForEntityGreen),
+		ComplianceStatus:             string(pbmtypes.PbmComplianceStatusCompliant),
+	}))
+	assert.Equal(t, "datastoreAccessibilityStatus: red, complianceStatus: nonCompliant",
+		getVolumeHealthReason(cnstypes.CnsVolume{
+			HealthStatus:                 string(pbmtypes.PbmHealthStatusForEntityRed),
+			DatastoreAccessibilityStatus: string(pbmtypes.PbmHealthStatusForEntityRed),
+			ComplianceStatus:             string(pbmtypes.PbmComplianceStatusNonCompliant),
+		}))
+}